@@ -0,0 +1,157 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// GroupCount will count the rows of the resource's table matching the where
+// clause with parameters, grouped by the groupColumn, and return the counts
+// keyed by the group values.  The groupColumn must be a column of the
+// resource.  Rows with a NULL group value are counted under the zero value of
+// K.  Supports the WithDebug, WithDeleted and WithTable options.
+func GroupCount[K comparable](ctx context.Context, rw *RW, resource interface{}, groupColumn string, where string, args []interface{}, opt ...Option) (map[K]int64, error) {
+	const op = "dbw.GroupCount"
+	switch {
+	case rw == nil || rw.underlying == nil:
+		return nil, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case isNil(resource):
+		return nil, fmt.Errorf("%s: missing resource: %w", op, ErrInvalidParameter)
+	case groupColumn == "":
+		return nil, fmt.Errorf("%s: missing group column: %w", op, ErrInvalidParameter)
+	case where == "" && len(args) > 0:
+		return nil, fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	}
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	opts, err := rw.resolveTable(ctx, resource, rw.getOpts(opt...))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	groupField := s.LookUpField(groupColumn)
+	if groupField == nil || groupField.DBName != groupColumn {
+		return nil, fmt.Errorf("%s: unknown group column %s: %w", op, groupColumn, ErrInvalidParameter)
+	}
+
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).
+		Table(tableName).
+		Select(groupColumn + ", count(*)").
+		Group(groupColumn)
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	if query, err = rw.softDeleteScope(query, resource, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	counts := map[K]int64{}
+	for rows.Next() {
+		// scanning into a pointer handles NULL group values
+		var key *K
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("%s: unable to scan group count: %w", op, err)
+		}
+		var k K
+		if key != nil {
+			k = *key
+		}
+		counts[k] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return counts, nil
+}
+
+// DistinctValues will read the distinct values of a column of the resource's
+// table for the rows matching the where clause with parameters, ordered by the
+// column, into dst which must be a pointer to a slice of the column's type (ex:
+// *[]string).  The column must be a column of the resource.  A nullable column
+// must be read into a slice of pointers or sql.Null types (ex: *[]*string).
+// Supports the WithDebug, WithDeleted and WithTable options.
+func (rw *RW) DistinctValues(ctx context.Context, resource interface{}, column string, where string, args []interface{}, dst interface{}, opt ...Option) error {
+	const op = "dbw.DistinctValues"
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case isNil(resource):
+		return fmt.Errorf("%s: missing resource: %w", op, ErrInvalidParameter)
+	case column == "":
+		return fmt.Errorf("%s: missing column: %w", op, ErrInvalidParameter)
+	case where == "" && len(args) > 0:
+		return fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	case isNil(dst):
+		return fmt.Errorf("%s: missing destination: %w", op, ErrInvalidParameter)
+	}
+	if t := reflect.TypeOf(dst); t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%s: destination must be a pointer to a slice: %w", op, ErrInvalidParameter)
+	}
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	opts, err := rw.resolveTable(ctx, resource, rw.getOpts(opt...))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	field := s.LookUpField(column)
+	if field == nil || field.DBName != column {
+		return fmt.Errorf("%s: unknown column %s: %w", op, column, ErrInvalidParameter)
+	}
+
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).
+		Table(tableName).
+		Distinct(column).
+		Order(column)
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	if query, err = rw.softDeleteScope(query, resource, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	values := reflect.MakeSlice(reflect.TypeOf(dst).Elem(), 0, 0)
+	for rows.Next() {
+		// scanning into a pointer to the element handles NULLs when the
+		// elements are pointers
+		v := reflect.New(values.Type().Elem())
+		if err := rows.Scan(v.Interface()); err != nil {
+			return fmt.Errorf("%s: unable to scan distinct value: %w", op, err)
+		}
+		values = reflect.Append(values, v.Elem())
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	reflect.ValueOf(dst).Elem().Set(values)
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupCount(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+
+	testCars := []struct {
+		model string
+		mpg   int32
+	}{
+		{"sedan", 30},
+		{"sedan", 30},
+		{"sedan", 25},
+		{"coupe", 25},
+		{"coupe", 20},
+		{"", 20}, // a NULL model
+	}
+	for _, tc := range testCars {
+		c, err := dbtest.NewTestCar()
+		require.NoError(t, err)
+		c.Model = tc.model
+		c.Mpg = tc.mpg
+		require.NoError(t, testRw.Create(testCtx, c))
+	}
+
+	t.Run("group-by-model", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counts, err := dbw.GroupCount[string](testCtx, testRw, &dbtest.TestCar{}, "model", "", nil)
+		require.NoError(err)
+		assert.Equal(map[string]int64{"sedan": 3, "coupe": 2, "": 1}, counts)
+	})
+	t.Run("group-by-mpg", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counts, err := dbw.GroupCount[int32](testCtx, testRw, &dbtest.TestCar{}, "mpg", "", nil)
+		require.NoError(err)
+		assert.Equal(map[int32]int64{30: 2, 25: 2, 20: 2}, counts)
+	})
+	t.Run("with-where", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counts, err := dbw.GroupCount[string](testCtx, testRw, &dbtest.TestCar{}, "model", "mpg >= ?", []interface{}{25}, dbw.WithDebug(true))
+		require.NoError(err)
+		assert.Equal(map[string]int64{"sedan": 3, "coupe": 1}, counts)
+	})
+	t.Run("no-rows", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counts, err := dbw.GroupCount[string](testCtx, testRw, &dbtest.TestCar{}, "model", "mpg > ?", []interface{}{100})
+		require.NoError(err)
+		assert.Empty(counts)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			resource        interface{}
+			groupColumn     string
+			where           string
+			args            []interface{}
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, &dbtest.TestCar{}, "model", "", nil, "missing underlying db"},
+			{"missing-resource", testRw, nil, "model", "", nil, "missing resource"},
+			{"missing-group-column", testRw, &dbtest.TestCar{}, "", "", nil, "missing group column"},
+			{"args-without-where", testRw, &dbtest.TestCar{}, "model", "", []interface{}{1}, "args provided with empty where"},
+			{"unknown-group-column", testRw, &dbtest.TestCar{}, "model; drop table db_test_car", "", nil, "unknown group column"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				counts, err := dbw.GroupCount[string](testCtx, tt.rw, tt.resource, tt.groupColumn, tt.where, tt.args)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Nil(counts)
+			})
+		}
+	})
+}
+
+func TestRW_DistinctValues(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+
+	for _, tc := range []struct {
+		model string
+		mpg   int32
+	}{
+		{"sedan", 30},
+		{"coupe", 30},
+		{"sedan", 25},
+		{"", 20}, // a NULL model
+	} {
+		c, err := dbtest.NewTestCar()
+		require.NoError(t, err)
+		c.Model = tc.model
+		c.Mpg = tc.mpg
+		require.NoError(t, testRw.Create(testCtx, c))
+	}
+
+	t.Run("strings", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var models []string
+		err := testRw.DistinctValues(testCtx, &dbtest.TestCar{}, "model", "model is not null", nil, &models)
+		require.NoError(err)
+		assert.Equal([]string{"coupe", "sedan"}, models)
+	})
+	t.Run("ints", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var mpgs []int32
+		err := testRw.DistinctValues(testCtx, &dbtest.TestCar{}, "mpg", "", nil, &mpgs, dbw.WithDebug(true))
+		require.NoError(err)
+		assert.Equal([]int32{20, 25, 30}, mpgs)
+	})
+	t.Run("nullable", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var models []*string
+		err := testRw.DistinctValues(testCtx, &dbtest.TestCar{}, "model", "mpg < ?", []interface{}{30}, &models)
+		require.NoError(err)
+		require.Len(models, 2)
+		var nulls int
+		for _, m := range models {
+			if m == nil {
+				nulls++
+				continue
+			}
+			assert.Equal("sedan", *m)
+		}
+		assert.Equal(1, nulls)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		var models []string
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			resource        interface{}
+			column          string
+			where           string
+			args            []interface{}
+			dst             interface{}
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, &dbtest.TestCar{}, "model", "", nil, &models, "missing underlying db"},
+			{"missing-resource", testRw, nil, "model", "", nil, &models, "missing resource"},
+			{"missing-column", testRw, &dbtest.TestCar{}, "", "", nil, &models, "missing column"},
+			{"args-without-where", testRw, &dbtest.TestCar{}, "model", "", []interface{}{1}, &models, "args provided with empty where"},
+			{"missing-destination", testRw, &dbtest.TestCar{}, "model", "", nil, nil, "missing destination"},
+			{"destination-not-a-slice", testRw, &dbtest.TestCar{}, "model", "", nil, []string{}, "destination must be a pointer to a slice"},
+			{"unknown-column", testRw, &dbtest.TestCar{}, "model; drop table db_test_car", "", nil, &models, "unknown column"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := tt.rw.DistinctValues(testCtx, tt.resource, tt.column, tt.where, tt.args, tt.dst)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
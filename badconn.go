@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"database/sql"
+)
+
+// badConnRetryPool is a gorm connection pool which retries a statement once
+// when it fails with a bad connection error (see: IsBadConnError and
+// WithAutoInvalidateBadConns).  The failed connection is discarded by the
+// database/sql pool (via driver.ErrBadConn or driver.Validator), so the retry
+// is executed with a different connection.  Beginning a transaction is
+// retried, but the statements of a transaction use its connection (a *sql.Tx)
+// and are never retried.
+type badConnRetryPool struct {
+	*sql.DB
+}
+
+// retryBadConn returns true if a statement which failed with the error should
+// be retried.
+func retryBadConn(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && IsBadConnError(err)
+}
+
+// ExecContext executes the statement and retries it once on a bad connection.
+func (p *badConnRetryPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := p.DB.ExecContext(ctx, query, args...)
+	if retryBadConn(ctx, err) {
+		return p.DB.ExecContext(ctx, query, args...)
+	}
+	return result, err
+}
+
+// QueryContext executes the query and retries it once on a bad connection.
+func (p *badConnRetryPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if retryBadConn(ctx, err) {
+		return p.DB.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// QueryRowContext executes the query and retries it once on a bad connection.
+func (p *badConnRetryPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := p.DB.QueryRowContext(ctx, query, args...)
+	if retryBadConn(ctx, row.Err()) {
+		return p.DB.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// PrepareContext prepares the statement and retries it once on a bad
+// connection.
+func (p *badConnRetryPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := p.DB.PrepareContext(ctx, query)
+	if retryBadConn(ctx, err) {
+		return p.DB.PrepareContext(ctx, query)
+	}
+	return stmt, err
+}
+
+// BeginTx begins a transaction and retries it once on a bad connection.
+func (p *badConnRetryPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if retryBadConn(ctx, err) {
+		return p.DB.BeginTx(ctx, opts)
+	}
+	return tx, err
+}
+
+// GetDBConn returns the pool's *sql.DB, which allows gorm's DB() to return it.
+func (p *badConnRetryPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
@@ -0,0 +1,257 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+)
+
+var errTestServerClosedConn = errors.New("server closed the connection unexpectedly")
+
+// testFaultyConnector is a driver.Connector which closes the connection used
+// by its next statements (see: failNext), like a server which has terminated
+// the connection.
+type testFaultyConnector struct {
+	driver   driver.Driver
+	dsn      string
+	failNext atomic.Int32
+	connects atomic.Int32
+}
+
+func (c *testFaultyConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	c.connects.Add(1)
+	return &testFaultyConn{Conn: conn, connector: c}, nil
+}
+
+func (c *testFaultyConnector) Driver() driver.Driver { return c.driver }
+
+type testFaultyConn struct {
+	driver.Conn
+	connector *testFaultyConnector
+	closed    bool
+}
+
+// fault closes the connection when the connector's next statement should
+// fail, and returns the error of a statement using the connection.
+func (c *testFaultyConn) fault() error {
+	if c.closed {
+		return driver.ErrBadConn
+	}
+	for {
+		n := c.connector.failNext.Load()
+		if n <= 0 {
+			return nil
+		}
+		if c.connector.failNext.CompareAndSwap(n, n-1) {
+			c.closed = true
+			_ = c.Conn.Close()
+			return errTestServerClosedConn
+		}
+	}
+}
+
+func (c *testFaultyConn) Close() error {
+	if c.closed {
+		return nil
+	}
+	return c.Conn.Close()
+}
+
+func (c *testFaultyConn) IsValid() bool { return !c.closed }
+
+func (c *testFaultyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *testFaultyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *testFaultyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.fault(); err != nil {
+		return nil, err
+	}
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &testFaultyTx{Tx: tx, conn: c}, nil
+}
+
+type testFaultyTx struct {
+	driver.Tx
+	conn *testFaultyConn
+}
+
+func (tx *testFaultyTx) Commit() error {
+	if tx.conn.closed {
+		return driver.ErrBadConn
+	}
+	return tx.Tx.Commit()
+}
+
+func (tx *testFaultyTx) Rollback() error {
+	if tx.conn.closed {
+		return driver.ErrBadConn
+	}
+	return tx.Tx.Rollback()
+}
+
+// testOpenFaultyDB opens a sqlite db with a testFaultyConnector and a single
+// connection, so the faults are injected into the connection in use.
+func testOpenFaultyDB(t *testing.T, opt ...dbw.Option) (*dbw.RW, *testFaultyConnector) {
+	t.Helper()
+	require := require.New(t)
+	sqliteDB, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(err)
+	connector := &testFaultyConnector{
+		driver: sqliteDB.Driver(),
+		dsn:    filepath.Join(t.TempDir(), "faulty.db"),
+	}
+	require.NoError(sqliteDB.Close())
+
+	conn, err := dbw.OpenWith(sqlite.Dialector{Conn: sql.OpenDB(connector)}, append(opt, dbw.WithMaxOpenConnections(1))...)
+	require.NoError(err)
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(context.Background(), "create table db_test_note (public_id text primary key, body text)", nil)
+	require.NoError(err)
+	return rw, connector
+}
+
+func TestDb_WithAutoInvalidateBadConns(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, connector := testOpenFaultyDB(t)
+		connects := connector.connects.Load()
+
+		connector.failNext.Store(1)
+		err := rw.Create(testCtx, &testNote{PublicId: "n_1"})
+		require.Error(err)
+		assert.ErrorIs(err, errTestServerClosedConn)
+		assert.True(dbw.IsBadConnError(err))
+
+		// the pool discarded the closed connection
+		require.NoError(rw.Create(testCtx, &testNote{PublicId: "n_1"}))
+		assert.Equal(connects+1, connector.connects.Load())
+	})
+	t.Run("retried", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, connector := testOpenFaultyDB(t, dbw.WithAutoInvalidateBadConns(true))
+		connects := connector.connects.Load()
+
+		connector.failNext.Store(1)
+		require.NoError(rw.Create(testCtx, &testNote{PublicId: "n_1", Body: "created"}))
+		assert.Equal(connects+1, connector.connects.Load())
+
+		connector.failNext.Store(1)
+		found := &testNote{PublicId: "n_1"}
+		require.NoError(rw.LookupBy(testCtx, found))
+		assert.Equal("created", found.Body)
+		assert.Equal(connects+2, connector.connects.Load())
+
+		connector.failNext.Store(1)
+		rowsUpdated, err := rw.Exec(testCtx, "update db_test_note set body = ? where public_id = ?", []interface{}{"updated", "n_1"})
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal(connects+3, connector.connects.Load())
+	})
+	t.Run("retried-once", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, connector := testOpenFaultyDB(t, dbw.WithAutoInvalidateBadConns(true))
+
+		connector.failNext.Store(2)
+		err := rw.Create(testCtx, &testNote{PublicId: "n_1"})
+		require.Error(err)
+		assert.ErrorIs(err, errTestServerClosedConn)
+		assert.Equal(int32(0), connector.failNext.Load())
+
+		require.NoError(rw.Create(testCtx, &testNote{PublicId: "n_1"}))
+	})
+	t.Run("not-retried-in-transaction", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, connector := testOpenFaultyDB(t, dbw.WithAutoInvalidateBadConns(true))
+
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		connector.failNext.Store(1)
+		err = tx.Create(testCtx, &testNote{PublicId: "n_1"})
+		require.Error(err)
+		assert.ErrorIs(err, errTestServerClosedConn)
+		_ = tx.Rollback(testCtx)
+
+		err = rw.LookupBy(testCtx, &testNote{PublicId: "n_1"})
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+	t.Run("missing-sql-db", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		sqlDB, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = sqlDB.Close() })
+		conn, err := dbw.OpenWith(sqlite.Dialector{Conn: struct{ *sql.DB }{sqlDB}}, dbw.WithAutoInvalidateBadConns(true))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "requires a *sql.DB connection pool")
+		assert.Nil(conn)
+	})
+}
+
+func TestIsBadConnError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad-conn", driver.ErrBadConn, true},
+		{"wrapped-bad-conn", fmt.Errorf("op: %w", driver.ErrBadConn), true},
+		{"eof", io.EOF, true},
+		{"unexpected-eof", fmt.Errorf("op: %w", io.ErrUnexpectedEOF), true},
+		{"server-closed", errTestServerClosedConn, true},
+		{"broken-pipe", errors.New("write tcp 127.0.0.1:5432: write: broken pipe"), true},
+		{"connection-reset", errors.New("read: connection reset by peer"), true},
+		{"net-op", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"pg-connection-failure", &pgconn.PgError{Code: "08006"}, true},
+		{"pg-admin-shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"pg-unique-violation", &pgconn.PgError{Code: "23505"}, false},
+		{"pg-serialization-failure", &pgconn.PgError{Code: "40001"}, false},
+		{"canceled", fmt.Errorf("op: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dbw.IsBadConnError(tt.err))
+		})
+	}
+}
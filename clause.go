@@ -5,11 +5,15 @@ package dbw
 
 import (
 	"sort"
+	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// versionColumn is the column of a resource's version (see: WithVersion)
+const versionColumn = "version"
+
 // ColumnValue defines a column and it's assigned value for a database
 // operation.  See: SetColumnValues(...)
 type ColumnValue struct {
@@ -98,6 +102,53 @@ func SetColumns(names []string) []ColumnValue {
 	return assignments
 }
 
+// IncrementVersion returns the column values with an assignment which
+// increments the version column of the conflicting record by one, replacing
+// any other assignment to the version column.  It's typically combined with
+// SetColumns(...) for an upsert of a versioned resource:
+//
+//	IncrementVersion(SetColumns([]string{"name"}))
+//
+// which renders: SET name = excluded.name, version = <table>.version + 1
+func IncrementVersion(columnValues []ColumnValue) []ColumnValue {
+	assignments := make([]ColumnValue, 0, len(columnValues)+1)
+	for _, cv := range columnValues {
+		if strings.EqualFold(cv.Column, versionColumn) {
+			continue
+		}
+		assignments = append(assignments, cv)
+	}
+	return append(assignments, ColumnValue{
+		Column: versionColumn,
+		Value:  Expr("? + 1", clause.Column{Table: clause.CurrentTable, Name: versionColumn}),
+	})
+}
+
+// PreferNonEmpty defines a list of column (names) to update using the set of
+// proposed insert columns during an on conflict update, unless a proposed
+// value is NULL or an empty string, in which case the conflicting record's
+// existing value is kept.  It's useful when merging partial updates from
+// multiple sources and it's only valid for text columns.  For example:
+//
+//	PreferNonEmpty([]string{"name"})
+//
+// which renders:
+//
+//	SET name = coalesce(nullif(excluded.name, ''), <table>.name)
+func PreferNonEmpty(names []string) []ColumnValue {
+	assignments := make([]ColumnValue, len(names))
+	for idx, name := range names {
+		assignments[idx] = ColumnValue{
+			Column: name,
+			Value: Expr("coalesce(nullif(?, ''), ?)",
+				clause.Column{Table: "excluded", Name: name},
+				clause.Column{Table: clause.CurrentTable, Name: name},
+			),
+		}
+	}
+	return assignments
+}
+
 // OnConflict specifies how to handle alternative actions to take when an insert
 // results in a unique constraint or exclusion constraint error.
 type OnConflict struct {
@@ -111,7 +162,9 @@ type OnConflict struct {
 	// these:
 	//	DoNothing: leaves the conflicting record as-is
 	//  UpdateAll: updates all the columns of the conflicting record using the resource's data
+	//  DeleteExisting: deletes the conflicting record before inserting the resource (RW.Create only)
 	//  []ColumnValue: update a set of columns of the conflicting record using the set of assignments
+	//  ChangedColumns: update the non-zero columns of a resource (see: UpdateChangedColumns)
 	Action interface{}
 }
 
@@ -127,3 +180,34 @@ type DoNothing bool
 // UpdateAll defines an "on conflict" action of updating all columns using the
 // proposed insert column values
 type UpdateAll bool
+
+// ChangedColumns defines an "on conflict" action of updating the columns of
+// the conflicting record which are non-zero in a resource, using the proposed
+// insert column values.  See: UpdateChangedColumns(...)
+type ChangedColumns struct {
+	resource interface{}
+}
+
+// UpdateChangedColumns creates an "on conflict" action which updates the
+// columns of the conflicting record that are set (non-zero) in the resource i,
+// excluding its primary keys and any non-updatable fields (see:
+// NonUpdatableFields), so an upsert writes exactly the columns that were
+// supplied.  The columns are validated against the resource's schema when the
+// insert is executed.  When i is nil, the resource being inserted is used (for
+// CreateItems that's its first item, since the action applies to every item).
+func UpdateChangedColumns(i interface{}) ChangedColumns {
+	return ChangedColumns{resource: i}
+}
+
+// DeleteExisting defines an "on conflict" action of deleting the conflicting
+// record and then inserting the proposed record, which is useful for
+// tombstone and dedup tables.  It's only supported by RW.Create with a Columns
+// target.
+//
+// Neither ON CONFLICT nor MERGE can express deleting a conflicting row and then
+// inserting the proposed row in one statement (a MERGE WHEN MATCHED THEN
+// DELETE won't insert the source row), so the delete and insert are executed
+// within a transaction for every dialect.  The delete is a MERGE ... WHEN
+// MATCHED THEN DELETE for postgres 15+ and a DELETE otherwise.  If the writer
+// is already in a transaction, then that transaction is used.
+type DeleteExisting bool
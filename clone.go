@@ -0,0 +1,157 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Cloner defines an interface for resources which can make a deep copy of
+// themselves, which is used by Clone.  Resources with unexported state (ex:
+// protobuf messages) should implement it.
+type Cloner interface {
+	// Clone returns a deep copy of the resource.
+	Clone() interface{}
+}
+
+// Clone will make a deep copy of the src resource, which must be a pointer to
+// a struct, and create it with the new id as its primary key.  The copy's
+// create and update time columns (see: WithCreateTimeColumn and
+// WithUpdateTimeColumn) and version column are reset to their zero values
+// before it's created, so they're managed by the database.  The copy is made
+// using the resource's Clone method when it implements Cloner, otherwise its
+// exported fields are copied recursively.  The resource's primary key must be
+// a single string column.  The src is not modified and the created resource is
+// returned.  Supports all the options of Create, except WithOnConflict and
+// WithUpsert.
+func (rw *RW) Clone(ctx context.Context, src interface{}, newId string, opt ...Option) (interface{}, error) {
+	const op = "dbw.Clone"
+	if rw.underlying == nil {
+		return nil, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	if rw.underlying.ReadOnly() {
+		return nil, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
+	if isNil(src) {
+		return nil, fmt.Errorf("%s: missing src: %w", op, ErrInvalidParameter)
+	}
+	if typ := reflect.TypeOf(src); typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s: src must be a pointer to a struct: %w", op, ErrInvalidParameter)
+	}
+	if newId == "" {
+		return nil, fmt.Errorf("%s: missing new id: %w", op, ErrInvalidParameter)
+	}
+	opts := GetOpts(opt...)
+	if opts.WithOnConflict != nil {
+		return nil, fmt.Errorf("%s: on conflict is not supported: %w", op, ErrInvalidParameter)
+	}
+	dst, err := cloneResource(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rw.resetClone(ctx, dst, newId, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rw.Create(ctx, dst, opt...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return dst, nil
+}
+
+// cloneResource returns a deep copy of the resource, which must be a pointer to
+// a struct.
+func cloneResource(resource interface{}) (interface{}, error) {
+	const op = "dbw.cloneResource"
+	if c, ok := resource.(Cloner); ok {
+		dst := c.Clone()
+		if isNil(dst) || reflect.TypeOf(dst) != reflect.TypeOf(resource) {
+			return nil, fmt.Errorf("%s: clone of %T returned %T: %w", op, resource, dst, ErrInvalidParameter)
+		}
+		return dst, nil
+	}
+	return deepCopy(reflect.ValueOf(resource)).Interface(), nil
+}
+
+// deepCopy returns a copy of the value, whose pointers, slices, maps and
+// interfaces are copied recursively.  The unexported fields of structs are
+// copied as is.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	default:
+		return v
+	}
+}
+
+// resetClone sets the primary key of the resource to the new id and resets its
+// time (see: timeFields) and version fields to their zero values.
+func (rw *RW) resetClone(ctx context.Context, resource interface{}, newId string, opts Options) error {
+	const op = "dbw.resetClone"
+	s, _, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(s.PrimaryFields) != 1 || s.PrioritizedPrimaryField == nil || s.PrioritizedPrimaryField.FieldType.Kind() != reflect.String {
+		return fmt.Errorf("%s: %s must have a single string primary key: %w", op, s.Table, ErrInvalidParameter)
+	}
+	rv := reflect.ValueOf(resource)
+	if err := s.PrioritizedPrimaryField.Set(ctx, rv, newId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	fields, err := rw.timeFields(resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if f, ok := s.FieldsByDBName[versionColumn]; ok {
+		fields = append(fields, f)
+	}
+	for _, f := range fields {
+		fv := f.ReflectValueOf(ctx, rv)
+		fv.Set(reflect.Zero(fv.Type()))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCloneConfig struct {
+	PublicId   string `gorm:"primaryKey"`
+	Name       string
+	Tags       dbw.JSON
+	Version    uint32             `gorm:"default:null"`
+	CreateTime string             `gorm:"default:current_timestamp"`
+	UpdateTime string             `gorm:"default:current_timestamp"`
+	Settings   *testCloneSettings `gorm:"-"`
+}
+
+type testCloneSettings struct {
+	Limits []int
+}
+
+func (*testCloneConfig) TableName() string { return "db_test_clone_config" }
+
+func TestDb_Clone(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+
+	t.Run("cloner", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		user, err := dbtest.NewTestUser()
+		require.NoError(err)
+		user.Email = "alice@example.com"
+		require.NoError(rw.Create(testCtx, user))
+		user.Version = 2
+
+		newId, err := dbw.NewId("u")
+		require.NoError(err)
+		cloned, err := rw.Clone(testCtx, user, newId)
+		require.NoError(err)
+		clonedUser, ok := cloned.(*dbtest.TestUser)
+		require.True(ok)
+		assert.Equal(newId, clonedUser.PublicId)
+		assert.Equal("alice@example.com", clonedUser.Email)
+		assert.Equal(uint32(1), clonedUser.Version)
+		assert.NotNil(clonedUser.CreateTime)
+
+		// the src isn't modified
+		assert.NotEqual(newId, user.PublicId)
+		assert.Equal(uint32(2), user.Version)
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = newId
+		require.NoError(rw.LookupBy(testCtx, &found))
+		assert.Equal("alice@example.com", found.Email)
+	})
+	t.Run("deep-copy", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		rw := dbw.New(conn)
+		_, err = rw.Exec(testCtx, `create table db_test_clone_config (
+  public_id text primary key,
+  name text,
+  tags text,
+  version integer not null default 1,
+  create_time text default current_timestamp,
+  update_time text default current_timestamp
+)`, nil)
+		require.NoError(err)
+		src := &testCloneConfig{
+			PublicId: "c_1",
+			Name:     "config",
+			Tags:     dbw.NewJSON([]string{"a", "b"}),
+			Settings: &testCloneSettings{Limits: []int{1, 2}},
+		}
+		require.NoError(rw.Create(testCtx, src))
+		src.Version = 7
+
+		cloned, err := rw.Clone(testCtx, src, "c_2")
+		require.NoError(err)
+		dst := cloned.(*testCloneConfig)
+		assert.Equal("c_2", dst.PublicId)
+		assert.Equal("config", dst.Name)
+		assert.Equal(uint32(1), dst.Version)
+		assert.NotEmpty(dst.CreateTime)
+		var tags []string
+		require.NoError(dst.Tags.Unmarshal(&tags))
+		assert.Equal([]string{"a", "b"}, tags)
+
+		// the copy doesn't share the src's pointers and slices
+		require.NotNil(dst.Settings)
+		assert.Equal([]int{1, 2}, dst.Settings.Limits)
+		dst.Settings.Limits[0] = 100
+		assert.Equal(1, src.Settings.Limits[0])
+		assert.Equal("c_1", src.PublicId)
+		assert.Equal(uint32(7), src.Version)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		user, err := dbtest.NewTestUser()
+		require.NoError(t, err)
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			src             interface{}
+			newId           string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, user, "u_1", nil, "missing underlying db"},
+			{"missing-src", rw, nil, "u_1", nil, "missing src"},
+			{"not-a-struct", rw, &[]string{}, "u_1", nil, "src must be a pointer to a struct"},
+			{"missing-new-id", rw, user, "", nil, "missing new id"},
+			{"on-conflict", rw, user, "u_1", []dbw.Option{dbw.WithUpsert(dbw.DoNothing(true))}, "on conflict is not supported"},
+			{"not-a-string-key", rw, &dbtest.TestRental{}, "u_1", nil, "must have a single string primary key"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				got, err := tt.rw.Clone(testCtx, tt.src, tt.newId, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Nil(got)
+			})
+		}
+	})
+}
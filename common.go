@@ -158,6 +158,21 @@ func BuildUpdatePaths(fieldValues map[string]interface{}, fieldMask []string, al
 	return masks, nulls
 }
 
+// MapResults returns the results of calling fn for each of the resources,
+// which is typically used to map the resources read by SearchWhere to another
+// type (ex: a DTO).  The order of the resources is preserved and nil is
+// returned when the resources are nil.
+func MapResults[T any, R any](in []*T, fn func(*T) R) []R {
+	if in == nil {
+		return nil
+	}
+	out := make([]R, 0, len(in))
+	for _, r := range in {
+		out = append(out, fn(r))
+	}
+	return out
+}
+
 func isZero(i interface{}) bool {
 	return i == nil || reflect.DeepEqual(i, reflect.Zero(reflect.TypeOf(i)).Interface())
 }
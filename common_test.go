@@ -4,6 +4,8 @@
 package dbw_test
 
 import (
+	"context"
+	"strconv"
 	"testing"
 
 	"github.com/hashicorp/go-dbw"
@@ -471,3 +473,35 @@ func TestBuildUpdatePaths(t *testing.T) {
 		})
 	}
 }
+
+func TestMapResults(t *testing.T) {
+	t.Parallel()
+	type userDTO struct {
+		Id   string
+		Name string
+	}
+	toDTO := func(u *dbtest.TestUser) userDTO {
+		return userDTO{Id: u.PublicId, Name: u.Name}
+	}
+	t.Run("nil", func(t *testing.T) {
+		assert.Nil(t, dbw.MapResults[dbtest.TestUser, userDTO](nil, toDTO))
+	})
+	t.Run("empty", func(t *testing.T) {
+		got := dbw.MapResults([]*dbtest.TestUser{}, toDTO)
+		assert.NotNil(t, got)
+		assert.Empty(t, got)
+	})
+	t.Run("search-results", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		rw := dbw.New(conn)
+		var want []userDTO
+		for i := 0; i < 3; i++ {
+			u := testUser(t, rw, "map-results-"+strconv.Itoa(i), "", "")
+			want = append(want, userDTO{Id: u.PublicId, Name: u.Name})
+		}
+		var users []*dbtest.TestUser
+		require.NoError(rw.SearchWhere(context.Background(), &users, "name like ?", []interface{}{"map-results-%"}, dbw.WithOrder("name asc")))
+		assert.Equal(want, dbw.MapResults(users, toDTO))
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// opLimiterAcquiredKey is the statement setting which records that a
+// statement acquired the op limiter, so it's only released by the statements
+// which acquired it.
+const opLimiterAcquiredKey = "dbw:op_limiter_acquired"
+
+// opLimiter limits the number of statements of a DB which are executed
+// concurrently (see: WithMaxConcurrentOps).  It's a semaphore whose slots are
+// the buffer of its channel.
+type opLimiter struct {
+	sem chan struct{}
+}
+
+func newOpLimiter(max int) *opLimiter {
+	return &opLimiter{sem: make(chan struct{}, max)}
+}
+
+// acquire will acquire a slot of the limiter.  When all the slots are in use,
+// it returns ErrTooManyRequests immediately if the ctx doesn't have a
+// deadline, otherwise it waits for a slot until the ctx is done.
+func (l *opLimiter) acquire(ctx context.Context) error {
+	const op = "dbw.(opLimiter).acquire"
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if ctx == nil {
+		return fmt.Errorf("%s: %w", op, ErrTooManyRequests)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return fmt.Errorf("%s: %w", op, ErrTooManyRequests)
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w: %s", op, ErrTooManyRequests, ctx.Err())
+	}
+}
+
+// release will release a slot acquired by acquire.
+func (l *opLimiter) release() {
+	<-l.sem
+}
+
+// register will register callbacks with the db, which acquire a slot before
+// each statement is executed and release it afterwards.
+func (l *opLimiter) register(db *gorm.DB) error {
+	const op = "dbw.(opLimiter).register"
+	before := func(db *gorm.DB) {
+		if err := l.acquire(db.Statement.Context); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		db.Statement.Settings.Store(opLimiterAcquiredKey, true)
+	}
+	after := func(db *gorm.DB) {
+		if _, ok := db.Statement.Settings.LoadAndDelete(opLimiterAcquiredKey); ok {
+			l.release()
+		}
+	}
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("dbw:acquire_op_limiter", before),
+		callbacks.Create().After("*").Register("dbw:release_op_limiter", after),
+		callbacks.Query().Before("*").Register("dbw:acquire_op_limiter", before),
+		callbacks.Query().After("*").Register("dbw:release_op_limiter", after),
+		callbacks.Update().Before("*").Register("dbw:acquire_op_limiter", before),
+		callbacks.Update().After("*").Register("dbw:release_op_limiter", after),
+		callbacks.Delete().Before("*").Register("dbw:acquire_op_limiter", before),
+		callbacks.Delete().After("*").Register("dbw:release_op_limiter", after),
+		callbacks.Row().Before("*").Register("dbw:acquire_op_limiter", before),
+		callbacks.Row().After("*").Register("dbw:release_op_limiter", after),
+		callbacks.Raw().Before("*").Register("dbw:acquire_op_limiter", before),
+		callbacks.Raw().After("*").Register("dbw:release_op_limiter", after),
+	} {
+		if err != nil {
+			return fmt.Errorf("%s: unable to register op limiter callback: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// InFlightOps returns the number of the DB's statements which are currently
+// executing and true, or false when the DB was opened without
+// WithMaxConcurrentOps.
+func (db *DB) InFlightOps() (int, bool) {
+	if db.opLimiter == nil {
+		return 0, false
+	}
+	return len(db.opLimiter.sem), true
+}
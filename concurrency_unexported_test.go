@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type testLimitedModel struct {
+	Id   int `gorm:"primaryKey"`
+	Name string
+}
+
+func (*testLimitedModel) TableName() string { return "db_test_limited" }
+
+func TestDB_WithMaxConcurrentOps(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	open := func(t *testing.T) (*DB, *RW) {
+		t.Helper()
+		db, err := Open(Sqlite, "file::memory:", WithMaxConcurrentOps(1))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close(testCtx) })
+		return db, New(db)
+	}
+	t.Run("releases", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, rw := open(t)
+		_, err := rw.Exec(testCtx, "create table db_test_limited (id integer primary key, name text)", nil)
+		require.NoError(err)
+		_, err = rw.Exec(testCtx, "insert into db_test_limited (name) values ('alice')", nil)
+		require.NoError(err)
+		var names []string
+		rows, err := rw.Query(testCtx, "select name from db_test_limited", nil)
+		require.NoError(err)
+		for rows.Next() {
+			var name string
+			require.NoError(rows.Scan(&name))
+			names = append(names, name)
+		}
+		require.NoError(rows.Close())
+		assert.Equal([]string{"alice"}, names)
+
+		// a failed statement releases its slot
+		_, err = rw.Exec(testCtx, "select * from db_test_missing", nil)
+		require.Error(err)
+
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		_, err = tx.Exec(testCtx, "insert into db_test_limited (name) values ('bob')", nil)
+		require.NoError(err)
+		require.NoError(tx.Commit(testCtx))
+
+		inFlight, ok := db.InFlightOps()
+		assert.True(ok)
+		assert.Equal(0, inFlight)
+	})
+	t.Run("fails-fast", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, rw := open(t)
+		require.NoError(db.opLimiter.acquire(testCtx))
+		inFlight, _ := db.InFlightOps()
+		assert.Equal(1, inFlight)
+
+		_, err := rw.Exec(testCtx, "select 1", nil)
+		require.Error(err)
+		assert.ErrorIs(err, ErrTooManyRequests)
+
+		db.opLimiter.release()
+		_, err = rw.Exec(testCtx, "select 1", nil)
+		require.NoError(err)
+	})
+	t.Run("waits-until-deadline", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, rw := open(t)
+		require.NoError(db.opLimiter.acquire(testCtx))
+
+		ctx, cancel := context.WithTimeout(testCtx, 20*time.Millisecond)
+		defer cancel()
+		_, err := rw.Exec(ctx, "select 1", nil)
+		require.Error(err)
+		assert.ErrorIs(err, ErrTooManyRequests)
+		assert.Contains(err.Error(), "context deadline exceeded")
+
+		// the slot is released before the deadline
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			db.opLimiter.release()
+		}()
+		ctx, cancel = context.WithTimeout(testCtx, 5*time.Second)
+		defer cancel()
+		_, err = rw.Exec(ctx, "select 1", nil)
+		require.NoError(err)
+	})
+	t.Run("rendered-statements", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, rw := open(t)
+		_, err := rw.Exec(testCtx, "create table db_test_limited (id integer primary key, name text)", nil)
+		require.NoError(err)
+		// the statements which are rendered in a dry run session and then
+		// executed must also acquire a slot
+		var executed []string
+		record := func(tx *gorm.DB) {
+			if _, ok := tx.Statement.Settings.Load(opLimiterAcquiredKey); ok && !tx.DryRun {
+				executed = append(executed, strings.Fields(tx.Statement.SQL.String())[0])
+			}
+		}
+		require.NoError(db.wrapped.Callback().Raw().After("dbw:acquire_op_limiter").Register("test:record", record))
+		require.NoError(db.wrapped.Callback().Row().After("dbw:acquire_op_limiter").Register("test:record", record))
+
+		_, err = rw.BatchExec(testCtx, "insert into db_test_limited (name) values (?)", [][]interface{}{{"alice"}, {"bob"}})
+		require.NoError(err)
+		assert.Equal([]string{"insert", "insert"}, executed)
+
+		executed = nil
+		var inserted []*testLimitedModel
+		require.NoError(rw.CreateItems(testCtx, []*testLimitedModel{{Id: 1, Name: "alice"}, {Id: 3, Name: "carol"}},
+			WithOnConflict(&OnConflict{Target: Columns{"id"}, Action: DoNothing(true)}),
+			WithReturnInserted(&inserted),
+		))
+		require.Len(inserted, 1)
+		assert.Equal("carol", inserted[0].Name)
+		assert.Contains(executed, "INSERT")
+
+		executed = nil
+		results, err := rw.UpsertItems(testCtx, []interface{}{&testLimitedModel{Id: 4, Name: "dave"}}, OnConflict{Target: Columns{"id"}, Action: UpdateAll(true)})
+		require.NoError(err)
+		assert.Len(results, 1)
+		assert.Contains(executed, "INSERT")
+
+		inFlight, _ := db.InFlightOps()
+		assert.Equal(0, inFlight)
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, err := Open(Sqlite, "file::memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = db.Close(testCtx) })
+		_, ok := db.InFlightOps()
+		assert.False(ok)
+	})
+	t.Run("negative", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := Open(Sqlite, "file::memory:", WithMaxConcurrentOps(-1))
+		require.Error(err)
+		assert.Contains(err.Error(), "max concurrent ops must not be negative")
+	})
+}
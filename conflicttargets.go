@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// validateConflictTargets returns the targets of the
+// WithConflictOnMultipleTargets option, which are the resource's unique keys
+// when the option doesn't specify any, after validating that they can be used
+// with the opts and the DB's dialect.
+func (rw *RW) validateConflictTargets(s *schema.Schema, opts Options) ([]Columns, error) {
+	const op = "dbw.validateConflictTargets"
+	switch {
+	case opts.WithOnConflict == nil:
+		return nil, fmt.Errorf("%s: multiple conflict targets require an on conflict action: %w", op, ErrInvalidParameter)
+	case opts.WithOnConflict.Target != nil:
+		return nil, fmt.Errorf("%s: multiple conflict targets can't be used with an on conflict target: %w", op, ErrInvalidParameter)
+	case opts.WithOnConflictFunc != nil:
+		return nil, fmt.Errorf("%s: multiple conflict targets can't be used with an on conflict func: %w", op, ErrInvalidParameter)
+	case opts.WithReturnInserted != nil:
+		return nil, fmt.Errorf("%s: multiple conflict targets can't be used with return inserted: %w", op, ErrInvalidParameter)
+	}
+	if deleteExisting, ok := opts.WithOnConflict.Action.(DeleteExisting); ok && bool(deleteExisting) {
+		return nil, fmt.Errorf("%s: multiple conflict targets can't be used with the delete existing conflict action: %w", op, ErrInvalidParameter)
+	}
+	typ, _, err := rw.underlying.DbType()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	switch typ {
+	case Postgres, CockroachDB, Sqlite:
+	default:
+		return nil, fmt.Errorf("%s: multiple conflict targets are not supported by %s: %w", op, typ, ErrInvalidParameter)
+	}
+	targets := opts.WithConflictTargets
+	if len(targets) == 0 {
+		for _, k := range schemaUniqueKeys(s) {
+			targets = append(targets, Columns(k))
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("%s: %s has no unique keys to use as conflict targets: %w", op, s.Table, ErrInvalidParameter)
+		}
+	}
+	for idx, target := range targets {
+		if len(target) == 0 {
+			return nil, fmt.Errorf("%s: conflict target %d is missing columns: %w", op, idx, ErrInvalidParameter)
+		}
+		for _, col := range target {
+			if f := s.LookUpField(col); f == nil || f.DBName == "" {
+				return nil, fmt.Errorf("%s: conflict target column %s not found in resource: %w", op, col, ErrInvalidParameter)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// insertItemsByConflictTargets inserts the items with the on conflict action of
+// opts.WithOnConflict, where the target of each item's on conflict is the first
+// of the targets on which the item conflicts with an existing row (or the first
+// target when it doesn't conflict).  The existing rows are read with one query
+// per target and the items are inserted by insertItemsByConflict, within a
+// transaction (a transaction is started if the writer isn't already in one).
+// It returns the rows affected by all the inserts.
+func (rw *RW) insertItemsByConflictTargets(ctx context.Context, valItems reflect.Value, targets []Columns, opts Options) (int64, error) {
+	const op = "dbw.insertItemsByConflictTargets"
+	s, tableName, err := rw.parseSchema(valItems.Interface(), opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	insert := func(w *RW) (int64, error) {
+		existing := make([]map[string]bool, 0, len(targets))
+		for _, target := range targets {
+			keys, err := w.existingTargetKeys(ctx, valItems, s, tableName, target)
+			if err != nil {
+				return noRowsAffected, err
+			}
+			existing = append(existing, keys)
+		}
+		action := opts.WithOnConflict.Action
+		opts.WithOnConflict = nil
+		opts.WithOnConflictFunc = func(item interface{}) *OnConflict {
+			for idx, target := range targets {
+				if key, ok := targetKey(ctx, s, target, item); ok && existing[idx][key] {
+					return &OnConflict{Target: target, Action: action}
+				}
+			}
+			return &OnConflict{Target: targets[0], Action: action}
+		}
+		return w.insertItemsByConflict(ctx, valItems, opts)
+	}
+	if rw.IsTx() {
+		rowsAffected, err := insert(rw)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		return rowsAffected, nil
+	}
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := insert(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+		}
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return rowsAffected, nil
+}
+
+// existingTargetKeys returns the keys (see: targetKey) of the rows which match
+// the items on the target's columns.  Items with a NULL target value are
+// skipped, since NULLs never conflict.
+func (rw *RW) existingTargetKeys(ctx context.Context, valItems reflect.Value, s *schema.Schema, tableName string, target Columns) (map[string]bool, error) {
+	const op = "dbw.existingTargetKeys"
+	conditions := make([]string, 0, valItems.Len())
+	args := make([]interface{}, 0, valItems.Len()*len(target))
+	for i := 0; i < valItems.Len(); i++ {
+		rv := reflect.ValueOf(valItems.Index(i).Interface())
+		columns := make([]string, 0, len(target))
+		values := make([]interface{}, 0, len(target))
+		for _, col := range target {
+			v, _ := s.LookUpField(col).ValueOf(ctx, rv)
+			if isNil(v) {
+				break
+			}
+			columns = append(columns, rw.underlying.wrapped.Statement.Quote(col)+" = ?")
+			values = append(values, v)
+		}
+		if len(values) < len(target) {
+			continue
+		}
+		conditions = append(conditions, "("+strings.Join(columns, " and ")+")")
+		args = append(args, values...)
+	}
+	existing := map[string]bool{}
+	if len(conditions) == 0 {
+		return existing, nil
+	}
+	quoted := make([]string, 0, len(target))
+	for _, col := range target {
+		quoted = append(quoted, rw.underlying.wrapped.Statement.Quote(col))
+	}
+	query := fmt.Sprintf("select %s from %s where %s", strings.Join(quoted, ", "), rw.underlying.wrapped.Statement.Quote(tableName), strings.Join(conditions, " or "))
+	rows, err := rw.underlying.wrapped.WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to read existing rows: %w", op, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		values := make([]interface{}, len(target))
+		dest := make([]interface{}, len(target))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%s: unable to scan existing row: %w", op, err)
+		}
+		parts := make([]string, 0, len(values))
+		for _, v := range values {
+			parts = append(parts, fmt.Sprintf("%v", normalizeScannedValue(v)))
+		}
+		existing[strings.Join(parts, "\x00")] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: unable to read existing rows: %w", op, err)
+	}
+	return existing, nil
+}
+
+// targetKey returns a string which identifies the item's values for the
+// target's columns and true, or false when a value is NULL.
+func targetKey(ctx context.Context, s *schema.Schema, target Columns, item interface{}) (string, bool) {
+	rv := reflect.ValueOf(item)
+	parts := make([]string, 0, len(target))
+	for _, col := range target {
+		v, _ := s.LookUpField(col).ValueOf(ctx, rv)
+		if isNil(v) {
+			return "", false
+		}
+		if valuer, ok := v.(driver.Valuer); ok {
+			dv, err := valuer.Value()
+			if err != nil || dv == nil {
+				return "", false
+			}
+			v = dv
+		} else if pv := reflect.ValueOf(v); pv.Kind() == reflect.Ptr {
+			v = pv.Elem().Interface()
+		}
+		parts = append(parts, fmt.Sprintf("%v", normalizeScannedValue(v)))
+	}
+	return strings.Join(parts, "\x00"), true
+}
@@ -0,0 +1,37 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeferConstraints will defer the checks of the deferrable constraints until
+// the transaction is committed (SET CONSTRAINTS ALL DEFERRED), which allows
+// rows with circular foreign keys to be written in any order within the
+// transaction.  Only the constraints declared DEFERRABLE are deferred, and any
+// violation is returned by Commit.  The RW must be a transaction (see: Begin
+// and DoTx), otherwise an ErrInternal is returned, and the setting only
+// applies to the transaction.  It's only supported by Postgres.
+func (rw *RW) DeferConstraints(ctx context.Context) error {
+	const op = "dbw.DeferConstraints"
+	if rw.underlying == nil {
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if dbType != Postgres {
+		return fmt.Errorf("%s: deferred constraints are not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+	if _, ok := rw.TxID(); !ok {
+		return fmt.Errorf("%s: deferring constraints requires a transaction: %w", op, ErrInternal)
+	}
+	if err := rw.underlying.wrapped.WithContext(ctx).Exec("set constraints all deferred").Error; err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
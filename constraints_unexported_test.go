@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRW_DeferConstraints(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const deferSql = "set constraints all deferred"
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(deferSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		require.NoError(tx.DeferConstraints(testCtx))
+		require.NoError(tx.Commit(testCtx))
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("exec-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(deferSql)).WillReturnError(errors.New("exec failed"))
+		mock.ExpectRollback()
+
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		err = tx.DeferConstraints(testCtx)
+		require.Error(err)
+		assert.Contains(err.Error(), "dbw.DeferConstraints: exec failed")
+		require.NoError(tx.Rollback(testCtx))
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("not-a-transaction", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		err := rw.DeferConstraints(testCtx)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInternal)
+		assert.Contains(err.Error(), "deferring constraints requires a transaction")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := TestSetup(t)
+		tx, err := New(conn).Begin(testCtx)
+		require.NoError(err)
+		t.Cleanup(func() { _ = tx.Rollback(testCtx) })
+		err = tx.DeferConstraints(testCtx)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "deferred constraints are not supported by sqlite")
+	})
+	t.Run("missing-underlying-db", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		err := (&RW{}).DeferConstraints(testCtx)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+	})
+}
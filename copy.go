@@ -0,0 +1,198 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// copyPlaceholderRegexp matches the postgres placeholders of a statement.
+var copyPlaceholderRegexp = regexp.MustCompile(`\$([0-9]+)`)
+
+// CopyTo will stream the results of the query to the writer in the Postgres
+// COPY CSV format with a header row, and return the number of rows exported.
+// It's dramatically faster than reading the results with SearchWhere and
+// serializing them, so it's typically used for large data dumps.  The query
+// must be a select (or values) statement and its args use the same
+// placeholders as Query.
+//
+// COPY doesn't support bind parameters, so the args are written into the
+// statement as escaped literals.  The supported arg types are nil, string,
+// []byte, bool, integers, floats, time.Time and driver.Valuers of them.
+//
+// CopyTo is only supported by Postgres and CockroachDB using the pgx driver,
+// and it's not supported within a transaction, since the copy requires a
+// dedicated connection from the pool.
+func (rw *RW) CopyTo(ctx context.Context, w io.Writer, sql string, args []interface{}) (int64, error) {
+	const op = "dbw.CopyTo"
+	switch {
+	case rw.underlying == nil:
+		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case isNil(w):
+		return noRowsAffected, fmt.Errorf("%s: missing writer: %w", op, ErrInvalidParameter)
+	case sql == "":
+		return noRowsAffected, fmt.Errorf("%s: missing sql: %w", op, ErrInvalidParameter)
+	case rw.IsTx():
+		return noRowsAffected, fmt.Errorf("%s: copy is not supported within a transaction: %w", op, ErrInvalidParameter)
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if dbType != Postgres && dbType != CockroachDB {
+		return noRowsAffected, fmt.Errorf("%s: copy is not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+	copySql, err := rw.copyToSql(sql, args)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	sqlDB, err := rw.underlying.wrapped.DB()
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+	var rowsAffected int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("copy requires the pgx driver, not %T: %w", driverConn, ErrInvalidParameter)
+		}
+		tag, err := c.Conn().PgConn().CopyTo(ctx, w, copySql)
+		if err != nil {
+			return err
+		}
+		rowsAffected = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return rowsAffected, nil
+}
+
+// copyToSql returns the copy statement for the query with its args written into
+// it as literals.  The query is built by gorm, so its placeholders (including
+// named args and slices) are handled the same as Query.
+func (rw *RW) copyToSql(query string, args []interface{}) (string, error) {
+	const op = "dbw.copyToSql"
+	stmt := rw.underlying.wrapped.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true}).Raw(query, args...).Statement
+	if stmt.Error != nil {
+		return "", fmt.Errorf("%s: unable to build the query: %w", op, stmt.Error)
+	}
+	literals := make([]string, 0, len(stmt.Vars))
+	for idx, v := range stmt.Vars {
+		l, err := copyLiteral(v)
+		if err != nil {
+			return "", fmt.Errorf("%s: arg %d: %w", op, idx, err)
+		}
+		if strings.HasPrefix(l, "-") {
+			// a negative number following an operator (ex: x-$1) would
+			// otherwise start a comment
+			l = "(" + l + ")"
+		}
+		literals = append(literals, l)
+	}
+	var replaceErr error
+	q := copyPlaceholderRegexp.ReplaceAllStringFunc(stmt.SQL.String(), func(placeholder string) string {
+		n, err := strconv.Atoi(placeholder[1:])
+		if err != nil || n < 1 || n > len(literals) {
+			replaceErr = fmt.Errorf("%s: placeholder %s has no arg: %w", op, placeholder, ErrInvalidParameter)
+			return placeholder
+		}
+		return literals[n-1]
+	})
+	if replaceErr != nil {
+		return "", replaceErr
+	}
+	return fmt.Sprintf("copy (%s) to stdout with (format csv, header true)", strings.TrimRight(strings.TrimSpace(q), ";")), nil
+}
+
+// copyLiteral returns the postgres literal of the value.  Strings are written
+// as escape string constants, so they're escaped the same regardless of the
+// standard_conforming_strings setting.
+func copyLiteral(v interface{}) (string, error) {
+	const op = "dbw.copyLiteral"
+	if valuer, ok := v.(driver.Valuer); ok && !isNil(v) {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return "", fmt.Errorf("%s: unable to get value: %w", op, err)
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		if strings.ContainsRune(v, 0) {
+			return "", fmt.Errorf("%s: string contains a null byte: %w", op, ErrInvalidParameter)
+		}
+		return "E'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(v) + "'", nil
+	case []byte:
+		return `'\x` + hex.EncodeToString(v) + "'::bytea", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return copyFloatLiteral(float64(v)), nil
+	case float64:
+		return copyFloatLiteral(v), nil
+	case time.Time:
+		return "'" + v.Format(time.RFC3339Nano) + "'", nil
+	case sql.NamedArg:
+		return "", fmt.Errorf("%s: unexpected named arg %s: %w", op, v.Name, ErrInvalidParameter)
+	default:
+		return "", fmt.Errorf("%s: unsupported type %T: %w", op, v, ErrInvalidParameter)
+	}
+}
+
+// copyFloatLiteral returns the postgres literal of the float, which must be
+// quoted for NaN and the infinities.
+func copyFloatLiteral(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::float8"
+	case math.IsInf(f, 1):
+		return "'Infinity'::float8"
+	case math.IsInf(f, -1):
+		return "'-Infinity'::float8"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
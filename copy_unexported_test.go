@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRW_CopyTo(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	t.Run("sql", func(t *testing.T) {
+		conn, _ := TestSetupWithMock(t)
+		rw := New(conn)
+		tests := []struct {
+			name    string
+			sql     string
+			args    []interface{}
+			wantSql string
+		}{
+			{"no-args", "select * from users;", nil, "copy (select * from users) to stdout with (format csv, header true)"},
+			{"args", "select * from users where name = ? and age > ? and active = ?", []interface{}{"o'brien", 21, true}, "copy (select * from users where name = E'o''brien' and age > 21 and active = true) to stdout with (format csv, header true)"},
+			{"slice", "select * from users where id in (?)", []interface{}{[]int{1, 2}}, "copy (select * from users where id in (1,2)) to stdout with (format csv, header true)"},
+			{"named", "select * from users where name = @name", []interface{}{sql.Named("name", "alice")}, "copy (select * from users where name = E'alice') to stdout with (format csv, header true)"},
+			{"negative", "select age-? from users", []interface{}{-1}, "copy (select age-(-1) from users) to stdout with (format csv, header true)"},
+			{"null", "select * from users where name is distinct from ?", []interface{}{nil}, "copy (select * from users where name is distinct from null) to stdout with (format csv, header true)"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				got, err := rw.copyToSql(tt.sql, tt.args)
+				require.NoError(err)
+				assert.Equal(tt.wantSql, got)
+			})
+		}
+	})
+	t.Run("literals", func(t *testing.T) {
+		tm := time.Date(2024, 1, 15, 10, 30, 0, 5, time.UTC)
+		tests := []struct {
+			name    string
+			value   interface{}
+			want    string
+			wantErr bool
+		}{
+			{"string", "a'b", "E'a''b'", false},
+			{"backslash", `a\'; drop table users; --`, `E'a\\''; drop table users; --'`, false},
+			{"null-byte", "a\x00b", "", true},
+			{"bytes", []byte("ab"), `'\x6162'::bytea`, false},
+			{"uint", uint64(math.MaxUint64), "18446744073709551615", false},
+			{"float", 1.5, "1.5", false},
+			{"nan", math.NaN(), "'NaN'::float8", false},
+			{"infinity", math.Inf(-1), "'-Infinity'::float8", false},
+			{"time", tm, "'2024-01-15T10:30:00.000000005Z'", false},
+			{"valuer", sql.NullString{String: "alice", Valid: true}, "E'alice'", false},
+			{"null-valuer", sql.NullString{}, "null", false},
+			{"unsupported", struct{}{}, "", true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				got, err := copyLiteral(tt.value)
+				if tt.wantErr {
+					require.Error(err)
+					assert.ErrorIs(err, ErrInvalidParameter)
+					return
+				}
+				require.NoError(err)
+				assert.Equal(tt.want, got)
+			})
+		}
+	})
+	t.Run("requires-pgx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := TestSetupWithMock(t)
+		rw := New(conn)
+		var buf bytes.Buffer
+		_, err := rw.CopyTo(testCtx, &buf, "select 1", nil)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "copy requires the pgx driver")
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, err := Open(Sqlite, "file::memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		var buf bytes.Buffer
+		_, err = New(conn).CopyTo(testCtx, &buf, "select 1", nil)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "copy is not supported by sqlite")
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectBegin()
+		tx, err := rw.Begin(testCtx)
+		require.NoError(t, err)
+		tests := []struct {
+			name            string
+			rw              *RW
+			w               *bytes.Buffer
+			sql             string
+			args            []interface{}
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &RW{}, &bytes.Buffer{}, "select 1", nil, "missing underlying db"},
+			{"missing-writer", rw, nil, "select 1", nil, "missing writer"},
+			{"missing-sql", rw, &bytes.Buffer{}, "", nil, "missing sql"},
+			{"in-tx", tx, &bytes.Buffer{}, "select 1", nil, "not supported within a transaction"},
+			{"unsupported-arg", rw, &bytes.Buffer{}, "select ?", []interface{}{struct{}{}}, "arg 0: dbw.copyLiteral: unsupported type"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				got, err := tt.rw.CopyTo(testCtx, tt.w, tt.sql, tt.args)
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Equal(int64(noRowsAffected), got)
+			})
+		}
+	})
+}
@@ -5,12 +5,17 @@ package dbw
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
 )
 
 // OpType defines a set of database operation types
@@ -78,7 +83,9 @@ func NonCreatableFields() []string {
 
 // Create a resource in the db with options: WithDebug, WithLookup,
 // WithReturnRowsAffected, OnConflict, WithBeforeWrite, WithAfterWrite,
-// WithVersion, WithTable, and WithWhere.
+// WithVersion, WithTable, WithDryRun, WithNoDatabaseSideEffects,
+// WithReturningColumns, WithUpsert, WithPartitionKey, WithConflictVersionCheck,
+// WithConflictConstraintOut and WithWhere.
 //
 // OnConflict specifies alternative actions to take when an insert results in a
 // unique constraint or exclusion constraint error. If WithVersion is used with
@@ -88,19 +95,43 @@ func NonCreatableFields() []string {
 // Zero is not a valid value for the WithVersion option and will return an
 // error. WithWhere allows specifying an additional constraint on the on
 // conflict operation in addition to the on conflict target policy (columns or
-// constraint).
-func (rw *RW) Create(ctx context.Context, i interface{}, opt ...Option) error {
+// constraint).  If the on conflict action assigns the same column more than
+// once with conflicting values (for example, using both SetColumns and
+// SetColumnValues), then an error is returned unless WithConflictOverride is
+// used, in which case SetColumnValues takes precedence over SetColumns.
+// WithConflictUpdateColumnsFromFieldMask allows the on conflict update columns
+// to be derived from field mask paths. WithConflictDebug will log the on
+// conflict target, action and rendered insert statement. WithDryRun will
+// generate the insert statement without executing it. The DeleteExisting on
+// conflict action will delete the conflicting record and then insert the
+// resource within a transaction. WithNoDatabaseSideEffects skips the WithLookup
+// lookup after the insert. WithReturningColumns limits the columns returned by
+// the insert, and scanned back into the resource, to the named columns.
+// WithUpsert is an OnConflict whose target is the resource's single unique key.
+func (rw *RW) Create(ctx context.Context, i interface{}, opt ...Option) (e error) {
 	const op = "dbw.Create"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
 	if rw.underlying == nil {
 		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
 	}
+	if rw.underlying.ReadOnly() {
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
 	if isNil(i) {
 		return fmt.Errorf("%s: missing interface: %w", op, ErrInvalidParameter)
 	}
 	if err := raiseErrorOnHooks(i); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, i, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts, err = rw.detectConflictTarget(i, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	// these fields should be nil, since they are not writeable and we want the
 	// db to manage them
@@ -113,56 +144,72 @@ func (rw *RW) Create(ctx context.Context, i interface{}, opt ...Option) error {
 			}
 		}
 	}
+	if err := rw.validatePartitionKey(ctx, i, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithConflictConstraint != nil {
+		if err := rw.validateConflictConstraintOut(opts); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		*opts.WithConflictConstraint = ""
+	}
 
-	db := rw.underlying.wrapped.WithContext(ctx)
+	var deleteExisting bool
 	if opts.WithOnConflict != nil {
-		c := clause.OnConflict{}
 		switch opts.WithOnConflict.Target.(type) {
-		case Constraint:
-			c.OnConstraint = string(opts.WithOnConflict.Target.(Constraint))
-		case Columns:
-			columns := make([]clause.Column, 0, len(opts.WithOnConflict.Target.(Columns)))
-			for _, name := range opts.WithOnConflict.Target.(Columns) {
-				columns = append(columns, clause.Column{Name: name})
-			}
-			c.Columns = columns
+		case Constraint, Columns:
 		default:
 			return fmt.Errorf("%s: invalid conflict target %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Target), ErrInvalidParameter)
 		}
-
 		switch opts.WithOnConflict.Action.(type) {
-		case DoNothing:
-			c.DoNothing = true
-		case UpdateAll:
-			c.UpdateAll = true
-		case []ColumnValue:
-			updates := opts.WithOnConflict.Action.([]ColumnValue)
-			set := make(clause.Set, 0, len(updates))
-			for _, s := range updates {
-				// make sure it's not one of the std immutable columns
-				if contains([]string{"createtime", "publicid"}, strings.ToLower(s.Column)) {
-					return fmt.Errorf("%s: cannot do update on conflict for column %s: %w", op, s.Column, ErrInvalidParameter)
-				}
-				switch sv := s.Value.(type) {
-				case Column:
-					set = append(set, sv.toAssignment(s.Column))
-				case ExprValue:
-					set = append(set, sv.toAssignment(s.Column))
-				default:
-					set = append(set, rawAssignment(s.Column, s.Value))
-				}
-			}
-			c.DoUpdates = set
+		case nil, DoNothing, UpdateAll, DeleteExisting, ChangedColumns, []ColumnValue:
 		default:
 			return fmt.Errorf("%s: invalid conflict action %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Action), ErrInvalidParameter)
 		}
-		if opts.WithVersion != nil || opts.WithWhereClause != "" {
-			where, args, err := rw.whereClausesFromOpts(ctx, i, opts)
-			if err != nil {
-				return fmt.Errorf("%s: %w", op, err)
+		if action, ok := opts.WithOnConflict.Action.(DeleteExisting); ok && bool(action) {
+			if opts.WithDryRun != nil {
+				return fmt.Errorf("%s: with dry run is not supported for the delete existing conflict action: %w", op, ErrInvalidParameter)
 			}
-			whereConditions := db.Statement.BuildCondition(where, args...)
-			c.Where = clause.Where{Exprs: whereConditions}
+			deleteExisting = true
+		}
+	}
+
+	// the resource is inserted by w, which is the transaction used to delete
+	// the existing record for the DeleteExisting conflict action
+	w := rw
+	if deleteExisting {
+		if w, err = rw.deleteExisting(ctx, i, opts); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if w != rw {
+			defer func() {
+				if e != nil {
+					if rollbackErr := w.Rollback(ctx); rollbackErr != nil {
+						e = errors.Join(e, rollbackErr)
+					}
+					return
+				}
+				if err := w.Commit(ctx); err != nil {
+					e = fmt.Errorf("%s: %w", op, err)
+				}
+			}()
+		}
+		// the insert must not include the on conflict clause
+		opts.WithOnConflict = nil
+	}
+
+	db := w.underlying.wrapped.WithContext(ctx)
+	if opts.WithOnConflict != nil {
+		c, err := rw.onConflictClause(ctx, db, i, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		db = db.Clauses(c)
+	}
+	if len(opts.WithReturningColumns) > 0 {
+		c, err := rw.returningClause(i, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
 		}
 		db = db.Clauses(c)
 	}
@@ -177,33 +224,154 @@ func (rw *RW) Create(ctx context.Context, i interface{}, opt ...Option) error {
 			return fmt.Errorf("%s: error before write: %w", op, err)
 		}
 	}
+	if err := w.runWriteCallbacks(ctx, i); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithOnConflict != nil && opts.WithConflictDebug {
+		rw.debugOnConflict(ctx, db, i)
+	}
+	if opts.WithDryRun != nil {
+		db = db.Session(&gorm.Session{DryRun: true})
+	}
 	tx := db.Create(i)
 	if tx.Error != nil {
 		return fmt.Errorf("%s: create failed: %w", op, tx.Error)
 	}
+	if opts.WithDryRun != nil {
+		*opts.WithDryRun = dryRunSql(tx)
+		return nil
+	}
 	if opts.WithRowsAffected != nil {
 		*opts.WithRowsAffected = tx.RowsAffected
 	}
+	if tx.RowsAffected == 0 && opts.WithConflictConstraint != nil {
+		name, err := rw.conflictConstraint(ctx, i, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		*opts.WithConflictConstraint = name
+	}
 	if tx.RowsAffected > 0 && opts.WithAfterWrite != nil {
 		if err := opts.WithAfterWrite(i, int(tx.RowsAffected)); err != nil {
 			return fmt.Errorf("%s: error after write: %w", op, err)
 		}
 	}
-	if err := rw.lookupAfterWrite(ctx, i, opt...); err != nil {
+	if err := w.lookupAfterWrite(ctx, i, opt...); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
+// validateConflictConstraintOut returns an ErrInvalidParameter when the
+// WithConflictConstraintOut option can't be used with the opts or the DB's
+// dialect.
+func (rw *RW) validateConflictConstraintOut(opts Options) error {
+	const op = "dbw.validateConflictConstraintOut"
+	if opts.WithOnConflict == nil {
+		return fmt.Errorf("%s: conflict constraint out requires a DoNothing conflict action: %w", op, ErrInvalidParameter)
+	}
+	if doNothing, ok := opts.WithOnConflict.Action.(DoNothing); !ok || !bool(doNothing) {
+		return fmt.Errorf("%s: conflict constraint out requires a DoNothing conflict action, not %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Action), ErrInvalidParameter)
+	}
+	typ, _, err := rw.underlying.DbType()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	switch typ {
+	case Postgres, CockroachDB, Sqlite:
+		return nil
+	default:
+		return fmt.Errorf("%s: conflict constraint out is not supported by %s: %w", op, typ, ErrInvalidParameter)
+	}
+}
+
+// isConflictTarget returns true if the unique key is the on conflict target.
+func isConflictTarget(k uniqueKey, target interface{}) bool {
+	switch t := target.(type) {
+	case Columns:
+		return sameColumns(k.Columns, t)
+	case Constraint:
+		return strings.EqualFold(k.Name, string(t))
+	default:
+		return false
+	}
+}
+
+// conflictConstraint returns the name of the first unique key of the resource's
+// table (see: catalogNamedUniqueKeys), starting with the on conflict target's
+// key, with a row which has the resource's values for the key's columns, or an
+// empty string when there isn't one.  Keys with a column which isn't a field of
+// the resource, or whose value is NULL or a database default, are skipped since
+// their values can't be determined (and NULLs never conflict).
+func (rw *RW) conflictConstraint(ctx context.Context, i interface{}, opts Options) (string, error) {
+	const op = "dbw.conflictConstraint"
+	s, tableName, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	keys, _, err := rw.catalogNamedUniqueKeys(ctx, tableName)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	// the conflict target's key is checked first, since a row which conflicts
+	// with it may also have the resource's values for another key
+	sort.SliceStable(keys, func(a, b int) bool {
+		return isConflictTarget(keys[a], opts.WithOnConflict.Target) && !isConflictTarget(keys[b], opts.WithOnConflict.Target)
+	})
+	rv := reflect.ValueOf(i)
+	db := rw.underlying.wrapped.WithContext(ctx)
+nextKey:
+	for _, k := range keys {
+		exprs := make([]clause.Expression, 0, len(k.Columns))
+		for _, column := range k.Columns {
+			f := s.LookUpField(column)
+			if f == nil {
+				continue nextKey
+			}
+			v, isZero := f.ValueOf(ctx, rv)
+			if isNil(v) || (isZero && f.HasDefaultValue) {
+				continue nextKey
+			}
+			exprs = append(exprs, clause.Eq{Column: clause.Column{Name: column}, Value: v})
+		}
+		var found []int
+		if err := db.Table(tableName).Select("1").Where(clause.And(exprs...)).Limit(1).Scan(&found).Error; err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		if len(found) > 0 {
+			return k.Name, nil
+		}
+	}
+	return "", nil
+}
+
 // CreateItems will create multiple items of the same type. Supported options:
 // WithBatchSize, WithDebug, WithBeforeWrite, WithAfterWrite,
-// WithReturnRowsAffected, OnConflict, WithVersion, WithTable, and WithWhere.
-// WithLookup is not a supported option.
+// WithReturnRowsAffected, OnConflict, WithConflictOverride,
+// WithConflictUpdateColumnsFromFieldMask, WithConflictDebug, WithVersion,
+// WithReturningColumns, WithUpsert, WithOnConflictFunc, WithPartitionKey,
+// WithConflictVersionCheck, WithReturnInserted, WithConflictOnMultipleTargets,
+// WithTable, and WithWhere. WithLookup is not a supported option, since the
+// items are never read after they're written: a batch upsert executes a single
+// insert per batch and WithReturnRowsAffected returns the rows affected
+// reported by the inserts.
+// If WithBatchSize isn't used, then the batch size of the DB's
+// WithCreateBatchSize is used.
+//
+// A WithOnConflict option applies uniformly to all the items.  When items of
+// the same type conflict on different unique keys, use WithOnConflictFunc to
+// specify the OnConflict of each item: the items are grouped by their
+// OnConflict and each group is inserted with its own statements within a
+// transaction.
 func (rw *RW) CreateItems(ctx context.Context, createItems interface{}, opt ...Option) error {
 	const op = "dbw.CreateItems"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
 	switch {
 	case rw.underlying == nil:
 		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
 	case isNil(createItems):
 		return fmt.Errorf("%s: missing items: %w", op, ErrInvalidParameter)
 	}
@@ -217,10 +385,46 @@ func (rw *RW) CreateItems(ctx context.Context, createItems interface{}, opt ...O
 	if err := raiseErrorOnHooks(createItems); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	opts := GetOpts(opt...)
+	var base []Option
+	if rw.underlying.createBatchSize > 0 {
+		base = append(base, WithBatchSize(rw.underlying.createBatchSize))
+	}
+	opts := rw.getOptsWithBase(base, opt...)
+	opts, err := rw.resolveTable(ctx, createItems, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts, err = rw.detectConflictTarget(createItems, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	switch {
 	case opts.WithLookup:
 		return fmt.Errorf("%s: with lookup not a supported option: %w", op, ErrInvalidParameter)
+	case opts.WithOnConflictFunc != nil && opts.WithOnConflict != nil:
+		return fmt.Errorf("%s: both on conflict and on conflict func options are set: %w", op, ErrInvalidParameter)
+	case opts.WithConflictConstraint != nil:
+		return fmt.Errorf("%s: with conflict constraint out not a supported option: %w", op, ErrInvalidParameter)
+	}
+	var conflictTargets []Columns
+	if opts.WithConflictTargets != nil {
+		s, _, err := rw.parseSchema(createItems, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if conflictTargets, err = rw.validateConflictTargets(s, opts); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if opts.WithReturnInserted != nil {
+		if opts.WithOnConflict == nil {
+			return fmt.Errorf("%s: return inserted requires a DoNothing conflict action: %w", op, ErrInvalidParameter)
+		}
+		if doNothing, ok := opts.WithOnConflict.Action.(DoNothing); !ok || !bool(doNothing) {
+			return fmt.Errorf("%s: return inserted requires a DoNothing conflict action, not %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Action), ErrInvalidParameter)
+		}
+		if err := rw.validateReturningResults(ctx, valCreateItems.Index(0).Interface(), opts.WithReturnInserted); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 	}
 	var foundType reflect.Type
 	for i := 0; i < valCreateItems.Len(); i++ {
@@ -248,6 +452,9 @@ func (rw *RW) CreateItems(ctx context.Context, createItems interface{}, opt ...O
 				}
 			}
 		}
+		if err := rw.validatePartitionKey(ctx, valCreateItems.Index(i).Interface(), opts); err != nil {
+			return fmt.Errorf("%s: item %d: %w", op, i, err)
+		}
 	}
 
 	if opts.WithBeforeWrite != nil {
@@ -255,59 +462,168 @@ func (rw *RW) CreateItems(ctx context.Context, createItems interface{}, opt ...O
 			return fmt.Errorf("%s: error before write: %w", op, err)
 		}
 	}
+	for i := 0; i < valCreateItems.Len(); i++ {
+		if err := rw.runWriteCallbacks(ctx, valCreateItems.Index(i).Interface()); err != nil {
+			return fmt.Errorf("%s: item %d: %w", op, i, err)
+		}
+	}
 
-	db := rw.underlying.wrapped.WithContext(ctx)
 	if opts.WithOnConflict != nil {
-		c := clause.OnConflict{}
 		switch opts.WithOnConflict.Target.(type) {
-		case Constraint:
-			c.OnConstraint = string(opts.WithOnConflict.Target.(Constraint))
-		case Columns:
-			columns := make([]clause.Column, 0, len(opts.WithOnConflict.Target.(Columns)))
-			for _, name := range opts.WithOnConflict.Target.(Columns) {
-				columns = append(columns, clause.Column{Name: name})
+		case Constraint, Columns:
+		case nil:
+			// the targets are provided by WithConflictOnMultipleTargets
+			if conflictTargets == nil {
+				return fmt.Errorf("%s: invalid conflict target %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Target), ErrInvalidParameter)
 			}
-			c.Columns = columns
 		default:
 			return fmt.Errorf("%s: invalid conflict target %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Target), ErrInvalidParameter)
 		}
-
 		switch opts.WithOnConflict.Action.(type) {
-		case DoNothing:
-			c.DoNothing = true
-		case UpdateAll:
-			c.UpdateAll = true
-		case []ColumnValue:
-			updates := opts.WithOnConflict.Action.([]ColumnValue)
-			set := make(clause.Set, 0, len(updates))
-			for _, s := range updates {
-				// make sure it's not one of the std immutable columns
-				if contains([]string{"createtime", "publicid"}, strings.ToLower(s.Column)) {
-					return fmt.Errorf("%s: cannot do update on conflict for column %s: %w", op, s.Column, ErrInvalidParameter)
-				}
-				switch sv := s.Value.(type) {
-				case Column:
-					set = append(set, sv.toAssignment(s.Column))
-				case ExprValue:
-					set = append(set, sv.toAssignment(s.Column))
-				default:
-					set = append(set, rawAssignment(s.Column, s.Value))
-				}
-			}
-			c.DoUpdates = set
+		case nil, DoNothing, UpdateAll, ChangedColumns, []ColumnValue:
+		case DeleteExisting:
+			return fmt.Errorf("%s: conflict action %v is only supported by RW.Create: %w", op, reflect.TypeOf(opts.WithOnConflict.Action), ErrInvalidParameter)
 		default:
 			return fmt.Errorf("%s: invalid conflict action %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Action), ErrInvalidParameter)
 		}
-		if opts.WithVersion != nil || opts.WithWhereClause != "" {
-			// this is a bit of a hack, but we need to pass in one of the items
-			// to get the where clause since we need to get the gorm Model and
-			// Parse the gorm statement to build the where clause
-			where, args, err := rw.whereClausesFromOpts(ctx, valCreateItems.Index(0).Interface(), opts)
+	}
+
+	var rowsAffected int64
+	switch {
+	case opts.WithReturnInserted != nil:
+		rowsAffected, err = rw.insertItemsReturningInserted(ctx, valCreateItems, opts)
+	case conflictTargets != nil:
+		rowsAffected, err = rw.insertItemsByConflictTargets(ctx, valCreateItems, conflictTargets, opts)
+	case opts.WithOnConflictFunc != nil:
+		rowsAffected, err = rw.insertItemsByConflict(ctx, valCreateItems, opts)
+	default:
+		rowsAffected, err = rw.insertItems(ctx, createItems, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithRowsAffected != nil {
+		*opts.WithRowsAffected = rowsAffected
+	}
+	if rowsAffected > 0 && opts.WithAfterWrite != nil {
+		if err := opts.WithAfterWrite(createItems, int(rowsAffected)); err != nil {
+			return fmt.Errorf("%s: error after write: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// insertItemsReturningInserted inserts the items in batches, like insertItems,
+// and scans the rows returned by each insert, which are the rows that were
+// inserted, into the opts.WithReturnInserted slice.  The batches are inserted
+// in a transaction, if the writer isn't already in one.
+func (rw *RW) insertItemsReturningInserted(ctx context.Context, valItems reflect.Value, opts Options) (int64, error) {
+	const op = "dbw.insertItemsReturningInserted"
+	batchSize := opts.WithBatchSize
+	if batchSize <= 0 || batchSize > valItems.Len() {
+		batchSize = valItems.Len()
+	}
+	insert := func(w *RW) (reflect.Value, error) {
+		db := w.underlying.wrapped.WithContext(ctx)
+		if opts.WithDebug {
+			db = db.Debug()
+		}
+		c, err := w.onConflictClause(ctx, db, valItems.Index(0).Interface(), opts)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		// an empty returning clause returns every column
+		returning := clause.Returning{}
+		if len(opts.WithReturningColumns) > 0 {
+			if returning, err = w.returningClause(valItems.Index(0).Interface(), opts); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		resultsType := reflect.TypeOf(opts.WithReturnInserted).Elem()
+		inserted := reflect.MakeSlice(resultsType, 0, valItems.Len())
+		for start := 0; start < valItems.Len(); start += batchSize {
+			end := start + batchSize
+			if end > valItems.Len() {
+				end = valItems.Len()
+			}
+			// each batch needs its own statement
+			batchDb := db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true, Logger: logger.Discard}).Clauses(c, returning)
+			if opts.WithTable != "" {
+				batchDb = batchDb.Table(opts.WithTable)
+			}
+			batchDb = batchDb.Create(valItems.Slice(start, end).Interface())
+			if batchDb.Error != nil {
+				return reflect.Value{}, fmt.Errorf("create failed: %w", batchDb.Error)
+			}
+			// the statement is executed using Raw, so it runs through the
+			// callbacks (logging, WithMaxConcurrentOps, etc)
+			rows, err := db.Raw(batchDb.Statement.SQL.String(), renderedVars(batchDb.Statement.Vars)...).Rows()
 			if err != nil {
-				return fmt.Errorf("%s: %w", op, err)
+				return reflect.Value{}, fmt.Errorf("create failed: %w", err)
+			}
+			for rows.Next() {
+				row := reflect.New(resultsType.Elem().Elem())
+				if err := db.ScanRows(rows, row.Interface()); err != nil {
+					_ = rows.Close()
+					return reflect.Value{}, fmt.Errorf("unable to scan returned row: %w", err)
+				}
+				inserted = reflect.Append(inserted, row)
+			}
+			err = rows.Err()
+			_ = rows.Close()
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("unable to read returned rows: %w", err)
+			}
+		}
+		return inserted, nil
+	}
+
+	var inserted reflect.Value
+	var err error
+	if rw.IsTx() || batchSize == valItems.Len() {
+		if inserted, err = insert(rw); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+	} else {
+		tx, err := rw.Begin(ctx)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		if inserted, err = insert(tx); err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				return noRowsAffected, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
 			}
-			whereConditions := db.Statement.BuildCondition(where, args...)
-			c.Where = clause.Where{Exprs: whereConditions}
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	// the inserted rows replace any existing elements of the results
+	reflect.ValueOf(opts.WithReturnInserted).Elem().Set(inserted)
+	return int64(inserted.Len()), nil
+}
+
+// insertItems inserts the slice of items in batches, using the single
+// opts.WithOnConflict for all of them, and returns the rows affected.
+func (rw *RW) insertItems(ctx context.Context, items interface{}, opts Options) (int64, error) {
+	const op = "dbw.insertItems"
+	valItems := reflect.ValueOf(items)
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithOnConflict != nil {
+		// this is a bit of a hack, but we need to pass in one of the items
+		// to get the where clause since we need to get the gorm Model and
+		// Parse the gorm statement to build the where clause
+		c, err := rw.onConflictClause(ctx, db, valItems.Index(0).Interface(), opts)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		db = db.Clauses(c)
+	}
+	if len(opts.WithReturningColumns) > 0 {
+		c, err := rw.returningClause(valItems.Index(0).Interface(), opts)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
 		}
 		db = db.Clauses(c)
 	}
@@ -318,21 +634,554 @@ func (rw *RW) CreateItems(ctx context.Context, createItems interface{}, opt ...O
 		db = db.Table(opts.WithTable)
 	}
 
-	tx := db.CreateInBatches(createItems, opts.WithBatchSize)
+	if opts.WithOnConflict != nil && opts.WithConflictDebug {
+		rw.debugOnConflict(ctx, db, items)
+	}
+	tx := db.CreateInBatches(items, opts.WithBatchSize)
 	if tx.Error != nil {
-		return fmt.Errorf("%s: create failed: %w", op, tx.Error)
+		return noRowsAffected, fmt.Errorf("%s: create failed: %w", op, tx.Error)
 	}
-	if opts.WithRowsAffected != nil {
-		*opts.WithRowsAffected = tx.RowsAffected
+	return tx.RowsAffected, nil
+}
+
+// conflictGroup is a group of items which share an on conflict clause.
+type conflictGroup struct {
+	onConflict *OnConflict
+	items      reflect.Value
+}
+
+// insertItemsByConflict groups the items by the OnConflict returned for each
+// of them by opts.WithOnConflictFunc, and inserts each group with its own
+// statements within a transaction (a transaction is started if the writer
+// isn't already in one).  It returns the rows affected by all the inserts.
+func (rw *RW) insertItemsByConflict(ctx context.Context, valItems reflect.Value, opts Options) (int64, error) {
+	const op = "dbw.insertItemsByConflict"
+	var groups []*conflictGroup
+	for i := 0; i < valItems.Len(); i++ {
+		item := valItems.Index(i)
+		onConflict := opts.WithOnConflictFunc(item.Interface())
+		var group *conflictGroup
+		for _, g := range groups {
+			if reflect.DeepEqual(g.onConflict, onConflict) {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &conflictGroup{onConflict: onConflict, items: reflect.MakeSlice(valItems.Type(), 0, 1)}
+			groups = append(groups, group)
+		}
+		group.items = reflect.Append(group.items, item)
 	}
-	if tx.RowsAffected > 0 && opts.WithAfterWrite != nil {
-		if err := opts.WithAfterWrite(createItems, int(tx.RowsAffected)); err != nil {
-			return fmt.Errorf("%s: error after write: %w", op, err)
+
+	insert := func(w *RW) (int64, error) {
+		var rowsAffected int64
+		for _, g := range groups {
+			groupOpts := opts
+			groupOpts.WithOnConflict = g.onConflict
+			n, err := w.insertItems(ctx, g.items.Interface(), groupOpts)
+			if err != nil {
+				return noRowsAffected, err
+			}
+			rowsAffected += n
+		}
+		return rowsAffected, nil
+	}
+	if rw.IsTx() || len(groups) == 1 {
+		rowsAffected, err := insert(rw)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		return rowsAffected, nil
+	}
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := insert(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+		}
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return rowsAffected, nil
+}
+
+// returningClause builds the gorm returning clause for the
+// opts.WithReturningColumns option, which replaces the default returning clause
+// of the insert.  The columns must be in the schema of the resource i.
+func (rw *RW) returningClause(i interface{}, opts Options) (clause.Returning, error) {
+	const op = "dbw.returningClause"
+	s, _, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return clause.Returning{}, fmt.Errorf("%s: %w", op, err)
+	}
+	columns := make([]clause.Column, 0, len(opts.WithReturningColumns))
+	for _, col := range opts.WithReturningColumns {
+		f := s.LookUpField(col)
+		if f == nil || f.DBName == "" {
+			return clause.Returning{}, fmt.Errorf("%s: unknown returning column %s: %w", op, col, ErrInvalidParameter)
+		}
+		columns = append(columns, clause.Column{Name: f.DBName})
+	}
+	return clause.Returning{Columns: columns}, nil
+}
+
+// onConflictClause builds the gorm on conflict clause for the
+// opts.WithOnConflict option.  The resource i is used to build any where clause
+// for the conflict update (see: WithVersion and WithWhere).  For sqlite, a
+// rowid target is resolved for the kind of table (see: sqliteConflictTarget).
+func (rw *RW) onConflictClause(ctx context.Context, db *gorm.DB, i interface{}, opts Options) (clause.OnConflict, error) {
+	const op = "dbw.onConflictClause"
+	c := clause.OnConflict{}
+	if opts.WithConflictVersionCheck != nil {
+		// the version check is the same as WithVersion
+		switch {
+		case *opts.WithConflictVersionCheck == 0:
+			return clause.OnConflict{}, fmt.Errorf("%s: conflict version check is zero: %w", op, ErrInvalidParameter)
+		case opts.WithVersion != nil && *opts.WithVersion != *opts.WithConflictVersionCheck:
+			return clause.OnConflict{}, fmt.Errorf("%s: conflict version check %d does not match with version %d: %w", op, *opts.WithConflictVersionCheck, *opts.WithVersion, ErrInvalidParameter)
+		}
+		opts.WithVersion = opts.WithConflictVersionCheck
+	}
+	switch opts.WithOnConflict.Target.(type) {
+	case Constraint:
+		if typ, _, _ := rw.underlying.DbType(); typ == CockroachDB {
+			// cockroachdb doesn't support "on conflict on constraint"
+			return clause.OnConflict{}, fmt.Errorf("%s: constraint conflict targets are not supported by %s, use a Columns target: %w", op, typ, ErrInvalidParameter)
+		}
+		if opts.WithIndexPredicate != "" {
+			return clause.OnConflict{}, fmt.Errorf("%s: an index predicate requires a Columns conflict target: %w", op, ErrInvalidParameter)
+		}
+		c.OnConstraint = string(opts.WithOnConflict.Target.(Constraint))
+	case Columns:
+		target, isRowid := opts.WithOnConflict.Target.(Columns), false
+		if typ, _, _ := rw.underlying.DbType(); typ == Sqlite {
+			_, tableName, err := rw.parseSchema(i, opts)
+			if err != nil {
+				return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+			}
+			if target, isRowid, err = rw.sqliteConflictTarget(ctx, tableName, target); err != nil {
+				return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		columns := make([]clause.Column, 0, len(target))
+		for _, name := range target {
+			columns = append(columns, clause.Column{Name: name})
+		}
+		c.Columns = columns
+		if !isRowid {
+			if err := rw.validateConflictTarget(ctx, i, target, opts); err != nil {
+				return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		if opts.WithIndexPredicate != "" {
+			// the predicate is required to infer a partial unique index
+			c.TargetWhere = clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: opts.WithIndexPredicate}}}
+		}
+	default:
+		return clause.OnConflict{}, fmt.Errorf("%s: invalid conflict target %v: %w", op, reflect.TypeOf(opts.WithOnConflict.Target), ErrInvalidParameter)
+	}
+
+	action := opts.WithOnConflict.Action
+	if changed, ok := action.(ChangedColumns); ok {
+		resource := changed.resource
+		if isNil(resource) {
+			resource = i
+		}
+		columns, err := rw.changedColumns(ctx, i, resource)
+		if err != nil {
+			return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+		}
+		action = SetColumns(columns)
+	}
+	if len(opts.WithConflictUpdateColumnsFromFieldMask) > 0 {
+		columns, err := rw.fieldMaskColumns(i, opts.WithConflictUpdateColumnsFromFieldMask)
+		if err != nil {
+			return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+		}
+		switch a := action.(type) {
+		case nil:
+			action = SetColumns(columns)
+		case []ColumnValue:
+			action = append(a, SetColumns(columns)...)
+		default:
+			return clause.OnConflict{}, fmt.Errorf("%s: conflict action %v cannot be used with field mask columns: %w", op, reflect.TypeOf(action), ErrInvalidParameter)
+		}
+	}
+
+	if a, ok := action.([]ColumnValue); ok && opts.WithConflictUpdateTimestamp {
+		columnValues, err := rw.withUpdateTimestamp(i, a, opts)
+		if err != nil {
+			return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+		}
+		action = columnValues
+	}
+
+	if opts.WithConflictVersionCheck != nil {
+		a, ok := action.([]ColumnValue)
+		if !ok {
+			return clause.OnConflict{}, fmt.Errorf("%s: conflict version check requires a []ColumnValue conflict action, not %v: %w", op, reflect.TypeOf(action), ErrInvalidParameter)
+		}
+		action = IncrementVersion(a)
+	}
+
+	switch action.(type) {
+	case DoNothing:
+		c.DoNothing = true
+	case UpdateAll:
+		c.UpdateAll = true
+	case DeleteExisting:
+		return clause.OnConflict{}, fmt.Errorf("%s: conflict action %v is only supported by RW.Create: %w", op, reflect.TypeOf(action), ErrInvalidParameter)
+	case []ColumnValue:
+		updates, err := mergeColumnValues(action.([]ColumnValue), opts.WithConflictOverride)
+		if err != nil {
+			return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+		}
+		set := make(clause.Set, 0, len(updates))
+		for _, s := range updates {
+			// make sure it's not one of the std immutable columns
+			if contains([]string{"createtime", "publicid"}, strings.ToLower(s.Column)) {
+				return clause.OnConflict{}, fmt.Errorf("%s: cannot do update on conflict for column %s: %w", op, s.Column, ErrInvalidParameter)
+			}
+			switch sv := s.Value.(type) {
+			case Column:
+				set = append(set, sv.toAssignment(s.Column))
+			case ExprValue:
+				set = append(set, sv.toAssignment(s.Column))
+			default:
+				set = append(set, rawAssignment(s.Column, s.Value))
+			}
+		}
+		c.DoUpdates = set
+	default:
+		return clause.OnConflict{}, fmt.Errorf("%s: invalid conflict action %v: %w", op, reflect.TypeOf(action), ErrInvalidParameter)
+	}
+	if opts.WithVersion != nil || opts.WithWhereClause != "" {
+		where, args, err := rw.whereClausesFromOpts(ctx, i, opts)
+		if err != nil {
+			return clause.OnConflict{}, fmt.Errorf("%s: %w", op, err)
+		}
+		whereConditions := db.Statement.BuildCondition(where, args...)
+		c.Where = clause.Where{Exprs: whereConditions}
+	}
+	return c, nil
+}
+
+// withUpdateTimestamp returns the column values with an assignment of the
+// current timestamp to the resource's update time column appended, unless the
+// resource doesn't have the column or the column values already assign it
+// (see: WithConflictUpdateTimestamp).
+func (rw *RW) withUpdateTimestamp(i interface{}, columnValues []ColumnValue, opts Options) ([]ColumnValue, error) {
+	const op = "dbw.withUpdateTimestamp"
+	s, _, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	_, updateTimeColumn := rw.timeColumns(opts)
+	if _, ok := s.FieldsByDBName[updateTimeColumn]; !ok {
+		return columnValues, nil
+	}
+	for _, cv := range columnValues {
+		if strings.EqualFold(cv.Column, updateTimeColumn) {
+			return columnValues, nil
+		}
+	}
+	assignments := make([]ColumnValue, 0, len(columnValues)+1)
+	assignments = append(assignments, columnValues...)
+	return append(assignments, ColumnValue{Column: updateTimeColumn, Value: Expr("current_timestamp")}), nil
+}
+
+// debugOnConflict will log the on conflict target and action of the db's
+// statement along with the insert statement for the resource(s) i (see:
+// WithConflictDebug).  The insert statement is rendered using a dry run, so
+// it's not executed.  It's logged using the DB's hclog (see: WithLogger),
+// since the hclog's gorm logger only logs database errors, otherwise it's
+// logged using the db's gorm logger.
+func (rw *RW) debugOnConflict(ctx context.Context, db *gorm.DB, i interface{}) {
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Create(i)
+	})
+	target, action := "unknown", "unknown"
+	if cl, ok := db.Statement.Clauses[clause.OnConflict{}.Name()]; ok {
+		if c, ok := cl.Expression.(clause.OnConflict); ok {
+			target, action = describeOnConflict(db, c)
+		}
+	}
+	const format = "on conflict target: %s, action: %s, sql: %s"
+	if l := rw.underlying.logger; l != nil {
+		if _, ok := l.(LogWriter); !ok {
+			l.Info(fmt.Sprintf(format, target, action, sql))
+			return
+		}
+	}
+	db.Logger.LogMode(logger.Info).Info(ctx, format, target, action, sql)
+}
+
+// describeOnConflict returns a description of the on conflict clause's target
+// and action.  Any action's assignments and where clause are rendered with
+// their values.
+func describeOnConflict(db *gorm.DB, c clause.OnConflict) (target string, action string) {
+	render := func(e clause.Expression) string {
+		stmt := &gorm.Statement{DB: db, Clauses: map[string]clause.Clause{}}
+		e.Build(stmt)
+		return db.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+	}
+	switch {
+	case c.OnConstraint != "":
+		target = "constraint " + c.OnConstraint
+	default:
+		names := make([]string, 0, len(c.Columns))
+		for _, col := range c.Columns {
+			names = append(names, col.Name)
+		}
+		target = "columns (" + strings.Join(names, ", ") + ")"
+	}
+	switch {
+	case c.DoNothing:
+		action = "do nothing"
+	case c.UpdateAll:
+		action = "update all"
+	default:
+		action = "do update set " + render(c.DoUpdates)
+	}
+	if len(c.Where.Exprs) > 0 {
+		action += " where " + render(clause.AndConditions{Exprs: c.Where.Exprs})
+	}
+	return target, action
+}
+
+// deleteExisting will delete any existing record which conflicts with the
+// resource (using the on conflict Columns target), so the resource can then be
+// inserted without an on conflict clause.  WithVersion and WithWhere are added
+// as constraints to the delete.  Unless the rw is already in a transaction, the
+// delete is executed within a new transaction which is returned, and the
+// caller must commit or rollback it after the insert.  Otherwise, the rw is
+// returned.
+func (rw *RW) deleteExisting(ctx context.Context, i interface{}, opts Options) (*RW, error) {
+	const op = "dbw.deleteExisting"
+	if opts.WithConflictVersionCheck != nil {
+		return nil, fmt.Errorf("%s: conflict version check is not supported by the delete existing action: %w", op, ErrInvalidParameter)
+	}
+	target, ok := opts.WithOnConflict.Target.(Columns)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid conflict target %v for delete existing action: %w", op, reflect.TypeOf(opts.WithOnConflict.Target), ErrInvalidParameter)
+	}
+	if err := rw.validateConflictTarget(ctx, i, target, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	where := make([]string, 0, len(target))
+	args := make([]interface{}, 0, len(target))
+	for _, col := range target {
+		f := s.LookUpField(col)
+		if f == nil {
+			return nil, fmt.Errorf("%s: conflict target column %s not found in resource: %w", op, col, ErrInvalidParameter)
 		}
+		v, _ := f.ValueOf(ctx, reflect.ValueOf(i))
+		where = append(where, fmt.Sprintf("%s = ?", rw.underlying.Quote(f.DBName)))
+		args = append(args, v)
+	}
+	if opts.WithVersion != nil || opts.WithWhereClause != "" {
+		optsWhere, optsArgs, err := rw.whereClausesFromOpts(ctx, i, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		where, args = append(where, optsWhere), append(args, optsArgs...)
+	}
+
+	if rw.IsTx() {
+		if err := rw.execDeleteExisting(ctx, tableName, strings.Join(where, " and "), args, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return rw, nil
+	}
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.execDeleteExisting(ctx, tableName, strings.Join(where, " and "), args, opts); err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return nil, fmt.Errorf("%s: %w", op, errors.Join(err, rollbackErr))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tx, nil
+}
+
+// execDeleteExisting will execute the delete of the DeleteExisting conflict
+// action.  A MERGE ... WHEN MATCHED THEN DELETE is used when the database
+// supports it (see: supportsMerge) and a DELETE is used otherwise.
+func (rw *RW) execDeleteExisting(ctx context.Context, tableName, where string, args []interface{}, opts Options) error {
+	const op = "dbw.execDeleteExisting"
+	merge, err := rw.supportsMerge(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	table := rw.underlying.Quote(tableName)
+	sql := fmt.Sprintf("delete from %s where %s", table, where)
+	if merge {
+		sql = fmt.Sprintf("merge into %s using (select 1) as dbw_source on %s when matched then delete", table, where)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	if err := db.Exec(sql, args...).Error; err != nil {
+		return fmt.Errorf("%s: delete existing failed: %w", op, err)
 	}
 	return nil
 }
 
+// serverVersionNumKey is the catalog cache key of the postgres server's
+// version number (see: supportsMerge).
+type serverVersionNumKey struct{}
+
+// supportsMerge returns true if the database supports MERGE, which requires
+// postgres 15+.  The server's version number is cached, since it's checked by
+// every DeleteExisting conflict action.
+func (rw *RW) supportsMerge(ctx context.Context) (bool, error) {
+	const op = "dbw.supportsMerge"
+	const minVersionNum = 150000
+	typ, _, err := rw.underlying.DbType()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if typ != Postgres {
+		return false, nil
+	}
+	cache := rw.underlying.catalogCache
+	if cache != nil {
+		if versionNum, ok := cache.Load(serverVersionNumKey{}); ok {
+			return versionNum.(int) >= minVersionNum, nil
+		}
+	}
+	var raw string
+	if err := rw.underlying.wrapped.WithContext(ctx).Raw("show server_version_num").Scan(&raw).Error; err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	versionNum, err := strconv.Atoi(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid server version num %q: %w", op, raw, ErrUnknown)
+	}
+	if cache != nil {
+		cache.Store(serverVersionNumKey{}, versionNum)
+	}
+	return versionNum >= minVersionNum, nil
+}
+
+// fieldMaskColumns translates the field mask paths into column names using the
+// resource's schema.  Non-updatable fields are filtered out of the paths.
+func (rw *RW) fieldMaskColumns(i interface{}, fieldMaskPaths []string) ([]string, error) {
+	const op = "dbw.fieldMaskColumns"
+	mDb := rw.underlying.wrapped.Model(i)
+	if err := mDb.Statement.Parse(i); err != nil || mDb.Statement.Schema == nil {
+		return nil, fmt.Errorf("%s: (internal error) unable to parse stmt: %w", op, ErrUnknown)
+	}
+	paths := filterPaths(fieldMaskPaths)
+	columns := make([]string, 0, len(paths))
+	for _, p := range paths {
+		f := mDb.Statement.Schema.LookUpField(p)
+		if f == nil {
+			for _, sf := range mDb.Statement.Schema.Fields {
+				if strings.EqualFold(sf.Name, p) || strings.EqualFold(sf.DBName, p) {
+					f = sf
+					break
+				}
+			}
+		}
+		if f == nil || f.DBName == "" {
+			return nil, fmt.Errorf("%s: field mask path %s not found in resource: %w", op, p, ErrInvalidFieldMask)
+		}
+		columns = append(columns, f.DBName)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s: after filtering non-updated fields, there are no field mask paths left: %w", op, ErrInvalidFieldMask)
+	}
+	return columns, nil
+}
+
+// changedColumns returns the columns of the resource which are non-zero,
+// excluding its primary keys, fields which are not updatable and any
+// non-updatable fields (see: NonUpdatableFields).  The resource must be the
+// same type as the resource i which is being inserted.
+func (rw *RW) changedColumns(ctx context.Context, i, resource interface{}) ([]string, error) {
+	const op = "dbw.changedColumns"
+	if reflect.TypeOf(resource) != reflect.TypeOf(i) {
+		return nil, fmt.Errorf("%s: changed columns resource %v is not a %v: %w", op, reflect.TypeOf(resource), reflect.TypeOf(i), ErrInvalidParameter)
+	}
+	mDb := rw.underlying.wrapped.Model(resource)
+	if err := mDb.Statement.Parse(resource); err != nil || mDb.Statement.Schema == nil {
+		return nil, fmt.Errorf("%s: (internal error) unable to parse stmt: %w", op, ErrUnknown)
+	}
+	nonUpdatable := NonUpdatableFields()
+	rv := reflect.ValueOf(resource)
+	var columns []string
+	for _, f := range mDb.Statement.Schema.Fields {
+		if f.DBName == "" || f.PrimaryKey || !f.Creatable || !f.Updatable || contains(nonUpdatable, f.Name) {
+			continue
+		}
+		if _, isZero := f.ValueOf(ctx, rv); isZero {
+			continue
+		}
+		columns = append(columns, f.DBName)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s: changed columns resource has no changed columns: %w", op, ErrInvalidParameter)
+	}
+	return columns, nil
+}
+
+// mergeColumnValues will merge on conflict column assignments which reference
+// the same column (case-insensitive). Assignments from SetColumnValues(...)
+// take precedence over assignments from SetColumns(...) for the same column,
+// however, merging assignments with conflicting intent is only allowed when
+// override is true; otherwise an ErrInvalidParameter is returned.  When
+// several SetColumnValues(...) assignments reference the same column, the last
+// one wins.  Identical assignments are simply deduplicated.  The merged
+// assignments retain the position of each column's first occurrence.
+func mergeColumnValues(columnValues []ColumnValue, override bool) ([]ColumnValue, error) {
+	const op = "dbw.mergeColumnValues"
+	merged := make([]ColumnValue, 0, len(columnValues))
+	idx := make(map[string]int, len(columnValues))
+	for _, cv := range columnValues {
+		key := strings.ToLower(cv.Column)
+		pos, found := idx[key]
+		if !found {
+			idx[key] = len(merged)
+			merged = append(merged, cv)
+			continue
+		}
+		existing := merged[pos]
+		switch {
+		case reflect.DeepEqual(existing.Value, cv.Value), isExcludedColumn(existing) && isExcludedColumn(cv):
+			continue
+		case !override:
+			return nil, fmt.Errorf("%s: column %s specified more than once with conflicting values: %w", op, cv.Column, ErrInvalidParameter)
+		case isExcludedColumn(cv) && !isExcludedColumn(existing):
+			// SetColumnValues takes precedence over SetColumns
+			continue
+		default:
+			merged[pos] = cv
+		}
+	}
+	return merged, nil
+}
+
+// isExcludedColumn returns true if the column value was created via
+// SetColumns(...), which assigns the proposed insert value to the column.
+func isExcludedColumn(cv ColumnValue) bool {
+	c, ok := cv.Value.(Column)
+	return ok && strings.EqualFold(c.Table, "excluded") && strings.EqualFold(c.Name, cv.Column)
+}
+
 func setFieldsToNil(i interface{}, fieldNames []string) {
 	// Note: error cases are not handled
 	_ = Clear(i, fieldNames, 2)
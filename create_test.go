@@ -9,10 +9,14 @@ import (
 	"errors"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-dbw"
 	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
@@ -47,6 +51,42 @@ func TestDb_Create(t *testing.T) {
 		require.NoError(err)
 		assert.Equal(foundUser.PublicId, user.PublicId)
 	})
+	t.Run("dry-run", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		w := dbw.New(db)
+		id, err := dbw.NewId("u")
+		require.NoError(err)
+		user, err := dbtest.NewTestUser()
+		require.NoError(err)
+		user.Name = "alice-" + id
+		var afterWriteCalled bool
+		var sql string
+		err = w.Create(testCtx, user,
+			dbw.WithDryRun(&sql),
+			dbw.WithAfterWrite(func(interface{}, int) error { afterWriteCalled = true; return nil }),
+		)
+		require.NoError(err)
+		assert.False(afterWriteCalled)
+		assert.Contains(strings.ToLower(sql), "insert into")
+		assert.Contains(sql, "db_test_user")
+		assert.Contains(sql, user.PublicId)
+		assert.Contains(sql, user.Name)
+		assert.NotContains(sql, "?")
+
+		// the user was not created
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = user.PublicId
+		err = w.LookupByPublicId(testCtx, &foundUser)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		// validation still runs
+		sql = ""
+		err = w.Create(testCtx, &dbtest.TestWithAfterCreate{}, dbw.WithDryRun(&sql))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Empty(sql)
+	})
 	t.Run("WithBeforeWrite", func(t *testing.T) {
 		assert, require := assert.New(t), require.New(t)
 		w := dbw.New(db)
@@ -222,10 +262,268 @@ func TestDb_Create(t *testing.T) {
 	})
 }
 
-func TestDb_Create_OnConflict(t *testing.T) {
+func TestDb_Create_ReturningColumns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	upsert := func(t *testing.T, existing *dbtest.TestUser, opt ...dbw.Option) *dbtest.TestUser {
+		t.Helper()
+		require := require.New(t)
+		u, err := dbtest.NewTestUser()
+		require.NoError(err)
+		u.PublicId = existing.PublicId
+		u.Name = existing.Name + "-upserted"
+		u.Email = "submitted@example.com"
+		opt = append(opt, dbw.WithOnConflict(&dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.SetColumns([]string{"name"}),
+		}))
+		require.NoError(rw.Create(ctx, u, opt...))
+		return u
+	}
+
+	t.Run("upsert", func(t *testing.T) {
+		assert := assert.New(t)
+		existing := testUser(t, rw, "returning-columns", "existing@example.com", "")
+		u := upsert(t, existing, dbw.WithReturningColumns([]string{"public_id", "version"}))
+		assert.Equal(existing.PublicId, u.PublicId)
+		// the version is refreshed from its submitted zero value
+		assert.NotZero(u.Version)
+		// the other fields retain their submitted values
+		assert.Equal("submitted@example.com", u.Email)
+		assert.Nil(u.CreateTime)
+	})
+	t.Run("default", func(t *testing.T) {
+		assert := assert.New(t)
+		existing := testUser(t, rw, "returning-columns-default", "existing@example.com", "")
+		u := upsert(t, existing)
+		assert.NotZero(u.Version)
+		// by default, the columns with database defaults are refreshed
+		assert.Equal("existing@example.com", u.Email)
+		assert.NotNil(u.CreateTime)
+	})
+	t.Run("create-items", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var items []*dbtest.TestUser
+		for i := 0; i < 2; i++ {
+			u := testUser(t, nil, "returning-columns-item-"+strconv.Itoa(i), "", "")
+			items = append(items, u)
+		}
+		require.NoError(rw.CreateItems(ctx, items, dbw.WithReturningColumns([]string{"version"})))
+		for _, u := range items {
+			assert.Equal(uint32(1), u.Version)
+			assert.Nil(u.CreateTime)
+		}
+	})
+	t.Run("unknown-column", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		u := testUser(t, nil, "returning-columns-unknown", "", "")
+		err := rw.Create(ctx, u, dbw.WithReturningColumns([]string{"public_id", "not_a_column"}))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "unknown returning column not_a_column")
+
+		err = rw.CreateItems(ctx, []*dbtest.TestUser{u}, dbw.WithReturningColumns([]string{"not_a_column"}))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+	})
+}
+
+// testTwoUniqueKeysModel has a primary key and a unique column, so its conflict
+// target is ambiguous.
+type testTwoUniqueKeysModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Email    string `gorm:"unique"`
+}
+
+func (*testTwoUniqueKeysModel) TableName() string { return "db_test_two_unique_keys" }
+
+func TestDb_Create_Upsert(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	existing := testUser(t, rw, "upsert", "", "")
+
+	t.Run("single-unique-key", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = existing.PublicId
+		conflictUser.Name = "upsert-updated"
+		var rowsAffected int64
+		err = rw.Create(ctx, conflictUser, dbw.WithUpsert(dbw.UpdateAll(true)), dbw.WithReturnRowsAffected(&rowsAffected))
+		require.NoError(err)
+		assert.Equal(int64(1), rowsAffected)
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = existing.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, &found))
+		assert.Equal("upsert-updated", found.Name)
+	})
+	t.Run("create-items", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = existing.PublicId
+		conflictUser.Name = "upsert-ignored"
+		newUser := testUser(t, nil, "upsert-new", "", "")
+		err = rw.CreateItems(ctx, []*dbtest.TestUser{conflictUser, newUser}, dbw.WithUpsert(dbw.DoNothing(true)))
+		require.NoError(err)
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = existing.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, &found))
+		assert.Equal("upsert-updated", found.Name)
+		found = dbtest.AllocTestUser()
+		found.PublicId = newUser.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, &found))
+		assert.Equal("upsert-new", found.Name)
+	})
+	t.Run("multiple-unique-keys", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		err := rw.Create(ctx, &testTwoUniqueKeysModel{PublicId: "1", Email: "alice@example.com"}, dbw.WithUpsert(dbw.DoNothing(true)))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "has 2 unique keys, use WithOnConflict with an explicit target")
+	})
+}
+
+func TestDb_Create_IgnoreConflictOn(t *testing.T) {
+	t.Parallel()
 	ctx := context.Background()
 	conn, _ := dbw.TestSetup(t)
 	rw := dbw.New(conn)
+	existing := testUser(t, rw, "ignore-conflict-on", "", "")
+
+	t.Run("ignored", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = existing.PublicId
+		conflictUser.Name = "ignore-conflict-on-ignored"
+		var rowsAffected int64
+		err = rw.Create(ctx, conflictUser, dbw.WithIgnoreConflictOn("public_id"), dbw.WithReturnRowsAffected(&rowsAffected))
+		require.NoError(err)
+		assert.Equal(int64(0), rowsAffected)
+
+		found, err := dbtest.NewTestUser()
+		require.NoError(err)
+		found.PublicId = existing.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, found))
+		assert.Equal(existing.Name, found.Name)
+	})
+	t.Run("other-constraint-errors", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.Name = existing.Name
+		err = rw.Create(ctx, conflictUser, dbw.WithIgnoreConflictOn("public_id"))
+		require.Error(err)
+		assert.NotErrorIs(err, dbw.ErrInvalidParameter)
+	})
+	t.Run("no-unique-index", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		err = rw.Create(ctx, conflictUser, dbw.WithIgnoreConflictOn("email"))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "no unique index on (email)")
+	})
+}
+
+func TestDb_Create_NoDatabaseSideEffects(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	ctx := context.Background()
+	conn, url := dbw.TestSetup(t)
+	dbType, _, err := conn.DbType()
+	require.NoError(err)
+	buf := new(strings.Builder)
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Mutex:  &sync.Mutex{},
+		Name:   "test",
+		Output: buf,
+		Level:  hclog.Debug,
+	})
+	debugConn, err := dbw.Open(dbType, url, dbw.WithLogger(gormDebugLogger{Logger: testLogger}))
+	require.NoError(err)
+	dbw.TestCreateTables(t, debugConn)
+	debugConn.Debug(true)
+	rw := dbw.New(debugConn)
+	selectCount := func() int {
+		return strings.Count(strings.ToLower(buf.String()), "select")
+	}
+
+	user, err := dbtest.NewTestUser()
+	require.NoError(err)
+	user.Name = "side-effects"
+	buf.Reset()
+	require.NoError(rw.Create(ctx, user, dbw.WithLookup(true)))
+	assert.Equal(1, selectCount(), "create with lookup should select the user")
+
+	user, err = dbtest.NewTestUser()
+	require.NoError(err)
+	user.Name = "no-side-effects"
+	buf.Reset()
+	require.NoError(rw.Create(ctx, user, dbw.WithLookup(true), dbw.WithNoDatabaseSideEffects(true)))
+	assert.Equal(0, selectCount(), "create should not select the user")
+	assert.Equal("no-side-effects", user.Name)
+
+	user.Name = "updated-no-side-effects"
+	buf.Reset()
+	rowsUpdated, err := rw.Update(ctx, user, []string{"Name"}, nil, dbw.WithNoDatabaseSideEffects(true))
+	require.NoError(err)
+	assert.Equal(1, rowsUpdated)
+	assert.Equal(0, selectCount(), "update should not select the user")
+	assert.Equal("updated-no-side-effects", user.Name)
+
+	buf.Reset()
+	_, err = rw.Update(ctx, user, []string{"Name"}, nil)
+	require.NoError(err)
+	assert.Equal(1, selectCount(), "update should select the user")
+
+	found, err := dbtest.NewTestUser()
+	require.NoError(err)
+	found.PublicId = user.PublicId
+	require.NoError(rw.LookupByPublicId(ctx, found))
+	assert.Equal("updated-no-side-effects", found.Name)
+}
+
+func BenchmarkDb_Create_NoDatabaseSideEffects(b *testing.B) {
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(b, err)
+	rw := dbw.New(conn)
+	_, err = rw.Exec(ctx, "create table bench_user (public_id text primary key, name text)", nil)
+	require.NoError(b, err)
+
+	type benchUser struct {
+		PublicId string `gorm:"primaryKey"`
+		Name     string
+	}
+	for _, noSideEffects := range []bool{false, true} {
+		b.Run("no-side-effects-"+strconv.FormatBool(noSideEffects), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				id, err := dbw.NewId("u")
+				require.NoError(b, err)
+				err = rw.Create(ctx, &benchUser{PublicId: id, Name: "bench"},
+					dbw.WithTable("bench_user"),
+					dbw.WithLookup(true),
+					dbw.WithNoDatabaseSideEffects(noSideEffects),
+				)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func TestDb_Create_OnConflict(t *testing.T) {
+	ctx := context.Background()
+	conn, url := dbw.TestSetup(t)
+	rw := dbw.New(conn)
 	dbType, _, err := conn.DbType()
 	require.NoError(t, err)
 
@@ -310,6 +608,84 @@ func TestDb_Create_OnConflict(t *testing.T) {
 			wantUpdate: true,
 			wantEmail:  "alice@gmail.com",
 		},
+		{
+			name: "overlapping-column-without-override",
+			onConflict: func() dbw.OnConflict {
+				onConflict := dbw.OnConflict{
+					Target: dbw.Columns{"public_id"},
+				}
+				cv := dbw.SetColumns([]string{"name", "email"})
+				cv = append(cv,
+					dbw.SetColumnValues(map[string]interface{}{
+						"email": "alice@gmail.com",
+					})...)
+				onConflict.Action = cv
+				return onConflict
+			}(),
+			wantErrContains: "dbw.Create: dbw.onConflictClause: dbw.mergeColumnValues: column email specified more than once with conflicting values: invalid parameter",
+		},
+		{
+			name: "overlapping-column-with-override",
+			onConflict: func() dbw.OnConflict {
+				onConflict := dbw.OnConflict{
+					Target: dbw.Columns{"public_id"},
+				}
+				cv := dbw.SetColumnValues(map[string]interface{}{
+					"email": "alice@gmail.com",
+				})
+				cv = append(cv, dbw.SetColumns([]string{"name", "email"})...)
+				onConflict.Action = cv
+				return onConflict
+			}(),
+			additionalOpts: []dbw.Option{dbw.WithConflictOverride(true)},
+			wantUpdate:     true,
+			wantEmail:      "alice@gmail.com",
+		},
+		{
+			name: "column-target-without-unique-index",
+			onConflict: dbw.OnConflict{
+				Target: dbw.Columns{"email"},
+				Action: dbw.SetColumns([]string{"name"}),
+			},
+			wantErrContains: "dbw.Create: dbw.onConflictClause: dbw.validateConflictTarget: no unique index on (email) for ON CONFLICT: invalid parameter",
+		},
+		{
+			name: "field-mask-columns",
+			onConflict: dbw.OnConflict{
+				Target: dbw.Columns{"public_id"},
+			},
+			additionalOpts: []dbw.Option{dbw.WithConflictUpdateColumnsFromFieldMask([]string{"Name", "CreateTime"})},
+			wantUpdate:     true,
+		},
+		{
+			name: "field-mask-columns-with-column-values",
+			onConflict: dbw.OnConflict{
+				Target: dbw.Columns{"public_id"},
+				Action: dbw.SetColumnValues(map[string]interface{}{
+					"email": "alice@gmail.com",
+				}),
+			},
+			additionalOpts: []dbw.Option{dbw.WithConflictUpdateColumnsFromFieldMask([]string{"name"})},
+			wantUpdate:     true,
+			wantEmail:      "alice@gmail.com",
+		},
+		{
+			name: "field-mask-columns-invalid-path",
+			onConflict: dbw.OnConflict{
+				Target: dbw.Columns{"public_id"},
+			},
+			additionalOpts:  []dbw.Option{dbw.WithConflictUpdateColumnsFromFieldMask([]string{"NotAField"})},
+			wantErrContains: "dbw.Create: dbw.onConflictClause: dbw.fieldMaskColumns: field mask path NotAField not found in resource: invalid field mask",
+		},
+		{
+			name: "field-mask-columns-invalid-action",
+			onConflict: dbw.OnConflict{
+				Target: dbw.Columns{"public_id"},
+				Action: dbw.DoNothing(true),
+			},
+			additionalOpts:  []dbw.Option{dbw.WithConflictUpdateColumnsFromFieldMask([]string{"Name"})},
+			wantErrContains: "dbw.Create: dbw.onConflictClause: conflict action dbw.DoNothing cannot be used with field mask columns: invalid parameter",
+		},
 		{
 			name: "do-nothing",
 			onConflict: dbw.OnConflict{
@@ -350,7 +726,7 @@ func TestDb_Create_OnConflict(t *testing.T) {
 				Target: dbw.Columns{"public_id"},
 				Action: dbw.SetColumns([]string{"name"}),
 			},
-			additionalOpts: []dbw.Option{dbw.WithVersion(func() *uint32 { i := uint32(1); return &i }())},
+			additionalOpts: []dbw.Option{dbw.WithConflictVersionCheck(1)},
 			wantUpdate:     true,
 		},
 		{
@@ -359,7 +735,7 @@ func TestDb_Create_OnConflict(t *testing.T) {
 				Target: dbw.Columns{"public_id"},
 				Action: dbw.SetColumns([]string{"name"}),
 			},
-			additionalOpts: []dbw.Option{dbw.WithWhere("db_test_user.version = ?", 100000000000)},
+			additionalOpts: []dbw.Option{dbw.WithConflictVersionCheck(100000)},
 			wantUpdate:     false,
 		},
 	}
@@ -417,6 +793,176 @@ func TestDb_Create_OnConflict(t *testing.T) {
 			}
 		})
 	}
+	t.Run("delete-existing", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		initialUser := createInitialUser()
+		_, err := rw.Exec(ctx, "update db_test_user set email = ? where public_id = ?", []interface{}{"alice@gmail.com", initialUser.PublicId})
+		require.NoError(err)
+
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = initialUser.PublicId
+		conflictUser.Name, err = dbw.NewId("test-user-name")
+		require.NoError(err)
+		var rowsAffected int64
+		onConflict := dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.DeleteExisting(true),
+		}
+		err = rw.Create(ctx, conflictUser, dbw.WithOnConflict(&onConflict), dbw.WithReturnRowsAffected(&rowsAffected), dbw.WithLookup(true))
+		require.NoError(err)
+		assert.Equal(int64(1), rowsAffected)
+
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = initialUser.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, &foundUser))
+		assert.Equal(conflictUser.Name, foundUser.Name)
+		// the existing row was deleted, so the email is not retained
+		assert.Empty(foundUser.Email)
+	})
+	t.Run("delete-existing-in-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		initialUser := createInitialUser()
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = initialUser.PublicId
+		conflictUser.Name, err = dbw.NewId("test-user-name")
+		require.NoError(err)
+		onConflict := dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.DeleteExisting(true),
+		}
+		tx, err := rw.Begin(ctx)
+		require.NoError(err)
+		require.NoError(tx.Create(ctx, conflictUser, dbw.WithOnConflict(&onConflict)))
+		require.NoError(tx.Rollback(ctx))
+
+		// the rollback must restore the existing row
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = initialUser.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, &foundUser))
+		assert.Equal(initialUser.Name, foundUser.Name)
+	})
+	t.Run("delete-existing-with-version-fail", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		initialUser := createInitialUser()
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = initialUser.PublicId
+		onConflict := dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.DeleteExisting(true),
+		}
+		version := uint32(1000)
+		err = rw.Create(ctx, conflictUser, dbw.WithOnConflict(&onConflict), dbw.WithVersion(&version))
+		require.Error(err)
+
+		// the failed insert must rollback the delete
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = initialUser.PublicId
+		require.NoError(rw.LookupByPublicId(ctx, &foundUser))
+		assert.Equal(initialUser.Name, foundUser.Name)
+	})
+	t.Run("delete-existing-constraint-target", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		user, err := dbtest.NewTestUser()
+		require.NoError(err)
+		onConflict := dbw.OnConflict{
+			Target: dbw.Constraint("db_test_user_pkey"),
+			Action: dbw.DeleteExisting(true),
+		}
+		err = rw.Create(ctx, user, dbw.WithOnConflict(&onConflict))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "invalid conflict target dbw.Constraint for delete existing action")
+	})
+	t.Run("delete-existing-vets-once", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := rw.Exec(ctx, "create table if not exists db_test_vet_count (public_id text primary key, name text not null)", nil)
+		require.NoError(err)
+		require.NoError(rw.Create(ctx, &testVetCountModel{PublicId: "1", Name: "alice"}))
+
+		m := &testVetCountModel{PublicId: "1", Name: "bob"}
+		onConflict := dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.DeleteExisting(true),
+		}
+		require.NoError(rw.Create(ctx, m, dbw.WithOnConflict(&onConflict)))
+		assert.Equal(1, m.vetCount)
+
+		found := &testVetCountModel{PublicId: "1"}
+		require.NoError(rw.LookupBy(ctx, found))
+		assert.Equal("bob", found.Name)
+	})
+	t.Run("conflict-debug", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		buf := new(strings.Builder)
+		testLogger := hclog.New(&hclog.LoggerOptions{
+			Mutex:  &sync.Mutex{},
+			Name:   "test",
+			Output: buf,
+			Level:  hclog.Debug,
+		})
+		// the hclog is wrapped by the default gorm logger, which only logs
+		// database errors
+		debugConn, err := dbw.Open(dbType, url, dbw.WithLogger(testLogger))
+		require.NoError(err)
+		dbw.TestCreateTables(t, debugConn)
+		debugRw := dbw.New(debugConn)
+
+		initialUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		require.NoError(debugRw.Create(ctx, initialUser))
+		conflictUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		conflictUser.PublicId = initialUser.PublicId
+		onConflict := dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.SetColumns([]string{"name"}),
+		}
+
+		// without the option, nothing is logged
+		require.NoError(debugRw.Create(ctx, conflictUser, dbw.WithOnConflict(&onConflict)))
+		assert.Empty(buf.String())
+
+		conflictUser.Name = "conflict-debug"
+		var rowsAffected int64
+		err = debugRw.Create(ctx, conflictUser,
+			dbw.WithOnConflict(&onConflict),
+			dbw.WithWhere("db_test_user.version = ?", 100000000000),
+			dbw.WithReturnRowsAffected(&rowsAffected),
+			dbw.WithConflictDebug(true),
+		)
+		require.NoError(err)
+		assert.Equal(int64(0), rowsAffected)
+		got := buf.String()
+		t.Log(got)
+		assert.Contains(got, "on conflict target: columns (public_id)")
+		assert.Contains(got, "action: do update set")
+		assert.Contains(got, "where db_test_user.version = 100000000000")
+		assert.Contains(strings.ToLower(got), "on conflict (")
+		assert.Contains(got, "conflict-debug")
+
+		// the dry run didn't change the existing user
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = initialUser.PublicId
+		require.NoError(debugRw.LookupByPublicId(ctx, &foundUser))
+		assert.Equal(initialUser.Name, foundUser.Name)
+
+		// operations without an on conflict aren't logged
+		buf.Reset()
+		newUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		require.NoError(debugRw.Create(ctx, newUser, dbw.WithConflictDebug(true)))
+		assert.Empty(buf.String())
+
+		// a gorm LogWriter logs it using the gorm logger
+		writerConn, err := dbw.Open(dbType, url, dbw.WithLogger(gormDebugLogger{Logger: testLogger}))
+		require.NoError(err)
+		dbw.TestCreateTables(t, writerConn)
+		require.NoError(dbw.New(writerConn).Create(ctx, conflictUser, dbw.WithOnConflict(&onConflict), dbw.WithConflictDebug(true)))
+		assert.Contains(buf.String(), "on conflict target: columns (public_id)")
+	})
 	t.Run("update-all", func(t *testing.T) {
 		// for now, let's just deal with postgres, since all dialects are a
 		// bit diff when it comes to auto-incremented pks.  Also, gorm currently
@@ -794,6 +1340,15 @@ func TestDb_CreateItems_OnConflict(t *testing.T) {
 			setup:           createOnConflictUsers,
 			wantErrContains: "dbw.CreateItems: invalid conflict target string: invalid parameter",
 		},
+		{
+			name: "delete-existing-not-supported",
+			onConflict: dbw.OnConflict{
+				Target: dbw.Columns{"public_id"},
+				Action: dbw.DeleteExisting(true),
+			},
+			setup:           createOnConflictUsers,
+			wantErrContains: "dbw.CreateItems: conflict action dbw.DeleteExisting is only supported by RW.Create: invalid parameter",
+		},
 		{
 			name: "with-version-success",
 			onConflict: dbw.OnConflict{
@@ -910,3 +1465,1195 @@ type dbTestUpdateAll struct {
 func (r *dbTestUpdateAll) GetPublicId() string {
 	return r.PublicId
 }
+
+// testStatementCounter is a LogWriter which counts the sql statements logged
+// by gorm.
+type testStatementCounter struct {
+	hclog.Logger
+	mu         sync.Mutex
+	statements []string
+}
+
+func (c *testStatementCounter) Printf(_ string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// gorm's trace logs the sql as the last arg
+	if len(args) > 0 {
+		if sql, ok := args[len(args)-1].(string); ok {
+			c.statements = append(c.statements, sql)
+		}
+	}
+}
+
+func (c *testStatementCounter) reset() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statements := c.statements
+	c.statements = nil
+	return statements
+}
+
+type testUpsertModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Name     string
+}
+
+func (*testUpsertModel) TableName() string { return "db_test_upsert" }
+
+func TestDb_CreateItems_UpsertStatements(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	testCtx := context.Background()
+	const itemCount = 10000
+	counter := &testStatementCounter{Logger: hclog.NewNullLogger()}
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithLogger(counter))
+	require.NoError(err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	conn.LogLevel(dbw.Info)
+	testRw := dbw.New(conn)
+	_, err = testRw.Exec(testCtx, "create table db_test_upsert (public_id text primary key, name text not null)", nil)
+	require.NoError(err)
+
+	items := make([]*testUpsertModel, 0, itemCount)
+	for i := 0; i < itemCount; i++ {
+		items = append(items, &testUpsertModel{PublicId: strconv.Itoa(i), Name: "created"})
+	}
+	require.NoError(testRw.CreateItems(testCtx, items, dbw.WithBatchSize(itemCount)))
+	counter.reset()
+
+	for _, item := range items {
+		item.Name = "upserted"
+	}
+	var rowsAffected int64
+	err = testRw.CreateItems(testCtx, items,
+		dbw.WithBatchSize(itemCount),
+		dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.UpdateAll(true)}),
+		dbw.WithReturnRowsAffected(&rowsAffected),
+	)
+	require.NoError(err)
+	assert.Equal(int64(itemCount), rowsAffected)
+
+	// the upsert is a single insert without any reads after the write
+	statements := counter.reset()
+	require.Len(statements, 1)
+	assert.True(strings.HasPrefix(statements[0], "INSERT INTO"))
+
+	var count int
+	rows, err := testRw.Query(testCtx, "select count(*) from db_test_upsert where name = ?", []interface{}{"upserted"})
+	require.NoError(err)
+	defer rows.Close()
+	require.True(rows.Next())
+	require.NoError(rows.Scan(&count))
+	assert.Equal(itemCount, count)
+}
+
+func TestDb_CreateItems_CreateBatchSize(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const itemCount = 250
+	setup := func(t *testing.T, opt ...dbw.Option) (*dbw.DB, *testStatementCounter) {
+		t.Helper()
+		require := require.New(t)
+		counter := &testStatementCounter{Logger: hclog.NewNullLogger()}
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:", append(opt, dbw.WithLogger(counter))...)
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		_, err = dbw.New(conn).Exec(testCtx, "create table db_test_upsert (public_id text primary key, name text not null)", nil)
+		require.NoError(err)
+		conn.LogLevel(dbw.Info)
+		counter.reset()
+		return conn, counter
+	}
+	newItems := func() []*testUpsertModel {
+		items := make([]*testUpsertModel, 0, itemCount)
+		for i := 0; i < itemCount; i++ {
+			items = append(items, &testUpsertModel{PublicId: strconv.Itoa(i), Name: "created"})
+		}
+		return items
+	}
+	countInserts := func(statements []string) int {
+		var inserts int
+		for _, s := range statements {
+			if strings.HasPrefix(s, "INSERT INTO") {
+				inserts++
+			}
+		}
+		return inserts
+	}
+
+	t.Run("open-default", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, counter := setup(t, dbw.WithCreateBatchSize(100))
+		var rowsAffected int64
+		require.NoError(dbw.New(conn).CreateItems(testCtx, newItems(), dbw.WithReturnRowsAffected(&rowsAffected)))
+		assert.Equal(int64(itemCount), rowsAffected)
+		assert.Equal(3, countInserts(counter.reset()))
+	})
+	t.Run("with-batch-size", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, counter := setup(t, dbw.WithCreateBatchSize(100))
+		require.NoError(dbw.New(conn).CreateItems(testCtx, newItems(), dbw.WithBatchSize(itemCount)))
+		assert.Equal(1, countInserts(counter.reset()))
+	})
+	t.Run("default-options", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, counter := setup(t, dbw.WithCreateBatchSize(100))
+		conn.SetDefaultOptions(dbw.WithBatchSize(50))
+		require.NoError(dbw.New(conn).CreateItems(testCtx, newItems()))
+		assert.Equal(5, countInserts(counter.reset()))
+	})
+	t.Run("negative", func(t *testing.T) {
+		_, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithCreateBatchSize(-1))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "create batch size must not be negative")
+	})
+}
+
+// testConflictModel has a primary key and a unique column, so its rows may
+// conflict on either of them.
+type testConflictModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Email    string `gorm:"unique"`
+	Name     string
+}
+
+func (*testConflictModel) TableName() string { return "db_test_conflict" }
+
+// testVetCountModel counts the calls of its VetForWrite.
+type testVetCountModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Name     string
+	vetCount int
+}
+
+func (*testVetCountModel) TableName() string { return "db_test_vet_count" }
+
+func (m *testVetCountModel) VetForWrite(context.Context, dbw.Reader, dbw.OpType, ...dbw.Option) error {
+	m.vetCount++
+	return nil
+}
+
+func TestDb_CreateItems_OnConflictFunc(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	setup := func(t *testing.T) (*dbw.RW, *testStatementCounter) {
+		t.Helper()
+		require := require.New(t)
+		counter := &testStatementCounter{Logger: hclog.NewNullLogger()}
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithLogger(counter))
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		rw := dbw.New(conn)
+		_, err = rw.Exec(testCtx, "create table db_test_conflict (public_id text primary key, email text not null unique, name text not null)", nil)
+		require.NoError(err)
+		require.NoError(rw.CreateItems(testCtx, []*testConflictModel{
+			{PublicId: "1", Email: "alice@example.com", Name: "alice"},
+			{PublicId: "2", Email: "bob@example.com", Name: "bob"},
+		}))
+		conn.LogLevel(dbw.Info)
+		counter.reset()
+		return rw, counter
+	}
+	byPublicId := &dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.UpdateAll(true)}
+	byEmail := &dbw.OnConflict{Target: dbw.Columns{"email"}, Action: dbw.SetColumns([]string{"name"})}
+	newItems := func() []*testConflictModel {
+		return []*testConflictModel{
+			{PublicId: "1", Email: "alice@example.org", Name: "alice-updated"},
+			{PublicId: "3", Email: "bob@example.com", Name: "bob-updated"},
+			{PublicId: "4", Email: "carol@example.com", Name: "carol"},
+		}
+	}
+	onConflictFn := func(item interface{}) *dbw.OnConflict {
+		switch item.(*testConflictModel).PublicId {
+		case "1":
+			return byPublicId
+		case "3":
+			return byEmail
+		default:
+			return nil
+		}
+	}
+	lookupAll := func(t *testing.T, rw *dbw.RW) []*testConflictModel {
+		t.Helper()
+		var found []*testConflictModel
+		require.NoError(t, rw.SearchWhere(testCtx, &found, "1=1", nil, dbw.WithOrder("public_id")))
+		return found
+	}
+
+	t.Run("uniform-conflict-target", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, _ := setup(t)
+		// the OnConflict applies to all the items, so the item which conflicts
+		// on the email fails the insert
+		err := rw.CreateItems(testCtx, newItems(), dbw.WithOnConflict(byPublicId))
+		require.Error(err)
+		assert.Contains(strings.ToLower(err.Error()), "unique constraint failed")
+	})
+	t.Run("per-item-conflict-targets", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, counter := setup(t)
+		var rowsAffected int64
+		err := rw.CreateItems(testCtx, newItems(), dbw.WithOnConflictFunc(onConflictFn), dbw.WithReturnRowsAffected(&rowsAffected))
+		require.NoError(err)
+		assert.Equal(int64(3), rowsAffected)
+
+		// one insert for each group of items
+		var inserts int
+		for _, s := range counter.reset() {
+			if strings.HasPrefix(s, "INSERT INTO") {
+				inserts++
+			}
+		}
+		assert.Equal(3, inserts)
+
+		assert.Equal([]*testConflictModel{
+			{PublicId: "1", Email: "alice@example.org", Name: "alice-updated"},
+			{PublicId: "2", Email: "bob@example.com", Name: "bob-updated"},
+			{PublicId: "4", Email: "carol@example.com", Name: "carol"},
+		}, lookupAll(t, rw))
+	})
+	t.Run("rollback", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, _ := setup(t)
+		items := newItems()
+		// carol's email conflicts with alice's, without an OnConflict
+		items[2].Email = "alice@example.org"
+		err := rw.CreateItems(testCtx, items, dbw.WithOnConflictFunc(onConflictFn))
+		require.Error(err)
+		assert.Equal([]*testConflictModel{
+			{PublicId: "1", Email: "alice@example.com", Name: "alice"},
+			{PublicId: "2", Email: "bob@example.com", Name: "bob"},
+		}, lookupAll(t, rw))
+	})
+	t.Run("with-on-conflict", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, _ := setup(t)
+		err := rw.CreateItems(testCtx, newItems(), dbw.WithOnConflictFunc(onConflictFn), dbw.WithOnConflict(byPublicId))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "both on conflict and on conflict func options are set")
+	})
+}
+
+func TestDb_Create_UpdateChangedColumns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	onConflict := func(action interface{}) dbw.Option {
+		return dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: action})
+	}
+	lookup := func(t *testing.T, publicId string) *dbtest.TestUser {
+		t.Helper()
+		found := dbtest.AllocTestUser()
+		found.PublicId = publicId
+		require.NoError(t, rw.LookupByPublicId(ctx, &found))
+		return &found
+	}
+
+	t.Run("partial", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "changed-columns", "changed@example.com", "555-1234")
+		partial := testUser(t, nil, "changed-columns-updated", "", "")
+		partial.PublicId = existing.PublicId
+		var rowsAffected int64
+		require.NoError(rw.Create(ctx, partial, onConflict(dbw.UpdateChangedColumns(nil)), dbw.WithReturnRowsAffected(&rowsAffected)))
+		assert.Equal(int64(1), rowsAffected)
+
+		found := lookup(t, existing.PublicId)
+		assert.Equal("changed-columns-updated", found.Name)
+		// the columns which weren't populated aren't updated
+		assert.Equal("changed@example.com", found.Email)
+		assert.Equal("555-1234", found.PhoneNumber)
+	})
+	t.Run("resource", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "changed-columns-resource", "resource@example.com", "555-1234")
+		conflict := testUser(t, nil, "changed-columns-resource-updated", "resource-updated@example.com", "")
+		conflict.PublicId = existing.PublicId
+		// only the columns set in the action's resource are updated, using the
+		// values of the resource being inserted
+		changes := &dbtest.TestUser{StoreTestUser: &dbtest.StoreTestUser{Email: "changes@example.com"}}
+		require.NoError(rw.Create(ctx, conflict, onConflict(dbw.UpdateChangedColumns(changes))))
+
+		found := lookup(t, existing.PublicId)
+		assert.Equal("changed-columns-resource", found.Name)
+		assert.Equal("resource-updated@example.com", found.Email)
+	})
+	t.Run("no-changed-columns", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "changed-columns-none", "", "")
+		conflict := testUser(t, nil, "", "", "")
+		conflict.PublicId = existing.PublicId
+		err := rw.Create(ctx, conflict, onConflict(dbw.UpdateChangedColumns(nil)))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "has no changed columns")
+	})
+	t.Run("different-type", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "changed-columns-type", "", "")
+		conflict := testUser(t, nil, "changed-columns-type-updated", "", "")
+		conflict.PublicId = existing.PublicId
+		err := rw.Create(ctx, conflict, onConflict(dbw.UpdateChangedColumns(&dbtest.TestCar{})))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "is not a *dbtest.TestUser")
+	})
+}
+
+func TestDb_Create_IncrementVersion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	onConflict := dbw.WithOnConflict(&dbw.OnConflict{
+		Target: dbw.Columns{"public_id"},
+		Action: dbw.IncrementVersion(dbw.SetColumns([]string{"name"})),
+	})
+	lookupVersion := func(t *testing.T, publicId string) uint32 {
+		t.Helper()
+		found := dbtest.AllocTestUser()
+		found.PublicId = publicId
+		require.NoError(t, rw.LookupByPublicId(ctx, &found))
+		return found.Version
+	}
+
+	t.Run("upserts", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "increment-version", "", "")
+		version := lookupVersion(t, existing.PublicId)
+		for i := 1; i <= 3; i++ {
+			// the name is unchanged, so only the action increments the version
+			conflict := testUser(t, nil, "increment-version", "", "")
+			conflict.PublicId = existing.PublicId
+			require.NoError(rw.Create(ctx, conflict, onConflict))
+			assert.Equal(version+uint32(i), lookupVersion(t, existing.PublicId))
+		}
+	})
+	t.Run("with-version", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "increment-version-lock", "", "")
+		version := lookupVersion(t, existing.PublicId)
+		conflict := testUser(t, nil, "increment-version-lock-updated", "", "")
+		conflict.PublicId = existing.PublicId
+		var rowsAffected int64
+		require.NoError(rw.Create(ctx, conflict, onConflict, dbw.WithVersion(&version), dbw.WithReturnRowsAffected(&rowsAffected)))
+		assert.Equal(int64(1), rowsAffected)
+		assert.Equal(version+1, lookupVersion(t, existing.PublicId))
+
+		// the stale version doesn't match, so the upsert doesn't update
+		conflict = testUser(t, nil, "increment-version-lock-stale", "", "")
+		conflict.PublicId = existing.PublicId
+		require.NoError(rw.Create(ctx, conflict, onConflict, dbw.WithVersion(&version), dbw.WithReturnRowsAffected(&rowsAffected)))
+		assert.Equal(int64(0), rowsAffected)
+		assert.Equal(version+1, lookupVersion(t, existing.PublicId))
+	})
+	t.Run("dry-run", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflict := testUser(t, nil, "increment-version-dry-run", "", "")
+		var sql string
+		require.NoError(rw.Create(ctx, conflict, onConflict, dbw.WithDryRun(&sql)))
+		assert.Contains(sql, "`name`=`excluded`.`name`,`version`=`db_test_user`.`version` + 1")
+	})
+	t.Run("replaces-version-assignment", func(t *testing.T) {
+		assert := assert.New(t)
+		got := dbw.IncrementVersion(dbw.SetColumns([]string{"name", "Version"}))
+		assert.Len(got, 2)
+		assert.Equal("name", got[0].Column)
+		assert.Equal("version", got[1].Column)
+	})
+}
+
+func TestDb_Create_WithConflictVersionCheck(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	setName := dbw.WithOnConflict(&dbw.OnConflict{
+		Target: dbw.Columns{"public_id"},
+		Action: dbw.SetColumns([]string{"name"}),
+	})
+	lookupVersion := func(t *testing.T, publicId string) uint32 {
+		t.Helper()
+		found := dbtest.AllocTestUser()
+		found.PublicId = publicId
+		require.NoError(t, rw.LookupByPublicId(ctx, &found))
+		return found.Version
+	}
+
+	t.Run("increment", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, rw, "version-check", "", "")
+		version := lookupVersion(t, existing.PublicId)
+		conflict := testUser(t, nil, "version-check", "", "")
+		conflict.PublicId = existing.PublicId
+		var rowsAffected int64
+		require.NoError(rw.Create(ctx, conflict, setName, dbw.WithConflictVersionCheck(version), dbw.WithReturnRowsAffected(&rowsAffected)))
+		assert.Equal(int64(1), rowsAffected)
+		assert.Equal(version+1, lookupVersion(t, existing.PublicId))
+
+		// the stale version doesn't match, so the upsert doesn't update
+		require.NoError(rw.Create(ctx, conflict, setName, dbw.WithConflictVersionCheck(version), dbw.WithReturnRowsAffected(&rowsAffected)))
+		assert.Equal(int64(0), rowsAffected)
+		assert.Equal(version+1, lookupVersion(t, existing.PublicId))
+	})
+	t.Run("same-sql-as-composed-options", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflict := testUser(t, nil, "version-check-dry-run", "", "")
+		version := uint32(2)
+		var want, got string
+		require.NoError(rw.Create(ctx, conflict,
+			dbw.WithOnConflict(&dbw.OnConflict{
+				Target: dbw.Columns{"public_id"},
+				Action: dbw.IncrementVersion(dbw.SetColumns([]string{"name"})),
+			}),
+			dbw.WithVersion(&version),
+			dbw.WithDryRun(&want),
+		))
+		require.NoError(rw.Create(ctx, conflict, setName, dbw.WithConflictVersionCheck(version), dbw.WithDryRun(&got)))
+		assert.Equal(want, got)
+		assert.Contains(got, "`version`=`db_test_user`.`version` + 1 WHERE db_test_user.version = 2")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		version := uint32(2)
+		tests := []struct {
+			name            string
+			opts            []dbw.Option
+			wantErrContains string
+		}{
+			{"zero", []dbw.Option{setName, dbw.WithConflictVersionCheck(0)}, "conflict version check is zero"},
+			{"mismatched-version", []dbw.Option{setName, dbw.WithConflictVersionCheck(1), dbw.WithVersion(&version)}, "does not match with version 2"},
+			{"update-all", []dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.UpdateAll(true)}), dbw.WithConflictVersionCheck(1)}, "requires a []ColumnValue conflict action"},
+			{"delete-existing", []dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.DeleteExisting(true)}), dbw.WithConflictVersionCheck(1)}, "not supported by the delete existing action"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				conflict := testUser(t, nil, "version-check-invalid", "", "")
+				err := rw.Create(ctx, conflict, tt.opts...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
+
+// testUpdateTimeModel has an update time column which isn't maintained by a
+// trigger.
+type testUpdateTimeModel struct {
+	Id         int `gorm:"primaryKey"`
+	Name       string
+	UpdateTime time.Time
+}
+
+func (*testUpdateTimeModel) TableName() string { return "db_test_update_time" }
+
+func TestDb_Create_WithConflictUpdateTimestamp(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(ctx, "create table db_test_update_time (id integer primary key, name text, update_time timestamp)", nil)
+	require.NoError(t, err)
+	staleTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	insertStale := func(t *testing.T, id int) {
+		t.Helper()
+		_, err := rw.Exec(ctx, "insert into db_test_update_time (id, name, update_time) values (?, ?, ?)", []interface{}{id, "alice", staleTime})
+		require.NoError(t, err)
+	}
+	lookupUpdateTime := func(t *testing.T, id int) time.Time {
+		t.Helper()
+		found := &testUpdateTimeModel{Id: id}
+		require.NoError(t, rw.LookupBy(ctx, found))
+		return found.UpdateTime
+	}
+
+	tests := []struct {
+		name        string
+		id          int
+		action      interface{}
+		opt         []dbw.Option
+		wantUpdated bool
+	}{
+		{"set-columns", 1, dbw.SetColumns([]string{"name"}), []dbw.Option{dbw.WithConflictUpdateTimestamp(true)}, true},
+		{"set-column-values", 2, dbw.SetColumnValues(map[string]interface{}{"name": "bob"}), []dbw.Option{dbw.WithConflictUpdateTimestamp(true)}, true},
+		{"disabled", 3, dbw.SetColumns([]string{"name"}), nil, false},
+		{"explicit-assignment", 4, dbw.SetColumnValues(map[string]interface{}{"name": "bob", "update_time": staleTime}), []dbw.Option{dbw.WithConflictUpdateTimestamp(true)}, false},
+		{"do-nothing", 5, dbw.DoNothing(true), []dbw.Option{dbw.WithConflictUpdateTimestamp(true)}, false},
+		{"other-column", 6, dbw.SetColumns([]string{"name"}), []dbw.Option{dbw.WithConflictUpdateTimestamp(true), dbw.WithUpdateTimeColumn("modified_time")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			insertStale(t, tt.id)
+			opt := append([]dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{
+				Target: dbw.Columns{"id"},
+				Action: tt.action,
+			})}, tt.opt...)
+			require.NoError(rw.Create(ctx, &testUpdateTimeModel{Id: tt.id, Name: "bob"}, opt...))
+			got := lookupUpdateTime(t, tt.id)
+			if tt.wantUpdated {
+				assert.True(got.After(staleTime), "update time %s should be after %s", got, staleTime)
+				return
+			}
+			assert.True(got.Equal(staleTime), "update time %s should equal %s", got, staleTime)
+		})
+	}
+	t.Run("dry-run", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var sql string
+		require.NoError(rw.Create(ctx, &testUpdateTimeModel{Id: 100, Name: "bob"},
+			dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"id"}, Action: dbw.SetColumns([]string{"name"})}),
+			dbw.WithConflictUpdateTimestamp(true),
+			dbw.WithDryRun(&sql),
+		))
+		assert.Contains(sql, "`name`=`excluded`.`name`,`update_time`=current_timestamp")
+	})
+}
+
+// testPartitionedModel is written to tables partitioned by its event day.
+type testPartitionedModel struct {
+	Id       int `gorm:"primaryKey"`
+	EventDay time.Time
+	Region   int64
+	Name     string
+}
+
+func (*testPartitionedModel) TableName() string { return "db_test_partitioned" }
+
+func TestDb_Create_WithPartitionKey(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	// sqlite doesn't support partitioned tables, so the partition is a table
+	for _, table := range []string{"db_test_partitioned", "db_test_partitioned_2024_01"} {
+		_, err = rw.Exec(ctx, "create table "+table+" (id integer primary key, event_day timestamp, region integer, name text)", nil)
+		require.NoError(t, err)
+	}
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	t.Run("create", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		m := &testPartitionedModel{Id: 1, EventDay: day, Region: 2, Name: "alice"}
+		require.NoError(rw.Create(ctx, m,
+			dbw.WithPartitionKey("event_day", day.In(time.FixedZone("EST", -5*60*60))),
+			dbw.WithTable("db_test_partitioned_2024_01"),
+		))
+		found := &testPartitionedModel{}
+		require.NoError(rw.LookupWhere(ctx, found, "id = ?", []interface{}{1}, dbw.WithTable("db_test_partitioned_2024_01")))
+		assert.Equal("alice", found.Name)
+
+		// the value is converted to the type of the column's field
+		require.NoError(rw.Create(ctx, &testPartitionedModel{Id: 2, EventDay: day, Region: 2}, dbw.WithPartitionKey("region", 2)))
+
+		// a nil value only requires the column to be set
+		require.NoError(rw.Create(ctx, &testPartitionedModel{Id: 3, EventDay: day}, dbw.WithPartitionKey("EventDay", nil)))
+	})
+	t.Run("create-items", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		items := []*testPartitionedModel{
+			{Id: 10, EventDay: day, Name: "alice"},
+			{Id: 11, Name: "bob"},
+		}
+		err := rw.CreateItems(ctx, items, dbw.WithPartitionKey("event_day", day))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "item 1: dbw.validatePartitionKey: partition key column event_day is not set")
+
+		items[1].EventDay = day
+		require.NoError(rw.CreateItems(ctx, items, dbw.WithPartitionKey("event_day", day)))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			resource        *testPartitionedModel
+			column          string
+			value           interface{}
+			wantErrContains string
+		}{
+			{"missing", &testPartitionedModel{Id: 20}, "event_day", day, "partition key column event_day is not set"},
+			{"mismatched", &testPartitionedModel{Id: 21, EventDay: day}, "event_day", day.AddDate(0, 1, 0), "does not match"},
+			{"mismatched-type", &testPartitionedModel{Id: 22, EventDay: day, Region: 2}, "region", "2", "does not match"},
+			{"unknown-column", &testPartitionedModel{Id: 23, EventDay: day}, "unknown", day, "partition key column unknown not found"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := rw.Create(ctx, tt.resource, dbw.WithPartitionKey(tt.column, tt.value))
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				found, err := rw.Exists(ctx, &testPartitionedModel{}, "id = ?", []interface{}{tt.resource.Id})
+				require.NoError(err)
+				assert.False(found)
+			})
+		}
+	})
+}
+
+type testMergeModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Name     string
+	Email    *string
+	Source   string
+}
+
+func (*testMergeModel) TableName() string { return "db_test_merge" }
+
+func TestDb_Create_OnConflict_PreferNonEmpty(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(ctx, "create table db_test_merge (public_id text primary key, name text, email text, source text)", nil)
+	require.NoError(t, err)
+	email := func(s string) *string { return &s }
+	merge := dbw.WithOnConflict(&dbw.OnConflict{
+		Target: dbw.Columns{"public_id"},
+		Action: append(dbw.PreferNonEmpty([]string{"name", "email"}), dbw.SetColumns([]string{"source"})...),
+	})
+	lookup := func(t *testing.T, publicId string) *testMergeModel {
+		t.Helper()
+		found := &testMergeModel{PublicId: publicId}
+		require.NoError(t, rw.LookupBy(ctx, found))
+		return found
+	}
+
+	tests := []struct {
+		name      string
+		incoming  testMergeModel
+		wantName  string
+		wantEmail string
+	}{
+		{"empty-and-null", testMergeModel{Name: "", Email: nil}, "alice", "alice@example.com"},
+		{"empty-string", testMergeModel{Name: "", Email: email("")}, "alice", "alice@example.com"},
+		{"non-empty", testMergeModel{Name: "alice smith", Email: email("smith@example.com")}, "alice smith", "smith@example.com"},
+		{"mixed", testMergeModel{Name: "", Email: email("smith@example.com")}, "alice", "smith@example.com"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			publicId := "m_" + strconv.Itoa(i)
+			require.NoError(rw.Create(ctx, &testMergeModel{PublicId: publicId, Name: "alice", Email: email("alice@example.com"), Source: "first"}))
+
+			incoming := tt.incoming
+			incoming.PublicId = publicId
+			incoming.Source = "second"
+			require.NoError(rw.Create(ctx, &incoming, merge))
+			found := lookup(t, publicId)
+			assert.Equal(tt.wantName, found.Name)
+			require.NotNil(found.Email)
+			assert.Equal(tt.wantEmail, *found.Email)
+			assert.Equal("second", found.Source)
+		})
+	}
+	t.Run("sql", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var sql string
+		require.NoError(rw.Create(ctx, &testMergeModel{PublicId: "m_sql"}, merge, dbw.WithDryRun(&sql)))
+		assert.Contains(sql, "`name`=coalesce(nullif(`excluded`.`name`, ''), `db_test_merge`.`name`)")
+		assert.Contains(sql, "`email`=coalesce(nullif(`excluded`.`email`, ''), `db_test_merge`.`email`)")
+	})
+}
+
+type testPartialIndexModel struct {
+	Id       int `gorm:"primaryKey"`
+	Email    string
+	Name     string
+	Archived bool
+}
+
+func (*testPartialIndexModel) TableName() string { return "db_test_partial_index" }
+
+func TestDb_Create_WithIndexPredicate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(ctx, "create table db_test_partial_index (id integer primary key, email text, name text, archived boolean not null default false)", nil)
+	require.NoError(t, err)
+	_, err = rw.Exec(ctx, "create unique index db_test_partial_index_email_uq on db_test_partial_index (email) where archived = false", nil)
+	require.NoError(t, err)
+	_, err = rw.Exec(ctx, "insert into db_test_partial_index (id, email, name, archived) values (1, 'alice@example.com', 'archived alice', true)", nil)
+	require.NoError(t, err)
+	const predicate = "archived = false"
+
+	t.Run("exists", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		found, err := rw.Exists(ctx, &testPartialIndexModel{}, "email = ?", []interface{}{"alice@example.com"})
+		require.NoError(err)
+		assert.True(found)
+
+		// the archived row isn't covered by the index
+		found, err = rw.Exists(ctx, &testPartialIndexModel{}, "email = ?", []interface{}{"alice@example.com"}, dbw.WithIndexPredicate(predicate))
+		require.NoError(err)
+		assert.False(found)
+	})
+	t.Run("upsert", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conflict := &dbw.OnConflict{
+			Target: dbw.Columns{"email"},
+			Action: dbw.SetColumns([]string{"name"}),
+		}
+		require.NoError(rw.Create(ctx, &testPartialIndexModel{Id: 2, Email: "alice@example.com", Name: "alice"},
+			dbw.WithOnConflict(conflict), dbw.WithIndexPredicate(predicate)))
+		found, err := rw.Exists(ctx, &testPartialIndexModel{}, "email = ?", []interface{}{"alice@example.com"}, dbw.WithIndexPredicate(predicate))
+		require.NoError(err)
+		assert.True(found)
+
+		// the existence check and the upsert agree on the conflicting row
+		require.NoError(rw.Create(ctx, &testPartialIndexModel{Id: 3, Email: "alice@example.com", Name: "alice smith"},
+			dbw.WithOnConflict(conflict), dbw.WithIndexPredicate(predicate)))
+		var rows []*testPartialIndexModel
+		require.NoError(rw.SearchWhere(ctx, &rows, "email = ?", []interface{}{"alice@example.com"}, dbw.WithOrder("id")))
+		require.Len(rows, 2)
+		assert.Equal("archived alice", rows[0].Name)
+		assert.Equal(2, rows[1].Id)
+		assert.Equal("alice smith", rows[1].Name)
+
+		// without the predicate, the partial index can't be inferred
+		err = rw.Create(ctx, &testPartialIndexModel{Id: 4, Email: "alice@example.com"}, dbw.WithOnConflict(conflict))
+		require.Error(err)
+	})
+	t.Run("sql", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var sql string
+		require.NoError(rw.Create(ctx, &testPartialIndexModel{Id: 5, Email: "bob@example.com"},
+			dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"email"}, Action: dbw.DoNothing(true)}),
+			dbw.WithIndexPredicate(predicate),
+			dbw.WithDryRun(&sql),
+		))
+		assert.Regexp("ON CONFLICT \\(`email`\\)\\s+WHERE archived = false DO NOTHING", sql)
+	})
+	t.Run("constraint-target", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		err := rw.Create(ctx, &testPartialIndexModel{Id: 6, Email: "carol@example.com"},
+			dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Constraint("db_test_partial_index_email_uq"), Action: dbw.DoNothing(true)}),
+			dbw.WithIndexPredicate(predicate),
+		)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "requires a Columns conflict target")
+	})
+}
+
+type testRowidModel struct {
+	Id   int64 `gorm:"primaryKey"`
+	Name string
+}
+
+func (*testRowidModel) TableName() string { return "db_test_rowid" }
+
+type testImplicitRowidModel struct {
+	Rowid int64 `gorm:"column:rowid;primaryKey"`
+	Name  string
+}
+
+func (*testImplicitRowidModel) TableName() string { return "db_test_implicit_rowid" }
+
+type testWithoutRowidModel struct {
+	Tenant string `gorm:"primaryKey"`
+	Key    string `gorm:"primaryKey"`
+	Value  string
+}
+
+func (*testWithoutRowidModel) TableName() string { return "db_test_without_rowid" }
+
+func TestDb_Create_OnConflict_SqliteRowid(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	for _, stmt := range []string{
+		"create table db_test_rowid (id integer primary key, name text)",
+		"create table db_test_implicit_rowid (name text)",
+		"create table db_test_without_rowid (tenant text, key text, value text, primary key (tenant, key)) without rowid",
+	} {
+		_, err = rw.Exec(ctx, stmt, nil)
+		require.NoError(t, err)
+	}
+	setName := dbw.SetColumns([]string{"name"})
+
+	t.Run("rowid-alias", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		require.NoError(rw.Create(ctx, &testRowidModel{Id: 1, Name: "alice"}))
+		conflict := &dbw.OnConflict{Target: dbw.Columns{"rowid"}, Action: setName}
+		var sql string
+		require.NoError(rw.Create(ctx, &testRowidModel{Id: 1, Name: "alice smith"}, dbw.WithOnConflict(conflict), dbw.WithDryRun(&sql)))
+		// the rowid is rendered as its alias column
+		assert.Contains(sql, "ON CONFLICT (`id`) DO UPDATE")
+
+		require.NoError(rw.Create(ctx, &testRowidModel{Id: 1, Name: "alice smith"}, dbw.WithOnConflict(conflict)))
+		found := &testRowidModel{Id: 1}
+		require.NoError(rw.LookupBy(ctx, found))
+		assert.Equal("alice smith", found.Name)
+	})
+	t.Run("implicit-rowid", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		require.NoError(rw.Create(ctx, &testImplicitRowidModel{Rowid: 1, Name: "bob"}))
+		conflict := &dbw.OnConflict{Target: dbw.Columns{"rowid"}, Action: setName}
+		require.NoError(rw.Create(ctx, &testImplicitRowidModel{Rowid: 1, Name: "bob smith"}, dbw.WithOnConflict(conflict)))
+		var found []*testImplicitRowidModel
+		require.NoError(rw.SearchWhere(ctx, &found, "", nil, dbw.WithLimit(-1)))
+		require.Len(found, 1)
+		assert.Equal("bob smith", found[0].Name)
+	})
+	t.Run("without-rowid", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		require.NoError(rw.Create(ctx, &testWithoutRowidModel{Tenant: "t1", Key: "k", Value: "v1"}))
+		require.NoError(rw.Create(ctx, &testWithoutRowidModel{Tenant: "t2", Key: "k", Value: "v1"}))
+		conflict := &dbw.OnConflict{Target: dbw.Columns{"tenant", "key"}, Action: dbw.SetColumns([]string{"value"})}
+		require.NoError(rw.Create(ctx, &testWithoutRowidModel{Tenant: "t1", Key: "k", Value: "v2"}, dbw.WithOnConflict(conflict)))
+
+		// only the row which matches the primary key is updated
+		var found []*testWithoutRowidModel
+		require.NoError(rw.SearchWhere(ctx, &found, "", nil, dbw.WithOrder("tenant"), dbw.WithLimit(-1)))
+		require.Len(found, 2)
+		assert.Equal("v2", found[0].Value)
+		assert.Equal("v1", found[1].Value)
+
+		// a partial primary key doesn't match the constraint
+		conflict = &dbw.OnConflict{Target: dbw.Columns{"key"}, Action: dbw.SetColumns([]string{"value"})}
+		err := rw.Create(ctx, &testWithoutRowidModel{Tenant: "t1", Key: "k", Value: "v3"}, dbw.WithOnConflict(conflict))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "no unique index on (key)")
+
+		// the table doesn't have a rowid
+		conflict = &dbw.OnConflict{Target: dbw.Columns{"rowid"}, Action: dbw.SetColumns([]string{"value"})}
+		err = rw.Create(ctx, &testWithoutRowidModel{Tenant: "t1", Key: "k", Value: "v3"}, dbw.WithOnConflict(conflict))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "db_test_without_rowid is a WITHOUT ROWID table")
+	})
+}
+
+type testImportModel struct {
+	PublicId   string `gorm:"primaryKey"`
+	Email      string
+	ExternalId *string
+}
+
+func (*testImportModel) TableName() string { return "db_test_import" }
+
+func TestDb_Create_WithConflictConstraintOut(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	for _, stmt := range []string{
+		"create table db_test_import (public_id text primary key, email text not null unique, external_id text)",
+		"create unique index db_test_import_external_id_uq on db_test_import (external_id)",
+		"create table db_test_rowid (id integer primary key, name text)",
+	} {
+		_, err = rw.Exec(ctx, stmt, nil)
+		require.NoError(t, err)
+	}
+	externalId := func(s string) *string { return &s }
+	require.NoError(t, rw.Create(ctx, &testImportModel{PublicId: "i_1", Email: "alice@example.com", ExternalId: externalId("ext_1")}))
+	require.NoError(t, rw.Create(ctx, &testRowidModel{Id: 1, Name: "alice"}))
+
+	doNothing := func(target interface{}) dbw.Option {
+		return dbw.WithOnConflict(&dbw.OnConflict{Target: target, Action: dbw.DoNothing(true)})
+	}
+	tests := []struct {
+		name     string
+		resource interface{}
+		target   interface{}
+		want     string
+	}{
+		{"primary-key", &testImportModel{PublicId: "i_1", Email: "bob@example.com"}, dbw.Columns{"public_id"}, "sqlite_autoindex_db_test_import_1"},
+		{"unique-column", &testImportModel{PublicId: "i_2", Email: "alice@example.com"}, dbw.Columns{"email"}, "sqlite_autoindex_db_test_import_2"},
+		{"unique-index", &testImportModel{PublicId: "i_3", Email: "eve@example.com", ExternalId: externalId("ext_1")}, dbw.Columns{"external_id"}, "db_test_import_external_id_uq"},
+		{"target-first", &testImportModel{PublicId: "i_1", Email: "alice@example.com"}, dbw.Columns{"email"}, "sqlite_autoindex_db_test_import_2"},
+		{"rowid-alias", &testRowidModel{Id: 1, Name: "alice smith"}, dbw.Columns{"id"}, "PRIMARY KEY"},
+		{"inserted", &testImportModel{PublicId: "i_4", Email: "mallory@example.com"}, dbw.Columns{"email"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			name := "not-set"
+			var rowsAffected int64
+			err := rw.Create(ctx, tt.resource, doNothing(tt.target), dbw.WithConflictConstraintOut(&name), dbw.WithReturnRowsAffected(&rowsAffected))
+			require.NoError(err)
+			assert.Equal(tt.want, name)
+			if tt.want == "" {
+				assert.Equal(int64(1), rowsAffected)
+			} else {
+				assert.Equal(int64(0), rowsAffected)
+			}
+		})
+	}
+	t.Run("invalid-parameters", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"missing-on-conflict", nil, "requires a DoNothing conflict action"},
+			{"do-nothing-false", []dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"email"}, Action: dbw.DoNothing(false)})}, "requires a DoNothing conflict action"},
+			{"update", []dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"email"}, Action: dbw.SetColumns([]string{"external_id"})})}, "requires a DoNothing conflict action, not []dbw.ColumnValue"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var name string
+				err := rw.Create(ctx, &testImportModel{PublicId: "i_5", Email: "trent@example.com"}, append(tt.opt, dbw.WithConflictConstraintOut(&name))...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+		t.Run("create-items", func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var name string
+			err := rw.CreateItems(ctx, []*testImportModel{{PublicId: "i_5", Email: "trent@example.com"}}, doNothing(dbw.Columns{"email"}), dbw.WithConflictConstraintOut(&name))
+			require.Error(err)
+			assert.ErrorIs(err, dbw.ErrInvalidParameter)
+			assert.Contains(err.Error(), "with conflict constraint out not a supported option")
+		})
+	})
+}
+
+func TestDb_CreateItems_WithReturnInserted(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(ctx, "create table db_test_import (public_id text primary key, email text not null unique, external_id text)", nil)
+	require.NoError(t, err)
+	require.NoError(t, rw.CreateItems(ctx, []*testImportModel{
+		{PublicId: "i_1", Email: "alice@example.com"},
+		{PublicId: "i_2", Email: "bob@example.com"},
+	}))
+	doNothing := dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.DoNothing(true)})
+
+	tests := []struct {
+		name      string
+		batchSize int
+	}{
+		{"single-batch", 0},
+		{"batches", 2},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			prefix := "b" + strconv.Itoa(i) + "_"
+			items := []*testImportModel{
+				{PublicId: "i_1", Email: prefix + "alice@example.com"},
+				{PublicId: prefix + "1", Email: prefix + "carol@example.com"},
+				{PublicId: "i_2", Email: prefix + "bob@example.com"},
+				{PublicId: prefix + "2", Email: prefix + "dave@example.com"},
+				{PublicId: prefix + "3", Email: prefix + "eve@example.com"},
+			}
+			// the existing elements are replaced
+			inserted := []*testImportModel{{PublicId: "stale"}}
+			var rowsAffected int64
+			err := rw.CreateItems(ctx, items, doNothing,
+				dbw.WithReturnInserted(&inserted),
+				dbw.WithReturnRowsAffected(&rowsAffected),
+				dbw.WithBatchSize(tt.batchSize),
+			)
+			require.NoError(err)
+			assert.Equal(int64(3), rowsAffected)
+			require.Len(inserted, 3)
+			assert.Equal(*items[1], *inserted[0])
+			assert.Equal(*items[3], *inserted[1])
+			assert.Equal(*items[4], *inserted[2])
+
+			// the conflicting rows weren't modified
+			found := &testImportModel{PublicId: "i_1"}
+			require.NoError(rw.LookupBy(ctx, found))
+			assert.Equal("alice@example.com", found.Email)
+		})
+	}
+	t.Run("all-conflicts", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var inserted []*testImportModel
+		err := rw.CreateItems(ctx, []*testImportModel{{PublicId: "i_1", Email: "x@example.com"}}, doNothing, dbw.WithReturnInserted(&inserted))
+		require.NoError(err)
+		assert.Empty(inserted)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		var inserted []*testImportModel
+		var wrongType []*testRowidModel
+		tests := []struct {
+			name            string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"missing-on-conflict", []dbw.Option{dbw.WithReturnInserted(&inserted)}, "return inserted requires a DoNothing conflict action"},
+			{"update", []dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.SetColumns([]string{"email"})}), dbw.WithReturnInserted(&inserted)}, "return inserted requires a DoNothing conflict action, not []dbw.ColumnValue"},
+			{"not-a-pointer", []dbw.Option{doNothing, dbw.WithReturnInserted(inserted)}, "must be a pointer to a slice"},
+			{"wrong-type", []dbw.Option{doNothing, dbw.WithReturnInserted(&wrongType)}, "must be a pointer to a slice of *dbw_test.testImportModel"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := rw.CreateItems(ctx, []*testImportModel{{PublicId: "i_9", Email: "z@example.com"}}, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
+
+type testMemberModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Email    string `gorm:"unique"`
+	Username string `gorm:"unique"`
+	Name     string
+}
+
+func (*testMemberModel) TableName() string { return "db_test_member" }
+
+func TestDb_CreateItems_WithConflictOnMultipleTargets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	setup := func(t *testing.T) *dbw.RW {
+		t.Helper()
+		require := require.New(t)
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithMaxOpenConnections(1))
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(ctx) })
+		rw := dbw.New(conn)
+		_, err = rw.Exec(ctx, `create table db_test_member (
+  public_id text primary key,
+  email text not null unique,
+  username text not null unique,
+  name text
+)`, nil)
+		require.NoError(err)
+		require.NoError(rw.CreateItems(ctx, []*testMemberModel{
+			{PublicId: "m_1", Email: "alice@example.com", Username: "alice", Name: "Alice"},
+			{PublicId: "m_2", Email: "bob@example.com", Username: "bob", Name: "Bob"},
+		}))
+		return rw
+	}
+	// the incoming rows conflict with the existing rows on different unique
+	// keys: m_3 on its email and m_4 on its username
+	incoming := func() []*testMemberModel {
+		return []*testMemberModel{
+			{PublicId: "m_3", Email: "alice@example.com", Username: "alice-smith", Name: "Alice Smith"},
+			{PublicId: "m_4", Email: "bob.jones@example.com", Username: "bob", Name: "Bob Jones"},
+			{PublicId: "m_5", Email: "carol@example.com", Username: "carol", Name: "Carol"},
+		}
+	}
+	updateName := dbw.WithOnConflict(&dbw.OnConflict{Action: dbw.SetColumns([]string{"name"})})
+	assertUpserted := func(t *testing.T, rw *dbw.RW) {
+		t.Helper()
+		assert, require := assert.New(t), require.New(t)
+		var found []*testMemberModel
+		require.NoError(rw.SearchWhere(ctx, &found, "", nil, dbw.WithOrder("public_id")))
+		assert.Equal([]*testMemberModel{
+			{PublicId: "m_1", Email: "alice@example.com", Username: "alice", Name: "Alice Smith"},
+			{PublicId: "m_2", Email: "bob@example.com", Username: "bob", Name: "Bob Jones"},
+			{PublicId: "m_5", Email: "carol@example.com", Username: "carol", Name: "Carol"},
+		}, found)
+	}
+
+	t.Run("single-target-fails", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := setup(t)
+		err := rw.CreateItems(ctx, incoming(), dbw.WithOnConflict(&dbw.OnConflict{
+			Target: dbw.Columns{"email"},
+			Action: dbw.SetColumns([]string{"name"}),
+		}))
+		require.Error(err)
+		assert.Contains(err.Error(), "UNIQUE constraint failed: db_test_member.username")
+	})
+	t.Run("targets", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := setup(t)
+		var rowsAffected int64
+		err := rw.CreateItems(ctx, incoming(), updateName,
+			dbw.WithConflictOnMultipleTargets(dbw.Columns{"email"}, dbw.Columns{"username"}),
+			dbw.WithReturnRowsAffected(&rowsAffected),
+		)
+		require.NoError(err)
+		assert.Equal(int64(3), rowsAffected)
+		assertUpserted(t, rw)
+	})
+	t.Run("unique-keys", func(t *testing.T) {
+		require := require.New(t)
+		rw := setup(t)
+		require.NoError(rw.CreateItems(ctx, incoming(), updateName, dbw.WithConflictOnMultipleTargets()))
+		assertUpserted(t, rw)
+	})
+	t.Run("within-transaction", func(t *testing.T) {
+		require := require.New(t)
+		rw := setup(t)
+		_, err := rw.DoTx(ctx, func(error) bool { return false }, 0, dbw.ConstBackoff{}, func(_ dbw.Reader, w dbw.Writer) error {
+			return w.CreateItems(ctx, incoming(), updateName, dbw.WithConflictOnMultipleTargets())
+		})
+		require.NoError(err)
+		assertUpserted(t, rw)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		rw := setup(t)
+		tests := []struct {
+			name            string
+			opts            []dbw.Option
+			wantErrContains string
+		}{
+			{
+				name:            "missing-on-conflict",
+				opts:            []dbw.Option{dbw.WithConflictOnMultipleTargets()},
+				wantErrContains: "multiple conflict targets require an on conflict action",
+			},
+			{
+				name: "on-conflict-target",
+				opts: []dbw.Option{
+					dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"email"}, Action: dbw.DoNothing(true)}),
+					dbw.WithConflictOnMultipleTargets(),
+				},
+				wantErrContains: "multiple conflict targets can't be used with an on conflict target",
+			},
+			{
+				name: "delete-existing",
+				opts: []dbw.Option{
+					dbw.WithOnConflict(&dbw.OnConflict{Action: dbw.DeleteExisting(true)}),
+					dbw.WithConflictOnMultipleTargets(),
+				},
+				wantErrContains: "multiple conflict targets can't be used with the delete existing conflict action",
+			},
+			{
+				name:            "missing-target-columns",
+				opts:            []dbw.Option{updateName, dbw.WithConflictOnMultipleTargets(dbw.Columns{"email"}, dbw.Columns{})},
+				wantErrContains: "conflict target 1 is missing columns",
+			},
+			{
+				name:            "unknown-target-column",
+				opts:            []dbw.Option{updateName, dbw.WithConflictOnMultipleTargets(dbw.Columns{"phone"})},
+				wantErrContains: "conflict target column phone not found in resource",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := rw.CreateItems(ctx, incoming(), tt.opts...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
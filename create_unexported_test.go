@@ -4,10 +4,13 @@
 package dbw
 
 import (
+	"context"
 	"sync/atomic"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_NonCreatableFields(t *testing.T) {
@@ -21,3 +24,114 @@ func Test_NonCreatableFields(t *testing.T) {
 	got = NonCreatableFields()
 	assert.Equal(got, []string{"Foo"})
 }
+
+func Test_mergeColumnValues(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		columnValues    []ColumnValue
+		override        bool
+		want            []ColumnValue
+		wantErrContains string
+	}{
+		{
+			name:         "no-overlap",
+			columnValues: append(SetColumns([]string{"name"}), SetColumnValues(map[string]interface{}{"email": "alice@gmail.com"})...),
+			want:         append(SetColumns([]string{"name"}), SetColumnValues(map[string]interface{}{"email": "alice@gmail.com"})...),
+		},
+		{
+			name:         "duplicate-set-columns",
+			columnValues: SetColumns([]string{"name", "NAME"}),
+			want:         SetColumns([]string{"name"}),
+		},
+		{
+			name:            "overlap-without-override",
+			columnValues:    append(SetColumns([]string{"email"}), SetColumnValues(map[string]interface{}{"email": "alice@gmail.com"})...),
+			wantErrContains: "column email specified more than once with conflicting values: invalid parameter",
+		},
+		{
+			name:         "overlap-with-override",
+			columnValues: append(SetColumns([]string{"name", "email"}), SetColumnValues(map[string]interface{}{"email": "alice@gmail.com"})...),
+			override:     true,
+			want:         append(SetColumns([]string{"name"}), ColumnValue{Column: "email", Value: "alice@gmail.com"}),
+		},
+		{
+			name:         "overlap-with-override-set-columns-last",
+			columnValues: append(SetColumnValues(map[string]interface{}{"email": "alice@gmail.com"}), SetColumns([]string{"email"})...),
+			override:     true,
+			want:         []ColumnValue{{Column: "email", Value: "alice@gmail.com"}},
+		},
+		{
+			name: "column-values-last-wins",
+			columnValues: append(
+				SetColumnValues(map[string]interface{}{"email": "alice@gmail.com"}),
+				SetColumnValues(map[string]interface{}{"email": "eve@gmail.com"})...,
+			),
+			override: true,
+			want:     []ColumnValue{{Column: "email", Value: "eve@gmail.com"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := mergeColumnValues(tt.columnValues, tt.override)
+			if tt.wantErrContains != "" {
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestRW_execDeleteExisting(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const mergeSql = `merge into "db_test_user" using \(select 1\) as dbw_source on "public_id" = \$1 when matched then delete`
+	const deleteSql = `delete from "db_test_user" where "public_id" = \$1`
+	t.Run("merge", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, mock := TestSetupWithMock(t)
+		mock.ExpectQuery(`show server_version_num`).WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("150004"))
+		mock.ExpectExec(mergeSql).WithArgs("u_1").WillReturnResult(sqlmock.NewResult(0, 1))
+		// the server's version is cached, so it's only queried once
+		mock.ExpectExec(mergeSql).WithArgs("u_2").WillReturnResult(sqlmock.NewResult(0, 0))
+		rw := New(db)
+		require.NoError(rw.execDeleteExisting(testCtx, "db_test_user", `"public_id" = ?`, []interface{}{"u_1"}, Options{}))
+		require.NoError(rw.execDeleteExisting(testCtx, "db_test_user", `"public_id" = ?`, []interface{}{"u_2"}, Options{}))
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("delete-before-pg15", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, mock := TestSetupWithMock(t)
+		mock.ExpectQuery(`show server_version_num`).WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("140010"))
+		mock.ExpectExec(deleteSql).WithArgs("u_1").WillReturnResult(sqlmock.NewResult(0, 1))
+		require.NoError(New(db).execDeleteExisting(testCtx, "db_test_user", `"public_id" = ?`, []interface{}{"u_1"}, Options{}))
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("delete-sqlite", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, _ := TestSetup(t)
+		rw := New(db)
+		_, err := rw.Exec(testCtx, "insert into db_test_user (public_id, name) values (?, ?)", []interface{}{"u_1", "alice"})
+		require.NoError(err)
+		merge, err := rw.supportsMerge(testCtx)
+		require.NoError(err)
+		assert.False(merge)
+		require.NoError(rw.execDeleteExisting(testCtx, "db_test_user", "`public_id` = ?", []interface{}{"u_1"}, Options{}))
+		found, err := rw.Exists(testCtx, &testUniqueUser{}, "public_id = ?", []interface{}{"u_1"})
+		require.NoError(err)
+		assert.False(found)
+	})
+	t.Run("invalid-version", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db, mock := TestSetupWithMock(t)
+		mock.ExpectQuery(`show server_version_num`).WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("not-a-number"))
+		err := New(db).execDeleteExisting(testCtx, "db_test_user", `"public_id" = ?`, []interface{}{"u_1"}, Options{})
+		require.Error(err)
+		assert.ErrorIs(err, ErrUnknown)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+}
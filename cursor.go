@@ -0,0 +1,481 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+const cursorVersion = 1
+
+// cursor types which are used to preserve the type of a cursor value, so it
+// can be decoded to the same type when binding query parameters
+const (
+	cursorTypeNil    = "nil"
+	cursorTypeString = "string"
+	cursorTypeInt    = "int"
+	cursorTypeUint   = "uint"
+	cursorTypeFloat  = "float"
+	cursorTypeBool   = "bool"
+	cursorTypeTime   = "time"
+	cursorTypeBytes  = "bytes"
+)
+
+// minCursorKeyLen is the minimum length of a cursor signing key, which is the
+// size of the HMAC-SHA256 output
+const minCursorKeyLen = sha256.Size
+
+var (
+	cursorKey atomic.Value
+
+	// defaultCursorKey is a random key which is generated for the process when
+	// a cursor is signed or verified before InitCursorKey(...) is called
+	defaultCursorKey     []byte
+	defaultCursorKeyErr  error
+	defaultCursorKeyOnce sync.Once
+)
+
+// InitCursorKey sets the key which is used to sign cursors and verify their
+// signatures (see: EncodeCursor).  By default, a random key is generated for the
+// process, so a cursor can only be decoded by the process which encoded it.
+// When cursors are returned to clients which may send them to another process
+// (ex: another instance of a service), the same key must be set for every
+// process.  The key must be at least 32 bytes and it should be kept secret.
+func InitCursorKey(key []byte) error {
+	const op = "dbw.InitCursorKey"
+	if len(key) < minCursorKeyLen {
+		return fmt.Errorf("%s: key must be at least %d bytes: %w", op, minCursorKeyLen, ErrInvalidParameter)
+	}
+	cursorKey.Store(append([]byte(nil), key...))
+	return nil
+}
+
+// cursorSigningKey returns the key set by InitCursorKey(...) or the process'
+// default random key when a key hasn't been set.
+func cursorSigningKey() ([]byte, error) {
+	const op = "dbw.cursorSigningKey"
+	if key, ok := cursorKey.Load().([]byte); ok {
+		return key, nil
+	}
+	defaultCursorKeyOnce.Do(func() {
+		key := make([]byte, minCursorKeyLen)
+		if _, defaultCursorKeyErr = rand.Read(key); defaultCursorKeyErr == nil {
+			defaultCursorKey = key
+		}
+	})
+	if defaultCursorKeyErr != nil {
+		return nil, fmt.Errorf("%s: unable to generate key: %w", op, defaultCursorKeyErr)
+	}
+	return defaultCursorKey, nil
+}
+
+// signCursor returns the HMAC-SHA256 signature of the encoded cursor payload.
+func signCursor(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+type cursor struct {
+	Version int           `json:"v"`
+	Values  []cursorValue `json:"vals"`
+}
+
+type cursorValue struct {
+	Column string `json:"c"`
+	Type   string `json:"t"`
+	Value  string `json:"v,omitempty"`
+}
+
+// EncodeCursor will encode the last-seen ordering values of a page of results
+// into an opaque, url safe cursor which can be returned to clients.  The type
+// of each value is preserved, so DecodeCursor(...) will return values which
+// bind correctly as query parameters.  Supported value types are: nil,
+// string, bool, []byte, time.Time and all the int, uint and float types.
+//
+// The cursor is signed with an HMAC-SHA256 of the key set by InitCursorKey(...),
+// so a cursor which was modified by a client is rejected by DecodeCursor(...).
+// The cursor is signed, not encrypted, so it should not contain sensitive
+// values.
+func EncodeCursor(values map[string]interface{}) (string, error) {
+	const op = "dbw.EncodeCursor"
+	key, err := cursorSigningKey()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	encoded, err := encodeCursor(key, values)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return encoded, nil
+}
+
+// encodeCursor will encode the values into a cursor which is signed with the
+// key: the base64 encoded payload and signature separated by a ".".
+func encodeCursor(key []byte, values map[string]interface{}) (string, error) {
+	const op = "dbw.encodeCursor"
+	if len(values) == 0 {
+		return "", fmt.Errorf("%s: missing values: %w", op, ErrInvalidParameter)
+	}
+	columns := make([]string, 0, len(values))
+	for c := range values {
+		if c == "" {
+			return "", fmt.Errorf("%s: missing column name: %w", op, ErrInvalidParameter)
+		}
+		columns = append(columns, c)
+	}
+	// sort the columns, so the same values always produce the same cursor
+	sort.Strings(columns)
+
+	cur := cursor{
+		Version: cursorVersion,
+		Values:  make([]cursorValue, 0, len(columns)),
+	}
+	for _, c := range columns {
+		cv := cursorValue{Column: c}
+		switch v := values[c].(type) {
+		case nil:
+			cv.Type = cursorTypeNil
+		case string:
+			cv.Type, cv.Value = cursorTypeString, v
+		case int:
+			cv.Type, cv.Value = cursorTypeInt, strconv.FormatInt(int64(v), 10)
+		case int8:
+			cv.Type, cv.Value = cursorTypeInt, strconv.FormatInt(int64(v), 10)
+		case int16:
+			cv.Type, cv.Value = cursorTypeInt, strconv.FormatInt(int64(v), 10)
+		case int32:
+			cv.Type, cv.Value = cursorTypeInt, strconv.FormatInt(int64(v), 10)
+		case int64:
+			cv.Type, cv.Value = cursorTypeInt, strconv.FormatInt(v, 10)
+		case uint:
+			cv.Type, cv.Value = cursorTypeUint, strconv.FormatUint(uint64(v), 10)
+		case uint8:
+			cv.Type, cv.Value = cursorTypeUint, strconv.FormatUint(uint64(v), 10)
+		case uint16:
+			cv.Type, cv.Value = cursorTypeUint, strconv.FormatUint(uint64(v), 10)
+		case uint32:
+			cv.Type, cv.Value = cursorTypeUint, strconv.FormatUint(uint64(v), 10)
+		case uint64:
+			cv.Type, cv.Value = cursorTypeUint, strconv.FormatUint(v, 10)
+		case float32:
+			cv.Type, cv.Value = cursorTypeFloat, strconv.FormatFloat(float64(v), 'g', -1, 32)
+		case float64:
+			cv.Type, cv.Value = cursorTypeFloat, strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			cv.Type, cv.Value = cursorTypeBool, strconv.FormatBool(v)
+		case time.Time:
+			cv.Type, cv.Value = cursorTypeTime, v.Format(time.RFC3339Nano)
+		case []byte:
+			cv.Type, cv.Value = cursorTypeBytes, base64.StdEncoding.EncodeToString(v)
+		default:
+			return "", fmt.Errorf("%s: unsupported type %T for column %s: %w", op, v, c, ErrInvalidParameter)
+		}
+		cur.Values = append(cur.Values, cv)
+	}
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(key, payload)), nil
+}
+
+// DecodeCursor will decode a cursor created by EncodeCursor(...) and return
+// its ordering values keyed by column name.  Values are returned using the
+// widest type of their kind: int64 for ints, uint64 for uints and float64 for
+// floats.  An ErrInvalidParameter is returned for a malformed cursor or a
+// cursor whose signature doesn't match its values.
+func DecodeCursor(encoded string) (map[string]interface{}, error) {
+	const op = "dbw.DecodeCursor"
+	key, err := cursorSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	values, err := decodeCursor(key, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return values, nil
+}
+
+// decodeCursor will verify the cursor's signature using the key and then
+// decode its values.
+func decodeCursor(key []byte, encoded string) (map[string]interface{}, error) {
+	const op = "dbw.decodeCursor"
+	if encoded == "" {
+		return nil, fmt.Errorf("%s: missing cursor: %w", op, ErrInvalidParameter)
+	}
+	payload, encodedSig, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, fmt.Errorf("%s: missing cursor signature: %w", op, ErrInvalidParameter)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to decode cursor signature: %w", op, ErrInvalidParameter)
+	}
+	if !hmac.Equal(sig, signCursor(key, payload)) {
+		return nil, fmt.Errorf("%s: invalid cursor signature: %w", op, ErrInvalidParameter)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to decode cursor: %w", op, ErrInvalidParameter)
+	}
+	var cur cursor
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return nil, fmt.Errorf("%s: unable to unmarshal cursor: %w", op, ErrInvalidParameter)
+	}
+	if cur.Version != cursorVersion {
+		return nil, fmt.Errorf("%s: unsupported cursor version %d: %w", op, cur.Version, ErrInvalidParameter)
+	}
+	if len(cur.Values) == 0 {
+		return nil, fmt.Errorf("%s: cursor has no values: %w", op, ErrInvalidParameter)
+	}
+	values := make(map[string]interface{}, len(cur.Values))
+	for _, cv := range cur.Values {
+		if cv.Column == "" {
+			return nil, fmt.Errorf("%s: missing column name: %w", op, ErrInvalidParameter)
+		}
+		if _, ok := values[cv.Column]; ok {
+			return nil, fmt.Errorf("%s: duplicate column %s: %w", op, cv.Column, ErrInvalidParameter)
+		}
+		var v interface{}
+		var err error
+		switch cv.Type {
+		case cursorTypeNil:
+			v = nil
+		case cursorTypeString:
+			v = cv.Value
+		case cursorTypeInt:
+			v, err = strconv.ParseInt(cv.Value, 10, 64)
+		case cursorTypeUint:
+			v, err = strconv.ParseUint(cv.Value, 10, 64)
+		case cursorTypeFloat:
+			v, err = strconv.ParseFloat(cv.Value, 64)
+		case cursorTypeBool:
+			v, err = strconv.ParseBool(cv.Value)
+		case cursorTypeTime:
+			v, err = time.Parse(time.RFC3339Nano, cv.Value)
+		case cursorTypeBytes:
+			v, err = base64.StdEncoding.DecodeString(cv.Value)
+		default:
+			return nil, fmt.Errorf("%s: unsupported type %q for column %s: %w", op, cv.Type, cv.Column, ErrInvalidParameter)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid %s value for column %s: %w", op, cv.Type, cv.Column, ErrInvalidParameter)
+		}
+		values[cv.Column] = v
+	}
+	return values, nil
+}
+
+// FindPage will find a page of resources matching the where clause with
+// parameters using keyset pagination, where the resources are ordered by the
+// keyColumn, which must uniquely identify a resource.  The afterCursor is
+// the nextCursor returned for the previous page, or empty for the first page.
+// The nextCursor returned is empty when there are no more pages.  A malformed
+// or modified afterCursor returns an ErrInvalidParameter.  Supports the WithDebug,
+// WithTable and WithResultTransformer options.  The WithLimit, WithOrder,
+// WithOrderBy and WithAppendResults options aren't supported, since the page
+// is ordered by the keyColumn.
+func FindPage[T any](ctx context.Context, rw *RW, keyColumn string, afterCursor string, pageSize int, where string, args []interface{}, opt ...Option) (items []*T, nextCursor string, err error) {
+	const op = "dbw.FindPage"
+	switch {
+	case rw == nil || rw.underlying == nil:
+		return nil, "", fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case !identifierRegexp.MatchString(keyColumn):
+		return nil, "", fmt.Errorf("%s: invalid key column %q: %w", op, keyColumn, ErrInvalidParameter)
+	case pageSize <= 0:
+		return nil, "", fmt.Errorf("%s: page size must be greater than zero: %w", op, ErrInvalidParameter)
+	case where == "" && len(args) > 0:
+		return nil, "", fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	}
+	opts := rw.getOpts(opt...)
+	switch {
+	case opts.WithLimit != 0:
+		return nil, "", fmt.Errorf("%s: with limit is not a supported option: %w", op, ErrInvalidParameter)
+	case opts.WithOrder != "" || opts.WithOrderBy != nil:
+		return nil, "", fmt.Errorf("%s: with order is not a supported option: %w", op, ErrInvalidParameter)
+	case opts.WithAppendResults:
+		return nil, "", fmt.Errorf("%s: with append results is not a supported option: %w", op, ErrInvalidParameter)
+	}
+	s, _, err := rw.parseSchema(new(T), opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	keyField := s.LookUpField(keyColumn)
+	if keyField == nil || keyField.DBName != keyColumn {
+		return nil, "", fmt.Errorf("%s: unknown key column %s: %w", op, keyColumn, ErrInvalidParameter)
+	}
+
+	if afterCursor != "" {
+		values, err := DecodeCursor(afterCursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		afterKey, ok := values[keyColumn]
+		if !ok || len(values) != 1 || afterKey == nil {
+			return nil, "", fmt.Errorf("%s: cursor is not for key column %s: %w", op, keyColumn, ErrInvalidParameter)
+		}
+		afterWhere := keyColumn + " > ?"
+		if where != "" {
+			afterWhere = "(" + where + ") and " + afterWhere
+		}
+		where = afterWhere
+		args = append(append(make([]interface{}, 0, len(args)+1), args...), afterKey)
+	}
+
+	// fetch one extra row, so we know if there's another page without
+	// issuing a separate query.  The results are transformed after the extra
+	// row is dropped.  The options are copied, so the caller's aren't modified.
+	pageOpts := append(make([]Option, 0, len(opt)+3), opt...)
+	pageOpts = append(pageOpts, WithLimit(pageSize+1), WithOrder(keyColumn+" asc"), WithResultTransformer(nil))
+	if err := rw.SearchWhere(ctx, &items, where, args, pageOpts...); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	if len(items) > pageSize {
+		items = items[:pageSize]
+		lastKey, _ := keyField.ValueOf(ctx, reflect.ValueOf(items[pageSize-1]).Elem())
+		nextCursor, err = EncodeCursor(map[string]interface{}{keyColumn: lastKey})
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := transformResults(&items, opts.WithResultTransformer); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nextCursor, nil
+}
+
+// EachPage will search for the resources matching the where clause with
+// parameters a page at a time and invoke fn with each page, until the
+// resources are exhausted, fn returns an error or the ctx is done.  The
+// resource must be a pointer to a struct, which is only used to determine the
+// resources' type and table, and each page is a slice of pointers of its type
+// (ex: []*User) with at most pageSize resources.  The pages are read using
+// keyset pagination on the resource's primary key, so each page is a query
+// which starts after the last primary key of the previous page, rather than
+// using an offset, and resources which are created or deleted while the pages
+// are read may or may not be included.  The page slice isn't reused, so fn may
+// retain it.  An error returned by fn is wrapped and returned.  Supports the
+// same options as SearchWhere, except WithLimit, WithOrder, WithOrderBy and
+// WithAppendResults, since the pages are ordered by the primary key.
+func (rw *RW) EachPage(ctx context.Context, resource interface{}, pageSize int, where string, args []interface{}, fn func(page interface{}) error, opt ...Option) error {
+	const op = "dbw.EachPage"
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case isNil(resource):
+		return fmt.Errorf("%s: missing resource: %w", op, ErrInvalidParameter)
+	case pageSize <= 0:
+		return fmt.Errorf("%s: page size must be greater than zero: %w", op, ErrInvalidParameter)
+	case where == "" && len(args) > 0:
+		return fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	case fn == nil:
+		return fmt.Errorf("%s: missing func: %w", op, ErrInvalidParameter)
+	}
+	typ := reflect.TypeOf(resource)
+	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: resource must be a pointer to a struct: %w", op, ErrInvalidParameter)
+	}
+	opts := rw.getOpts(opt...)
+	switch {
+	case opts.WithLimit != 0:
+		return fmt.Errorf("%s: with limit is not a supported option: %w", op, ErrInvalidParameter)
+	case opts.WithOrder != "" || opts.WithOrderBy != nil:
+		return fmt.Errorf("%s: with order is not a supported option: %w", op, ErrInvalidParameter)
+	case opts.WithAppendResults:
+		return fmt.Errorf("%s: with append results is not a supported option: %w", op, ErrInvalidParameter)
+	}
+	s, _, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(s.PrimaryFields) == 0 {
+		return fmt.Errorf("%s: %s has no primary key: %w", op, s.Table, ErrInvalidParameter)
+	}
+	order := make([]string, 0, len(s.PrimaryFields))
+	for _, f := range s.PrimaryFields {
+		order = append(order, f.DBName+" asc")
+	}
+
+	// the results are transformed after the last primary key of the page is
+	// read, since the transformer may modify it.  The options are copied, so
+	// the caller's aren't modified.
+	pageOpts := append(make([]Option, 0, len(opt)+3), opt...)
+	pageOpts = append(pageOpts, WithLimit(pageSize), WithOrder(strings.Join(order, ", ")), WithResultTransformer(nil))
+	var afterKey []interface{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		pageWhere, pageArgs := where, args
+		if afterKey != nil {
+			pageWhere, pageArgs = afterKeyWhere(s.PrimaryFields, afterKey, where, args)
+		}
+		page := reflect.New(reflect.SliceOf(typ))
+		if err := rw.SearchWhere(ctx, page.Interface(), pageWhere, pageArgs, pageOpts...); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		n := page.Elem().Len()
+		if n == 0 {
+			return nil
+		}
+		last := page.Elem().Index(n - 1).Elem()
+		afterKey = make([]interface{}, 0, len(s.PrimaryFields))
+		for _, f := range s.PrimaryFields {
+			v, _ := f.ValueOf(ctx, last)
+			afterKey = append(afterKey, v)
+		}
+		if err := transformResults(page.Interface(), opts.WithResultTransformer); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(page.Elem().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if n < pageSize {
+			return nil
+		}
+	}
+}
+
+// afterKeyWhere returns the where clause with parameters which matches the
+// rows of the where clause whose primary key is after the key, which is the
+// primary key of the last row of the previous page.  A composite key is
+// compared column by column (ex: "a > ? or (a = ? and b > ?)"), which every
+// dialect supports.
+func afterKeyWhere(fields []*schema.Field, key []interface{}, where string, args []interface{}) (string, []interface{}) {
+	var ors []string
+	var afterArgs []interface{}
+	for i, f := range fields {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, fields[j].DBName+" = ?")
+			afterArgs = append(afterArgs, key[j])
+		}
+		ands = append(ands, f.DBName+" > ?")
+		afterArgs = append(afterArgs, key[i])
+		ors = append(ors, "("+strings.Join(ands, " and ")+")")
+	}
+	afterWhere := "(" + strings.Join(ors, " or ") + ")"
+	if where != "" {
+		afterWhere = "(" + where + ") and " + afterWhere
+	}
+	return afterWhere, append(append(make([]interface{}, 0, len(args)+len(afterArgs)), args...), afterArgs...)
+}
@@ -0,0 +1,450 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeCursor(t *testing.T) {
+	t.Parallel()
+	testTime := time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := []struct {
+		name            string
+		values          map[string]interface{}
+		want            map[string]interface{}
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{
+			name: "types",
+			values: map[string]interface{}{
+				"name":        "alice",
+				"int":         int(-1),
+				"int8":        int8(-8),
+				"int16":       int16(-16),
+				"int32":       int32(-32),
+				"int64":       int64(-64),
+				"uint":        uint(1),
+				"uint8":       uint8(8),
+				"uint16":      uint16(16),
+				"uint32":      uint32(32),
+				"uint64":      uint64(64),
+				"float32":     float32(1.5),
+				"float64":     float64(2.25),
+				"bool":        true,
+				"create_time": testTime,
+				"bytes":       []byte("bytes"),
+				"null":        nil,
+			},
+			want: map[string]interface{}{
+				"name":        "alice",
+				"int":         int64(-1),
+				"int8":        int64(-8),
+				"int16":       int64(-16),
+				"int32":       int64(-32),
+				"int64":       int64(-64),
+				"uint":        uint64(1),
+				"uint8":       uint64(8),
+				"uint16":      uint64(16),
+				"uint32":      uint64(32),
+				"uint64":      uint64(64),
+				"float32":     float64(1.5),
+				"float64":     float64(2.25),
+				"bool":        true,
+				"create_time": testTime,
+				"bytes":       []byte("bytes"),
+				"null":        nil,
+			},
+		},
+		{
+			name:            "missing-values",
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing values",
+		},
+		{
+			name:            "missing-column",
+			values:          map[string]interface{}{"": "alice"},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing column name",
+		},
+		{
+			name:            "unsupported-type",
+			values:          map[string]interface{}{"name": struct{}{}},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "unsupported type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			encoded, err := dbw.EncodeCursor(tt.values)
+			if tt.wantErr {
+				require.Error(err)
+				assert.Empty(encoded)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(err, tt.wantErrIs)
+				}
+				if tt.wantErrContains != "" {
+					assert.Contains(err.Error(), tt.wantErrContains)
+				}
+				return
+			}
+			require.NoError(err)
+			assert.NotEmpty(encoded)
+
+			again, err := dbw.EncodeCursor(tt.values)
+			require.NoError(err)
+			assert.Equal(encoded, again)
+
+			got, err := dbw.DecodeCursor(encoded)
+			require.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+	t.Run("decode-errors", func(t *testing.T) {
+		encoded, err := dbw.EncodeCursor(map[string]interface{}{"name": "alice"})
+		require.NoError(t, err)
+		payload, sig, ok := strings.Cut(encoded, ".")
+		require.True(t, ok)
+		tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"v":1,"vals":[{"c":"name","t":"string","v":"bob"}]}`))
+		decodeTests := []struct {
+			name            string
+			cursor          string
+			wantErrContains string
+		}{
+			{"missing-cursor", "", "missing cursor"},
+			{"unsigned", payload, "missing cursor signature"},
+			{"bad-signature-encoding", payload + ".!!!", "unable to decode cursor signature"},
+			{"tampered", tampered + "." + sig, "invalid cursor signature"},
+			{"empty-signature", payload + ".", "invalid cursor signature"},
+		}
+		for _, tt := range decodeTests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				got, err := dbw.DecodeCursor(tt.cursor)
+				require.Error(err)
+				assert.Nil(got)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+	t.Run("bind-decoded-values", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		testCtx := context.Background()
+		conn, _ := dbw.TestSetup(t)
+		rw := dbw.New(conn)
+		for i := 0; i < 5; i++ {
+			testUser(t, rw, "cursor-user-"+strconv.Itoa(i), "", "")
+		}
+
+		var page []*dbtest.TestUser
+		err := rw.SearchWhere(testCtx, &page, "name like ?", []interface{}{"cursor-user-%"}, dbw.WithLimit(2), dbw.WithOrder("name asc"))
+		require.NoError(err)
+		require.Len(page, 2)
+
+		last := page[len(page)-1]
+		encoded, err := dbw.EncodeCursor(map[string]interface{}{"name": last.Name})
+		require.NoError(err)
+
+		values, err := dbw.DecodeCursor(encoded)
+		require.NoError(err)
+
+		var nextPage []*dbtest.TestUser
+		err = rw.SearchWhere(testCtx, &nextPage, "name like ? and name > ?", []interface{}{"cursor-user-%", values["name"]}, dbw.WithLimit(2), dbw.WithOrder("name asc"))
+		require.NoError(err)
+		require.Len(nextPage, 2)
+		assert.Equal("cursor-user-2", nextPage[0].Name)
+		assert.Equal("cursor-user-3", nextPage[1].Name)
+	})
+}
+
+func TestFindPage(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	var wantIds []string
+	for i := 0; i < 7; i++ {
+		u := testUser(t, rw, "page-user-"+strconv.Itoa(i), "", "")
+		wantIds = append(wantIds, u.PublicId)
+	}
+	sort.Strings(wantIds)
+	const where = "name like ?"
+	args := []interface{}{"page-user-%"}
+
+	t.Run("to-exhaustion", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var gotIds []string
+		var pageSizes []int
+		cursor := ""
+		for {
+			items, next, err := dbw.FindPage[dbtest.TestUser](testCtx, rw, "public_id", cursor, 3, where, args)
+			require.NoError(err)
+			pageSizes = append(pageSizes, len(items))
+			for _, u := range items {
+				gotIds = append(gotIds, u.PublicId)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		assert.Equal([]int{3, 3, 1}, pageSizes)
+		assert.Equal(wantIds, gotIds)
+	})
+	t.Run("single-page", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		items, next, err := dbw.FindPage[dbtest.TestUser](testCtx, rw, "public_id", "", len(wantIds), where, args)
+		require.NoError(err)
+		assert.Len(items, len(wantIds))
+		assert.Empty(next)
+	})
+	t.Run("with-result-transformer", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var transformed int
+		items, next, err := dbw.FindPage[dbtest.TestUser](testCtx, rw, "public_id", "", 2, where, args,
+			dbw.WithResultTransformer(func(interface{}) error {
+				transformed++
+				return nil
+			}),
+		)
+		require.NoError(err)
+		assert.Len(items, 2)
+		assert.NotEmpty(next)
+		assert.Equal(2, transformed)
+	})
+	t.Run("caller-options-unmodified", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		opt := make([]dbw.Option, 1, 4)
+		opt[0] = dbw.WithDebug(false)
+		_, _, err := dbw.FindPage[dbtest.TestUser](testCtx, rw, "public_id", "", 2, where, args, opt...)
+		require.NoError(err)
+		for _, o := range opt[1:cap(opt)] {
+			assert.Nil(o)
+		}
+	})
+
+	otherCursor, err := dbw.EncodeCursor(map[string]interface{}{"name": "page-user-1"})
+	require.NoError(t, err)
+	errTests := []struct {
+		name            string
+		rw              *dbw.RW
+		keyColumn       string
+		cursor          string
+		pageSize        int
+		where           string
+		args            []interface{}
+		opt             []dbw.Option
+		wantErrContains string
+	}{
+		{name: "nil-underlying", rw: &dbw.RW{}, keyColumn: "public_id", pageSize: 1, wantErrContains: "missing underlying db"},
+		{name: "invalid-key-column", rw: rw, keyColumn: "public_id; drop table db_test_user", pageSize: 1, wantErrContains: "invalid key column"},
+		{name: "unknown-key-column", rw: rw, keyColumn: "not_a_column", pageSize: 1, wantErrContains: "unknown key column"},
+		{name: "zero-page-size", rw: rw, keyColumn: "public_id", wantErrContains: "page size must be greater than zero"},
+		{name: "no-where-with-args", rw: rw, keyColumn: "public_id", pageSize: 1, args: args, wantErrContains: "args provided with empty where"},
+		{name: "malformed-cursor", rw: rw, keyColumn: "public_id", cursor: "!!!.!!!", pageSize: 1, wantErrContains: "unable to decode cursor"},
+		{name: "other-column-cursor", rw: rw, keyColumn: "public_id", cursor: otherCursor, pageSize: 1, wantErrContains: "cursor is not for key column"},
+		{name: "with-limit", rw: rw, keyColumn: "public_id", pageSize: 1, opt: []dbw.Option{dbw.WithLimit(10)}, wantErrContains: "with limit is not a supported option"},
+		{name: "with-order", rw: rw, keyColumn: "public_id", pageSize: 1, opt: []dbw.Option{dbw.WithOrder("name")}, wantErrContains: "with order is not a supported option"},
+		{name: "with-append-results", rw: rw, keyColumn: "public_id", pageSize: 1, opt: []dbw.Option{dbw.WithAppendResults(true)}, wantErrContains: "with append results is not a supported option"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			items, next, err := dbw.FindPage[dbtest.TestUser](testCtx, tt.rw, tt.keyColumn, tt.cursor, tt.pageSize, tt.where, tt.args, tt.opt...)
+			require.Error(err)
+			assert.ErrorIs(err, dbw.ErrInvalidParameter)
+			assert.Contains(err.Error(), tt.wantErrContains)
+			assert.Nil(items)
+			assert.Empty(next)
+		})
+	}
+}
+
+func TestDb_EachPage(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	var wantIds []string
+	for i := 0; i < 7; i++ {
+		u := testUser(t, rw, "each-page-user-"+strconv.Itoa(i), "", "")
+		wantIds = append(wantIds, u.PublicId)
+	}
+	sort.Strings(wantIds)
+	const where = "name like ?"
+	args := []interface{}{"each-page-user-%"}
+
+	t.Run("to-exhaustion", func(t *testing.T) {
+		tests := []struct {
+			pageSize      int
+			wantPageSizes []int
+		}{
+			{3, []int{3, 3, 1}},
+			{7, []int{7}},
+			{10, []int{7}},
+		}
+		for _, tt := range tests {
+			t.Run(strconv.Itoa(tt.pageSize), func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var gotIds []string
+				var pageSizes []int
+				err := rw.EachPage(testCtx, &dbtest.TestUser{}, tt.pageSize, where, args, func(page interface{}) error {
+					users, ok := page.([]*dbtest.TestUser)
+					require.True(ok)
+					pageSizes = append(pageSizes, len(users))
+					for _, u := range users {
+						gotIds = append(gotIds, u.PublicId)
+					}
+					return nil
+				})
+				require.NoError(err)
+				assert.Equal(tt.wantPageSizes, pageSizes)
+				assert.Equal(wantIds, gotIds)
+			})
+		}
+	})
+	t.Run("no-results", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var pages int
+		err := rw.EachPage(testCtx, &dbtest.TestUser{}, 3, "name = ?", []interface{}{"not-a-user"}, func(interface{}) error {
+			pages++
+			return nil
+		})
+		require.NoError(err)
+		assert.Equal(0, pages)
+	})
+	t.Run("fn-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		errStop := errors.New("stop")
+		var pages int
+		err := rw.EachPage(testCtx, &dbtest.TestUser{}, 3, where, args, func(interface{}) error {
+			pages++
+			return errStop
+		})
+		require.Error(err)
+		assert.ErrorIs(err, errStop)
+		assert.Equal(1, pages)
+	})
+	t.Run("canceled", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		ctx, cancel := context.WithCancel(testCtx)
+		var pages int
+		err := rw.EachPage(ctx, &dbtest.TestUser{}, 3, where, args, func(interface{}) error {
+			pages++
+			cancel()
+			return nil
+		})
+		require.Error(err)
+		assert.ErrorIs(err, context.Canceled)
+		assert.Equal(1, pages)
+	})
+	t.Run("caller-options-unmodified", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		opt := make([]dbw.Option, 1, 4)
+		opt[0] = dbw.WithDebug(false)
+		err := rw.EachPage(testCtx, &dbtest.TestUser{}, 3, where, args, func(interface{}) error { return nil }, opt...)
+		require.NoError(err)
+		for _, o := range opt[1:cap(opt)] {
+			assert.Nil(o)
+		}
+	})
+	t.Run("with-result-transformer", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var gotIds []string
+		err := rw.EachPage(testCtx, &dbtest.TestUser{}, 3, where, args,
+			func(page interface{}) error {
+				for _, u := range page.([]*dbtest.TestUser) {
+					gotIds = append(gotIds, u.PublicId)
+				}
+				return nil
+			},
+			// the transformer modifies the primary key, which must not
+			// change the next page
+			dbw.WithResultTransformer(func(i interface{}) error {
+				i.(*dbtest.TestUser).PublicId += "-transformed"
+				return nil
+			}),
+		)
+		require.NoError(err)
+		require.Len(gotIds, len(wantIds))
+		for i, id := range wantIds {
+			assert.Equal(id+"-transformed", gotIds[i])
+		}
+	})
+	t.Run("composite-primary-key", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		rw := dbw.New(conn)
+		_, err = rw.Exec(testCtx, "create table db_test_without_rowid (tenant text, key text, value text, primary key (tenant, key)) without rowid", nil)
+		require.NoError(err)
+		var want []string
+		for _, tenant := range []string{"t1", "t2", "t3"} {
+			for _, key := range []string{"a", "b", "c"} {
+				require.NoError(rw.Create(testCtx, &testWithoutRowidModel{Tenant: tenant, Key: key, Value: "v"}))
+				want = append(want, tenant+"/"+key)
+			}
+		}
+		var got []string
+		err = rw.EachPage(testCtx, &testWithoutRowidModel{}, 2, "", nil, func(page interface{}) error {
+			for _, m := range page.([]*testWithoutRowidModel) {
+				got = append(got, m.Tenant+"/"+m.Key)
+			}
+			return nil
+		})
+		require.NoError(err)
+		assert.Equal(want, got)
+	})
+
+	noop := func(interface{}) error { return nil }
+	errTests := []struct {
+		name            string
+		rw              *dbw.RW
+		resource        interface{}
+		pageSize        int
+		where           string
+		args            []interface{}
+		fn              func(interface{}) error
+		opt             []dbw.Option
+		wantErrContains string
+	}{
+		{name: "nil-underlying", rw: &dbw.RW{}, resource: &dbtest.TestUser{}, pageSize: 1, fn: noop, wantErrContains: "missing underlying db"},
+		{name: "missing-resource", rw: rw, pageSize: 1, fn: noop, wantErrContains: "missing resource"},
+		{name: "not-a-struct", rw: rw, resource: &[]*dbtest.TestUser{}, pageSize: 1, fn: noop, wantErrContains: "resource must be a pointer to a struct"},
+		{name: "zero-page-size", rw: rw, resource: &dbtest.TestUser{}, fn: noop, wantErrContains: "page size must be greater than zero"},
+		{name: "no-where-with-args", rw: rw, resource: &dbtest.TestUser{}, pageSize: 1, args: args, fn: noop, wantErrContains: "args provided with empty where"},
+		{name: "missing-fn", rw: rw, resource: &dbtest.TestUser{}, pageSize: 1, wantErrContains: "missing func"},
+		{name: "with-limit", rw: rw, resource: &dbtest.TestUser{}, pageSize: 1, fn: noop, opt: []dbw.Option{dbw.WithLimit(10)}, wantErrContains: "with limit is not a supported option"},
+		{name: "with-order", rw: rw, resource: &dbtest.TestUser{}, pageSize: 1, fn: noop, opt: []dbw.Option{dbw.WithOrder("name")}, wantErrContains: "with order is not a supported option"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			err := tt.rw.EachPage(testCtx, tt.resource, tt.pageSize, tt.where, tt.args, tt.fn, tt.opt...)
+			require.Error(err)
+			assert.ErrorIs(err, dbw.ErrInvalidParameter)
+			assert.Contains(err.Error(), tt.wantErrContains)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeCursor(t *testing.T) {
+	t.Parallel()
+	key := bytes.Repeat([]byte("k"), minCursorKeyLen)
+	sign := func(s string) string {
+		payload := base64.RawURLEncoding.EncodeToString([]byte(s))
+		return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(key, payload))
+	}
+	t.Run("round-trip", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		encoded, err := encodeCursor(key, map[string]interface{}{"name": "alice"})
+		require.NoError(err)
+		got, err := decodeCursor(key, encoded)
+		require.NoError(err)
+		assert.Equal(map[string]interface{}{"name": "alice"}, got)
+
+		_, err = decodeCursor(bytes.Repeat([]byte("o"), minCursorKeyLen), encoded)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "invalid cursor signature")
+	})
+	tests := []struct {
+		name            string
+		cursor          string
+		wantErrContains string
+	}{
+		{"not-base64", "!!!." + base64.RawURLEncoding.EncodeToString(signCursor(key, "!!!")), "unable to decode cursor"},
+		{"not-json", sign("not-json"), "unable to unmarshal cursor"},
+		{"bad-version", sign(`{"v":2,"vals":[{"c":"name","t":"string","v":"alice"}]}`), "unsupported cursor version"},
+		{"no-values", sign(`{"v":1,"vals":[]}`), "cursor has no values"},
+		{"missing-column", sign(`{"v":1,"vals":[{"c":"","t":"string","v":"alice"}]}`), "missing column name"},
+		{"dup-column", sign(`{"v":1,"vals":[{"c":"name","t":"string","v":"alice"},{"c":"name","t":"string","v":"bob"}]}`), "duplicate column"},
+		{"bad-type", sign(`{"v":1,"vals":[{"c":"name","t":"struct","v":"alice"}]}`), "unsupported type"},
+		{"bad-int", sign(`{"v":1,"vals":[{"c":"name","t":"int","v":"alice"}]}`), "invalid int value"},
+		{"bad-time", sign(`{"v":1,"vals":[{"c":"name","t":"time","v":"alice"}]}`), "invalid time value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := decodeCursor(key, tt.cursor)
+			require.Error(err)
+			assert.Nil(got)
+			assert.ErrorIs(err, ErrInvalidParameter)
+			assert.Contains(err.Error(), tt.wantErrContains)
+		})
+	}
+}
+
+func TestInitCursorKey(t *testing.T) {
+	// do not run with t.Parallel(), since the key is shared by every cursor
+	assert, require := assert.New(t), require.New(t)
+	prev, err := cursorSigningKey()
+	require.NoError(err)
+	t.Cleanup(func() { cursorKey.Store(prev) })
+
+	encoded, err := EncodeCursor(map[string]interface{}{"name": "alice"})
+	require.NoError(err)
+
+	err = InitCursorKey([]byte("too-short"))
+	require.Error(err)
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	key := bytes.Repeat([]byte("k"), minCursorKeyLen)
+	require.NoError(InitCursorKey(key))
+	// the key is copied, so modifying it doesn't change the signing key
+	key[0] = 'x'
+	got, err := cursorSigningKey()
+	require.NoError(err)
+	assert.Equal(bytes.Repeat([]byte("k"), minCursorKeyLen), got)
+
+	// cursors signed with the previous key are rejected
+	_, err = DecodeCursor(encoded)
+	require.Error(err)
+	assert.ErrorIs(err, ErrInvalidParameter)
+
+	encoded, err = EncodeCursor(map[string]interface{}{"name": "alice"})
+	require.NoError(err)
+	values, err := DecodeCursor(encoded)
+	require.NoError(err)
+	assert.Equal(map[string]interface{}{"name": "alice"}, values)
+}
@@ -8,6 +8,9 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/jackc/pgconn"
@@ -34,6 +37,10 @@ const (
 
 	// Sqlite is a sqlite db type
 	Sqlite DbType = 2
+
+	// CockroachDB is a cockroachdb db type, which is opened using the postgres
+	// dialect
+	CockroachDB DbType = 3
 )
 
 // String provides a string rep of the DbType.
@@ -42,6 +49,7 @@ func (db DbType) String() string {
 		"unknown",
 		"postgres",
 		"sqlite",
+		"cockroachdb",
 	}[db]
 }
 
@@ -53,6 +61,8 @@ func StringToDbType(dialect string) (DbType, error) {
 		return Postgres, nil
 	case "sqlite":
 		return Sqlite, nil
+	case "cockroachdb":
+		return CockroachDB, nil
 	default:
 		return UnknownDB, fmt.Errorf("%s is an unknown dialect", dialect)
 	}
@@ -63,15 +73,118 @@ func StringToDbType(dialect string) (DbType, error) {
 // pool.
 type DB struct {
 	wrapped *gorm.DB
+
+	// rejectFullScans specifies that unlimited reads without a where clause
+	// are rejected (see: WithRejectFullScans)
+	rejectFullScans bool
+
+	// retryableErrorFn decides whether an error is retryable and it
+	// overrides the built-in detection of transient errors (see:
+	// WithRetryableErrorFunc)
+	retryableErrorFn func(error) bool
+
+	// readTimeout and writeTimeout are the default timeouts for read and
+	// write operations (see: WithDefaultReadTimeout and
+	// WithDefaultWriteTimeout)
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	// createTimeColumn and updateTimeColumn are the columns whose values are
+	// managed by the database (see: WithCreateTimeColumn and
+	// WithUpdateTimeColumn)
+	createTimeColumn string
+	updateTimeColumn string
+
+	// tableResolver resolves the table name for every operation (see:
+	// WithTableResolver)
+	tableResolver func(ctx context.Context, defaultName string) string
+
+	// createBatchSize is the default batch size for CreateItems (see:
+	// WithCreateBatchSize)
+	createBatchSize int
+
+	// defaultOptions are the default options for every operation and they're
+	// shared with the DB's transactions (see: SetDefaultOptions)
+	defaultOptions *defaultOptions
+
+	// healthChecker runs the DB's health checks and it's shared with the DB's
+	// transactions (see: WithHealthCheck)
+	healthChecker *healthChecker
+
+	// namedQueries are the DB's registered queries and they're shared with
+	// the DB's transactions (see: RegisterQuery)
+	namedQueries *namedQueries
+
+	// softDeleteColumns are the DB's soft delete columns (see:
+	// WithSoftDeleteColumn)
+	softDeleteColumns []SoftDeleteColumn
+
+	// writeCallbacks are invoked before the resources of their types are
+	// written (see: WithWriteCallback)
+	writeCallbacks []WriteCallback
+
+	// opLimiter limits the DB's concurrent statements and it's shared with
+	// the DB's transactions (see: WithMaxConcurrentOps)
+	opLimiter *opLimiter
+
+	// readOnly specifies that the DB's writes are rejected and it's shared
+	// with the DB's transactions (see: SetReadOnly)
+	readOnly *atomic.Bool
+
+	// logger is the hclog the DB was opened with, which is used for the
+	// messages that the hclog's gorm logger doesn't log (see: WithLogger)
+	logger hclog.Logger
+
+	// catalogCache caches the results of catalog lookups which would otherwise
+	// be repeated by every operation (ex: the on conflict targets which match a
+	// unique key) and it's shared with the DB's transactions
+	catalogCache *sync.Map
+
+	// dbType is the DbType the DB was opened with, which is needed for db
+	// types like CockroachDB that share a dialect with another db type.  It's
+	// UnknownDB when the DB was opened using OpenWith(...)
+	dbType DbType
+
+	// txId is the id assigned to a transaction when it begins and it's empty
+	// when the DB isn't a transaction.
+	txId string
+}
+
+// clone returns a copy of the DB which wraps the provided gorm DB, while
+// retaining the DB's settings.  It's typically used when starting a
+// transaction.
+func (db *DB) clone(wrapped *gorm.DB) *DB {
+	cp := *db
+	cp.wrapped = wrapped
+	return &cp
 }
 
-// DbType will return the DbType and raw name of the connection type
+// DbType will return the DbType and raw name of the connection type.  The raw
+// name is the dialect's name, so it's "postgres" for a CockroachDB connection.
 func (db *DB) DbType() (typ DbType, rawName string, e error) {
 	rawName = db.wrapped.Dialector.Name()
+	if db.dbType != UnknownDB {
+		return db.dbType, rawName, nil
+	}
 	typ, _ = StringToDbType(rawName)
 	return typ, rawName, nil
 }
 
+// Quote will quote the identifier (ex: a table or column name) for the
+// dialect of the db, so it can be safely interpolated into sql which is built
+// dynamically (ex: WithWhere, WithJoin or WithTable).  Postgres identifiers
+// are quoted with double quotes and sqlite identifiers with backticks.  A
+// qualified identifier (ex: public.users) is quoted part by part and an
+// identifier which is already quoted is returned as it is.
+//
+// Quote is only for identifiers and never for values, which must still be
+// passed as parameters using placeholders.
+func (db *DB) Quote(identifier string) string {
+	var sb strings.Builder
+	db.wrapped.Dialector.QuoteTo(&sb, identifier)
+	return sb.String()
+}
+
 // Debug will enable/disable debug info for the connection
 func (db *DB) Debug(on bool) {
 	if on {
@@ -103,6 +216,44 @@ const (
 	Info
 )
 
+// SetDefaultOptions sets the default options for every operation of the RWs
+// created from the DB, including RWs created before the defaults are set and
+// transactions started from them (ex: WithDebug in a dev environment).  The
+// options provided to an operation take precedence over the defaults, which
+// take precedence over dbw's built-in defaults.  Options which are only valid
+// for Open(..) and OpenWith(...) have no effect.  Each call replaces the
+// previous defaults and calling it without options clears them.
+func (db *DB) SetDefaultOptions(opt ...Option) {
+	if db.defaultOptions == nil {
+		zeroDbMu.Lock()
+		if db.defaultOptions == nil {
+			db.defaultOptions = &defaultOptions{}
+		}
+		zeroDbMu.Unlock()
+	}
+	db.defaultOptions.set(opt)
+}
+
+// defaultOptions are the default options of a DB, which are safe for
+// concurrent use.
+type defaultOptions struct {
+	mu   sync.RWMutex
+	opts []Option
+}
+
+func (d *defaultOptions) set(opts []Option) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opts = append([]Option(nil), opts...)
+}
+
+// get returns a copy of the default options, which the caller may append to.
+func (d *defaultOptions) get() []Option {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Option(nil), d.opts...)
+}
+
 // LogLevel will set the logging level for the db
 func (db *DB) LogLevel(l LogLevel) {
 	db.wrapped.Logger = db.wrapped.Logger.LogMode(logger.LogLevel(l))
@@ -111,8 +262,9 @@ func (db *DB) LogLevel(l LogLevel) {
 // SqlDB returns the underlying sql.DB  Note: this makes it possible to do
 // things like set database/sql connection options like SetMaxIdleConns. If
 // you're simply setting max/min connections then you should use the
-// WithMinOpenConnections and WithMaxOpenConnections options when
-// "opening" the database.
+// WithMinOpenConnections, WithMaxOpenConnections, WithMaxIdleConnections,
+// WithConnMaxLifetime and WithConnMaxIdleTime options when "opening" the
+// database.
 //
 // Care should be take when deciding to use this for basic database operations
 // like Exec, Query, etc since these functions are already provided by dbw.RW
@@ -135,6 +287,14 @@ func (db *DB) Close(ctx context.Context) error {
 	if db.wrapped == nil {
 		return fmt.Errorf("%s: missing underlying database: %w", op, ErrInternal)
 	}
+	if db.healthChecker != nil {
+		db.healthChecker.shutdown()
+	}
+	if db.namedQueries != nil {
+		if err := db.namedQueries.close(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
 	underlying, err := db.wrapped.DB()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -142,8 +302,35 @@ func (db *DB) Close(ctx context.Context) error {
 	return underlying.Close()
 }
 
-// Open a database connection which is long-lived. The options of
-// WithLogger, WithLogLevel and WithMaxOpenConnections are supported.
+// ListTables returns the names of the tables in the current schema of the
+// database.  The dialect differences (information_schema for postgres,
+// sqlite_master for sqlite, etc) are handled by the underlying driver.
+func (db *DB) ListTables(ctx context.Context) ([]string, error) {
+	const op = "dbw.(DB).ListTables"
+	if db.wrapped == nil {
+		return nil, fmt.Errorf("%s: missing underlying database: %w", op, ErrInternal)
+	}
+	tables, err := db.wrapped.WithContext(ctx).Migrator().GetTables()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tables, nil
+}
+
+// Open a database connection which is long-lived. CockroachDB connections
+// are opened using the postgres dialect and, unless WithRetryableErrorFunc is
+// used, its transaction restart errors are classified as retryable. The
+// options of WithLogger, WithLogLevel, WithMaxOpenConnections,
+// WithRejectFullScans, WithContextLogFields, WithRetryableErrorFunc,
+// WithDefaultReadTimeout, WithDefaultWriteTimeout, WithCreateTimeColumn,
+// WithUpdateTimeColumn, WithTableResolver, WithCreateBatchSize,
+// WithLogSQLArgs, WithHealthCheck, WithGormPlugin, WithSoftDeleteColumn and
+// WithMaxConcurrentOps and WithWriteCallback are supported.
+//
+// The connection url is validated before the database is opened and an
+// ErrInvalidParameter is returned for a malformed url: postgres and
+// cockroachdb urls must be parsable by the driver and a sqlite database file
+// must be in an existing directory.
 //
 // Note: Consider if you need to call Close() on the returned DB.  Typically the
 // answer is no, but there are occasions when it's necessary.  See the sql.DB
@@ -153,9 +340,12 @@ func Open(dbType DbType, connectionUrl string, opt ...Option) (*DB, error) {
 	if connectionUrl == "" {
 		return nil, fmt.Errorf("%s: missing connection url: %w", op, ErrInvalidParameter)
 	}
+	if err := validateConnectionUrl(dbType, connectionUrl); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 	var dialect gorm.Dialector
 	switch dbType {
-	case Postgres:
+	case Postgres, CockroachDB:
 		dialect = postgres.New(postgres.Config{
 			DSN: connectionUrl,
 		},
@@ -166,7 +356,7 @@ func Open(dbType DbType, connectionUrl string, opt ...Option) (*DB, error) {
 	default:
 		return nil, fmt.Errorf("unable to open %s database type", dbType)
 	}
-	db, err := openDialector(dialect, opt...)
+	db, err := openDialector(dialect, dbType, opt...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -187,38 +377,103 @@ type Dialector interface {
 }
 
 // OpenWith will open a database connection using a Dialector which is
-// long-lived. The options of WithLogger, WithLogLevel and
-// WithMaxOpenConnections are supported.
+// long-lived. The options of WithLogger, WithLogLevel, WithMaxOpenConnections,
+// WithRejectFullScans, WithContextLogFields, WithRetryableErrorFunc,
+// WithDefaultReadTimeout, WithDefaultWriteTimeout, WithCreateTimeColumn,
+// WithUpdateTimeColumn, WithTableResolver, WithCreateBatchSize,
+// WithLogSQLArgs, WithHealthCheck, WithGormPlugin, WithSoftDeleteColumn and
+// WithMaxConcurrentOps and WithWriteCallback are supported.
 //
 // Note: Consider if you need to call Close() on the returned DB.  Typically the
 // answer is no, but there are occasions when it's necessary.  See the sql.DB
 // docs for more information.
 func OpenWith(dialector Dialector, opt ...Option) (*DB, error) {
-	return openDialector(dialector, opt...)
+	return openDialector(dialector, UnknownDB, opt...)
 }
 
-func openDialector(dialect gorm.Dialector, opt ...Option) (*DB, error) {
-	db, err := gorm.Open(dialect, &gorm.Config{})
+func openDialector(dialect gorm.Dialector, dbType DbType, opt ...Option) (*DB, error) {
+	opts := GetOpts(opt...)
+	if opts.WithCreateBatchSize < 0 {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: create batch size must not be negative", dialect)
+	}
+	if opts.WithHealthCheck < 0 {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: health check interval must not be negative", dialect)
+	}
+	if opts.WithMaxIdleConnections < 0 {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: max idle connections must not be negative", dialect)
+	}
+	if opts.WithConnMaxLifetime < 0 {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: conn max lifetime must not be negative", dialect)
+	}
+	if opts.WithConnMaxIdleTime < 0 {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: conn max idle time must not be negative", dialect)
+	}
+	if opts.WithMaxOpenConnections > 0 && opts.WithMaxIdleConnections > opts.WithMaxOpenConnections {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: %s", dialect, fmt.Sprintf("max_idle_connections must not be greater than max_open_connections of %d", opts.WithMaxOpenConnections))
+	}
+	if opts.WithMaxConcurrentOps < 0 {
+		return nil, fmt.Errorf("unable to create db object with dialect %s: max concurrent ops must not be negative", dialect)
+	}
+	for _, c := range opts.WithSoftDeleteColumns {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("unable to create db object with dialect %s: %w", dialect, err)
+		}
+	}
+	for _, c := range opts.WithWriteCallbacks {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("unable to create db object with dialect %s: %w", dialect, err)
+		}
+	}
+	db, err := gorm.Open(dialect, &gorm.Config{CreateBatchSize: opts.WithCreateBatchSize})
 	if err != nil {
 		return nil, fmt.Errorf("unable to open database: %w", err)
 	}
+	if opts.WithAutoInvalidateBadConns {
+		sqlDB, ok := db.ConnPool.(*sql.DB)
+		if !ok {
+			return nil, fmt.Errorf("unable to create db object with dialect %s: auto invalidating bad connections requires a *sql.DB connection pool: %w", dialect, ErrInvalidParameter)
+		}
+		pool := &badConnRetryPool{DB: sqlDB}
+		db.ConnPool = pool
+		db.Statement.ConnPool = pool
+	}
+	var limiter *opLimiter
+	if opts.WithMaxConcurrentOps > 0 {
+		limiter = newOpLimiter(opts.WithMaxConcurrentOps)
+		if err := limiter.register(db); err != nil {
+			return nil, fmt.Errorf("unable to limit concurrent ops: %w", err)
+		}
+	}
+	for _, plugin := range opts.WithGormPlugins {
+		if isNil(plugin) {
+			return nil, fmt.Errorf("unable to register gorm plugin: missing plugin: %w", ErrInvalidParameter)
+		}
+		if err := db.Use(plugin); err != nil {
+			return nil, fmt.Errorf("unable to register gorm plugin %s: %w", plugin.Name(), err)
+		}
+	}
 	if strings.ToLower(dialect.Name()) == "sqlite" {
 		if err := db.Exec("PRAGMA foreign_keys=ON", nil).Error; err != nil {
 			return nil, fmt.Errorf("unable to enable sqlite foreign keys: %w", err)
 		}
 	}
-	opts := GetOpts(opt...)
 	if opts.WithLogger != nil {
 		var newLogger logger.Interface
 		loggerConfig := logger.Config{
-			LogLevel: logger.LogLevel(opts.withLogLevel), // Log level
-			Colorful: false,                              // Disable color
+			LogLevel:             logger.LogLevel(opts.withLogLevel), // Log level
+			Colorful:             false,                              // Disable color
+			ParameterizedQueries: !opts.WithLogSQLArgs,               // Omit the args from the sql
 		}
 		switch v := opts.WithLogger.(type) {
 		case LogWriter:
 			// it's already a gorm logger, so we just need to configure it
 			newLogger = logger.New(v, loggerConfig)
 		default:
+			if opts.WithContextLogFields != nil {
+				// create a gorm logger for each query with the context's fields
+				newLogger = newCtxFieldsLogger(opts.WithLogger, loggerConfig, opts.WithContextLogFields)
+				break
+			}
 			newLogger = logger.New(
 				getGormLogger(opts.WithLogger), // wrap the hclog with a gorm logger that only logs errors
 				loggerConfig,
@@ -226,6 +481,10 @@ func openDialector(dialect gorm.Dialector, opt ...Option) (*DB, error) {
 		}
 		db = db.Session(&gorm.Session{Logger: newLogger})
 	}
+	if opts.WithLogger == nil && !opts.WithLogSQLArgs {
+		// the existing logger with the args omitted from the sql
+		db = db.Session(&gorm.Session{Logger: newParamsOmittingLogger(db.Logger)})
+	}
 	if opts.WithMaxOpenConnections > 0 {
 		if opts.WithMinOpenConnections > 0 && (opts.WithMaxOpenConnections < opts.WithMinOpenConnections) {
 			return nil, fmt.Errorf("unable to create db object with dialect %s: %s", dialect, fmt.Sprintf("max_open_connections must be unlimited by setting 0 or at least %d", opts.WithMinOpenConnections))
@@ -236,8 +495,58 @@ func openDialector(dialect gorm.Dialector, opt ...Option) (*DB, error) {
 		}
 		underlyingDB.SetMaxOpenConns(opts.WithMaxOpenConnections)
 	}
+	maxIdleConns := defaultMaxIdleConns
+	if opts.WithMaxIdleConnections > 0 || opts.WithConnMaxLifetime > 0 || opts.WithConnMaxIdleTime > 0 {
+		underlyingDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("unable retrieve db: %w", err)
+		}
+		if opts.WithMaxIdleConnections > 0 {
+			maxIdleConns = opts.WithMaxIdleConnections
+			underlyingDB.SetMaxIdleConns(maxIdleConns)
+		}
+		if opts.WithConnMaxLifetime > 0 {
+			underlyingDB.SetConnMaxLifetime(opts.WithConnMaxLifetime)
+		}
+		if opts.WithConnMaxIdleTime > 0 {
+			underlyingDB.SetConnMaxIdleTime(opts.WithConnMaxIdleTime)
+		}
+	}
 
-	ret := &DB{wrapped: db}
+	ret := &DB{
+		wrapped:           db,
+		rejectFullScans:   opts.WithRejectFullScans,
+		retryableErrorFn:  opts.WithRetryableErrorFunc,
+		dbType:            dbType,
+		readTimeout:       opts.WithDefaultReadTimeout,
+		writeTimeout:      opts.WithDefaultWriteTimeout,
+		createTimeColumn:  opts.WithCreateTimeColumn,
+		updateTimeColumn:  opts.WithUpdateTimeColumn,
+		tableResolver:     opts.WithTableResolver,
+		createBatchSize:   opts.WithCreateBatchSize,
+		defaultOptions:    &defaultOptions{},
+		namedQueries:      &namedQueries{},
+		softDeleteColumns: opts.WithSoftDeleteColumns,
+		writeCallbacks:    opts.WithWriteCallbacks,
+		opLimiter:         limiter,
+		readOnly:          &atomic.Bool{},
+		catalogCache:      &sync.Map{},
+		logger:            opts.WithLogger,
+	}
+	if dbType == CockroachDB && ret.retryableErrorFn == nil {
+		ret.retryableErrorFn = isCockroachTransientError
+	}
+	if opts.WithHealthCheck > 0 {
+		underlyingDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("unable retrieve db: %w", err)
+		}
+		ret.healthChecker = newHealthChecker(opts.WithHealthCheck, underlyingDB.PingContext, func() {
+			underlyingDB.SetMaxIdleConns(0)
+			underlyingDB.SetMaxIdleConns(maxIdleConns)
+		})
+		ret.healthChecker.start()
+	}
 	ret.Debug(opts.WithDebug)
 	return ret, nil
 }
@@ -265,3 +574,141 @@ func (g gormLogger) Printf(_ string, values ...interface{}) {
 func getGormLogger(log hclog.Logger) gormLogger {
 	return gormLogger{logger: log}
 }
+
+// ctxFieldsLogger is a gorm logger which attaches the fields extracted from
+// an operation's context to the hclog used to log the operation (see:
+// WithContextLogFields)
+type ctxFieldsLogger struct {
+	logger   hclog.Logger
+	config   logger.Config
+	fieldsFn func(ctx context.Context) []interface{}
+}
+
+func newCtxFieldsLogger(l hclog.Logger, config logger.Config, fieldsFn func(ctx context.Context) []interface{}) *ctxFieldsLogger {
+	return &ctxFieldsLogger{logger: l, config: config, fieldsFn: fieldsFn}
+}
+
+// LogMode satisfies the gorm logger.Interface
+func (l *ctxFieldsLogger) LogMode(level logger.LogLevel) logger.Interface {
+	cp := *l
+	cp.config.LogLevel = level
+	return &cp
+}
+
+// Info satisfies the gorm logger.Interface
+func (l *ctxFieldsLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	l.withCtx(ctx).Info(ctx, msg, data...)
+}
+
+// Warn satisfies the gorm logger.Interface
+func (l *ctxFieldsLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	l.withCtx(ctx).Warn(ctx, msg, data...)
+}
+
+// Error satisfies the gorm logger.Interface
+func (l *ctxFieldsLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	l.withCtx(ctx).Error(ctx, msg, data...)
+}
+
+// Trace satisfies the gorm logger.Interface
+func (l *ctxFieldsLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.withCtx(ctx).Trace(ctx, begin, fc, err)
+}
+
+// ParamsFilter satisfies the gorm.ParamsFilter interface and omits the
+// args from the logged sql when the logger is configured to (see:
+// WithLogSQLArgs)
+func (l *ctxFieldsLogger) ParamsFilter(_ context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
+
+// withCtx returns a gorm logger which uses the hclog with the fields from the
+// ctx attached.
+func (l *ctxFieldsLogger) withCtx(ctx context.Context) logger.Interface {
+	hl := l.logger
+	if ctx != nil {
+		if fields := l.fieldsFn(ctx); len(fields) > 0 {
+			hl = hl.With(fields...)
+		}
+	}
+	return logger.New(getGormLogger(hl), l.config)
+}
+
+// paramsOmittingLogger wraps a gorm logger and omits the args from the sql
+// which it logs (see: WithLogSQLArgs)
+type paramsOmittingLogger struct {
+	logger.Interface
+}
+
+func newParamsOmittingLogger(l logger.Interface) *paramsOmittingLogger {
+	return &paramsOmittingLogger{Interface: l}
+}
+
+// LogMode satisfies the gorm logger.Interface and returns a
+// paramsOmittingLogger which wraps the logger with the new log level
+func (l *paramsOmittingLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return newParamsOmittingLogger(l.Interface.LogMode(level))
+}
+
+// ParamsFilter satisfies the gorm.ParamsFilter interface and omits the args
+// from the logged sql
+func (l *paramsOmittingLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+// txLogger wraps a gorm logger and includes a transaction's id in the
+// statements and messages logged for operations within the transaction.
+type txLogger struct {
+	logger.Interface
+	txId string
+}
+
+func newTxLogger(l logger.Interface, txId string) *txLogger {
+	return &txLogger{Interface: l, txId: txId}
+}
+
+// LogMode satisfies the gorm logger.Interface and returns a txLogger which
+// wraps the logger with the new log level
+func (l *txLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return newTxLogger(l.Interface.LogMode(level), l.txId)
+}
+
+// Info satisfies the gorm logger.Interface
+func (l *txLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	l.Interface.Info(ctx, l.withTxId(msg), data...)
+}
+
+// Warn satisfies the gorm logger.Interface
+func (l *txLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	l.Interface.Warn(ctx, l.withTxId(msg), data...)
+}
+
+// Error satisfies the gorm logger.Interface
+func (l *txLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	l.Interface.Error(ctx, l.withTxId(msg), data...)
+}
+
+// Trace satisfies the gorm logger.Interface and appends the transaction's id
+// as a comment to the logged sql
+func (l *txLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.Interface.Trace(ctx, begin, func() (string, int64) {
+		sql, rowsAffected := fc()
+		return l.withTxId(sql), rowsAffected
+	}, err)
+}
+
+// ParamsFilter satisfies the gorm.ParamsFilter interface, when the wrapped
+// logger supports it.
+func (l *txLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if f, ok := l.Interface.(gorm.ParamsFilter); ok {
+		return f.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
+
+func (l *txLogger) withTxId(s string) string {
+	return fmt.Sprintf("%s /* tx_id: %s */", s, l.txId)
+}
@@ -5,12 +5,17 @@ package dbw
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/hashicorp/go-hclog"
 	"github.com/jackc/pgconn"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
@@ -53,3 +58,179 @@ func TestDB_gormLogger(t *testing.T) {
 		assert.NotEmpty(t, buf.Bytes())
 	})
 }
+
+func TestDB_paramsOmittingLogger(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	testCtx := context.Background()
+	db, err := Open(Sqlite, "file::memory:", WithLogSQLArgs(false))
+	require.NoError(err)
+	t.Cleanup(func() { _ = db.Close(testCtx) })
+
+	// the existing logger is wrapped, rather than replaced
+	l, ok := db.wrapped.Logger.(*paramsOmittingLogger)
+	require.True(ok)
+	assert.Equal(logger.Default.LogMode(logger.Error), l.Interface)
+
+	// the args are omitted after the log level is changed
+	db.LogLevel(Info)
+	f, ok := db.wrapped.Logger.(gorm.ParamsFilter)
+	require.True(ok)
+	sql, params := f.ParamsFilter(testCtx, "select ?", "alice@example.com")
+	assert.Equal("select ?", sql)
+	assert.Nil(params)
+	assert.Equal(logger.Default.LogMode(logger.Info), db.wrapped.Logger.(*paramsOmittingLogger).Interface)
+}
+
+func TestDB_CockroachDB(t *testing.T) {
+	t.Parallel()
+	restartErr := errors.New("TransactionRetryWithProtoRefreshError: restart transaction")
+	openCockroachDB := func(t *testing.T, opt ...Option) *DB {
+		t.Helper()
+		sqlDB, _, err := sqlmock.New()
+		require.NoError(t, err)
+		db, err := openDialector(postgres.New(postgres.Config{Conn: sqlDB}), CockroachDB, opt...)
+		require.NoError(t, err)
+		return db
+	}
+	t.Run("db-type", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		db := openCockroachDB(t)
+		typ, rawName, err := db.DbType()
+		require.NoError(err)
+		assert.Equal(CockroachDB, typ)
+		assert.Equal("postgres", rawName)
+		assert.Equal("cockroachdb", typ.String())
+	})
+	t.Run("retryable-errors", func(t *testing.T) {
+		assert := assert.New(t)
+		rw := New(openCockroachDB(t))
+		assert.True(rw.IsRetryableError(restartErr))
+		assert.False(rw.IsRetryableError(errors.New("syntax error")))
+
+		// a postgres db doesn't classify the restart error as retryable
+		sqlDB, _, err := sqlmock.New()
+		require.NoError(t, err)
+		pgDb, err := openDialector(postgres.New(postgres.Config{Conn: sqlDB}), Postgres)
+		require.NoError(t, err)
+		assert.False(New(pgDb).IsRetryableError(restartErr))
+	})
+	t.Run("with-retryable-error-func", func(t *testing.T) {
+		assert := assert.New(t)
+		rw := New(openCockroachDB(t, WithRetryableErrorFunc(func(error) bool { return false })))
+		assert.False(rw.IsRetryableError(restartErr))
+	})
+	t.Run("on-conflict-constraint", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := New(openCockroachDB(t))
+		type testUser struct {
+			PublicId string `gorm:"primaryKey"`
+			Name     string
+		}
+		err := rw.Create(context.Background(), &testUser{PublicId: "u_1234567890", Name: "alice"}, WithOnConflict(&OnConflict{
+			Target: Constraint("db_test_user_pkey"),
+			Action: DoNothing(true),
+		}))
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "not supported by cockroachdb")
+	})
+}
+
+func TestDB_Quote(t *testing.T) {
+	t.Parallel()
+	sqlDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	pgDb, err := openDialector(postgres.New(postgres.Config{Conn: sqlDB}), Postgres)
+	require.NoError(t, err)
+	sqliteDb, err := Open(Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqliteDb.Close(context.Background()) })
+
+	tests := []struct {
+		name       string
+		identifier string
+		wantPg     string
+		wantSqlite string
+	}{
+		{"column", "name", `"name"`, "`name`"},
+		{"qualified-table", "public.users", `"public"."users"`, "`public`.`users`"},
+		{"already-quoted", `"name"`, `"name"`, "`\"name\"`"},
+		{"embedded-quote", `na"me`, `"na""me"`, "`na\"me`"},
+		{"embedded-backtick", "na`me", "\"na`me\"", "`na``me`"},
+		{"injection", `name"; drop table users; --`, `"name""; drop table users; --"`, "`name\"; drop table users; --`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tt.wantPg, pgDb.Quote(tt.identifier))
+			assert.Equal(tt.wantSqlite, sqliteDb.Quote(tt.identifier))
+		})
+	}
+	t.Run("sqlite-query", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := New(sqliteDb)
+		_, err := rw.Exec(context.Background(), "create table "+sqliteDb.Quote("db_test_quote")+" ("+sqliteDb.Quote("order")+" text)", nil)
+		require.NoError(err)
+		_, err = rw.Exec(context.Background(), "insert into db_test_quote values (?)", []interface{}{"first"})
+		require.NoError(err)
+		var got string
+		require.NoError(sqliteDb.wrapped.Raw("select " + sqliteDb.Quote("order") + " from db_test_quote").Scan(&got).Error)
+		assert.Equal("first", got)
+	})
+}
+
+// testGormPlugin is a gorm plugin which counts the queries executed by the
+// db it's registered with.
+type testGormPlugin struct {
+	name    string
+	err     error
+	queries int
+}
+
+func (p *testGormPlugin) Name() string { return p.name }
+
+func (p *testGormPlugin) Initialize(db *gorm.DB) error {
+	if p.err != nil {
+		return p.err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register(p.name+":count", func(*gorm.DB) {
+		p.queries++
+	})
+}
+
+func TestDB_WithGormPlugin(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p := &testGormPlugin{name: "test-counter"}
+		db, err := Open(Sqlite, "file::memory:", WithGormPlugin(p))
+		require.NoError(err)
+		t.Cleanup(func() { _ = db.Close(testCtx) })
+		// Open enables sqlite's foreign keys, which is a raw statement
+		queries := p.queries
+		_, err = New(db).Exec(testCtx, "select 1", nil)
+		require.NoError(err)
+		assert.Equal(queries+1, p.queries)
+		assert.Contains(db.wrapped.Config.Plugins, "test-counter")
+	})
+	t.Run("initialize-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := Open(Sqlite, "file::memory:", WithGormPlugin(&testGormPlugin{name: "test-error", err: errors.New("unable to initialize")}))
+		require.Error(err)
+		assert.Contains(err.Error(), "unable to register gorm plugin test-error: unable to initialize")
+	})
+	t.Run("duplicate", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := Open(Sqlite, "file::memory:", WithGormPlugin(&testGormPlugin{name: "test-dup"}), WithGormPlugin(&testGormPlugin{name: "test-dup"}))
+		require.Error(err)
+		assert.ErrorIs(err, gorm.ErrRegistered)
+	})
+	t.Run("nil", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var p *testGormPlugin
+		_, err := Open(Sqlite, "file::memory:", WithGormPlugin(p))
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+	})
+}
@@ -5,29 +5,49 @@ package dbw
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 // Delete a resource in the db with options: WithWhere, WithDebug, WithTable,
-// and WithVersion. WithWhere and WithVersion allows specifying a additional
+// WithDryRun and WithVersion. WithWhere and WithVersion allows specifying a additional
 // constraints on the operation in addition to the PKs. Delete returns the
-// number of rows deleted and any errors.
+// number of rows deleted and any errors.  When the resource has a soft delete
+// column of the DB (see: WithSoftDeleteColumn), its row is marked as deleted,
+// rather than deleted, and a row which is already marked isn't counted.
 func (rw *RW) Delete(ctx context.Context, i interface{}, opt ...Option) (int, error) {
 	const op = "dbw.Delete"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
 	if rw.underlying == nil {
 		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
 	}
+	if rw.underlying.ReadOnly() {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
 	if isNil(i) {
 		return noRowsAffected, fmt.Errorf("%s: missing interface: %w", op, ErrInvalidParameter)
 	}
 	if err := raiseErrorOnHooks(i); err != nil {
 		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, i, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	softDeleteColumns, tableName, softDeleteAssignments, err := rw.softDeleteAssignments(i, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
 
 	mDb := rw.underlying.wrapped.Model(i)
-	err := mDb.Statement.Parse(i)
+	err = mDb.Statement.Parse(i)
 	if err == nil && mDb.Statement.Schema == nil {
 		return noRowsAffected, fmt.Errorf("%s: (internal error) unable to parse stmt: %w", op, ErrUnknown)
 	}
@@ -56,10 +76,22 @@ func (rw *RW) Delete(ctx context.Context, i interface{}, opt ...Option) (int, er
 	if opts.WithTable != "" {
 		db = db.Table(opts.WithTable)
 	}
-	db = db.Delete(i)
+	if opts.WithDryRun != nil {
+		db = db.Session(&gorm.Session{DryRun: true})
+	}
+	if len(softDeleteAssignments) > 0 {
+		// the row is marked as deleted, unless it's already deleted
+		db = notDeletedScope(db, tableName, softDeleteColumns).Model(i).UpdateColumns(softDeleteAssignments)
+	} else {
+		db = db.Delete(i)
+	}
 	if db.Error != nil {
 		return noRowsAffected, fmt.Errorf("%s: %w", op, db.Error)
 	}
+	if opts.WithDryRun != nil {
+		*opts.WithDryRun = dryRunSql(db)
+		return noRowsAffected, nil
+	}
 	rowsDeleted := int(db.RowsAffected)
 	if rowsDeleted > 0 && opts.WithAfterWrite != nil {
 		if err := opts.WithAfterWrite(i, rowsDeleted); err != nil {
@@ -70,12 +102,18 @@ func (rw *RW) Delete(ctx context.Context, i interface{}, opt ...Option) (int, er
 }
 
 // DeleteItems will delete multiple items of the same type. Options supported:
-// WithWhereClause, WithDebug, WithTable
+// WithWhereClause, WithDebug, WithTable.  Items with a soft delete column of
+// the DB (see: WithSoftDeleteColumn) are marked as deleted, rather than
+// deleted.
 func (rw *RW) DeleteItems(ctx context.Context, deleteItems interface{}, opt ...Option) (int, error) {
 	const op = "dbw.DeleteItems"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
 	switch {
 	case rw.underlying == nil:
 		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
 	case isNil(deleteItems):
 		return noRowsAffected, fmt.Errorf("%s: no interfaces to delete: %w", op, ErrInvalidParameter)
 	}
@@ -91,18 +129,26 @@ func (rw *RW) DeleteItems(ctx context.Context, deleteItems interface{}, opt ...O
 		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
 	}
 
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, deleteItems, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
 	switch {
 	case opts.WithLookup:
 		return noRowsAffected, fmt.Errorf("%s: with lookup not a supported option: %w", op, ErrInvalidParameter)
 	case opts.WithVersion != nil:
 		return noRowsAffected, fmt.Errorf("%s: with version is not a supported option: %w", op, ErrInvalidParameter)
 	}
+	softDeleteColumns, tableName, softDeleteAssignments, err := rw.softDeleteAssignments(valDeleteItems.Index(0).Interface(), opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
 
 	// we need to dig out the stmt so in just a sec we can make sure the PKs are
 	// set for all the items, so we'll just use the first item to do so.
 	mDb := rw.underlying.wrapped.Model(valDeleteItems.Index(0).Interface())
-	err := mDb.Statement.Parse(valDeleteItems.Index(0).Interface())
+	err = mDb.Statement.Parse(valDeleteItems.Index(0).Interface())
 	switch {
 	case err != nil:
 		return noRowsAffected, fmt.Errorf("%s: (internal error) error parsing stmt: %w", op, err)
@@ -165,7 +211,12 @@ func (rw *RW) DeleteItems(ctx context.Context, deleteItems interface{}, opt ...O
 		}
 	}
 
-	db = db.Delete(deleteItems)
+	if len(softDeleteAssignments) > 0 {
+		// the rows are marked as deleted, unless they're already deleted
+		db = notDeletedScope(db, tableName, softDeleteColumns).Model(deleteItems).UpdateColumns(softDeleteAssignments)
+	} else {
+		db = db.Delete(deleteItems)
+	}
 	if db.Error != nil {
 		return noRowsAffected, fmt.Errorf("%s: %w", op, db.Error)
 	}
@@ -178,6 +229,215 @@ func (rw *RW) DeleteItems(ctx context.Context, deleteItems interface{}, opt ...O
 	return rowsDeleted, nil
 }
 
+// DeleteByPublicIds will delete the resources with the public_ids provided
+// from the resource's table.  The resource is only used to determine the
+// table and it's not modified.  The ids are deleted in batches (see:
+// WithBatchSize), so the number of ids isn't constrained by the database's
+// limit on query parameters.  The batches are deleted within a single
+// transaction, so either all or none of them are deleted.  If the RW is
+// already a transaction, then it's used and the caller is responsible for
+// its life cycle.  DeleteByPublicIds returns the number of rows deleted.  The
+// rows of a resource with a soft delete column of the DB (see:
+// WithSoftDeleteColumn) are marked as deleted, rather than deleted.  The
+// WithBatchSize, WithDebug and WithTable options are supported.
+func (rw *RW) DeleteByPublicIds(ctx context.Context, resource interface{}, publicIds []string, opt ...Option) (int, error) {
+	const op = "dbw.DeleteByPublicIds"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
+	switch {
+	case rw.underlying == nil:
+		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case isNil(resource):
+		return noRowsAffected, fmt.Errorf("%s: missing resource: %w", op, ErrInvalidParameter)
+	case len(publicIds) == 0:
+		return noRowsAffected, fmt.Errorf("%s: missing public ids: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(resource); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, resource, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	_, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	quotedTable := rw.underlying.wrapped.Statement.Quote(tableName)
+	sql := fmt.Sprintf("delete from %s where public_id in ?", quotedTable)
+	softDeleteColumns, _, softDeleteAssignments, err := rw.softDeleteAssignments(resource, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	var setArgs, whereArgs []interface{}
+	if len(softDeleteAssignments) > 0 {
+		// the rows are marked as deleted, unless they're already deleted
+		set := make([]string, 0, len(softDeleteColumns))
+		where := []string{"public_id in ?"}
+		for _, c := range softDeleteColumns {
+			set = append(set, c.Name+" = ?")
+			setArgs = append(setArgs, softDeleteAssignments[c.Name])
+			notDeleted, args := c.notDeleted(quotedTable)
+			where = append(where, notDeleted)
+			whereArgs = append(whereArgs, args...)
+		}
+		sql = fmt.Sprintf("update %s set %s where %s", quotedTable, strings.Join(set, ", "), strings.Join(where, " and "))
+	}
+
+	tx := rw
+	if !rw.IsTx() {
+		if tx, err = rw.Begin(ctx); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	var rowsDeleted int
+	for _, batch := range publicIdBatches(publicIds, opts.WithBatchSize) {
+		db := tx.underlying.wrapped.WithContext(ctx)
+		if opts.WithDebug {
+			db = db.Debug()
+		}
+		args := make([]interface{}, 0, len(setArgs)+1+len(whereArgs))
+		args = append(append(append(args, setArgs...), batch), whereArgs...)
+		if db = db.Exec(sql, args...); db.Error != nil {
+			if tx != rw {
+				if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+					return noRowsAffected, fmt.Errorf("%s: %w", op, errors.Join(db.Error, rollbackErr))
+				}
+			}
+			return noRowsAffected, fmt.Errorf("%s: %w", op, db.Error)
+		}
+		rowsDeleted += int(db.RowsAffected)
+	}
+	if tx != rw {
+		if err := tx.Commit(ctx); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return rowsDeleted, nil
+}
+
 type tableNamer interface {
 	TableName() string
 }
+
+const (
+	// purgeChunkDelay is the delay between chunks for PurgeWhere, which gives
+	// replicas a chance to catch up during large purges.
+	purgeChunkDelay = 10 * time.Millisecond
+
+	// purgeChunkRetries is the number of times a chunk will be retried by
+	// PurgeWhere when a transient error (deadlock, lock timeout, etc) occurs.
+	purgeChunkRetries = 3
+)
+
+// PurgeWhere will delete all the resources matching the where clause with
+// parameters in chunks of chunkSize rows, which is useful when purging a very
+// large number of rows.  The resource is used to determine the table and
+// primary key(s) of the rows being purged.  PurgeWhere sleeps briefly between
+// chunks, retries a chunk when it fails with a transient error (deadlocks,
+// lock timeouts, etc) and calls the optional progress func with the total rows
+// deleted after each chunk.  PurgeWhere can be cancelled via the ctx and
+// returns the number of rows deleted so far along with any error.  Each chunk
+// is deleted in its own statement, so PurgeWhere should not be used within a
+// transaction.  The DB's default write timeout (see: WithDefaultWriteTimeout)
+// applies to each chunk, rather than the whole purge.  The WithDebug,
+// WithTable and WithRetryableErrorFunc options are supported.  The rows are
+// always deleted, even when the resource has a soft delete column.
+func (rw *RW) PurgeWhere(ctx context.Context, resource interface{}, where string, args []interface{}, chunkSize int, progress func(totalDeleted int), opt ...Option) (int, error) {
+	const op = "dbw.PurgeWhere"
+	switch {
+	case rw.underlying == nil:
+		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case isNil(resource):
+		return noRowsAffected, fmt.Errorf("%s: missing resource: %w", op, ErrInvalidParameter)
+	case where == "":
+		return noRowsAffected, fmt.Errorf("%s: missing where clause: %w", op, ErrInvalidParameter)
+	case chunkSize <= 0:
+		return noRowsAffected, fmt.Errorf("%s: chunk size must be greater than zero: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(resource); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, resource, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mDb := rw.underlying.wrapped.Model(resource)
+	if err := mDb.Statement.Parse(resource); err != nil || mDb.Statement.Schema == nil {
+		return noRowsAffected, fmt.Errorf("%s: (internal error) unable to parse stmt: %w", op, ErrUnknown)
+	}
+	if len(mDb.Statement.Schema.PrimaryFieldDBNames) == 0 {
+		return noRowsAffected, fmt.Errorf("%s: no primary key(s) for %s: %w", op, mDb.Statement.Schema.Table, ErrInvalidParameter)
+	}
+	tableName := mDb.Statement.Schema.Table
+	if opts.WithTable != "" {
+		tableName = opts.WithTable
+	}
+	quotedTable := mDb.Statement.Quote(tableName)
+	pkColumns := strings.Join(mDb.Statement.Schema.PrimaryFieldDBNames, ", ")
+	pkTarget := pkColumns
+	if len(mDb.Statement.Schema.PrimaryFieldDBNames) > 1 {
+		pkTarget = "(" + pkColumns + ")"
+	}
+	sql := fmt.Sprintf(
+		"delete from %s where %s in (select %s from %s where %s limit %d)",
+		quotedTable, pkTarget, pkColumns, quotedTable, where, chunkSize,
+	)
+
+	isRetryable := rw.IsRetryableError
+	if opts.WithRetryableErrorFunc != nil {
+		isRetryable = opts.WithRetryableErrorFunc
+	}
+
+	var totalDeleted int
+	for {
+		if err := ctx.Err(); err != nil {
+			return totalDeleted, fmt.Errorf("%s: cancelled: %w", op, err)
+		}
+		var rowsDeleted int
+		for attempts := uint(1); ; attempts++ {
+			// the default write timeout applies to each chunk's statement
+			chunkCtx, cancel := rw.writeContext(ctx)
+			db := rw.underlying.wrapped.WithContext(chunkCtx)
+			if opts.WithDebug {
+				db = db.Debug()
+			}
+			db = db.Exec(sql, args...)
+			cancel()
+			if db.Error == nil {
+				rowsDeleted = int(db.RowsAffected)
+				break
+			}
+			if !isRetryable(db.Error) || attempts > purgeChunkRetries {
+				return totalDeleted, fmt.Errorf("%s: %w", op, db.Error)
+			}
+			select {
+			case <-ctx.Done():
+				return totalDeleted, fmt.Errorf("%s: cancelled: %w", op, db.Error)
+			case <-time.After(ExpBackoff{}.Duration(attempts)):
+			}
+		}
+		if rowsDeleted == 0 {
+			return totalDeleted, nil
+		}
+		totalDeleted += rowsDeleted
+		if progress != nil {
+			progress(totalDeleted)
+		}
+		if rowsDeleted < chunkSize {
+			return totalDeleted, nil
+		}
+		select {
+		case <-ctx.Done():
+			return totalDeleted, fmt.Errorf("%s: cancelled: %w", op, ctx.Err())
+		case <-time.After(purgeChunkDelay):
+		}
+	}
+}
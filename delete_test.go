@@ -7,6 +7,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/go-dbw"
@@ -271,6 +273,21 @@ func TestDb_Delete(t *testing.T) {
 			}
 		})
 	}
+	t.Run("dry-run", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		user := newUser()
+		var sql string
+		rowsDeleted, err := testRw.Delete(context.Background(), user, dbw.WithDryRun(&sql))
+		require.NoError(err)
+		assert.Equal(0, rowsDeleted)
+		assert.Contains(strings.ToLower(sql), "delete from")
+		assert.Contains(sql, user.PublicId)
+
+		// the user was not deleted
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = user.PublicId
+		require.NoError(testRw.LookupByPublicId(context.Background(), &foundUser))
+	})
 }
 
 func TestDb_DeleteItems(t *testing.T) {
@@ -589,3 +606,224 @@ func TestDb_DeleteItems(t *testing.T) {
 		}
 	})
 }
+
+func TestDb_PurgeWhere(t *testing.T) {
+	db, _ := dbw.TestSetup(t)
+	testRw := dbw.New(db)
+	testCtx := context.Background()
+
+	createFn := func(t *testing.T, email string, cnt int) {
+		t.Helper()
+		users := make([]*dbtest.TestUser, 0, cnt)
+		for i := 0; i < cnt; i++ {
+			users = append(users, testUser(t, nil, "", email, ""))
+		}
+		require.NoError(t, testRw.CreateItems(testCtx, users))
+	}
+	countFn := func(t *testing.T, email string) int {
+		t.Helper()
+		var found []*dbtest.TestUser
+		require.NoError(t, testRw.SearchWhere(testCtx, &found, "email = ?", []interface{}{email}, dbw.WithLimit(-1)))
+		return len(found)
+	}
+
+	t.Run("chunks", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		const email = "purge-chunks@example.com"
+		createFn(t, email, 5000)
+		createFn(t, "keep-chunks@example.com", 10)
+
+		var progress []int
+		deleted, err := testRw.PurgeWhere(testCtx, &dbtest.TestUser{}, "email = ?", []interface{}{email}, 500, func(totalDeleted int) {
+			progress = append(progress, totalDeleted)
+		})
+		require.NoError(err)
+		assert.Equal(5000, deleted)
+		assert.Equal([]int{500, 1000, 1500, 2000, 2500, 3000, 3500, 4000, 4500, 5000}, progress)
+		assert.Equal(0, countFn(t, email))
+		assert.Equal(10, countFn(t, "keep-chunks@example.com"))
+	})
+	t.Run("partial-chunk", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		const email = "purge-partial@example.com"
+		createFn(t, email, 25)
+
+		var progress []int
+		deleted, err := testRw.PurgeWhere(testCtx, &dbtest.TestUser{}, "email = ?", []interface{}{email}, 10, func(totalDeleted int) {
+			progress = append(progress, totalDeleted)
+		}, dbw.WithTable("db_test_user"))
+		require.NoError(err)
+		assert.Equal(25, deleted)
+		assert.Equal([]int{10, 20, 25}, progress)
+		assert.Equal(0, countFn(t, email))
+	})
+	t.Run("composite-pk", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		u := testUser(t, testRw, "", "purge-rental@example.com", "")
+		for i := 0; i < 3; i++ {
+			c := testCar(t, testRw)
+			testRental(t, testRw, u.PublicId, c.PublicId)
+		}
+		deleted, err := testRw.PurgeWhere(testCtx, &dbtest.TestRental{}, "user_id = ?", []interface{}{u.PublicId}, 2, nil)
+		require.NoError(err)
+		assert.Equal(3, deleted)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		const email = "purge-cancelled@example.com"
+		createFn(t, email, 5000)
+
+		ctx, cancel := context.WithCancel(testCtx)
+		defer cancel()
+		var progress []int
+		deleted, err := testRw.PurgeWhere(ctx, &dbtest.TestUser{}, "email = ?", []interface{}{email}, 500, func(totalDeleted int) {
+			progress = append(progress, totalDeleted)
+			if totalDeleted >= 1000 {
+				cancel()
+			}
+		})
+		require.Error(err)
+		assert.ErrorIs(err, context.Canceled)
+		assert.Equal(1000, deleted)
+		assert.Equal([]int{500, 1000}, progress)
+		assert.Equal(4000, countFn(t, email))
+	})
+	t.Run("with-retryable-error-func", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var calls int
+		deleted, err := testRw.PurgeWhere(testCtx, &dbtest.TestUser{}, "bad_column_name = ?", []interface{}{1}, 10, nil,
+			dbw.WithRetryableErrorFunc(func(error) bool {
+				calls++
+				return true
+			}),
+		)
+		require.Error(err)
+		assert.Equal(0, deleted)
+		// the initial attempt plus 3 retries
+		assert.Equal(4, calls)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			resource        interface{}
+			where           string
+			chunkSize       int
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, &dbtest.TestUser{}, "1 = 1", 10, "missing underlying db"},
+			{"missing-resource", testRw, nil, "1 = 1", 10, "missing resource"},
+			{"missing-where", testRw, &dbtest.TestUser{}, "", 10, "missing where clause"},
+			{"zero-chunk-size", testRw, &dbtest.TestUser{}, "1 = 1", 0, "chunk size must be greater than zero"},
+			{"hooks", testRw, &dbtest.TestWithBeforeDelete{}, "1 = 1", 10, "gorm callback/hooks are not supported"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				deleted, err := tt.rw.PurgeWhere(testCtx, tt.resource, tt.where, nil, tt.chunkSize, nil)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Equal(0, deleted)
+			})
+		}
+	})
+}
+
+func TestDb_DeleteByPublicIds(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	db, _ := dbw.TestSetup(t)
+	testRw := dbw.New(db)
+
+	createFn := func(t *testing.T, email string, cnt int) []string {
+		t.Helper()
+		users := make([]*dbtest.TestUser, 0, cnt)
+		ids := make([]string, 0, cnt)
+		for i := 0; i < cnt; i++ {
+			u := testUser(t, nil, "", email, "")
+			users = append(users, u)
+			ids = append(ids, u.PublicId)
+		}
+		require.NoError(t, testRw.CreateItems(testCtx, users))
+		return ids
+	}
+	countFn := func(t *testing.T, email string) int {
+		t.Helper()
+		var found []*dbtest.TestUser
+		require.NoError(t, testRw.SearchWhere(testCtx, &found, "email = ?", []interface{}{email}, dbw.WithLimit(-1)))
+		return len(found)
+	}
+
+	t.Run("huge-id-set", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		const email = "delete-by-public-ids@example.com"
+		ids := createFn(t, email, 2000)
+		createFn(t, "keep-by-public-ids@example.com", 10)
+		// 100k ids exceeds the parameter limits of both postgres and sqlite,
+		// so a single in query would fail
+		for i := len(ids); i < 100_000; i++ {
+			ids = append(ids, "u_missing_"+strconv.Itoa(i))
+		}
+		deleted, err := testRw.DeleteByPublicIds(testCtx, &dbtest.TestUser{}, ids)
+		require.NoError(err)
+		assert.Equal(2000, deleted)
+		assert.Equal(0, countFn(t, email))
+		assert.Equal(10, countFn(t, "keep-by-public-ids@example.com"))
+	})
+	t.Run("within-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		const email = "delete-by-public-ids-tx@example.com"
+		ids := createFn(t, email, 10)
+		tx, err := testRw.Begin(testCtx)
+		require.NoError(err)
+		deleted, err := tx.DeleteByPublicIds(testCtx, &dbtest.TestUser{}, ids, dbw.WithBatchSize(3))
+		require.NoError(err)
+		assert.Equal(10, deleted)
+		// the caller owns the tx, so rolling it back restores the rows
+		require.NoError(tx.Rollback(testCtx))
+		assert.Equal(10, countFn(t, email))
+	})
+	t.Run("rollback-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mDb, mock := dbw.TestSetupWithMock(t)
+		execErr, rollbackErr := errors.New("delete failed"), errors.New("rollback failed")
+		mock.ExpectBegin()
+		mock.ExpectExec(`delete from "db_test_user"`).WillReturnError(execErr)
+		mock.ExpectRollback().WillReturnError(rollbackErr)
+		deleted, err := dbw.New(mDb).DeleteByPublicIds(testCtx, &dbtest.TestUser{}, []string{"u_1"})
+		require.Error(err)
+		assert.Equal(0, deleted)
+		assert.ErrorIs(err, execErr)
+		assert.ErrorIs(err, rollbackErr)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	errTests := []struct {
+		name            string
+		rw              *dbw.RW
+		resource        interface{}
+		ids             []string
+		opt             []dbw.Option
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{name: "nil-underlying", rw: &dbw.RW{}, resource: &dbtest.TestUser{}, ids: []string{"u_1"}, wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "missing underlying db"},
+		{name: "nil-resource", rw: testRw, ids: []string{"u_1"}, wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "missing resource"},
+		{name: "missing-ids", rw: testRw, resource: &dbtest.TestUser{}, wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "missing public ids"},
+		{name: "bad-table", rw: testRw, resource: &dbtest.TestUser{}, ids: []string{"u_1"}, opt: []dbw.Option{dbw.WithTable("invalid_table_name")}},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			deleted, err := tt.rw.DeleteByPublicIds(testCtx, tt.resource, tt.ids, tt.opt...)
+			require.Error(err)
+			assert.Equal(0, deleted)
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(err, tt.wantErrIs)
+			}
+			if tt.wantErrContains != "" {
+				assert.Contains(err.Error(), tt.wantErrContains)
+			}
+		})
+	}
+}
@@ -9,10 +9,36 @@ import (
 	"time"
 )
 
+// IsRetryableError returns true if the error should trigger a retry of a
+// transaction.  It uses the func provided via WithRetryableErrorFunc when the
+// DB was opened, and defaults to the built-in detection of transient errors
+// (serialization failures, deadlocks and lock timeouts).  It's typically used
+// as the retryErrorsMatchingFn for DoTx(...)
+func (rw *RW) IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if rw.underlying != nil && rw.underlying.retryableErrorFn != nil {
+		return rw.underlying.retryableErrorFn(err)
+	}
+	return isTransientError(err)
+}
+
 // DoTx will wrap the Handler func passed within a transaction with retries
 // you should ensure that any objects written to the db in your TxHandler are retryable, which
 // means that the object may be sent to the db several times (retried), so
 // things like the primary key may need to be reset before retry.
+// RW.IsRetryableError can be used as the retryErrorsMatchingFn to retry
+// transient errors (serialization failures, deadlocks, a busy sqlite database,
+// etc).  The RetryInfo returned reports the number of retries, the total
+// backoff and the retries by the class of their error.  When the retries are
+// exhausted, the error returned reports the number of attempts and wraps both
+// ErrMaxRetries and the handler's last error.  The transaction
+// is committed when the handler returns nil and rolled back when it returns an
+// error or panics, in which case the panic is propagated after the rollback.
+// The handler's reader and writer are both the transaction's *RW, so the RW
+// methods which aren't part of the Reader and Writer interfaces (ex: TxID) can
+// be used via a type assertion.
 func (rw *RW) DoTx(ctx context.Context, retryErrorsMatchingFn func(error) bool, retries uint, backOff Backoff, handler TxHandler) (RetryInfo, error) {
 	const op = "dbw.DoTx"
 	if rw.underlying == nil {
@@ -28,24 +54,33 @@ func (rw *RW) DoTx(ctx context.Context, retryErrorsMatchingFn func(error) bool,
 		return RetryInfo{}, fmt.Errorf("%s: missing retry errors matching function: %w", op, ErrInvalidParameter)
 	}
 	info := RetryInfo{}
+	var lastErr error
 	for attempts := uint(1); ; attempts++ {
 		if attempts > retries+1 {
-			return info, fmt.Errorf("%s: too many retries: %d of %d: %w", op, attempts-1, retries+1, ErrMaxRetries)
+			return info, fmt.Errorf("%s: too many retries: %d of %d: %w (last error: %w)", op, attempts-1, retries+1, ErrMaxRetries, lastErr)
 		}
 
 		// step one of this, start a transaction...
-		newTx := rw.underlying.wrapped.WithContext(ctx)
-		newTx = newTx.Begin()
+		txDb, err := rw.underlying.beginTx(ctx)
+		if err != nil {
+			return info, fmt.Errorf("%s: %w", op, err)
+		}
+		newTx := txDb.wrapped
 
-		newRW := &RW{underlying: &DB{newTx}}
-		if err := handler(newRW, newRW); err != nil {
+		newRW := &RW{underlying: txDb}
+		if err := runTxHandler(newRW, handler); err != nil {
 			if err := newTx.Rollback().Error; err != nil {
 				return info, fmt.Errorf("%s: %w", op, err)
 			}
 			if retry := retryErrorsMatchingFn(err); retry {
+				lastErr = err
 				d := backOff.Duration(attempts)
 				info.Retries++
 				info.Backoff = info.Backoff + d
+				if info.Errors == nil {
+					info.Errors = map[string]int{}
+				}
+				info.Errors[retryErrorClass(err)]++
 				select {
 				case <-ctx.Done():
 					return info, fmt.Errorf("%s: cancelled: %w", op, err)
@@ -65,3 +100,16 @@ func (rw *RW) DoTx(ctx context.Context, retryErrorsMatchingFn func(error) bool,
 		return info, nil // it all worked!!!
 	}
 }
+
+// runTxHandler invokes the handler with the transaction's RW as its reader and
+// writer.  If the handler panics, the transaction is rolled back before the
+// panic is propagated, so it doesn't hold its connection and locks.
+func runTxHandler(txRW *RW, handler TxHandler) error {
+	defer func() {
+		if r := recover(); r != nil {
+			_ = txRW.underlying.wrapped.Rollback()
+			panic(r)
+		}
+	}()
+	return handler(txRW, txRW)
+}
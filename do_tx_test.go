@@ -13,8 +13,10 @@ import (
 
 	"github.com/hashicorp/go-dbw"
 	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
 )
 
 func TestDb_DoTx(t *testing.T) {
@@ -243,4 +245,171 @@ func TestDb_DoTx(t *testing.T) {
 		require.NoError(err)
 		assert.Equal(foundUser.Name, user.Name)
 	})
+	t.Run("panic", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		user, err := dbtest.NewTestUser()
+		require.NoError(err)
+		attempts := 0
+		assert.PanicsWithValue("handler panic", func() {
+			_, _ = rw.DoTx(testCtx, retryOnFn, 10, dbw.ExpBackoff{}, func(_ dbw.Reader, w dbw.Writer) error {
+				attempts++
+				if err := w.Create(testCtx, user); err != nil {
+					return err
+				}
+				panic("handler panic")
+			})
+		})
+		// the panic isn't retried and the create was rolled back
+		assert.Equal(1, attempts)
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = user.PublicId
+		err = rw.LookupByPublicId(testCtx, &foundUser)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		// the connection was returned to the pool, so the db can still be
+		// written
+		user2, err := dbtest.NewTestUser()
+		require.NoError(err)
+		require.NoError(rw.Create(testCtx, user2))
+	})
+}
+
+func TestDb_DoTx_RetryErrors(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	retryAllFn := func(error) bool { return true }
+	t.Run("mixed", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		failures := []error{
+			&pgconn.PgError{Code: "40001"},
+			&pgconn.PgError{Code: "40P01"},
+			fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40001"}),
+			errors.New("database is locked"),
+			errors.New("restart transaction"),
+			errors.New("connection reset by peer"),
+		}
+		attempts := 0
+		got, err := rw.DoTx(testCtx, retryAllFn, uint(len(failures)), dbw.ConstBackoff{DurationMs: 1}, func(dbw.Reader, dbw.Writer) error {
+			attempts++
+			if attempts <= len(failures) {
+				return failures[attempts-1]
+			}
+			return nil
+		})
+		require.NoError(err)
+		assert.Equal(len(failures), got.Retries)
+		assert.Equal(map[string]int{
+			"40001":                          2,
+			"40P01":                          1,
+			dbw.RetryErrorDatabaseLocked:     1,
+			dbw.RetryErrorRestartTransaction: 1,
+			dbw.RetryErrorOther:              1,
+		}, got.Errors)
+	})
+	t.Run("too-many-retries", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		got, err := rw.DoTx(testCtx, retryAllFn, 2, dbw.ConstBackoff{DurationMs: 1}, func(dbw.Reader, dbw.Writer) error {
+			return &pgconn.PgError{Code: "40P01"}
+		})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrMaxRetries)
+		assert.Equal(map[string]int{"40P01": got.Retries}, got.Errors)
+
+		// the handler's last error is wrapped
+		var pgErr *pgconn.PgError
+		require.True(errors.As(err, &pgErr))
+		assert.Equal("40P01", pgErr.Code)
+		assert.Contains(err.Error(), "too many retries: 3 of 3")
+	})
+	t.Run("is-retryable-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		// serialization failures and a busy sqlite database are retried
+		failures := []error{
+			&pgconn.PgError{Code: "40001"},
+			errors.New("database is locked"),
+		}
+		attempts := 0
+		got, err := rw.DoTx(testCtx, rw.IsRetryableError, 3, dbw.ConstBackoff{DurationMs: 1}, func(dbw.Reader, dbw.Writer) error {
+			attempts++
+			if attempts <= len(failures) {
+				return failures[attempts-1]
+			}
+			return nil
+		})
+		require.NoError(err)
+		assert.Equal(3, attempts)
+		assert.Equal(2, got.Retries)
+		assert.Equal(map[string]int{"40001": 1, dbw.RetryErrorDatabaseLocked: 1}, got.Errors)
+
+		// other errors aren't retried
+		attempts = 0
+		got, err = rw.DoTx(testCtx, rw.IsRetryableError, 3, dbw.ConstBackoff{DurationMs: 1}, func(dbw.Reader, dbw.Writer) error {
+			attempts++
+			return &pgconn.PgError{Code: "23505"}
+		})
+		require.Error(err)
+		assert.Equal(1, attempts)
+		assert.Equal(0, got.Retries)
+	})
+	t.Run("not-retried", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		got, err := rw.DoTx(testCtx, func(error) bool { return false }, 2, dbw.ConstBackoff{DurationMs: 1}, func(dbw.Reader, dbw.Writer) error {
+			return &pgconn.PgError{Code: "40001"}
+		})
+		require.Error(err)
+		assert.Nil(got.Errors)
+	})
+}
+
+func TestRW_IsRetryableError(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	const crdbRetryCode = "40003"
+	t.Run("default", func(t *testing.T) {
+		assert := assert.New(t)
+		rw := dbw.New(conn)
+		assert.False(rw.IsRetryableError(nil))
+		assert.True(rw.IsRetryableError(&pgconn.PgError{Code: "40001"}))
+		assert.True(rw.IsRetryableError(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40P01"})))
+		assert.False(rw.IsRetryableError(&pgconn.PgError{Code: crdbRetryCode}))
+		assert.False(rw.IsRetryableError(errors.New("not retryable")))
+		assert.False(rw.IsRetryableError(dbw.ErrInvalidParameter))
+		assert.False(rw.IsRetryableError(&pgconn.PgError{Code: "23505"}))
+		assert.True(rw.IsRetryableError(errors.New("database is locked")))
+	})
+	t.Run("with-retryable-error-func", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		customConn, err := dbw.OpenWith(sqlite.Open("file::memory:"), dbw.WithRetryableErrorFunc(func(err error) bool {
+			var pgErr *pgconn.PgError
+			return errors.As(err, &pgErr) && pgErr.Code == crdbRetryCode
+		}))
+		require.NoError(err)
+		rw := dbw.New(customConn)
+		assert.False(rw.IsRetryableError(nil))
+		assert.True(rw.IsRetryableError(&pgconn.PgError{Code: crdbRetryCode}))
+		assert.False(rw.IsRetryableError(&pgconn.PgError{Code: "40001"}))
+
+		// the func is retained by transactions
+		attempts := 0
+		got, err := rw.DoTx(testCtx, rw.IsRetryableError, 2, dbw.ConstBackoff{DurationMs: 1}, func(_ dbw.Reader, w dbw.Writer) error {
+			attempts++
+			if !w.(*dbw.RW).IsRetryableError(&pgconn.PgError{Code: crdbRetryCode}) {
+				return errors.New("custom retryable error func not retained by transaction")
+			}
+			if attempts < 3 {
+				return &pgconn.PgError{Code: crdbRetryCode}
+			}
+			return nil
+		})
+		require.NoError(err)
+		assert.Equal(2, got.Retries)
+		assert.Equal(3, attempts)
+	})
 }
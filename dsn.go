@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgresDSN returns a postgres connection url, which can be used with
+// Open(Postgres, ...), where every component is correctly escaped (ex: a
+// password with special characters).  Empty components are omitted, so the
+// driver's defaults are used for them, and a port <= 0 means the default port.
+// The extra parameters are added to the url's query (ex: connect_timeout)
+// along with the sslmode.
+func PostgresDSN(host string, port int, user, password, dbname, sslmode string, extra map[string]string) string {
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   host,
+	}
+	if port > 0 {
+		u.Host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	switch {
+	case password != "":
+		u.User = url.UserPassword(user, password)
+	case user != "":
+		u.User = url.User(user)
+	}
+	if dbname != "" {
+		u.Path = "/" + dbname
+	}
+	params := url.Values{}
+	for k, v := range extra {
+		params.Set(k, v)
+	}
+	if sslmode != "" {
+		params.Set("sslmode", sslmode)
+	}
+	// Encode sorts the params by key, so the dsn is deterministic
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// validateConnectionUrl will parse the connection url for the database type,
+// so a malformed url is reported when the database is opened, rather than by
+// the driver when the connection is first used.
+func validateConnectionUrl(dbType DbType, connectionUrl string) error {
+	const op = "dbw.validateConnectionUrl"
+	switch dbType {
+	case Postgres, CockroachDB:
+		// url parsing errors include the url with any password, so they're
+		// reported without it
+		if strings.HasPrefix(connectionUrl, "postgres://") || strings.HasPrefix(connectionUrl, "postgresql://") {
+			if _, err := url.Parse(connectionUrl); err != nil {
+				var urlErr *url.Error
+				if errors.As(err, &urlErr) {
+					err = urlErr.Err
+				}
+				return fmt.Errorf("%s: invalid %s connection url: %s: %w", op, dbType, err, ErrInvalidParameter)
+			}
+		}
+		// ParseConfig supports both urls and keyword/value connection strings,
+		// and it redacts any password from its errors.
+		if _, err := pgconn.ParseConfig(connectionUrl); err != nil {
+			return fmt.Errorf("%s: invalid %s connection url: %s: %w", op, dbType, err, ErrInvalidParameter)
+		}
+	case Sqlite:
+		path, query, err := sqlitePath(connectionUrl)
+		if err != nil {
+			return fmt.Errorf("%s: invalid sqlite connection url: %s: %w", op, err, ErrInvalidParameter)
+		}
+		if path == "" || path == ":memory:" || query.Get("mode") == "memory" {
+			return nil
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return fmt.Errorf("%s: sqlite database path %s is a directory: %w", op, path, ErrInvalidParameter)
+		}
+		dir := filepath.Dir(path)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s: sqlite database directory %s does not exist: %w", op, dir, ErrInvalidParameter)
+		}
+	}
+	return nil
+}
+
+// sqlitePath returns the database file path and the query parameters of a
+// sqlite connection url, which is either a filename or a "file:" URI (ex:
+// file::memory:?cache=shared), optionally followed by query parameters.
+func sqlitePath(connectionUrl string) (string, url.Values, error) {
+	if strings.HasPrefix(connectionUrl, "file:") {
+		u, err := url.Parse(connectionUrl)
+		if err != nil {
+			return "", nil, err
+		}
+		query, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return "", nil, err
+		}
+		if u.Opaque != "" {
+			return u.Opaque, query, nil
+		}
+		return u.Path, query, nil
+	}
+	path, rawQuery, _ := strings.Cut(connectionUrl, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, query, nil
+}
@@ -3,7 +3,16 @@
 
 package dbw
 
-import "errors"
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
 var (
 	// ErrUnknown is an unknown/undefined error
@@ -23,4 +32,129 @@ var (
 
 	// ErrInvalidFieldMask is an invalid field mask error
 	ErrInvalidFieldMask = errors.New("invalid field mask")
+
+	// ErrUnsafeQuery is an unsafe query error (see: WithRejectFullScans)
+	ErrUnsafeQuery = errors.New("unsafe query")
+
+	// ErrTooManyRequests is a too many concurrent operations error (see:
+	// WithMaxConcurrentOps)
+	ErrTooManyRequests = errors.New("too many requests")
+
+	// ErrReadOnly is a write to a read-only DB error (see: DB.SetReadOnly)
+	ErrReadOnly = errors.New("read only")
 )
+
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgLockNotAvailable     = "55P03"
+
+	pgConnectionExceptionClass = "08"
+	pgAdminShutdown            = "57P01"
+	pgCrashShutdown            = "57P02"
+	pgCannotConnectNow         = "57P03"
+)
+
+// badConnMessages are the (lower case) messages of the errors which indicate
+// that a database connection is broken, for the drivers which don't return a
+// typed error (or driver.ErrBadConn) when the connection is closed.
+var badConnMessages = []string{
+	"bad connection",
+	"server closed the connection unexpectedly",
+	"broken pipe",
+	"connection reset by peer",
+	"conn closed",
+}
+
+// IsBadConnError returns true if the error indicates that the database
+// connection used by the operation is broken (ex: the server closed the
+// connection unexpectedly or it was terminated by an administrator), so the
+// operation may succeed if it's retried with a different connection.  Errors
+// caused by a canceled or expired context are never bad connection errors.
+// See: WithAutoInvalidateBadConns
+func IsBadConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, pgConnectionExceptionClass):
+			return true
+		case pgErr.Code == pgAdminShutdown, pgErr.Code == pgCrashShutdown, pgErr.Code == pgCannotConnectNow:
+			return true
+		}
+		return false
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var netErr *net.OpError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range badConnMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransientError returns true if the error is a transient database error
+// (deadlocks, serialization failures and lock timeouts) which may succeed if
+// the operation is retried.
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgSerializationFailure, pgDeadlockDetected, pgLockNotAvailable:
+			return true
+		}
+		return false
+	}
+	// sqlite doesn't provide error codes via its error strings, so we'll have
+	// to settle for matching SQLITE_BUSY and SQLITE_LOCKED messages.
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// isCockroachTransientError returns true if the error is a transient error
+// for a CockroachDB database.  CockroachDB reports most of its transaction
+// retry errors as serialization failures, but some of its retry errors (like
+// TransactionRetryWithProtoRefreshError) are only identifiable by their
+// "restart transaction" message.
+func isCockroachTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if isTransientError(err) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "restart transaction")
+}
+
+// retryErrorClass returns the class of the error for RetryInfo.Errors: its
+// SQLSTATE code when it has one, otherwise a class derived from its message.
+func retryErrorClass(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code != "" {
+		return pgErr.Code
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked"):
+		return RetryErrorDatabaseLocked
+	case strings.Contains(msg, "restart transaction"):
+		return RetryErrorRestartTransaction
+	default:
+		return RetryErrorOther
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isTransientError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"pg-deadlock", &pgconn.PgError{Code: pgDeadlockDetected}, true},
+		{"pg-serialization", fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: pgSerializationFailure}), true},
+		{"pg-lock-not-available", &pgconn.PgError{Code: pgLockNotAvailable}, true},
+		{"pg-unique-violation", &pgconn.PgError{Code: "23505"}, false},
+		{"sqlite-busy", errors.New("database is locked"), true},
+		{"sqlite-locked", errors.New("database table is locked: db_test_user"), true},
+		{"other", errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransientError(tt.err))
+		})
+	}
+}
+
+func Test_isCockroachTransientError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"pg-serialization", &pgconn.PgError{Code: pgSerializationFailure, Message: "restart transaction: TransactionRetryWithProtoRefreshError"}, true},
+		{"restart-transaction", errors.New("TransactionRetryWithProtoRefreshError: restart transaction"), true},
+		{"sqlite-busy", errors.New("database is locked"), true},
+		{"pg-unique-violation", &pgconn.PgError{Code: "23505"}, false},
+		{"other", errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isCockroachTransientError(tt.err))
+		})
+	}
+}
+
+func Test_retryErrorClass(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"pg-serialization", &pgconn.PgError{Code: pgSerializationFailure}, pgSerializationFailure},
+		{"pg-deadlock-wrapped", fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: pgDeadlockDetected}), pgDeadlockDetected},
+		{"sqlite-busy", errors.New("database is locked"), RetryErrorDatabaseLocked},
+		{"sqlite-locked", errors.New("database table is locked: users"), RetryErrorDatabaseLocked},
+		{"restart-transaction", errors.New("TransactionRetryWithProtoRefreshError: restart transaction"), RetryErrorRestartTransaction},
+		{"other", errors.New("connection reset by peer"), RetryErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryErrorClass(tt.err))
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultMaxIdleConns is the database/sql default for the max number of idle
+// connections, which is restored after the pool is reset by a health check
+// when the WithMaxIdleConnections option isn't used.
+const defaultMaxIdleConns = 2
+
+// HealthStatus is the status of the DB's last health check (see:
+// WithHealthCheck)
+type HealthStatus struct {
+	// CheckedAt is when the last health check ran and it's the zero time when
+	// no health check has run.
+	CheckedAt time.Time
+
+	// Err is the error of the last health check's ping and it's nil when the
+	// database is healthy.
+	Err error
+
+	// ConsecutiveFailures is the number of consecutive health checks which
+	// failed.
+	ConsecutiveFailures int
+
+	// Resets is the number of times the pool's idle connections were evicted
+	// after a failed health check.
+	Resets int
+}
+
+// Healthy returns true when the last health check succeeded.
+func (s HealthStatus) Healthy() bool {
+	return !s.CheckedAt.IsZero() && s.Err == nil
+}
+
+// healthChecker periodically pings a database and resets its pool of idle
+// connections when a ping fails, so the next operation gets a new connection
+// rather than a stale one.
+type healthChecker struct {
+	interval time.Duration
+	ping     func(ctx context.Context) error
+	reset    func()
+
+	mu     sync.RWMutex
+	status HealthStatus
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newHealthChecker(interval time.Duration, ping func(ctx context.Context) error, reset func()) *healthChecker {
+	return &healthChecker{
+		interval: interval,
+		ping:     ping,
+		reset:    reset,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start runs the health checks at the checker's interval until it's stopped.
+func (h *healthChecker) start() {
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				h.check()
+			}
+		}
+	}()
+}
+
+// check pings the database, with a timeout of the checker's interval, and
+// resets the pool when the ping fails.
+func (h *healthChecker) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+	err := h.ping(ctx)
+	if err != nil {
+		h.reset()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.CheckedAt = time.Now()
+	h.status.Err = err
+	if err != nil {
+		h.status.ConsecutiveFailures++
+		h.status.Resets++
+		return
+	}
+	h.status.ConsecutiveFailures = 0
+}
+
+// getStatus returns the status of the last health check.
+func (h *healthChecker) getStatus() HealthStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status
+}
+
+// shutdown stops the health checks and waits for a running check to finish.
+// It's safe to call more than once.
+func (h *healthChecker) shutdown() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		<-h.done
+	})
+}
+
+// HealthStatus returns the status of the DB's last health check and true, or
+// false when the DB was opened without WithHealthCheck.
+func (db *DB) HealthStatus() (HealthStatus, bool) {
+	if db.healthChecker == nil {
+		return HealthStatus{}, false
+	}
+	return db.healthChecker.getStatus(), true
+}
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPinger is a controllable fake for the pings and resets of a health
+// checker.
+type testPinger struct {
+	mu     sync.Mutex
+	pings  int
+	resets int
+	err    error
+}
+
+func (p *testPinger) ping(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pings++
+	return p.err
+}
+
+func (p *testPinger) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resets++
+	// evicting the stale connections recovers the pool
+	p.err = nil
+}
+
+func (p *testPinger) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *testPinger) counts() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pings, p.resets
+}
+
+func Test_healthChecker(t *testing.T) {
+	t.Parallel()
+	const interval = 5 * time.Millisecond
+	const waitFor = time.Second
+
+	t.Run("interval", func(t *testing.T) {
+		assert := assert.New(t)
+		p := &testPinger{}
+		h := newHealthChecker(interval, p.ping, p.reset)
+		assert.Equal(HealthStatus{}, h.getStatus())
+		assert.False(h.getStatus().Healthy())
+		h.start()
+		t.Cleanup(h.shutdown)
+		assert.Eventually(func() bool {
+			pings, _ := p.counts()
+			return pings >= 3
+		}, waitFor, interval)
+		status := h.getStatus()
+		assert.True(status.Healthy())
+		assert.Zero(status.Resets)
+		_, resets := p.counts()
+		assert.Zero(resets)
+	})
+	t.Run("recovers", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		p := &testPinger{}
+		p.fail(errors.New("connection reset by peer"))
+		h := newHealthChecker(interval, p.ping, p.reset)
+		// a failed check resets the pool
+		h.check()
+		status := h.getStatus()
+		require.Error(status.Err)
+		assert.False(status.Healthy())
+		assert.Equal(1, status.ConsecutiveFailures)
+		assert.Equal(1, status.Resets)
+		_, resets := p.counts()
+		assert.Equal(1, resets)
+
+		// the next check succeeds using the reset pool
+		h.start()
+		t.Cleanup(h.shutdown)
+		assert.Eventually(func() bool { return h.getStatus().Healthy() }, waitFor, interval)
+		status = h.getStatus()
+		assert.Zero(status.ConsecutiveFailures)
+		assert.Equal(1, status.Resets)
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		assert := assert.New(t)
+		p := &testPinger{}
+		h := newHealthChecker(interval, p.ping, p.reset)
+		h.start()
+		assert.Eventually(func() bool {
+			pings, _ := p.counts()
+			return pings >= 1
+		}, waitFor, interval)
+		h.shutdown()
+		h.shutdown()
+		pings, _ := p.counts()
+		time.Sleep(5 * interval)
+		after, _ := p.counts()
+		assert.Equal(pings, after)
+	})
+}
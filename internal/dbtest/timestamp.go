@@ -38,17 +38,47 @@ var (
 	PositiveInfinityTS = time.Date(math.MaxInt32, time.December, 31, 23, 59, 59, 1e9-1, time.UTC)
 )
 
-// Scan implements sql.Scanner for protobuf Timestamp.
+// timestampFormats are the string formats for timestamps which may be
+// returned by the database (sqlite returns timestamps as strings when the
+// column type isn't known, e.g. for expressions).
+var timestampFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Scan implements sql.Scanner for protobuf Timestamp.  The scanned time is
+// always normalized to UTC, regardless of the dialect.  A NULL value results in
+// an empty Timestamp, which allows nullable columns (outer joins, aggregates,
+// etc) to be scanned directly into a Timestamp.
 func (ts *Timestamp) Scan(value interface{}) error {
 	switch t := value.(type) {
+	case nil:
+		ts.Timestamp = nil
 	case time.Time:
-		ts.Timestamp = timestamppb.New(t) // google proto version
+		ts.Timestamp = timestamppb.New(t.UTC()) // google proto version
+	case []byte:
+		return ts.Scan(string(t))
 	case string:
 		switch value {
 		case "-infinity":
 			ts.Timestamp = timestamppb.New(NegativeInfinityTS)
 		case "infinity":
 			ts.Timestamp = timestamppb.New(PositiveInfinityTS)
+		default:
+			for _, f := range timestampFormats {
+				if parsed, err := time.Parse(f, t); err == nil {
+					ts.Timestamp = timestamppb.New(parsed.UTC())
+					return nil
+				}
+			}
+			return errors.New("Not a protobuf Timestamp")
 		}
 	default:
 		return errors.New("Not a protobuf Timestamp")
@@ -56,12 +86,14 @@ func (ts *Timestamp) Scan(value interface{}) error {
 	return nil
 }
 
-// Scan implements driver.Valuer for protobuf Timestamp.
+// Scan implements driver.Valuer for protobuf Timestamp.  The value is always
+// written as UTC, regardless of the dialect, and an unset Timestamp is
+// written as null.
 func (ts *Timestamp) Value() (driver.Value, error) {
-	if ts == nil {
+	if ts == nil || ts.Timestamp == nil {
 		return nil, nil
 	}
-	return ts.Timestamp.AsTime(), nil
+	return ts.Timestamp.AsTime().UTC(), nil
 }
 
 // GormDataType gorm common data type (required)
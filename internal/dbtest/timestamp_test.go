@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbtest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTimestamp_Scan(t *testing.T) {
+	t.Parallel()
+	est := time.FixedZone("EST", -5*60*60)
+	nonUTC := time.Date(2021, time.March, 14, 10, 30, 15, 500, est)
+
+	tests := []struct {
+		name            string
+		value           interface{}
+		want            time.Time
+		wantErrContains string
+	}{
+		{"time", nonUTC, nonUTC.UTC(), ""},
+		{"string-with-zone", "2021-03-14 10:30:15.0000005-05:00", nonUTC.UTC(), ""},
+		{"bytes-with-zone", []byte("2021-03-14T10:30:15.0000005-05:00"), nonUTC.UTC(), ""},
+		{"string-without-zone", "2021-03-14 15:30:15", time.Date(2021, time.March, 14, 15, 30, 15, 0, time.UTC), ""},
+		{"negative-infinity", "-infinity", dbtest.NegativeInfinityTS, ""},
+		{"positive-infinity", "infinity", dbtest.PositiveInfinityTS, ""},
+		{"null", nil, time.Time{}, ""},
+		{"invalid-string", "not a timestamp", time.Time{}, "Not a protobuf Timestamp"},
+		{"invalid-type", 1, time.Time{}, "Not a protobuf Timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var ts dbtest.Timestamp
+			err := ts.Scan(tt.value)
+			if tt.wantErrContains != "" {
+				require.Error(err)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				return
+			}
+			require.NoError(err)
+			if tt.value == nil {
+				assert.Nil(ts.Timestamp)
+				return
+			}
+			assert.True(tt.want.Equal(ts.AsTime()))
+			assert.Equal(time.UTC, ts.AsTime().Location())
+		})
+	}
+}
+
+func TestTimestamp_Value(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	nonUTC := time.Date(2021, time.March, 14, 10, 30, 15, 0, time.FixedZone("EST", -5*60*60))
+	v, err := dbtest.New(nonUTC).Value()
+	require.NoError(err)
+	got, ok := v.(time.Time)
+	require.True(ok)
+	assert.True(nonUTC.Equal(got))
+	assert.Equal(time.UTC, got.Location())
+
+	var nilTs *dbtest.Timestamp
+	v, err = nilTs.Value()
+	require.NoError(err)
+	assert.Nil(v)
+
+	v, err = (&dbtest.Timestamp{}).Value()
+	require.NoError(err)
+	assert.Nil(v)
+}
+
+func TestTimestamp_RoundTrip(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+
+	nonUTC := time.Date(2021, time.March, 14, 10, 30, 15, 0, time.FixedZone("EST", -5*60*60))
+	user, err := dbtest.NewTestUser()
+	require.NoError(err)
+	require.NoError(rw.Create(testCtx, user))
+
+	// bypass the update_time triggers by writing directly with exec
+	_, err = rw.Exec(testCtx, "update db_test_user set update_time = ? where public_id = ?", []interface{}{dbtest.New(nonUTC), user.PublicId})
+	require.NoError(err)
+
+	found := dbtest.AllocTestUser()
+	found.PublicId = user.PublicId
+	require.NoError(rw.LookupBy(testCtx, &found))
+	dbType, _, err := conn.DbType()
+	require.NoError(err)
+	if dbType == dbw.Sqlite {
+		// postgres triggers will always set the update_time to now()
+		assert.True(nonUTC.Equal(found.UpdateTime.AsTime()))
+	}
+	assert.Equal(time.UTC, found.UpdateTime.AsTime().Location())
+	assert.Equal(time.UTC, found.CreateTime.AsTime().Location())
+
+	query := "select ?"
+	if dbType == dbw.Postgres {
+		query = "select ?::timestamptz"
+	}
+	rows, err := rw.Query(testCtx, query, []interface{}{dbtest.New(nonUTC)})
+	require.NoError(err)
+	defer rows.Close()
+	require.True(rows.Next())
+	var got dbtest.Timestamp
+	require.NoError(rows.Scan(&got))
+	assert.True(nonUTC.Equal(got.AsTime()))
+	assert.Equal(time.UTC, got.AsTime().Location())
+
+	// an unset timestamp is written and read as null
+	nullRows, err := rw.Query(testCtx, query, []interface{}{&dbtest.Timestamp{}})
+	require.NoError(err)
+	defer nullRows.Close()
+	require.True(nullRows.Next())
+	got = dbtest.Timestamp{Timestamp: timestamppb.Now()}
+	require.NoError(nullRows.Scan(&got))
+	assert.Nil(got.Timestamp)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Iterator iterates over the rows of a query (see: RW.QueryIterator).  Next
+// closes the underlying rows when they're exhausted or an error occurs, and
+// Scan closes them when it fails, so a loop which runs until Next returns
+// false never leaks a connection.  Close must still be called when the loop
+// may stop early, and it's safe to call Close more than once.  As a safety
+// net, an Iterator which is garbage collected without being closed logs a
+// warning (at the Warn log level) and closes its rows, but the connection is
+// held until the GC runs, so callers shouldn't rely on it.
+type Iterator struct {
+	rw   *RW
+	rows *sql.Rows
+	sql  string
+
+	mu     sync.Mutex
+	closed bool
+	err    error
+}
+
+// QueryIterator will run the raw query and return an Iterator over its rows.
+// QueryIterator will operate within the context of any ongoing transaction
+// for the Reader.  Unlike Query, the rows are closed when the Iterator is
+// exhausted or fails.  The WithDebug option is supported.
+func (rw *RW) QueryIterator(ctx context.Context, sql string, values []interface{}, opt ...Option) (*Iterator, error) {
+	const op = "dbw.QueryIterator"
+	rows, err := rw.Query(ctx, sql, values, opt...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	it := &Iterator{rw: rw, rows: rows, sql: sql}
+	runtime.SetFinalizer(it, (*Iterator).finalize)
+	return it, nil
+}
+
+// Next prepares the next row for Scan and returns true, or it returns false
+// when there are no more rows or an error occurred (see: Err), in which case
+// the rows are closed.
+func (it *Iterator) Next() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.closed {
+		return false
+	}
+	if it.rows.Next() {
+		return true
+	}
+	if err := it.rows.Err(); err != nil {
+		it.err = err
+	}
+	it.close()
+	return false
+}
+
+// Scan will scan the current row into the result.  When the scan fails, the
+// rows are closed and the error is also returned by Err.
+func (it *Iterator) Scan(result interface{}) error {
+	const op = "dbw.Iterator.Scan"
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.closed {
+		return fmt.Errorf("%s: iterator is closed: %w", op, ErrInvalidParameter)
+	}
+	if err := it.rw.ScanRows(it.rows, result); err != nil {
+		it.err = err
+		it.close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Err returns the error, if any, which stopped the iteration.
+func (it *Iterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
+
+// Close will close the rows of the Iterator, which returns its connection to
+// the pool.  It's safe to call Close more than once.
+func (it *Iterator) Close() error {
+	const op = "dbw.Iterator.Close"
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if err := it.close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// close will close the rows, if they're not already closed, and it must be
+// called with the lock held.
+func (it *Iterator) close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	runtime.SetFinalizer(it, nil)
+	return it.rows.Close()
+}
+
+// finalize is the safety net for an Iterator which is garbage collected
+// without being closed.
+func (it *Iterator) finalize() {
+	if it.closed {
+		return
+	}
+	it.rw.underlying.wrapped.Logger.Warn(context.Background(), "dbw: iterator was garbage collected without being closed: %s", it.sql)
+	_ = it.rows.Close()
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDb_QueryIterator(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const query = "select 1 as n union all select 2 union all select 3"
+	open := func(t *testing.T) (*dbw.RW, func() int) {
+		t.Helper()
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		sqlDB, err := conn.SqlDB(testCtx)
+		require.NoError(t, err)
+		return dbw.New(conn), func() int { return sqlDB.Stats().InUse }
+	}
+	type result struct {
+		N int
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, inUse := open(t)
+		it, err := rw.QueryIterator(testCtx, query, nil)
+		require.NoError(err)
+		var got []int
+		for it.Next() {
+			var r result
+			require.NoError(it.Scan(&r))
+			got = append(got, r.N)
+		}
+		require.NoError(it.Err())
+		assert.Equal([]int{1, 2, 3}, got)
+		// the rows are closed on exhaustion, without calling Close
+		assert.Equal(0, inUse())
+		assert.False(it.Next())
+		assert.NoError(it.Close())
+		assert.NoError(it.Close())
+	})
+	t.Run("closed-on-scan-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, inUse := open(t)
+		it, err := rw.QueryIterator(testCtx, query, nil)
+		require.NoError(err)
+		require.True(it.Next())
+		err = it.Scan(nil)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Error(it.Err())
+		assert.Equal(0, inUse())
+		assert.False(it.Next())
+
+		err = it.Scan(&result{})
+		require.Error(err)
+		assert.Contains(err.Error(), "iterator is closed")
+	})
+	t.Run("close-early", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, inUse := open(t)
+		it, err := rw.QueryIterator(testCtx, query, nil)
+		require.NoError(err)
+		require.True(it.Next())
+		assert.Equal(1, inUse())
+		require.NoError(it.Close())
+		assert.Equal(0, inUse())
+		assert.False(it.Next())
+	})
+	t.Run("no-leak-after-gc", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counter := &testStatementCounter{}
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithLogger(counter))
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		conn.LogLevel(dbw.Warn)
+		sqlDB, err := conn.SqlDB(testCtx)
+		require.NoError(err)
+		rw, inUse := dbw.New(conn), func() int { return sqlDB.Stats().InUse }
+		func() {
+			for i := 0; i < 20; i++ {
+				it, err := rw.QueryIterator(testCtx, query, nil)
+				require.NoError(err)
+				// stop early without calling Close
+				require.True(it.Next())
+			}
+		}()
+		assert.Equal(20, inUse())
+		assert.Eventually(func() bool {
+			runtime.GC()
+			return inUse() == 0
+		}, 5*time.Second, 10*time.Millisecond)
+		// a warning is logged for each iterator which wasn't closed
+		warnings := counter.reset()
+		require.Len(warnings, 20)
+		assert.Equal(query, warnings[0])
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, _ := open(t)
+		it, err := rw.QueryIterator(testCtx, "", nil)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Nil(it)
+	})
+}
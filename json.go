@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSON is a column value (ex: a postgres json or jsonb column) which is
+// marshaled to json when it's written and unmarshaled when it's read.
+//
+// When it's written, a nil Data is written as a json null literal, unless
+// WithJSONNullAsSQLNull(true) is used, in which case it's written as SQL NULL.
+// These are different values for postgres (ex: "column is null" only matches
+// SQL NULL).
+//
+// When it's read, SQL NULL, an empty string and a json null literal all
+// result in a nil Data, and IsSQLNull reports whether the column was SQL NULL
+// (or an empty string).  Use Unmarshal to read the column into a typed value.
+type JSON struct {
+	// Data is the value of the column.  When it's read, Data is the value
+	// unmarshaled by encoding/json (ex: a map[string]interface{} for an
+	// object).
+	Data interface{}
+
+	nullAsSQLNull bool
+	raw           []byte
+}
+
+// NewJSON creates a new JSON for the data.  Supports the
+// WithJSONNullAsSQLNull option.
+func NewJSON(data interface{}, opt ...Option) JSON {
+	opts := GetOpts(opt...)
+	return JSON{
+		Data:          data,
+		nullAsSQLNull: opts.WithJSONNullAsSQLNull,
+	}
+}
+
+// IsSQLNull returns false when the JSON was read from a column with a json
+// value (including a json null literal), and true when it was read from a
+// column which was SQL NULL (or an empty string) or it wasn't read.
+func (j JSON) IsSQLNull() bool {
+	return len(j.raw) == 0
+}
+
+// Unmarshal will unmarshal the JSON into v, which must be a pointer.  The
+// value v points to is set to its zero value when the JSON is SQL NULL or a
+// json null literal.  The JSON is the column which was read or, when it
+// wasn't read, its marshaled Data.
+func (j JSON) Unmarshal(v interface{}) error {
+	const op = "dbw.(JSON).Unmarshal"
+	if isNil(v) || reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return fmt.Errorf("%s: v must be a non-nil pointer: %w", op, ErrInvalidParameter)
+	}
+	b := j.raw
+	if len(b) == 0 && !isNil(j.Data) {
+		var err error
+		if b, err = json.Marshal(j.Data); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if len(b) == 0 || string(b) == "null" {
+		rv := reflect.ValueOf(v).Elem()
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer for JSON.
+func (j JSON) Value() (driver.Value, error) {
+	const op = "dbw.(JSON).Value"
+	if isNil(j.Data) && j.nullAsSQLNull {
+		return nil, nil
+	}
+	b, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner for JSON.
+func (j *JSON) Scan(value interface{}) error {
+	const op = "dbw.(JSON).Scan"
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		b = append([]byte(nil), v...)
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("%s: unable to scan %T: %w", op, value, ErrInvalidParameter)
+	}
+	j.Data, j.raw = nil, nil
+	if len(b) == 0 {
+		return nil
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	j.Data, j.raw = data, b
+	return nil
+}
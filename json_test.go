@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testJSONModel struct {
+	Id    int `gorm:"primaryKey"`
+	Attrs dbw.JSON
+}
+
+func (*testJSONModel) TableName() string { return "db_test_json" }
+
+type testJSONAttrs struct {
+	Color string `json:"color"`
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(testCtx, "create table db_test_json (id integer primary key, attrs text)", nil)
+	require.NoError(t, err)
+
+	t.Run("write", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			attrs       dbw.JSON
+			wantSQLNull bool
+			wantRaw     string
+		}{
+			{"nil-as-json-null", dbw.NewJSON(nil), false, "null"},
+			{"nil-as-sql-null", dbw.NewJSON(nil, dbw.WithJSONNullAsSQLNull(true)), true, ""},
+			{"nil-pointer-as-sql-null", dbw.NewJSON((*testJSONAttrs)(nil), dbw.WithJSONNullAsSQLNull(true)), true, ""},
+			{"value", dbw.NewJSON(&testJSONAttrs{Color: "red"}, dbw.WithJSONNullAsSQLNull(true)), false, `{"color":"red"}`},
+		}
+		for i, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				id := 100 + i
+				require.NoError(rw.Create(testCtx, &testJSONModel{Id: id, Attrs: tt.attrs}))
+				found, err := rw.Exists(testCtx, &testJSONModel{}, "id = ? and attrs is null", []interface{}{id})
+				require.NoError(err)
+				assert.Equal(tt.wantSQLNull, found)
+				if !tt.wantSQLNull {
+					var raw []string
+					rows, err := rw.Query(testCtx, "select attrs from db_test_json where id = ?", []interface{}{id})
+					require.NoError(err)
+					for rows.Next() {
+						var s string
+						require.NoError(rows.Scan(&s))
+						raw = append(raw, s)
+					}
+					require.NoError(rows.Close())
+					assert.Equal([]string{tt.wantRaw}, raw)
+				}
+			})
+		}
+	})
+	t.Run("read", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			raw         interface{}
+			wantSQLNull bool
+			wantData    interface{}
+		}{
+			{"sql-null", nil, true, nil},
+			{"empty-string", "", true, nil},
+			{"json-null", "null", false, nil},
+			{"value", `{"color":"blue"}`, false, map[string]interface{}{"color": "blue"}},
+		}
+		for i, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				id := 200 + i
+				_, err := rw.Exec(testCtx, "insert into db_test_json (id, attrs) values (?, ?)", []interface{}{id, tt.raw})
+				require.NoError(err)
+
+				found := &testJSONModel{Id: id}
+				require.NoError(rw.LookupBy(testCtx, found))
+				assert.Equal(tt.wantSQLNull, found.Attrs.IsSQLNull())
+				assert.Equal(tt.wantData, found.Attrs.Data)
+
+				// a typed value is set to its zero value for nulls
+				attrs := testJSONAttrs{Color: "stale"}
+				require.NoError(found.Attrs.Unmarshal(&attrs))
+				if tt.wantData == nil {
+					assert.Equal(testJSONAttrs{}, attrs)
+				} else {
+					assert.Equal(testJSONAttrs{Color: "blue"}, attrs)
+				}
+			})
+		}
+	})
+	t.Run("round-trip", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		require.NoError(rw.Create(testCtx, &testJSONModel{Id: 300, Attrs: dbw.NewJSON(testJSONAttrs{Color: "green"})}))
+		found := &testJSONModel{Id: 300}
+		require.NoError(rw.LookupBy(testCtx, found))
+		assert.False(found.Attrs.IsSQLNull())
+		var attrs testJSONAttrs
+		require.NoError(found.Attrs.Unmarshal(&attrs))
+		assert.Equal("green", attrs.Color)
+
+		// the data is unmarshaled when the JSON wasn't read
+		attrs = testJSONAttrs{}
+		require.NoError(dbw.NewJSON(map[string]string{"color": "red"}).Unmarshal(&attrs))
+		assert.Equal("red", attrs.Color)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var j dbw.JSON
+		err := j.Scan(42)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+
+		err = j.Scan("{")
+		require.Error(err)
+
+		_, err = dbw.NewJSON(func() {}).Value()
+		require.Error(err)
+
+		err = j.Unmarshal(testJSONAttrs{})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+	})
+}
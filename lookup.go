@@ -6,6 +6,7 @@ package dbw
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	"gorm.io/gorm"
 )
@@ -14,10 +15,13 @@ import (
 // unique. If the resource implements either ResourcePublicIder or
 // ResourcePrivateIder interface, then they are used as the resource's
 // primary key for lookup.  Otherwise, the resource tags are used to
-// determine it's primary key(s) for lookup.  The WithDebug and WithTable
-// options are supported.
+// determine it's primary key(s) for lookup.  A resource whose row is marked
+// as deleted isn't found (see: SoftDeleter), unless WithDeleted(true) is used.
+// The WithDebug, WithTable, WithRowLock and WithDeleted options are supported.
 func (rw *RW) LookupBy(ctx context.Context, resourceWithIder interface{}, opt ...Option) error {
 	const op = "dbw.LookupById"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
 	if rw.underlying == nil {
 		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
 	}
@@ -31,7 +35,11 @@ func (rw *RW) LookupBy(ctx context.Context, resourceWithIder interface{}, opt ..
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
+	opts, err = rw.resolveTable(ctx, resourceWithIder, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	db := rw.underlying.wrapped.WithContext(ctx)
 	if opts.WithTable != "" {
 		db = db.Table(opts.WithTable)
@@ -39,6 +47,16 @@ func (rw *RW) LookupBy(ctx context.Context, resourceWithIder interface{}, opt ..
 	if opts.WithDebug {
 		db = db.Debug()
 	}
+	locking, lockRows, err := rw.rowLockClause(resourceWithIder, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if lockRows {
+		db = db.Clauses(locking)
+	}
+	if db, err = rw.softDeleteScope(db, resourceWithIder, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	rw.clearDefaultNullResourceFields(ctx, resourceWithIder)
 	if err := db.Where(where, keys...).First(resourceWithIder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -50,22 +68,173 @@ func (rw *RW) LookupBy(ctx context.Context, resourceWithIder interface{}, opt ..
 }
 
 // LookupByPublicId will lookup resource by its public_id, which must be unique.
-// The WithTable option is supported.
+// A resource whose row is marked as deleted isn't found (see: SoftDeleter),
+// unless WithDeleted(true) is used.  The WithTable, WithRowLock and WithDeleted
+// options are supported.
 func (rw *RW) LookupByPublicId(ctx context.Context, resource ResourcePublicIder, opt ...Option) error {
 	return rw.LookupBy(ctx, resource, opt...)
 }
 
+// LookupColumn will lookup the value of a single column of a resource by its
+// primary keys (see: LookupBy) and scan it into dst, which must be a pointer
+// (ex: *uint32 for a version).  The column must be a column of the resource
+// and ErrRecordNotFound is returned when the resource doesn't exist.  A
+// nullable column must be scanned into a pointer to a pointer or a sql.Null
+// type.  The WithDebug and WithTable options are supported.
+func (rw *RW) LookupColumn(ctx context.Context, resource interface{}, column string, dst interface{}, opt ...Option) error {
+	const op = "dbw.LookupColumn"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case column == "":
+		return fmt.Errorf("%s: missing column: %w", op, ErrInvalidParameter)
+	case isNil(dst) || reflect.TypeOf(dst).Kind() != reflect.Ptr:
+		return fmt.Errorf("%s: destination must be a pointer: %w", op, ErrInvalidParameter)
+	}
+	if err := validateResourcesInterface(resource); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	where, keys, err := rw.primaryKeysWhere(ctx, resource)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	opts, err := rw.resolveTable(ctx, resource, rw.getOpts(opt...))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if f := s.LookUpField(column); f == nil || f.DBName != column {
+		return fmt.Errorf("%s: unknown column %s: %w", op, column, ErrInvalidParameter)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	rows, err := db.Session(&gorm.Session{NewDB: true}).
+		Table(tableName).
+		Select(column).
+		Where(where, keys...).
+		Limit(1).
+		Rows()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return fmt.Errorf("%s: %w", op, ErrRecordNotFound)
+	}
+	if err := rows.Scan(dst); err != nil {
+		return fmt.Errorf("%s: unable to scan column %s: %w", op, column, err)
+	}
+	return nil
+}
+
+// LookupByPublicIds will lookup the resources with the public_ids provided
+// and append them to the resources, which must be a pointer to a slice of
+// pointers.  The ids are looked up in batches (see: WithBatchSize), so the
+// number of ids isn't constrained by the database's limit on query
+// parameters.  Duplicate ids are ignored and the order of the resources
+// returned is not guaranteed.  The resources whose rows are marked as deleted
+// aren't returned (see: SoftDeleter), unless WithDeleted(true) is used.  The
+// WithBatchSize, WithDebug, WithTable, WithResultTransformer and WithDeleted
+// options are supported.
+func (rw *RW) LookupByPublicIds(ctx context.Context, resources interface{}, publicIds []string, opt ...Option) error {
+	const op = "dbw.LookupByPublicIds"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case len(publicIds) == 0:
+		return fmt.Errorf("%s: missing public ids: %w", op, ErrInvalidParameter)
+	}
+	if err := validateResourcesInterface(resources); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	dest := reflect.ValueOf(resources).Elem()
+	if dest.Kind() != reflect.Slice {
+		return fmt.Errorf("%s: interface parameter must be a pointer to a slice: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(resources); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, resources, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	if opts.WithTable != "" {
+		db = db.Table(opts.WithTable)
+	}
+	if db, err = rw.softDeleteScope(db, resources, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, batch := range publicIdBatches(publicIds, opts.WithBatchSize) {
+		found := reflect.New(dest.Type())
+		if err := db.Where("public_id in ?", batch).Find(found.Interface()).Error; err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		dest.Set(reflect.AppendSlice(dest, found.Elem()))
+	}
+	if err := transformResults(resources, opts.WithResultTransformer); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// publicIdBatches returns the unique public ids in batches of batchSize.  If
+// batchSize <= 0, then DefaultBatchSize is used.
+func publicIdBatches(publicIds []string, batchSize int) [][]string {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	seen := make(map[string]struct{}, len(publicIds))
+	unique := make([]string, 0, len(publicIds))
+	for _, id := range publicIds {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	batches := make([][]string, 0, (len(unique)+batchSize-1)/batchSize)
+	for len(unique) > 0 {
+		n := batchSize
+		if len(unique) < n {
+			n = len(unique)
+		}
+		batches = append(batches, unique[:n])
+		unique = unique[n:]
+	}
+	return batches
+}
+
 func (rw *RW) lookupAfterWrite(ctx context.Context, i interface{}, opt ...Option) error {
 	const op = "dbw.lookupAfterWrite"
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
 	withLookup := opts.WithLookup
 	if err := raiseErrorOnHooks(i); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if !withLookup {
+	if !withLookup || opts.WithNoDatabaseSideEffects {
 		return nil
 	}
-	if err := rw.LookupBy(ctx, i, opt...); err != nil {
+	// the resource was just written, so it's looked up even when its row is
+	// marked as deleted
+	lookupOpts := append(make([]Option, 0, len(opt)+1), opt...)
+	if err := rw.LookupBy(ctx, i, append(lookupOpts, WithDeleted(true))...); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
@@ -5,6 +5,8 @@ package dbw_test
 
 import (
 	"context"
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -215,3 +217,166 @@ func TestDb_LookupBy(t *testing.T) {
 		}
 	})
 }
+
+func TestDb_LookupByPublicIds(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	db, _ := dbw.TestSetup(t)
+	testRw := dbw.New(db)
+
+	const cnt = 2000
+	users := make([]*dbtest.TestUser, 0, cnt)
+	wantIds := make([]string, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		u := testUser(t, nil, "", "lookup-by-public-ids@example.com", "")
+		users = append(users, u)
+		wantIds = append(wantIds, u.PublicId)
+	}
+	require.NoError(t, testRw.CreateItems(testCtx, users))
+	sort.Strings(wantIds)
+
+	// 100k ids exceeds the parameter limits of both postgres and sqlite, so a
+	// single in query would fail
+	ids := append([]string{}, wantIds...)
+	for i := len(ids); i < 100_000; i++ {
+		ids = append(ids, "u_missing_"+strconv.Itoa(i))
+	}
+	gotIds := func(found []*dbtest.TestUser) []string {
+		ids := make([]string, 0, len(found))
+		for _, u := range found {
+			ids = append(ids, u.PublicId)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	t.Run("huge-id-set", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*dbtest.TestUser
+		require.NoError(testRw.LookupByPublicIds(testCtx, &found, ids))
+		assert.Equal(wantIds, gotIds(found))
+	})
+	t.Run("with-batch-size", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*dbtest.TestUser
+		dupIds := append(append([]string{}, wantIds[:10]...), wantIds[:10]...)
+		require.NoError(testRw.LookupByPublicIds(testCtx, &found, dupIds, dbw.WithBatchSize(3)))
+		assert.Equal(wantIds[:10], gotIds(found))
+	})
+	t.Run("appends", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		found := []*dbtest.TestUser{users[0]}
+		require.NoError(testRw.LookupByPublicIds(testCtx, &found, []string{users[1].PublicId}))
+		require.Len(found, 2)
+		assert.Equal(users[1].PublicId, found[1].PublicId)
+	})
+	t.Run("not-found", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*dbtest.TestUser
+		require.NoError(testRw.LookupByPublicIds(testCtx, &found, []string{"u_missing"}))
+		assert.Empty(found)
+	})
+	errTests := []struct {
+		name            string
+		rw              *dbw.RW
+		resources       interface{}
+		ids             []string
+		opt             []dbw.Option
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{name: "nil-underlying", rw: &dbw.RW{}, resources: &[]*dbtest.TestUser{}, ids: wantIds[:1], wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "missing underlying db"},
+		{name: "missing-ids", rw: testRw, resources: &[]*dbtest.TestUser{}, wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "missing public ids"},
+		{name: "not-a-ptr", rw: testRw, resources: []*dbtest.TestUser{}, ids: wantIds[:1], wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "interface parameter must to be a pointer"},
+		{name: "not-a-slice", rw: testRw, resources: &dbtest.TestUser{}, ids: wantIds[:1], wantErrIs: dbw.ErrInvalidParameter, wantErrContains: "must be a pointer to a slice"},
+		{name: "bad-table", rw: testRw, resources: &[]*dbtest.TestUser{}, ids: wantIds[:1], opt: []dbw.Option{dbw.WithTable("invalid_table_name")}},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			err := tt.rw.LookupByPublicIds(testCtx, tt.resources, tt.ids, tt.opt...)
+			require.Error(err)
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(err, tt.wantErrIs)
+			}
+			if tt.wantErrContains != "" {
+				assert.Contains(err.Error(), tt.wantErrContains)
+			}
+		})
+	}
+}
+
+func TestDb_LookupColumn(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	db, _ := dbw.TestSetup(t)
+	testRw := dbw.New(db)
+	u := testUser(t, testRw, "lookup-column", "", "")
+	u.Name = "lookup-column-updated"
+	_, err := testRw.Update(testCtx, u, []string{"Name"}, nil)
+	require.NoError(t, err)
+
+	t.Run("version", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var version uint32
+		lookup := dbtest.AllocTestUser()
+		lookup.PublicId = u.PublicId
+		err := testRw.LookupColumn(testCtx, &lookup, "version", &version, dbw.WithDebug(true))
+		require.NoError(err)
+		assert.Equal(u.Version, version)
+		assert.Equal(uint32(2), version)
+	})
+	t.Run("name", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var name string
+		lookup := dbtest.AllocTestUser()
+		lookup.PublicId = u.PublicId
+		require.NoError(testRw.LookupColumn(testCtx, &lookup, "name", &name))
+		assert.Equal("lookup-column-updated", name)
+	})
+	t.Run("nullable", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var email *string
+		lookup := dbtest.AllocTestUser()
+		lookup.PublicId = u.PublicId
+		require.NoError(testRw.LookupColumn(testCtx, &lookup, "email", &email))
+		assert.Nil(email)
+	})
+	t.Run("not-found", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var version uint32
+		lookup := testUser(t, nil, "", "", "")
+		err := testRw.LookupColumn(testCtx, lookup, "version", &version)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		var version uint32
+		lookup := dbtest.AllocTestUser()
+		lookup.PublicId = u.PublicId
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			resource        interface{}
+			column          string
+			dst             interface{}
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, &lookup, "version", &version, "missing underlying db"},
+			{"missing-column", testRw, &lookup, "", &version, "missing column"},
+			{"missing-destination", testRw, &lookup, "version", nil, "destination must be a pointer"},
+			{"destination-not-a-pointer", testRw, &lookup, "version", version, "destination must be a pointer"},
+			{"missing-resource", testRw, nil, "version", &version, "interface parameter must to be a pointer"},
+			{"unknown-column", testRw, &lookup, "version; drop table db_test_user", &version, "unknown column"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := tt.rw.LookupColumn(testCtx, tt.resource, tt.column, tt.dst)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
@@ -0,0 +1,168 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// namedQueries is the registry of a DB's named queries, which is shared with
+// the DB's transactions (see: RegisterQuery)
+type namedQueries struct {
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+func (n *namedQueries) get(name string) (*sql.Stmt, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	stmt, ok := n.stmts[name]
+	return stmt, ok
+}
+
+// register will prepare the query and register its statement with the name.
+func (n *namedQueries) register(name, query string, prepare func(query string) (*sql.Stmt, error)) error {
+	const op = "dbw.(namedQueries).register"
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.stmts[name]; ok {
+		return fmt.Errorf("%s: query %s is already registered: %w", op, name, ErrInvalidParameter)
+	}
+	stmt, err := prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: unable to prepare query %s: %w", op, name, err)
+	}
+	if n.stmts == nil {
+		n.stmts = map[string]*sql.Stmt{}
+	}
+	n.stmts[name] = stmt
+	return nil
+}
+
+// close will close the prepared statements of the registered queries.
+func (n *namedQueries) close() error {
+	const op = "dbw.(namedQueries).close"
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var firstErr error
+	for name, stmt := range n.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: unable to close query %s: %w", op, name, err)
+		}
+		delete(n.stmts, name)
+	}
+	return firstErr
+}
+
+// RegisterQuery will register the sql as a named query, which is prepared once
+// and run by name using RunNamed(...).  Unlike a prepared statement cache,
+// this gives explicit control over which statements are prepared and
+// centralizes the sql of frequently run queries.  The sql is prepared as it
+// is, so its parameters must use the placeholders of the database (ex: $1 for
+// postgres and ? for sqlite).  An ErrInvalidParameter is returned when the
+// name is already registered, and an error is returned when the database
+// can't prepare the sql (ex: a syntax error).  The prepared statements are
+// closed when the DB is closed.
+func (db *DB) RegisterQuery(name, sql string) error {
+	const op = "dbw.(DB).RegisterQuery"
+	switch {
+	case db.wrapped == nil:
+		return fmt.Errorf("%s: missing underlying database: %w", op, ErrInternal)
+	case db.txId != "":
+		return fmt.Errorf("%s: queries cannot be registered by a transaction: %w", op, ErrInvalidParameter)
+	case name == "":
+		return fmt.Errorf("%s: missing name: %w", op, ErrInvalidParameter)
+	case sql == "":
+		return fmt.Errorf("%s: missing sql: %w", op, ErrInvalidParameter)
+	case db.namedQueries == nil:
+		return fmt.Errorf("%s: missing named queries: %w", op, ErrInternal)
+	}
+	underlying, err := db.wrapped.DB()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := db.namedQueries.register(name, sql, underlying.Prepare); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RunNamed will run the named query (see: DB.RegisterQuery) with the args as
+// its parameters.  The results are scanned into dst, which may be a pointer to
+// a resource, which receives the first row (an ErrRecordNotFound is returned
+// when there are no rows), or to a slice of resources, whose elements are
+// replaced by the rows.  When
+// dst is nil, the query is executed without reading any results (ex: an
+// update).  When the RW is a transaction, the query is run within it.  An
+// ErrRecordNotFound is also returned when the name isn't registered.
+func (rw *RW) RunNamed(ctx context.Context, name string, args []interface{}, dst interface{}) error {
+	const op = "dbw.RunNamed"
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case dst == nil && rw.underlying.ReadOnly():
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case name == "":
+		return fmt.Errorf("%s: missing name: %w", op, ErrInvalidParameter)
+	case rw.underlying.namedQueries == nil:
+		return fmt.Errorf("%s: query %s is not registered: %w", op, name, ErrRecordNotFound)
+	}
+	if dst != nil {
+		if err := validateResourcesInterface(dst); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	stmt, ok := rw.underlying.namedQueries.get(name)
+	if !ok {
+		return fmt.Errorf("%s: query %s is not registered: %w", op, name, ErrRecordNotFound)
+	}
+	if tx, ok := rw.underlying.wrapped.Statement.ConnPool.(*sql.Tx); ok {
+		stmt = tx.StmtContext(ctx, stmt)
+		defer stmt.Close()
+	}
+	if dst == nil {
+		ctx, cancel := rw.writeContext(ctx)
+		defer cancel()
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	results := reflect.ValueOf(dst).Elem()
+	if results.Kind() != reflect.Slice {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return fmt.Errorf("%s: %w", op, ErrRecordNotFound)
+		}
+		if err := rw.underlying.wrapped.ScanRows(rows, dst); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+	results.Set(reflect.MakeSlice(results.Type(), 0, 0))
+	for rows.Next() {
+		elem := reflect.New(results.Type().Elem().Elem())
+		if err := rw.underlying.wrapped.ScanRows(rows, elem.Interface()); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		results.Set(reflect.Append(results, elem))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDb_RunNamed(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	id, err := dbw.NewId("u")
+	require.NoError(t, err)
+	prefix := "named-" + id
+	alice := testUser(t, testRw, prefix+"-alice", "alice@example.com", "")
+	bob := testUser(t, testRw, prefix+"-bob", "bob@example.com", "")
+
+	require.NoError(t, conn.RegisterQuery("user-by-id", "select * from db_test_user where public_id = ?"))
+	require.NoError(t, conn.RegisterQuery("users-by-prefix", "select * from db_test_user where name like ? order by name"))
+	require.NoError(t, conn.RegisterQuery("set-email", "update db_test_user set email = ? where public_id = ?"))
+
+	t.Run("register-invalid", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			queryName       string
+			sql             string
+			wantIs          error
+			wantErrContains string
+		}{
+			{"missing-name", "", "select 1", dbw.ErrInvalidParameter, "missing name"},
+			{"missing-sql", "missing-sql", "", dbw.ErrInvalidParameter, "missing sql"},
+			{"duplicate", "user-by-id", "select 1", dbw.ErrInvalidParameter, "query user-by-id is already registered"},
+			{"syntax-error", "syntax-error", "selec * from db_test_user", nil, "unable to prepare query syntax-error"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := conn.RegisterQuery(tt.queryName, tt.sql)
+				require.Error(err)
+				if tt.wantIs != nil {
+					assert.ErrorIs(err, tt.wantIs)
+				}
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+	t.Run("resource", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		found := dbtest.AllocTestUser()
+		require.NoError(testRw.RunNamed(testCtx, "user-by-id", []interface{}{alice.PublicId}, &found))
+		assert.Equal(alice.Name, found.Name)
+		assert.Equal(alice.Email, found.Email)
+	})
+	t.Run("resources", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*dbtest.TestUser
+		require.NoError(testRw.RunNamed(testCtx, "users-by-prefix", []interface{}{prefix + "-%"}, &found))
+		require.Len(found, 2)
+		assert.Equal(alice.PublicId, found[0].PublicId)
+		assert.Equal(bob.PublicId, found[1].PublicId)
+	})
+	t.Run("exec-in-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := testRw.DoTx(testCtx, func(error) bool { return false }, 0, dbw.ExpBackoff{}, func(r dbw.Reader, w dbw.Writer) error {
+			tx := w.(*dbw.RW)
+			require.NoError(tx.RunNamed(testCtx, "set-email", []interface{}{"bob@example.org", bob.PublicId}, nil))
+			found := dbtest.AllocTestUser()
+			require.NoError(tx.RunNamed(testCtx, "user-by-id", []interface{}{bob.PublicId}, &found))
+			assert.Equal("bob@example.org", found.Email)
+			return nil
+		})
+		require.NoError(err)
+		found := dbtest.AllocTestUser()
+		require.NoError(testRw.RunNamed(testCtx, "user-by-id", []interface{}{bob.PublicId}, &found))
+		assert.Equal("bob@example.org", found.Email)
+	})
+	t.Run("resource-not-found", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		found := dbtest.AllocTestUser()
+		err := testRw.RunNamed(testCtx, "user-by-id", []interface{}{"u_unknown"}, &found)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+	t.Run("not-registered", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		err := testRw.RunNamed(testCtx, "unknown", nil, nil)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+	t.Run("invalid-dst", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		err := testRw.RunNamed(testCtx, "user-by-id", []interface{}{alice.PublicId}, dbtest.AllocTestUser())
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+	})
+}
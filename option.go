@@ -4,7 +4,13 @@
 package dbw
 
 import (
+	"context"
+	"reflect"
+	"time"
+
 	"github.com/hashicorp/go-hclog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetOpts - iterate the inbound Options and return a struct.
@@ -18,6 +24,21 @@ func GetOpts(opt ...Option) Options {
 	return opts
 }
 
+// getOpts returns the options for an operation of the RW, which are the DB's
+// default options (see: DB.SetDefaultOptions) overridden by the opt provided.
+func (rw *RW) getOpts(opt ...Option) Options {
+	return rw.getOptsWithBase(nil, opt...)
+}
+
+// getOptsWithBase returns the options for an operation of the RW like
+// getOpts, except the base options are overridden by the DB's default options.
+func (rw *RW) getOptsWithBase(base []Option, opt ...Option) Options {
+	if rw != nil && rw.underlying != nil && rw.underlying.defaultOptions != nil {
+		base = append(base, rw.underlying.defaultOptions.get()...)
+	}
+	return GetOpts(append(base, opt...)...)
+}
+
 // Option - how Options are passed as arguments.
 type Option func(*Options)
 
@@ -73,6 +94,10 @@ type Options struct {
 	// up.
 	WithOrder string
 
+	// WithOrderBy provides an option to provide an order, built using an
+	// OrderBy, when searching.
+	WithOrderBy *OrderBy
+
 	// WithPrngValues provides an option to provide values to seed an PRNG when generating IDs
 	WithPrngValues []string
 
@@ -82,6 +107,11 @@ type Options struct {
 	// behavior for a logger (the default only emits postgres errors)
 	WithLogger hclog.Logger
 
+	// WithLogSQLArgs specifies whether the values of a statement's arguments
+	// are included in the sql that's logged.  It's only valid for Open(..)
+	// and OpenWith(...) and defaults to true.
+	WithLogSQLArgs bool
+
 	// WithMinOpenConnections specifies and optional min open connections for the
 	// database.  A value of zero means that there is no min.
 	WithMaxOpenConnections int
@@ -90,6 +120,20 @@ type Options struct {
 	// database.  A value of zero equals unlimited connections
 	WithMinOpenConnections int
 
+	// WithMaxIdleConnections specifies an optional max idle connections for
+	// the database.  A value of zero means the database/sql default is used.
+	WithMaxIdleConnections int
+
+	// WithConnMaxLifetime specifies an optional max amount of time a
+	// connection may be reused.  A value of zero means connections are reused
+	// forever.
+	WithConnMaxLifetime time.Duration
+
+	// WithConnMaxIdleTime specifies an optional max amount of time a
+	// connection may be idle.  A value of zero means connections aren't closed
+	// because they're idle.
+	WithConnMaxIdleTime time.Duration
+
 	// WithDebug indicates that the given operation should invoke debug output
 	// mode
 	WithDebug bool
@@ -99,18 +143,205 @@ type Options struct {
 	// exclusion constraint error
 	WithOnConflict *OnConflict
 
+	// WithOnConflictFunc specifies an optional func which returns the on
+	// conflict criteria of each item for CreateItems (see: WithOnConflictFunc)
+	WithOnConflictFunc func(item interface{}) *OnConflict
+
+	// WithConflictTargets specifies the conflict targets of CreateItems'
+	// items, which are checked for existing rows (see:
+	// WithConflictOnMultipleTargets)
+	WithConflictTargets []Columns
+
+	// WithConflictTargetAutoDetect specifies that the on conflict target is
+	// detected from the resource's single unique key (see: WithUpsert).
+	WithConflictTargetAutoDetect bool
+
 	// WithRowsAffected specifies an option for returning the rows affected
 	// and typically used with "bulk" write operations.
 	WithRowsAffected *int64
 
 	// WithTable specifies an option for setting a table name to use for the
-	// operation.
+	// operation.  The name may be qualified by its schema (ex: reporting.events)
 	WithTable string
 
 	// WithBatchSize specifies an option for setting the batch size for bulk
 	// operations. If WithBatchSize == 0, then the default batch size is used.
 	WithBatchSize int
 
+	// WithConflictOverride specifies that on conflict column assignments
+	// which reference the same column with conflicting values are allowed.
+	// SetColumnValues(...) assignments take precedence over SetColumns(...)
+	// assignments for the same column.
+	WithConflictOverride bool
+
+	// WithConflictUpdateColumnsFromFieldMask specifies field mask paths which
+	// are translated into the columns updated by an on conflict action.
+	WithConflictUpdateColumnsFromFieldMask []string
+
+	// WithConflictUpdateTimestamp specifies that an on conflict update also
+	// sets the resource's update time column to the current timestamp.
+	WithConflictUpdateTimestamp bool
+
+	// WithConflictVersionCheck specifies the version which the conflicting
+	// row must have for an on conflict update, which also increments the
+	// row's version.
+	WithConflictVersionCheck *uint32
+
+	// WithConflictConstraint specifies the name of the unique constraint
+	// which a DO NOTHING insert conflicted with.
+	WithConflictConstraint *string
+
+	// WithRejectFullScans specifies that reads without a where clause and with
+	// unlimited results are rejected.  It's only valid for Open(..) and
+	// OpenWith(...)
+	WithRejectFullScans bool
+
+	// WithAllowFullScan specifies that a read without a where clause and with
+	// unlimited results is allowed, even when WithRejectFullScans was used to
+	// open the database.
+	WithAllowFullScan bool
+
+	// WithDeleted specifies that the deleted rows of a SoftDeleter resource are
+	// included in a read.
+	WithDeleted bool
+
+	// WithConflictDebug specifies that the on conflict target, action and
+	// rendered insert statement are logged when WithOnConflict is used.
+	WithConflictDebug bool
+
+	// WithContextLogFields specifies an optional func which extracts
+	// structured fields from an operation's context, which are attached to the
+	// entries logged for the operation.  It's only valid for Open(..) and
+	// OpenWith(...)
+	WithContextLogFields func(ctx context.Context) []interface{}
+
+	// WithDryRun specifies a pointer which receives the sql generated by a
+	// write operation, which is not executed.
+	WithDryRun *string
+
+	// WithResultTransformer specifies an optional func which is called for
+	// every resource read by a read operation, before it's returned.
+	WithResultTransformer func(i interface{}) error
+
+	// WithRetryableErrorFunc specifies an optional func which decides whether
+	// an error is retryable (see: RW.IsRetryableError)
+	WithRetryableErrorFunc func(error) bool
+
+	// WithExcludeColumns specifies columns which are not selected by a read
+	// operation.
+	WithExcludeColumns []string
+
+	// WithWindowCount specifies a pointer which receives the total count of
+	// rows matching a search, regardless of its limit.
+	WithWindowCount *int64
+
+	// WithNoDatabaseSideEffects specifies that the database doesn't modify
+	// the resources written (no triggers, computed columns, etc), so the
+	// lookup after a write operation is skipped.
+	WithNoDatabaseSideEffects bool
+
+	// WithAppendResults specifies that SearchWhere appends the resources found
+	// to the existing contents of the resources slice.
+	WithAppendResults bool
+
+	// WithReturnOldValues specifies a map which receives the column values of
+	// the row before it's updated.
+	WithReturnOldValues *map[string]interface{}
+
+	// WithDefaultReadTimeout specifies the default timeout for read
+	// operations.  It's only valid for Open(..) and OpenWith(...)
+	WithDefaultReadTimeout time.Duration
+
+	// WithDefaultWriteTimeout specifies the default timeout for write
+	// operations.  It's only valid for Open(..) and OpenWith(...)
+	WithDefaultWriteTimeout time.Duration
+
+	// WithCreateTimeColumn specifies the create time column, which is
+	// managed by the database.
+	WithCreateTimeColumn string
+
+	// WithUpdateTimeColumn specifies the update time column, which is
+	// managed by the database.
+	WithUpdateTimeColumn string
+
+	// WithGormClauses specifies gorm clauses which are applied to the
+	// statement of a read operation.
+	WithGormClauses []clause.Expression
+
+	// WithReturningColumns specifies the columns returned by an insert.
+	WithReturningColumns []string
+
+	// WithReturningResults specifies a pointer to a slice which receives the
+	// rows updated by UpdateWhere.
+	WithReturningResults interface{}
+
+	// WithReturnInserted specifies a pointer to a slice which receives the
+	// rows inserted by CreateItems.
+	WithReturnInserted interface{}
+
+	// WithSnapshot specifies the id of an exported snapshot which is imported
+	// by a transaction when it begins.
+	WithSnapshot string
+
+	// WithPartitionKeyColumn and WithPartitionKeyValue specify the partition
+	// key column which must be set, to the value when it's not nil, on the
+	// resources being created.
+	WithPartitionKeyColumn string
+	WithPartitionKeyValue  interface{}
+
+	// WithIndexPredicate specifies the predicate of a partial unique index,
+	// which is used by an on conflict target and Exists.
+	WithIndexPredicate string
+
+	// WithTableResolver specifies a func which resolves the table name for
+	// every operation.  It's only valid for Open(..) and OpenWith(...)
+	WithTableResolver func(ctx context.Context, defaultName string) string
+
+	// WithCreateBatchSize specifies the default batch size for CreateItems.
+	// It's only valid for Open(..) and OpenWith(...)
+	WithCreateBatchSize int
+
+	// WithHealthCheck specifies the interval of the DB's health checks.  It's
+	// only valid for Open(..) and OpenWith(...)
+	WithHealthCheck time.Duration
+
+	// WithGormPlugins specifies the gorm plugins to register with the DB.
+	// It's only valid for Open(..) and OpenWith(...)
+	WithGormPlugins []gorm.Plugin
+
+	// WithSoftDeleteColumns specifies the DB's soft delete columns.  It's only
+	// valid for Open(..) and OpenWith(...)
+	WithSoftDeleteColumns []SoftDeleteColumn
+
+	// WithWriteCallbacks specifies the DB's write callbacks.  It's only valid
+	// for Open(..) and OpenWith(...)
+	WithWriteCallbacks []WriteCallback
+
+	// WithMaxConcurrentOps specifies the max number of the DB's statements
+	// which are executed concurrently.  It's only valid for Open(..) and
+	// OpenWith(...)
+	WithMaxConcurrentOps int
+
+	// WithAutoInvalidateBadConns specifies that the DB's statements are
+	// retried once when they fail with a bad connection error.  It's only
+	// valid for Open(..) and OpenWith(...)
+	WithAutoInvalidateBadConns bool
+
+	// WithDistinctOn specifies the "distinct on" columns for a read.
+	WithDistinctOn []string
+
+	// WithRowLock specifies the strength of the locks on the rows of a read.
+	WithRowLock RowLockStrength
+
+	// WithRowLockOf specifies the tables whose rows are locked by a read with
+	// a row lock.
+	WithRowLockOf []string
+
+	// WithJSONNullAsSQLNull specifies that a JSON with nil data is written as
+	// SQL NULL, rather than a json null literal.  It's only valid for
+	// NewJSON(...)
+	WithJSONNullAsSQLNull bool
+
 	withLogLevel LogLevel
 }
 
@@ -120,6 +351,7 @@ func getDefaultOptions() Options {
 		WithNullPaths:      []string{},
 		WithBatchSize:      DefaultBatchSize,
 		withLogLevel:       Error,
+		WithLogSQLArgs:     true,
 	}
 }
 
@@ -208,6 +440,16 @@ func WithOrder(withOrder string) Option {
 	}
 }
 
+// WithOrderBy provides an option to provide an order when searching, which is
+// built using an OrderBy and rendered for the dialect, so explicit NULL
+// placement (see: OrderBy.NullsFirst and OrderBy.NullsLast) is consistent
+// across dialects.  It cannot be combined with WithOrder.
+func WithOrderBy(orderBy *OrderBy) Option {
+	return func(o *Options) {
+		o.WithOrderBy = orderBy
+	}
+}
+
 // WithPrngValues provides an option to provide values to seed an PRNG when generating IDs
 func WithPrngValues(withPrngValues []string) Option {
 	return func(o *Options) {
@@ -225,6 +467,17 @@ func WithLogger(l hclog.Logger) Option {
 	}
 }
 
+// WithLogSQLArgs specifies whether the values of a statement's arguments are
+// included in the sql that's logged, which defaults to true.  When false, the
+// sql is logged with its placeholders (ex: $1 or ?) and without the values of
+// its arguments, so sql can be logged without writing sensitive data (like
+// emails) to the logs.  It's only valid for Open(..) and OpenWith(...)
+func WithLogSQLArgs(enable bool) Option {
+	return func(o *Options) {
+		o.WithLogSQLArgs = enable
+	}
+}
+
 // WithMaxOpenConnections specifies and optional max open connections for the
 // database.  A value of zero equals unlimited connections
 func WithMaxOpenConnections(max int) Option {
@@ -241,6 +494,34 @@ func WithMinOpenConnections(max int) Option {
 	}
 }
 
+// WithMaxIdleConnections specifies an optional max idle connections for the
+// database, which must not be greater than the max open connections.  A value
+// of zero means the database/sql default of 2 is used.
+func WithMaxIdleConnections(max int) Option {
+	return func(o *Options) {
+		o.WithMaxIdleConnections = max
+	}
+}
+
+// WithConnMaxLifetime specifies an optional max amount of time a connection
+// may be reused, which avoids keeping stale connections (ex: behind a load
+// balancer that drops long-lived connections).  A value of zero means
+// connections are reused forever.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *Options) {
+		o.WithConnMaxLifetime = d
+	}
+}
+
+// WithConnMaxIdleTime specifies an optional max amount of time a connection
+// may be idle before it's closed.  A value of zero means connections aren't
+// closed because they're idle.
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(o *Options) {
+		o.WithConnMaxIdleTime = d
+	}
+}
+
 // WithDebug specifies the given operation should invoke debug mode for the
 // database output
 func WithDebug(with bool) Option {
@@ -258,6 +539,64 @@ func WithOnConflict(onConflict *OnConflict) Option {
 	}
 }
 
+// WithOnConflictFunc specifies an option for CreateItems to use a different
+// on conflict criteria for each item, as returned by the func (a nil OnConflict
+// inserts the item without an on conflict clause).  The items are grouped by
+// their OnConflict and each group is inserted with its own statements within a
+// transaction, so items of the same type can conflict on different unique
+// keys.  It can't be used with WithOnConflict.
+func WithOnConflictFunc(fn func(item interface{}) *OnConflict) Option {
+	return func(o *Options) {
+		o.WithOnConflictFunc = fn
+	}
+}
+
+// WithConflictOnMultipleTargets specifies an option for CreateItems to upsert
+// items into a table with several unique keys, where an item may conflict with
+// an existing row on any of them (ON CONFLICT can only name one target).  The
+// option's targets (or the resource's unique keys, when none are given) are
+// checked for existing rows with one query per target, and each item is
+// inserted with the WithOnConflict option's action and the first target on
+// which it conflicts (or the first target, when it doesn't conflict).  The
+// reads and inserts are executed within a transaction (a transaction is
+// started if the writer isn't already in one).  The WithOnConflict option must
+// specify the action without a target, and the option can't be used with
+// WithOnConflictFunc, WithReturnInserted or the DeleteExisting action.  It's
+// supported by Postgres, CockroachDB and Sqlite.
+func WithConflictOnMultipleTargets(targets ...Columns) Option {
+	return func(o *Options) {
+		o.WithConflictTargets = append([]Columns{}, targets...)
+	}
+}
+
+// WithIgnoreConflictOn specifies an option to ignore conflicts on the columns
+// of a unique index, while conflicts on any other unique constraint still
+// return an error.  It's shorthand for an OnConflict with a Columns target and
+// a DoNothing action, so it replaces any WithOnConflict option and the columns
+// must match a unique index of the resource's table.
+func WithIgnoreConflictOn(columns ...string) Option {
+	return func(o *Options) {
+		o.WithOnConflict = &OnConflict{
+			Target: Columns(columns),
+			Action: DoNothing(true),
+		}
+	}
+}
+
+// WithUpsert specifies an option for Create and CreateItems to take the on
+// conflict action (see: OnConflict) when an insert conflicts with the
+// resource's unique key, which is detected from the resource's model: its
+// primary key, unique fields and unique indexes.  An ErrInvalidParameter is
+// returned when the model has zero or multiple unique keys, since the target
+// is ambiguous and must be specified using WithOnConflict.  It replaces any
+// WithOnConflict option.
+func WithUpsert(action interface{}) Option {
+	return func(o *Options) {
+		o.WithOnConflict = &OnConflict{Action: action}
+		o.WithConflictTargetAutoDetect = true
+	}
+}
+
 // WithReturnRowsAffected specifies an option for returning the rows affected
 // and typically used with "bulk" write operations.
 func WithReturnRowsAffected(rowsAffected *int64) Option {
@@ -267,7 +606,11 @@ func WithReturnRowsAffected(rowsAffected *int64) Option {
 }
 
 // WithTable specifies an option for setting a table name to use for the
-// operation.
+// operation.  The name may be qualified by its schema (ex: reporting.events),
+// which allows an operation on a table in another schema.  An
+// ErrInvalidParameter is returned by the operation when the name isn't a
+// table name or a schema qualified table name (ex: it contains whitespace or
+// a semicolon).
 func WithTable(name string) Option {
 	return func(o *Options) {
 		o.WithTable = name
@@ -289,3 +632,515 @@ func WithBatchSize(size int) Option {
 		o.WithBatchSize = size
 	}
 }
+
+// WithConflictOverride specifies an option to allow on conflict column
+// assignments which reference the same column with conflicting values.  When
+// enabled, SetColumnValues(...) assignments take precedence over
+// SetColumns(...) assignments for the same column.  Without this option, an
+// ErrInvalidParameter is returned for conflicting assignments.
+func WithConflictOverride(enable bool) Option {
+	return func(o *Options) {
+		o.WithConflictOverride = enable
+	}
+}
+
+// WithConflictUpdateColumnsFromFieldMask specifies an option to derive the
+// columns updated by an on conflict action from field mask paths.  The paths
+// are translated to column names using the resource's schema and
+// non-updatable fields are filtered out (see: InitNonUpdatableFields), so the
+// on conflict update touches exactly the fields provided.  It must be used
+// with WithOnConflict and the on conflict action must either be nil or
+// []ColumnValue (in which case the derived columns are appended).
+func WithConflictUpdateColumnsFromFieldMask(paths []string) Option {
+	return func(o *Options) {
+		o.WithConflictUpdateColumnsFromFieldMask = paths
+	}
+}
+
+// WithConflictUpdateTimestamp specifies an option to set the update time
+// column (see: WithUpdateTimeColumn) to the current timestamp when an on
+// conflict action updates an existing row, so the row's modification time
+// stays accurate without listing the column in the action.  It only applies
+// when the resource has the update time column and the on conflict action is
+// []ColumnValue (ex: SetColumns, SetColumnValues or UpdateChangedColumns),
+// and an assignment of the column in the action takes precedence.
+func WithConflictUpdateTimestamp(enable bool) Option {
+	return func(o *Options) {
+		o.WithConflictUpdateTimestamp = enable
+	}
+}
+
+// WithConflictVersionCheck specifies an option for the optimistic locking of
+// an on conflict update: the conflicting row is only updated when its version
+// matches the version and the update also increments its version column (see:
+// IncrementVersion).  It's the same as using WithVersion with an
+// IncrementVersion action, expressed with a single option.  Zero is not a
+// valid version, the option requires a []ColumnValue action (ex: SetColumns)
+// and it can't be used with a WithVersion of a different version or the
+// DeleteExisting action.
+func WithConflictVersionCheck(version uint32) Option {
+	return func(o *Options) {
+		o.WithConflictVersionCheck = &version
+	}
+}
+
+// WithConflictConstraintOut specifies an option for Create with a DoNothing
+// conflict action: when the insert is skipped, the name of the unique
+// constraint (or unique index) which the resource conflicted with is returned
+// in name, which helps to disambiguate a skipped insert into a table with more
+// than one unique key.  The name is determined after the insert by reading the
+// table's unique keys from the database's catalog and then looking up the row
+// with the resource's values for each key until one is found, so a skipped
+// insert requires a query for the unique keys and up to one query per key.
+// The name is empty when the insert isn't skipped or the conflicting row can't
+// be found (ex: it was deleted after the insert).  Keys with a column which
+// isn't a field of the resource, or whose value is NULL or a database default,
+// are not checked.  Sqlite doesn't name the primary key of a rowid alias
+// (INTEGER PRIMARY KEY), so it's returned as "PRIMARY KEY".  Only supported
+// by Postgres, CockroachDB and Sqlite.
+func WithConflictConstraintOut(name *string) Option {
+	return func(o *Options) {
+		o.WithConflictConstraint = name
+	}
+}
+
+// WithRejectFullScans specifies an option to reject reads without a where
+// clause and with unlimited results (see: WithLimit), which typically
+// indicates a forgotten where clause which will scan an entire table.  These
+// reads will return an ErrUnsafeQuery unless WithAllowFullScan is used. It's
+// only valid for Open(..) and OpenWith(...)
+func WithRejectFullScans(enable bool) Option {
+	return func(o *Options) {
+		o.WithRejectFullScans = enable
+	}
+}
+
+// WithAllowFullScan specifies an option to allow a read without a where clause
+// and with unlimited results, when the database was opened using
+// WithRejectFullScans.
+func WithAllowFullScan(enable bool) Option {
+	return func(o *Options) {
+		o.WithAllowFullScan = enable
+	}
+}
+
+// WithDeleted specifies an option to include the deleted rows of a resource
+// which implements SoftDeleter (or has a soft delete column, see:
+// WithSoftDeleteColumn) in a read.
+func WithDeleted(enable bool) Option {
+	return func(o *Options) {
+		o.WithDeleted = enable
+	}
+}
+
+// WithConflictDebug specifies an option to log the resolved on conflict target
+// and action, along with the rendered insert statement, when WithOnConflict is
+// used for a write operation.  Unlike WithDebug, it only logs the statements
+// for operations with an on conflict clause and it logs them regardless of
+// the database's log level.
+func WithConflictDebug(enable bool) Option {
+	return func(o *Options) {
+		o.WithConflictDebug = enable
+	}
+}
+
+// WithContextLogFields specifies an optional func which extracts structured
+// fields (key/value pairs) from an operation's context, like a request id or
+// tenant id.  The func is called for every logged query and the fields are
+// attached to the log entry via the hclog's With(...).  It's only valid for
+// Open(..) and OpenWith(...) and it's only used with a WithLogger(...) logger
+// which doesn't implement the LogWriter interface.
+func WithContextLogFields(fn func(ctx context.Context) []interface{}) Option {
+	return func(o *Options) {
+		o.WithContextLogFields = fn
+	}
+}
+
+// WithDryRun specifies an option for a dry run of a write operation (Create,
+// Update and Delete), which generates its sql without executing it.  The
+// generated sql, with its args interpolated, is written to the provided
+// pointer.  All the operation's validation is still run, along with any
+// WithBeforeWrite func, but the WithAfterWrite func is not called and zero
+// rows affected are returned.
+func WithDryRun(sql *string) Option {
+	return func(o *Options) {
+		o.WithDryRun = sql
+	}
+}
+
+// WithResultTransformer specifies an option to provide a func which is called
+// for every resource read by SearchWhere and LookupWhere after it's scanned and
+// before the read returns, which allows centralizing read-time processing like
+// decrypting a column.  The i interface{} passed at runtime will be a pointer
+// to the resource.  An error returned by the func aborts the read.
+func WithResultTransformer(fn func(i interface{}) error) Option {
+	return func(o *Options) {
+		o.WithResultTransformer = fn
+	}
+}
+
+// WithRetryableErrorFunc specifies an option to provide a func which decides
+// whether an error is retryable, which overrides the built-in detection of
+// transient errors (serialization failures, deadlocks and lock timeouts).
+// It's typically used to add retryable errors for Postgres compatible
+// databases which have additional retryable error codes.  It's valid for
+// Open(..) and OpenWith(...), which sets the func used by RW.IsRetryableError,
+// PurgeWhere and BatchExec, and for PurgeWhere and BatchExec, which overrides
+// the func for that operation.
+func WithRetryableErrorFunc(fn func(error) bool) Option {
+	return func(o *Options) {
+		o.WithRetryableErrorFunc = fn
+	}
+}
+
+// WithDefaultReadTimeout specifies an option for the default timeout of read
+// operations (LookupBy, LookupWhere, SearchWhere, etc), which is applied when
+// the operation's context doesn't have a deadline.  It's not applied to Query,
+// since its rows are read after it returns.  It's only valid for Open(..) and
+// OpenWith(...) and a timeout <= 0 means no default timeout.
+func WithDefaultReadTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.WithDefaultReadTimeout = timeout
+	}
+}
+
+// WithDefaultWriteTimeout specifies an option for the default timeout of write
+// operations (Create, Update, Delete, Exec, etc), which is applied when the
+// operation's context doesn't have a deadline.  It's only valid for Open(..)
+// and OpenWith(...) and a timeout <= 0 means no default timeout.
+func WithDefaultWriteTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.WithDefaultWriteTimeout = timeout
+	}
+}
+
+// WithExcludeColumns specifies an option for columns which are not selected by
+// SearchWhere, which is useful for skipping large columns (blobs, json, etc)
+// that aren't needed.  All the other columns of the resource are selected and
+// the excluded columns of the resources returned are left unset.
+func WithExcludeColumns(columns []string) Option {
+	return func(o *Options) {
+		o.WithExcludeColumns = columns
+	}
+}
+
+// WithWindowCount specifies an option for a pointer which receives the total
+// count of rows matching SearchWhere's where clause, regardless of its limit.
+// The count is selected along with the page of results using a "count(*)
+// over()" window function, so it requires a database which supports window
+// functions (postgres, sqlite 3.25+, etc) and it's set to zero when no rows are
+// returned.  It's compatible with WithExcludeColumns.
+func WithWindowCount(count *int64) Option {
+	return func(o *Options) {
+		o.WithWindowCount = count
+	}
+}
+
+// WithNoDatabaseSideEffects specifies an option which hints that the database
+// doesn't modify the resources written by Create and Update (no triggers,
+// computed columns, defaults, etc), so the lookup that refreshes the resource
+// after the write is skipped.  The resource retains its in-memory values along
+// with any values returned by the insert, which makes this a performance
+// option for hot paths where the caller knows the database won't transform
+// the data.  It takes precedence over WithLookup.
+func WithNoDatabaseSideEffects(enable bool) Option {
+	return func(o *Options) {
+		o.WithNoDatabaseSideEffects = enable
+	}
+}
+
+// WithAppendResults specifies an option for SearchWhere to append the
+// resources found to the existing contents of the resources slice, rather than
+// replacing them, which allows the results of several queries to be collected
+// into a single slice.  The existing resources are left unchanged if the
+// search returns an error.
+func WithAppendResults(enable bool) Option {
+	return func(o *Options) {
+		o.WithAppendResults = enable
+	}
+}
+
+// WithReturnOldValues specifies an option for Update to return the column
+// values of the row before it's updated, which is useful for audit logs.  The
+// row is read by its primary key within the same transaction as the update,
+// and a transaction is started when the writer isn't already in one.
+func WithReturnOldValues(oldValues *map[string]interface{}) Option {
+	return func(o *Options) {
+		o.WithReturnOldValues = oldValues
+	}
+}
+
+// WithCreateTimeColumn specifies an option for the name of the create time
+// column, whose value is managed by the database, so it's never written by
+// Update.  It defaults to DefaultCreateTimeColumn and it's valid for Open(..)
+// and OpenWith(...), which sets the column for the DB, and for Update, which
+// overrides the column for that operation.
+func WithCreateTimeColumn(name string) Option {
+	return func(o *Options) {
+		o.WithCreateTimeColumn = name
+	}
+}
+
+// WithUpdateTimeColumn specifies an option for the name of the update time
+// column, whose value is managed by the database (typically via a trigger), so
+// it's never written by Update.  It defaults to DefaultUpdateTimeColumn and
+// it's valid for Open(..) and OpenWith(...), which sets the column for the DB,
+// and for Update, which overrides the column for that operation.
+func WithUpdateTimeColumn(name string) Option {
+	return func(o *Options) {
+		o.WithUpdateTimeColumn = name
+	}
+}
+
+// WithGormClauses specifies an option for gorm clauses which are applied to
+// the statement of SearchWhere and LookupWhere.  It's an escape hatch which
+// provides access to gorm clauses that dbw doesn't wrap (ex: a custom locking
+// strength), so the clauses must be compatible with the operation's
+// statement.  Nil clauses aren't allowed.
+func WithGormClauses(clauses ...clause.Expression) Option {
+	return func(o *Options) {
+		o.WithGormClauses = clauses
+	}
+}
+
+// WithReturningColumns specifies an option for Create and CreateItems which
+// limits the columns returned by the insert (or upsert) to the named columns,
+// so only they're scanned back into the resource, while its other fields
+// retain their submitted values.  The columns must exist in the resource's
+// schema.  By default, the columns with database default values are returned.
+// Note: WithLookup will still refresh the entire resource after the insert.
+func WithReturningColumns(columns []string) Option {
+	return func(o *Options) {
+		o.WithReturningColumns = columns
+	}
+}
+
+// WithReturningResults specifies an option for UpdateWhere to return the
+// updated rows, which are scanned into dst using an "update ... returning *".
+// dst must be a pointer to a slice of pointers to the resource's type and any
+// existing elements are replaced.  Returning the rows from the update avoids
+// a race with writes between the update and a subsequent read.  It's supported
+// by Postgres, CockroachDB and Sqlite 3.35.0 or later.
+func WithReturningResults(dst interface{}) Option {
+	return func(o *Options) {
+		o.WithReturningResults = dst
+	}
+}
+
+// WithReturnInserted specifies an option for CreateItems with a DoNothing
+// conflict action to return the rows which were inserted, which are scanned
+// into dst using an "insert ... on conflict do nothing returning *".  The
+// items which were skipped because they conflicted with an existing row don't
+// return a row, so dst receives just the new rows, without a diff of the items
+// against the table.  dst must be a pointer to a slice of pointers to the
+// items' type and any existing elements are replaced.  It's supported by
+// Postgres, CockroachDB and Sqlite 3.35.0 or later.
+func WithReturnInserted(dst interface{}) Option {
+	return func(o *Options) {
+		o.WithReturnInserted = dst
+	}
+}
+
+// WithSnapshot specifies an option for BeginTx to import the snapshot with the
+// id (see: ExportSnapshot), so the transaction sees the same data as the
+// transaction which exported it.  The transaction's isolation level is set to
+// repeatable read, which is required to import a snapshot.  It's only
+// supported by Postgres.
+func WithSnapshot(id string) Option {
+	return func(o *Options) {
+		o.WithSnapshot = id
+	}
+}
+
+// WithPartitionKey specifies an option for Create and CreateItems to validate
+// that the partition key column of a partitioned table is set on the resources
+// being created, and that it matches the value when it's not nil, before the
+// insert is issued.  A missing or mismatched partition key returns an
+// ErrInvalidParameter, rather than the database's "no partition of relation
+// found for row" error.  WithTable can be used to insert directly into the
+// partition's table.
+func WithPartitionKey(column string, value interface{}) Option {
+	return func(o *Options) {
+		o.WithPartitionKeyColumn = column
+		o.WithPartitionKeyValue = value
+	}
+}
+
+// WithIndexPredicate specifies an option for the predicate of a partial unique
+// index (ex: "deleted_time is null"), so an existence check and an upsert both
+// agree with the uniqueness rule enforced by the index.  For Create,
+// CreateItems and UpsertItems, it's the where clause of the on conflict's
+// Columns target, which is required to infer a partial unique index.  For
+// Exists, it's added to the where clause, so only the rows covered by
+// the index are checked.  The predicate is written into the sql as it is and
+// it can't have parameters, so it must never include untrusted input.
+func WithIndexPredicate(predicate string) Option {
+	return func(o *Options) {
+		o.WithIndexPredicate = predicate
+	}
+}
+
+// WithDistinctOn specifies an option for SearchWhere to select only the first
+// row of each set of rows with the same values for the columns (ex: the latest
+// row per user), using a "distinct on" which is supported by Postgres and
+// CockroachDB.  The columns must exist in the resource's schema and they must
+// match the leading columns of the WithOrder option, which determines the
+// first row of each set.
+func WithDistinctOn(columns []string) Option {
+	return func(o *Options) {
+		o.WithDistinctOn = columns
+	}
+}
+
+// WithTableResolver specifies an option for Open(..) and OpenWith(...) which
+// provides a func to resolve the table name of every operation from its
+// context and the resource's default table name, which allows request scoped
+// routing like a table per tenant.  When the func returns an empty name, the
+// default table name is used.  The WithTable option of an operation takes
+// precedence over the resolver.  Raw sql operations (Exec, Query, etc) are not
+// resolved.
+func WithTableResolver(fn func(ctx context.Context, defaultName string) string) Option {
+	return func(o *Options) {
+		o.WithTableResolver = fn
+	}
+}
+
+// WithCreateBatchSize specifies an option for Open(..) and OpenWith(...) which
+// sets the default batch size for CreateItems, so callers don't need to
+// provide WithBatchSize to every call to stay within the database's limit on
+// query parameters.  The WithBatchSize option of a call, including one set via
+// DB.SetDefaultOptions, takes precedence.  If WithCreateBatchSize == 0, then
+// DefaultBatchSize is used.
+func WithCreateBatchSize(size int) Option {
+	return func(o *Options) {
+		o.WithCreateBatchSize = size
+	}
+}
+
+// WithHealthCheck specifies an option for Open(..) and OpenWith(...) which
+// pings the database at the interval in the background.  When a ping fails,
+// the pool's idle connections are evicted (the pool's max idle connections are
+// set to zero and then restored to the WithMaxIdleConnections option's value
+// or the database/sql default), so the next
+// operation gets a new connection rather than a stale one.  The status of the
+// last health check is returned by DB.HealthStatus() and the health checks
+// stop when the DB is closed.  If WithHealthCheck == 0, then there are no
+// health checks.
+func WithHealthCheck(interval time.Duration) Option {
+	return func(o *Options) {
+		o.WithHealthCheck = interval
+	}
+}
+
+// WithGormPlugin specifies an option for Open(..) and OpenWith(...) which
+// registers a gorm plugin (see: gorm.DB.Use) with the DB after it's opened,
+// so existing gorm plugins (ex: gorm.io/sharding) can be used without forking
+// dbw.  The option may be given more than once and the plugins are registered
+// in the order given.  Plugins operate below dbw's abstraction, so they may
+// interact with dbw's hooks and options in unexpected ways (ex: a plugin which
+// rewrites statements may defeat WithRejectFullScans).
+func WithGormPlugin(plugin gorm.Plugin) Option {
+	return func(o *Options) {
+		o.WithGormPlugins = append(o.WithGormPlugins, plugin)
+	}
+}
+
+// WithSoftDeleteColumn specifies an option for Open(..) and OpenWith(...)
+// which defines a soft delete column of the DB and how it marks a row as
+// deleted, for schemas which don't follow the SoftDeleter convention of a
+// NULL timestamp (ex: an is_deleted boolean).  For every resource which has
+// the column: Delete and DeleteItems mark its rows as deleted by setting the
+// column, rather than deleting them, and reads exclude its deleted rows the
+// same as a SoftDeleter resource.  The option may be given more than once, so
+// a resource may have more than one soft delete column (ex: is_deleted and
+// deleted_at), in which case they're all set by a delete and a row marked as
+// deleted by any of them is excluded by reads.
+func WithSoftDeleteColumn(name string, kind SoftDeleteKind) Option {
+	return func(o *Options) {
+		o.WithSoftDeleteColumns = append(o.WithSoftDeleteColumns, SoftDeleteColumn{Name: name, Kind: kind})
+	}
+}
+
+// WithWriteCallback specifies an option for Open(..) and OpenWith(...) which
+// registers a callback that's invoked before every resource of the type (a
+// struct or a pointer to a struct) is written by Create, CreateItems and
+// Update, which centralizes type-wide invariants (ex: maintaining a column
+// whose value is computed in Go) without implementing gorm hooks.  The
+// callback is invoked with the resource after the WithBeforeWrite option's
+// func, and the write fails when it returns an error.  Update only writes the
+// fields of its field mask, so the fields set by the callback must be
+// included.  The option may be given more than once and the callbacks for a
+// type are invoked in the order they were given.
+func WithWriteCallback(typ reflect.Type, fn func(ctx context.Context, resource interface{}) error) Option {
+	return func(o *Options) {
+		o.WithWriteCallbacks = append(o.WithWriteCallbacks, WriteCallback{Type: typ, Fn: fn})
+	}
+}
+
+// WithAutoInvalidateBadConns specifies an option for Open(..) and
+// OpenWith(...) which retries a statement once when it fails with a bad
+// connection error (see: IsBadConnError), such as Postgres' "server closed the
+// connection unexpectedly".  The broken connection is discarded by the
+// database/sql pool, so the retry uses a fresh connection, which allows
+// long-lived services to recover from dead connections without reconnecting.
+// Beginning a transaction (including the transaction of a write) is retried,
+// but the statements executed within a transaction are never retried, since
+// the transaction is lost with its connection (see: DoTx).  A statement whose
+// connection broke after it was sent may have been executed, so writes should
+// be idempotent when the option is used.
+func WithAutoInvalidateBadConns(enable bool) Option {
+	return func(o *Options) {
+		o.WithAutoInvalidateBadConns = enable
+	}
+}
+
+// WithMaxConcurrentOps specifies an option for Open(..) and OpenWith(...)
+// which limits the number of the DB's statements which are executed
+// concurrently, separately from the pool's connection limit, so load spikes
+// are shed rather than queued inside the driver.  Every statement executed by
+// an operation (including the DB's transactions) acquires a slot before it's
+// executed and releases it afterwards.  When all the slots are in use, the
+// operation fails with ErrTooManyRequests immediately if its context doesn't
+// have a deadline, otherwise it waits for a slot until the deadline (see:
+// WithDefaultReadTimeout and WithDefaultWriteTimeout).  The number of
+// statements executing is returned by DB.InFlightOps().  If
+// WithMaxConcurrentOps == 0, then there's no limit.
+func WithMaxConcurrentOps(max int) Option {
+	return func(o *Options) {
+		o.WithMaxConcurrentOps = max
+	}
+}
+
+// WithJSONNullAsSQLNull specifies an option for NewJSON(...) which writes a JSON
+// with nil data as SQL NULL, rather than a json null literal (the default).
+func WithJSONNullAsSQLNull(enable bool) Option {
+	return func(o *Options) {
+		o.WithJSONNullAsSQLNull = enable
+	}
+}
+
+// WithRowLock specifies an option for LookupBy, LookupByPublicId, LookupWhere
+// and SearchWhere to lock the rows which are read with the strength (ex:
+// ForNoKeyUpdate renders "FOR NO KEY UPDATE"), which is typically used within a
+// transaction before the rows are updated.  Postgres and CockroachDB support
+// every strength, sqlite doesn't support row locks and other dialects only
+// support ForUpdate and ForShare, so an ErrInvalidParameter is returned for an
+// unsupported strength.  The default is NoRowLock.
+func WithRowLock(strength RowLockStrength) Option {
+	return func(o *Options) {
+		o.WithRowLock = strength
+	}
+}
+
+// WithRowLockOf specifies an option for the reads which support WithRowLock
+// to only lock the rows of the tables (ex: "FOR UPDATE OF users"), which
+// reduces lock contention when the read joins several tables.  A table must be
+// referenced by the read's query by its name (without a schema) or, when it's
+// aliased, by its alias: either the resource's table or a table of a
+// clause.From given by WithGormClauses.  An ErrInvalidParameter is returned for
+// an unknown table or when WithRowLock isn't used.
+func WithRowLockOf(tables ...string) Option {
+	return func(o *Options) {
+		o.WithRowLockOf = tables
+	}
+}
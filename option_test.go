@@ -4,10 +4,15 @@
 package dbw
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Test_getOpts provides unit tests for GetOpts and all the options
@@ -134,6 +139,370 @@ func Test_getOpts(t *testing.T) {
 		testOpts.WithLogger = testLogger
 		assert.Equal(opts, testOpts)
 	})
+	t.Run("WithIgnoreConflictOn", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithIgnoreConflictOn("public_id", "name"))
+		testOpts.WithOnConflict = &OnConflict{
+			Target: Columns{"public_id", "name"},
+			Action: DoNothing(true),
+		}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithNoDatabaseSideEffects", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithNoDatabaseSideEffects = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithNoDatabaseSideEffects(true))
+		testOpts.WithNoDatabaseSideEffects = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithReturningColumns", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithReturningColumns = nil
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithReturningColumns([]string{"public_id", "version"}))
+		testOpts.WithReturningColumns = []string{"public_id", "version"}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithReturnInserted", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithReturnInserted = nil
+		assert.Equal(opts, testOpts)
+
+		var inserted []*testLockedAccount
+		opts = GetOpts(WithReturnInserted(&inserted))
+		testOpts.WithReturnInserted = &inserted
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithGormClauses", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithGormClauses = nil
+		assert.Equal(opts, testOpts)
+
+		locking := clause.Locking{Strength: "SHARE"}
+		opts = GetOpts(WithGormClauses(locking))
+		testOpts.WithGormClauses = []clause.Expression{locking}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithCreateTimeColumn", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithCreateTimeColumn = ""
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithCreateTimeColumn("created_at"))
+		testOpts.WithCreateTimeColumn = "created_at"
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithUpdateTimeColumn", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithUpdateTimeColumn = ""
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithUpdateTimeColumn("updated_at"))
+		testOpts.WithUpdateTimeColumn = "updated_at"
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithAppendResults", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithAppendResults = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithAppendResults(true))
+		testOpts.WithAppendResults = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithReturnOldValues", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithReturnOldValues = nil
+		assert.Equal(opts, testOpts)
+
+		var oldValues map[string]interface{}
+		opts = GetOpts(WithReturnOldValues(&oldValues))
+		testOpts.WithReturnOldValues = &oldValues
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithWindowCount", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		var count int64
+		opts = GetOpts(WithWindowCount(&count))
+		testOpts.WithWindowCount = &count
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithOrderBy", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		orderBy := NewOrderBy().Asc("name").NullsLast()
+		opts = GetOpts(WithOrderBy(orderBy))
+		testOpts.WithOrderBy = orderBy
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithDistinctOn", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithDistinctOn([]string{"name"}))
+		testOpts.WithDistinctOn = []string{"name"}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithExcludeColumns", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithExcludeColumns([]string{"email"}))
+		testOpts.WithExcludeColumns = []string{"email"}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithResultTransformer", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		assert.Nil(opts.WithResultTransformer)
+
+		fn := func(interface{}) error { return nil }
+		opts = GetOpts(WithResultTransformer(fn))
+		assert.NotNil(opts.WithResultTransformer)
+	})
+	t.Run("WithRetryableErrorFunc", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		assert.Nil(opts.WithRetryableErrorFunc)
+
+		fn := func(error) bool { return true }
+		opts = GetOpts(WithRetryableErrorFunc(fn))
+		assert.NotNil(opts.WithRetryableErrorFunc)
+	})
+	t.Run("WithUpsert", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithUpsert(UpdateAll(true)))
+		testOpts.WithOnConflict = &OnConflict{Action: UpdateAll(true)}
+		testOpts.WithConflictTargetAutoDetect = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithOnConflictFunc", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		assert.Nil(opts.WithOnConflictFunc)
+
+		opts = GetOpts(WithOnConflictFunc(func(interface{}) *OnConflict { return nil }))
+		assert.NotNil(opts.WithOnConflictFunc)
+	})
+	t.Run("WithConflictOnMultipleTargets", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		assert.Nil(opts.WithConflictTargets)
+
+		opts = GetOpts(WithConflictOnMultipleTargets())
+		assert.NotNil(opts.WithConflictTargets)
+		assert.Empty(opts.WithConflictTargets)
+
+		opts = GetOpts(WithConflictOnMultipleTargets(Columns{"email"}, Columns{"username"}))
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictTargets = []Columns{{"email"}, {"username"}}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithLogSQLArgs", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithLogSQLArgs = true
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithLogSQLArgs(false))
+		testOpts.WithLogSQLArgs = false
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithHealthCheck", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithHealthCheck = 0
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithHealthCheck(time.Minute))
+		testOpts.WithHealthCheck = time.Minute
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithGormPlugin", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithGormPlugins = nil
+		assert.Equal(opts, testOpts)
+
+		p1, p2 := &testGormPlugin{name: "p1"}, &testGormPlugin{name: "p2"}
+		opts = GetOpts(WithGormPlugin(p1), WithGormPlugin(p2))
+		testOpts.WithGormPlugins = []gorm.Plugin{p1, p2}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithSoftDeleteColumn", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithSoftDeleteColumns = nil
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithSoftDeleteColumn("is_deleted", BooleanFlag), WithSoftDeleteColumn("deleted_at", TimestampNull))
+		testOpts.WithSoftDeleteColumns = []SoftDeleteColumn{{Name: "is_deleted", Kind: BooleanFlag}, {Name: "deleted_at", Kind: TimestampNull}}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithMaxConcurrentOps", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithMaxConcurrentOps = 0
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithMaxConcurrentOps(10))
+		testOpts.WithMaxConcurrentOps = 10
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithRowLock", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithRowLock = NoRowLock
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithRowLock(ForNoKeyUpdate))
+		testOpts.WithRowLock = ForNoKeyUpdate
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithRowLockOf", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		assert.Nil(opts.WithRowLockOf)
+
+		opts = GetOpts(WithRowLockOf("users", "u"))
+		testOpts := getDefaultOptions()
+		testOpts.WithRowLockOf = []string{"users", "u"}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithJSONNullAsSQLNull", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithJSONNullAsSQLNull = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithJSONNullAsSQLNull(true))
+		testOpts.WithJSONNullAsSQLNull = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithCreateBatchSize", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithCreateBatchSize(100))
+		testOpts.WithCreateBatchSize = 100
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithTableResolver", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		assert.Nil(opts.WithTableResolver)
+
+		fn := func(_ context.Context, defaultName string) string { return defaultName }
+		opts = GetOpts(WithTableResolver(fn))
+		assert.NotNil(opts.WithTableResolver)
+	})
+	t.Run("WithDefaultReadTimeout", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithDefaultReadTimeout(time.Second))
+		testOpts.WithDefaultReadTimeout = time.Second
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithDefaultWriteTimeout", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithDefaultWriteTimeout(time.Second))
+		testOpts.WithDefaultWriteTimeout = time.Second
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithContextLogFields", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		assert.Nil(opts.WithContextLogFields)
+
+		fn := func(context.Context) []interface{} { return nil }
+		opts = GetOpts(WithContextLogFields(fn))
+		assert.NotNil(opts.WithContextLogFields)
+	})
 	t.Run("WithMaxOpenConnections", func(t *testing.T) {
 		assert := assert.New(t)
 		// test default of false
@@ -144,6 +513,36 @@ func Test_getOpts(t *testing.T) {
 		testOpts.WithMaxOpenConnections = 22
 		assert.Equal(opts, testOpts)
 	})
+	t.Run("WithMaxIdleConnections", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of 0
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+		opts = GetOpts(WithMaxIdleConnections(5))
+		testOpts.WithMaxIdleConnections = 5
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConnMaxLifetime", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of 0
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+		opts = GetOpts(WithConnMaxLifetime(time.Hour))
+		testOpts.WithConnMaxLifetime = time.Hour
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConnMaxIdleTime", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of 0
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		assert.Equal(opts, testOpts)
+		opts = GetOpts(WithConnMaxIdleTime(time.Minute))
+		testOpts.WithConnMaxIdleTime = time.Minute
+		assert.Equal(opts, testOpts)
+	})
 	t.Run("WithDebug", func(t *testing.T) {
 		assert := assert.New(t)
 		// test default of false
@@ -193,6 +592,31 @@ func Test_getOpts(t *testing.T) {
 		opts = GetOpts(WithBeforeWrite(fn))
 		assert.NotNil(opts.WithBeforeWrite)
 	})
+	t.Run("WithWriteCallback", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		assert.Nil(opts.WithWriteCallbacks)
+
+		fn := func(context.Context, interface{}) error { return nil }
+		opts = GetOpts(WithWriteCallback(reflect.TypeOf(&testLockedAccount{}), fn), WithWriteCallback(reflect.TypeOf(testLockedAccount{}), fn))
+		assert.Len(opts.WithWriteCallbacks, 2)
+		assert.Equal(reflect.TypeOf(&testLockedAccount{}), opts.WithWriteCallbacks[0].Type)
+		assert.Equal(reflect.TypeOf(testLockedAccount{}), opts.WithWriteCallbacks[1].Type)
+		assert.NotNil(opts.WithWriteCallbacks[0].Fn)
+	})
+	t.Run("WithAutoInvalidateBadConns", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithAutoInvalidateBadConns = false
+		assert.Equal(opts, testOpts)
+		opts = GetOpts(WithAutoInvalidateBadConns(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithAutoInvalidateBadConns = true
+		assert.Equal(opts, testOpts)
+	})
 	t.Run("WithAfterWrite", func(t *testing.T) {
 		assert := assert.New(t)
 		// test defaults
@@ -266,4 +690,191 @@ func Test_getOpts(t *testing.T) {
 		testOpts.WithBatchSize = 100
 		assert.Equal(opts, testOpts)
 	})
+	t.Run("WithConflictOverride", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictOverride = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithConflictOverride(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithConflictOverride = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConflictUpdateColumnsFromFieldMask", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of nil
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictUpdateColumnsFromFieldMask = nil
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithConflictUpdateColumnsFromFieldMask([]string{"Name", "Email"}))
+		testOpts = getDefaultOptions()
+		testOpts.WithConflictUpdateColumnsFromFieldMask = []string{"Name", "Email"}
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConflictUpdateTimestamp", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictUpdateTimestamp = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithConflictUpdateTimestamp(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithConflictUpdateTimestamp = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConflictVersionCheck", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictVersionCheck = nil
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithConflictVersionCheck(2))
+		testOpts = getDefaultOptions()
+		version := uint32(2)
+		testOpts.WithConflictVersionCheck = &version
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithReturningResults", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of nil
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithReturningResults = nil
+		assert.Equal(opts, testOpts)
+
+		var results []*struct{}
+		opts = GetOpts(WithReturningResults(&results))
+		testOpts = getDefaultOptions()
+		testOpts.WithReturningResults = &results
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithSnapshot", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of ""
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithSnapshot = ""
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithSnapshot("00000003-0000001B-1"))
+		testOpts = getDefaultOptions()
+		testOpts.WithSnapshot = "00000003-0000001B-1"
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithPartitionKey", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of "" and nil
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithPartitionKeyColumn = ""
+		testOpts.WithPartitionKeyValue = nil
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithPartitionKey("tenant_id", "t_1"))
+		testOpts = getDefaultOptions()
+		testOpts.WithPartitionKeyColumn = "tenant_id"
+		testOpts.WithPartitionKeyValue = "t_1"
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithIndexPredicate", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of ""
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithIndexPredicate = ""
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithIndexPredicate("deleted_time is null"))
+		testOpts = getDefaultOptions()
+		testOpts.WithIndexPredicate = "deleted_time is null"
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConflictConstraintOut", func(t *testing.T) {
+		assert := assert.New(t)
+		// test defaults
+		opts := getDefaultOptions()
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictConstraint = nil
+		assert.Equal(opts, testOpts)
+
+		var name string
+		opts = GetOpts(WithConflictConstraintOut(&name))
+		testOpts.WithConflictConstraint = &name
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithRejectFullScans", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithRejectFullScans = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithRejectFullScans(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithRejectFullScans = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithAllowFullScan", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithAllowFullScan = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithAllowFullScan(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithAllowFullScan = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithDeleted", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithDeleted = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithDeleted(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithDeleted = true
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithDryRun", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of nil
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithDryRun = nil
+		assert.Equal(opts, testOpts)
+
+		var sql string
+		opts = GetOpts(WithDryRun(&sql))
+		testOpts = getDefaultOptions()
+		testOpts.WithDryRun = &sql
+		assert.Equal(opts, testOpts)
+	})
+	t.Run("WithConflictDebug", func(t *testing.T) {
+		assert := assert.New(t)
+		// test default of false
+		opts := GetOpts()
+		testOpts := getDefaultOptions()
+		testOpts.WithConflictDebug = false
+		assert.Equal(opts, testOpts)
+
+		opts = GetOpts(WithConflictDebug(true))
+		testOpts = getDefaultOptions()
+		testOpts.WithConflictDebug = true
+		assert.Equal(opts, testOpts)
+	})
 }
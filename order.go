@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// nullsOrder specifies where NULLs are placed when ordering by a column.
+type nullsOrder int
+
+const (
+	nullsDefault nullsOrder = iota
+	nullsFirst
+	nullsLast
+)
+
+// SortDirection specifies the direction of a column of an Order.
+type SortDirection int
+
+const (
+	// Ascending orders a column in ascending order.
+	Ascending SortDirection = iota
+
+	// Descending orders a column in descending order.
+	Descending
+)
+
+// Order is a column and its direction, which may be provided to NewOrderBy.
+type Order struct {
+	// Column is the name of the column, which may be qualified by its table
+	// (ex: users.name).
+	Column string
+
+	// Direction is the direction of the column.
+	Direction SortDirection
+}
+
+type orderByColumn struct {
+	name  string
+	desc  bool
+	nulls nullsOrder
+}
+
+// OrderBy is a builder for the order of a search, whose columns are validated
+// before they're written into sql.  It's rendered for the dialect of the
+// search, so the placement of NULLs is consistent across dialects.  Use
+// WithOrderBy(...) to order a search.
+type OrderBy struct {
+	columns []orderByColumn
+	err     error
+}
+
+// NewOrderBy creates a new OrderBy with the orders, in the order they're
+// provided (ex: NewOrderBy(Order{"create_time", Descending}, Order{"public_id",
+// Ascending})).  More columns may be added using Asc and Desc.
+func NewOrderBy(orders ...Order) *OrderBy {
+	const op = "dbw.NewOrderBy"
+	o := &OrderBy{}
+	for _, order := range orders {
+		switch order.Direction {
+		case Ascending, Descending:
+		default:
+			if o.err == nil {
+				o.err = fmt.Errorf("%s: invalid sort direction %d for column %q: %w", op, int(order.Direction), order.Column, ErrInvalidParameter)
+			}
+		}
+		o.add(order.Column, order.Direction == Descending)
+	}
+	return o
+}
+
+// Asc adds a column in ascending order.  The column may be qualified by its
+// table (ex: users.name).
+func (o *OrderBy) Asc(column string) *OrderBy {
+	return o.add(column, false)
+}
+
+// Desc adds a column in descending order.  The column may be qualified by its
+// table (ex: users.name).
+func (o *OrderBy) Desc(column string) *OrderBy {
+	return o.add(column, true)
+}
+
+// NullsFirst places the NULLs of the last column added before its non NULL
+// values.
+func (o *OrderBy) NullsFirst() *OrderBy {
+	return o.setNulls(nullsFirst)
+}
+
+// NullsLast places the NULLs of the last column added after its non NULL
+// values.
+func (o *OrderBy) NullsLast() *OrderBy {
+	return o.setNulls(nullsLast)
+}
+
+func (o *OrderBy) add(column string, desc bool) *OrderBy {
+	const op = "dbw.(OrderBy).add"
+	if o.err == nil {
+		for _, name := range strings.Split(column, ".") {
+			if !identifierRegexp.MatchString(name) {
+				o.err = fmt.Errorf("%s: invalid order by column %q: %w", op, column, ErrInvalidParameter)
+				return o
+			}
+		}
+	}
+	o.columns = append(o.columns, orderByColumn{name: column, desc: desc})
+	return o
+}
+
+func (o *OrderBy) setNulls(nulls nullsOrder) *OrderBy {
+	const op = "dbw.(OrderBy).setNulls"
+	if len(o.columns) == 0 {
+		if o.err == nil {
+			o.err = fmt.Errorf("%s: nulls order requires a column: %w", op, ErrInvalidParameter)
+		}
+		return o
+	}
+	o.columns[len(o.columns)-1].nulls = nulls
+	return o
+}
+
+// render returns the order by clause for the db type.  Postgres and
+// CockroachDB support "nulls first" and "nulls last", while the placement of
+// NULLs is emulated with a leading "case when column is null" expression for
+// other db types (ex: older versions of sqlite don't support the syntax).
+func (o *OrderBy) render(dbType DbType) (string, error) {
+	const op = "dbw.(OrderBy).render"
+	switch {
+	case o.err != nil:
+		return "", fmt.Errorf("%s: %w", op, o.err)
+	case len(o.columns) == 0:
+		return "", fmt.Errorf("%s: missing order by columns: %w", op, ErrInvalidParameter)
+	}
+	nativeNulls := dbType == Postgres || dbType == CockroachDB
+	items := make([]string, 0, len(o.columns))
+	for _, c := range o.columns {
+		direction := "asc"
+		if c.desc {
+			direction = "desc"
+		}
+		switch {
+		case c.nulls == nullsDefault:
+			items = append(items, c.name+" "+direction)
+		case nativeNulls && c.nulls == nullsFirst:
+			items = append(items, c.name+" "+direction+" nulls first")
+		case nativeNulls:
+			items = append(items, c.name+" "+direction+" nulls last")
+		case c.nulls == nullsFirst:
+			items = append(items, fmt.Sprintf("case when %s is null then 0 else 1 end, %s %s", c.name, c.name, direction))
+		default:
+			items = append(items, fmt.Sprintf("case when %s is null then 1 else 0 end, %s %s", c.name, c.name, direction))
+		}
+	}
+	return strings.Join(items, ", "), nil
+}
+
+// validateColumns returns an ErrInvalidParameter when a column isn't a column
+// of the schema.  Columns which are qualified by a table other than the
+// schema's table (ex: a joined table) aren't validated.
+func (o *OrderBy) validateColumns(s *schema.Schema, tableName string) error {
+	const op = "dbw.(OrderBy).validateColumns"
+	for _, c := range o.columns {
+		name := c.name
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			if table := name[:i]; table != tableName && table != s.Table {
+				continue
+			}
+			name = name[i+1:]
+		}
+		if _, ok := s.FieldsByDBName[name]; !ok {
+			return fmt.Errorf("%s: order by column %q is not a column of %s: %w", op, c.name, tableName, ErrInvalidParameter)
+		}
+	}
+	return nil
+}
+
+// orderFromOpts returns the order by clause of the opts, which is rendered
+// from the WithOrderBy option for the RW's db type, when it's set.  The
+// columns of the WithOrderBy option are validated against the schema of the
+// resources.
+func (rw *RW) orderFromOpts(resources interface{}, opts Options) (string, error) {
+	const op = "dbw.orderFromOpts"
+	if opts.WithOrderBy == nil {
+		return opts.WithOrder, nil
+	}
+	if opts.WithOrder != "" {
+		return "", fmt.Errorf("%s: both order and order by options are set: %w", op, ErrInvalidParameter)
+	}
+	if opts.WithOrderBy.err == nil && len(opts.WithOrderBy.columns) > 0 {
+		s, tableName, err := rw.parseSchema(resources, opts)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		if err := opts.WithOrderBy.validateColumns(s, tableName); err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	orderBy, err := opts.WithOrderBy.render(dbType)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return orderBy, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDb_SearchWhere_OrderBy(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+
+	// cars with a NULL model are interleaved with the others
+	for _, tc := range []struct {
+		model string
+		mpg   int32
+	}{
+		{"b", 10},
+		{"", 20},
+		{"a", 30},
+		{"", 40},
+		{"c", 50},
+	} {
+		c, err := dbtest.NewTestCar()
+		require.NoError(t, err)
+		c.Model = tc.model
+		c.Mpg = tc.mpg
+		require.NoError(t, testRw.Create(testCtx, c))
+	}
+	models := func(cars []*dbtest.TestCar) []string {
+		m := make([]string, 0, len(cars))
+		for _, c := range cars {
+			m = append(m, c.Model)
+		}
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		orderBy *dbw.OrderBy
+		want    []string
+		wantMpg []int32
+	}{
+		{
+			name:    "asc-nulls-first",
+			orderBy: dbw.NewOrderBy().Asc("model").NullsFirst().Asc("mpg"),
+			want:    []string{"", "", "a", "b", "c"},
+			wantMpg: []int32{20, 40, 30, 10, 50},
+		},
+		{
+			name:    "asc-nulls-last",
+			orderBy: dbw.NewOrderBy().Asc("model").NullsLast().Asc("mpg"),
+			want:    []string{"a", "b", "c", "", ""},
+			wantMpg: []int32{30, 10, 50, 20, 40},
+		},
+		{
+			name:    "desc-nulls-first",
+			orderBy: dbw.NewOrderBy().Desc("model").NullsFirst().Desc("mpg"),
+			want:    []string{"", "", "c", "b", "a"},
+			wantMpg: []int32{40, 20, 50, 10, 30},
+		},
+		{
+			name:    "desc-nulls-last",
+			orderBy: dbw.NewOrderBy().Desc("db_test_car.model").NullsLast().Desc("mpg"),
+			want:    []string{"c", "b", "a", "", ""},
+			wantMpg: []int32{50, 10, 30, 40, 20},
+		},
+		{
+			name:    "orders",
+			orderBy: dbw.NewOrderBy(dbw.Order{Column: "model", Direction: dbw.Descending}, dbw.Order{Column: "db_test_car.mpg", Direction: dbw.Ascending}).NullsFirst(),
+			want:    []string{"c", "b", "a", "", ""},
+			wantMpg: []int32{50, 10, 30, 20, 40},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var cars []*dbtest.TestCar
+			err := testRw.SearchWhere(testCtx, &cars, "", nil, dbw.WithOrderBy(tt.orderBy), dbw.WithLimit(-1))
+			require.NoError(err)
+			assert.Equal(tt.want, models(cars))
+			mpgs := make([]int32, 0, len(cars))
+			for _, c := range cars {
+				mpgs = append(mpgs, c.Mpg)
+			}
+			assert.Equal(tt.wantMpg, mpgs)
+		})
+	}
+	t.Run("invalid-parameters", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"invalid-column", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy().Asc("model; drop table db_test_car"))}, "invalid order by column"},
+			{"nulls-without-column", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy().NullsLast())}, "nulls order requires a column"},
+			{"missing-columns", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy())}, "missing order by columns"},
+			{"unknown-column", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy(dbw.Order{Column: "create_time", Direction: dbw.Descending}, dbw.Order{Column: "color", Direction: dbw.Ascending}))}, `order by column "color" is not a column of db_test_car`},
+			{"unknown-qualified-column", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy().Asc("db_test_car.color"))}, `order by column "db_test_car.color" is not a column of db_test_car`},
+			{"invalid-direction", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy(dbw.Order{Column: "model", Direction: dbw.SortDirection(2)}))}, "invalid sort direction 2"},
+			{"with-order", []dbw.Option{dbw.WithOrderBy(dbw.NewOrderBy().Asc("model")), dbw.WithOrder("mpg")}, "both order and order by options are set"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var cars []*dbtest.TestCar
+				err := testRw.SearchWhere(testCtx, &cars, "", nil, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderBy_render(t *testing.T) {
+	t.Parallel()
+	orderBy := NewOrderBy().Asc("name").Desc("users.create_time").NullsLast().Asc("email").NullsFirst()
+	tests := []struct {
+		name   string
+		dbType DbType
+		want   string
+	}{
+		{
+			name:   "postgres",
+			dbType: Postgres,
+			want:   "name asc, users.create_time desc nulls last, email asc nulls first",
+		},
+		{
+			name:   "cockroachdb",
+			dbType: CockroachDB,
+			want:   "name asc, users.create_time desc nulls last, email asc nulls first",
+		},
+		{
+			name:   "sqlite",
+			dbType: Sqlite,
+			want: "name asc, " +
+				"case when users.create_time is null then 1 else 0 end, users.create_time desc, " +
+				"case when email is null then 0 else 1 end, email asc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderBy.render(tt.dbType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
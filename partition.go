@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// validatePartitionKey returns an ErrInvalidParameter when the resource's
+// partition key column (see: WithPartitionKey) isn't set or doesn't match the
+// partition key value.  It's a no-op without a WithPartitionKey option.
+func (rw *RW) validatePartitionKey(ctx context.Context, i interface{}, opts Options) error {
+	const op = "dbw.validatePartitionKey"
+	if opts.WithPartitionKeyColumn == "" {
+		return nil
+	}
+	s, _, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	f := s.LookUpField(opts.WithPartitionKeyColumn)
+	if f == nil || f.DBName == "" {
+		return fmt.Errorf("%s: partition key column %s not found in resource: %w", op, opts.WithPartitionKeyColumn, ErrInvalidParameter)
+	}
+	v, isZero := f.ValueOf(ctx, reflect.ValueOf(i))
+	if isZero {
+		return fmt.Errorf("%s: partition key column %s is not set: %w", op, f.DBName, ErrInvalidParameter)
+	}
+	if opts.WithPartitionKeyValue != nil && !partitionKeyEqual(v, opts.WithPartitionKeyValue) {
+		return fmt.Errorf("%s: partition key column %s value %v does not match %v: %w", op, f.DBName, v, opts.WithPartitionKeyValue, ErrInvalidParameter)
+	}
+	return nil
+}
+
+// partitionKeyEqual returns whether the partition key value of a resource is
+// equal to the expected value.  Pointers are dereferenced, times are compared
+// using time.Equal and the expected value is converted to the type of the
+// resource's value when it's convertible (ex: an int and an int64).
+func partitionKeyEqual(got, want interface{}) bool {
+	gv, wv := reflect.ValueOf(got), reflect.ValueOf(want)
+	for gv.Kind() == reflect.Ptr && !gv.IsNil() {
+		gv = gv.Elem()
+	}
+	for wv.Kind() == reflect.Ptr && !wv.IsNil() {
+		wv = wv.Elem()
+	}
+	if gt, ok := gv.Interface().(time.Time); ok {
+		wt, ok := wv.Interface().(time.Time)
+		return ok && gt.Equal(wt)
+	}
+	if wv.Type() != gv.Type() {
+		// an int is convertible to a string, but they're never equal
+		isString := gv.Kind() == reflect.String
+		if !wv.Type().ConvertibleTo(gv.Type()) || isString != (wv.Kind() == reflect.String) {
+			return false
+		}
+		wv = wv.Convert(gv.Type())
+	}
+	return reflect.DeepEqual(gv.Interface(), wv.Interface())
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
 )
 
 // Query will run the raw query and return the *sql.Rows results. Query will
@@ -21,7 +22,7 @@ func (rw *RW) Query(ctx context.Context, sql string, values []interface{}, opt .
 	if sql == "" {
 		return nil, fmt.Errorf("%s: missing sql: %w", op, ErrInvalidParameter)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
 	db := rw.underlying.wrapped.WithContext(ctx)
 	if opts.WithDebug {
 		db = db.Debug()
@@ -33,6 +34,39 @@ func (rw *RW) Query(ctx context.Context, sql string, values []interface{}, opt .
 	return db.Rows()
 }
 
+// QueryRow will run the raw query and scan its first row into dst, which must
+// be a pointer to a struct, and the rows are closed before it returns.
+// ErrRecordNotFound is returned when the query doesn't return any rows.
+// QueryRow will operate within the context of any ongoing transaction for the
+// Reader.  The WithDebug option is supported.
+func (rw *RW) QueryRow(ctx context.Context, dst interface{}, sql string, values []interface{}, opt ...Option) error {
+	const op = "dbw.QueryRow"
+	if isNil(dst) {
+		return fmt.Errorf("%s: missing dst: %w", op, ErrInvalidParameter)
+	}
+	if typ := reflect.TypeOf(dst); typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: dst must be a pointer to a struct: %w", op, ErrInvalidParameter)
+	}
+	rows, err := rw.Query(ctx, sql, values, opt...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return fmt.Errorf("%s: %w", op, ErrRecordNotFound)
+	}
+	if err := rw.ScanRows(rows, dst); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
 // ScanRows will scan the rows into the interface
 func (rw *RW) ScanRows(rows *sql.Rows, result interface{}) error {
 	const op = "dbw.ScanRows"
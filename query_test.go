@@ -12,6 +12,7 @@ import (
 	"github.com/hashicorp/go-dbw/internal/dbtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestDb_Query(t *testing.T) {
@@ -68,6 +69,75 @@ func TestDb_Query(t *testing.T) {
 	})
 }
 
+func TestDb_QueryRow(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	rw := dbw.New(conn)
+	for _, name := range []string{"alice", "bob"} {
+		u, err := dbtest.NewTestUser()
+		require.NoError(t, err)
+		u.Name = name
+		require.NoError(t, rw.Create(testCtx, u))
+	}
+	t.Run("valid", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found dbtest.TestUser
+		err := rw.QueryRow(testCtx, &found, "select * from db_test_user where name = ?", []interface{}{"bob"}, dbw.WithDebug(true))
+		require.NoError(err)
+		assert.Equal("bob", found.Name)
+		assert.NotEmpty(found.PublicId)
+	})
+	t.Run("first-row", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found struct {
+			Name  string
+			Total int
+		}
+		err := rw.QueryRow(testCtx, &found, "select name, count(*) over () as total from db_test_user order by name", nil)
+		require.NoError(err)
+		assert.Equal("alice", found.Name)
+		assert.Equal(2, found.Total)
+	})
+	t.Run("not-found", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found dbtest.TestUser
+		err := rw.QueryRow(testCtx, &found, "select * from db_test_user where name = ?", []interface{}{"carol"})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		var found dbtest.TestUser
+		var name string
+		tests := []struct {
+			name            string
+			dst             interface{}
+			sql             string
+			wantErrContains string
+		}{
+			{"missing-dst", nil, "select * from db_test_user", "missing dst"},
+			{"not-a-struct", &name, "select name from db_test_user", "dst must be a pointer to a struct"},
+			{"not-a-pointer", found, "select * from db_test_user", "dst must be a pointer to a struct"},
+			{"missing-sql", &found, "", "missing sql"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := rw.QueryRow(testCtx, tt.dst, tt.sql, nil)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+	t.Run("bad-sql", func(t *testing.T) {
+		require := require.New(t)
+		var found dbtest.TestUser
+		err := rw.QueryRow(testCtx, &found, "from", nil)
+		require.Error(err)
+	})
+}
+
 func TestDb_ScanRows(t *testing.T) {
 	t.Parallel()
 	testCtx := context.Background()
@@ -92,6 +162,48 @@ func TestDb_ScanRows(t *testing.T) {
 			assert.Equal(user.PublicId, u.PublicId)
 		}
 	})
+	t.Run("proto-message", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		user, err := dbtest.NewTestUser()
+		require.NoError(err)
+		user.Name = "proto-" + user.PublicId
+		err = rw.Create(testCtx, user, dbw.WithLookup(true))
+		require.NoError(err)
+		require.NotNil(user.CreateTime)
+		require.NotNil(user.UpdateTime)
+
+		rows, err := rw.Query(testCtx, "select * from db_test_user where public_id = ?", []interface{}{user.PublicId})
+		require.NoError(err)
+		var found []*dbtest.StoreTestUser
+		for rows.Next() {
+			// scan directly into the proto message (not the TestUser wrapper)
+			u := &dbtest.StoreTestUser{}
+			require.NoError(rw.ScanRows(rows, u))
+			found = append(found, u)
+		}
+		require.NoError(rows.Err())
+		require.NoError(rows.Close())
+		require.Len(found, 1)
+		assert.True(proto.Equal(user.StoreTestUser, found[0]))
+		assert.True(user.CreateTime.AsTime().Equal(found[0].CreateTime.AsTime()))
+		assert.True(user.UpdateTime.AsTime().Equal(found[0].UpdateTime.AsTime()))
+
+		// nullable nested messages are left nil
+		rows, err = rw.Query(testCtx, "select public_id, null as create_time from db_test_user where public_id = ?", []interface{}{user.PublicId})
+		require.NoError(err)
+		require.True(rows.Next())
+		u := &dbtest.StoreTestUser{}
+		require.NoError(rw.ScanRows(rows, u))
+		require.NoError(rows.Close())
+		assert.Equal(user.PublicId, u.PublicId)
+		assert.Nil(u.CreateTime)
+
+		var searched []*dbtest.StoreTestUser
+		err = rw.SearchWhere(testCtx, &searched, "public_id = ?", []interface{}{user.PublicId}, dbw.WithTable(user.TableName()))
+		require.NoError(err)
+		require.Len(searched, 1)
+		assert.True(proto.Equal(user.StoreTestUser, searched[0]))
+	})
 	t.Run("missing-underlying-db", func(t *testing.T) {
 		assert, require := assert.New(t), require.New(t)
 		rw := dbw.RW{}
@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// zeroDbMu serializes the allocation of the shared state of a DB which
+// wasn't opened by Open(...) or OpenWith(...) (ex: a zero value DB).
+var zeroDbMu sync.Mutex
+
+// SetReadOnly turns the DB's read-only mode on or off at runtime (ex: to drain
+// writes during a maintenance window or before a failover, without restarting
+// the service or reconfiguring the pool).  While it's on, the write
+// operations (Create, CreateItems, Update, UpdateWhere, Save, Clone,
+// UpsertItems, Delete, DeleteItems, DeleteByPublicIds, PurgeWhere, Exec,
+// BatchExec, ResetSequence, WithTriggersDisabled and a RunNamed without a dst)
+// fail with ErrReadOnly before they're executed, and reads are unaffected.
+// The mode is shared by the RWs created from the DB and their transactions,
+// so a transaction which is in progress when it's turned on can still be
+// committed or rolled back, but its subsequent writes fail.  Raw sql executed
+// by Query isn't checked, since it's a read operation.
+func (db *DB) SetReadOnly(on bool) {
+	if db.readOnly == nil {
+		zeroDbMu.Lock()
+		if db.readOnly == nil {
+			db.readOnly = &atomic.Bool{}
+		}
+		zeroDbMu.Unlock()
+	}
+	db.readOnly.Store(on)
+}
+
+// ReadOnly returns true when the DB's read-only mode is on (see: SetReadOnly).
+func (db *DB) ReadOnly() bool {
+	return db.readOnly != nil && db.readOnly.Load()
+}
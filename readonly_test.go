@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_SetReadOnly(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+
+	t.Run("toggle", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		rw := dbw.New(conn)
+		assert.False(conn.ReadOnly())
+		user := testUser(t, rw, "read-only-alice", "", "")
+
+		conn.SetReadOnly(true)
+		assert.True(conn.ReadOnly())
+		newUser, err := dbtest.NewTestUser()
+		require.NoError(err)
+		err = rw.Create(testCtx, newUser)
+		assert.ErrorIs(err, dbw.ErrReadOnly)
+		assert.Contains(err.Error(), "dbw.Create: read only")
+		err = rw.LookupBy(testCtx, newUser)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		// reads and RWs created after the toggle are unaffected by it
+		found := &dbtest.TestUser{StoreTestUser: &dbtest.StoreTestUser{PublicId: user.PublicId}}
+		require.NoError(dbw.New(conn).LookupBy(testCtx, found))
+		assert.Equal("read-only-alice", found.Name)
+		_, err = dbw.New(conn).Exec(testCtx, "delete from db_test_user", nil)
+		assert.ErrorIs(err, dbw.ErrReadOnly)
+
+		conn.SetReadOnly(false)
+		assert.False(conn.ReadOnly())
+		require.NoError(rw.Create(testCtx, newUser))
+	})
+	t.Run("write-operations", func(t *testing.T) {
+		conn, _ := dbw.TestSetup(t)
+		require.NoError(t, conn.RegisterQuery("read-only-set-email", "update db_test_user set email = ? where public_id = ?"))
+		rw := dbw.New(conn)
+		user := testUser(t, rw, "read-only-bob", "bob@example.com", "")
+		conn.SetReadOnly(true)
+		t.Cleanup(func() { conn.SetReadOnly(false) })
+
+		newUser := func() *dbtest.TestUser {
+			u, err := dbtest.NewTestUser()
+			require.NoError(t, err)
+			return u
+		}
+		tests := []struct {
+			name string
+			fn   func() error
+		}{
+			{"Create", func() error { return rw.Create(testCtx, newUser()) }},
+			{"CreateItems", func() error { return rw.CreateItems(testCtx, []*dbtest.TestUser{newUser()}) }},
+			{"Update", func() error {
+				u := user.Clone().(*dbtest.TestUser)
+				u.Name = "updated"
+				_, err := rw.Update(testCtx, u, []string{"Name"}, nil)
+				return err
+			}},
+			{"UpdateWhere", func() error {
+				_, err := rw.UpdateWhere(testCtx, &dbtest.TestUser{}, map[string]interface{}{"name": "updated"}, "public_id = ?", []interface{}{user.PublicId})
+				return err
+			}},
+			{"Save", func() error { _, err := rw.Save(testCtx, newUser()); return err }},
+			{"Clone", func() error { _, err := rw.Clone(testCtx, user, "u_clone"); return err }},
+			{"UpsertItems", func() error {
+				_, err := rw.UpsertItems(testCtx, []interface{}{newUser()}, dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.DoNothing(true)})
+				return err
+			}},
+			{"Delete", func() error { _, err := rw.Delete(testCtx, user); return err }},
+			{"DeleteItems", func() error { _, err := rw.DeleteItems(testCtx, []*dbtest.TestUser{user}); return err }},
+			{"DeleteByPublicIds", func() error {
+				_, err := rw.DeleteByPublicIds(testCtx, &dbtest.TestUser{}, []string{user.PublicId})
+				return err
+			}},
+			{"PurgeWhere", func() error {
+				_, err := rw.PurgeWhere(testCtx, &dbtest.TestUser{}, "public_id = ?", []interface{}{user.PublicId}, 10, nil)
+				return err
+			}},
+			{"Exec", func() error { _, err := rw.Exec(testCtx, "delete from db_test_user", nil); return err }},
+			{"BatchExec", func() error {
+				_, err := rw.BatchExec(testCtx, "delete from db_test_user where public_id = ?", [][]interface{}{{user.PublicId}})
+				return err
+			}},
+			{"ResetSequence", func() error { return rw.ResetSequence(testCtx, "db_test_user", "id") }},
+			{"WithTriggersDisabled", func() error {
+				return rw.WithTriggersDisabled(testCtx, "db_test_user", func() error { return nil })
+			}},
+			{"RunNamed", func() error {
+				return rw.RunNamed(testCtx, "read-only-set-email", []interface{}{"updated@example.com", user.PublicId}, nil)
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.ErrorIs(t, tt.fn(), dbw.ErrReadOnly)
+			})
+		}
+		found := &dbtest.TestUser{StoreTestUser: &dbtest.StoreTestUser{PublicId: user.PublicId}}
+		require.NoError(t, rw.LookupBy(testCtx, found))
+		assert.Equal(t, "read-only-bob", found.Name)
+		assert.Equal(t, "bob@example.com", found.Email)
+	})
+	t.Run("transaction-in-progress", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		rw := dbw.New(conn)
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		before, err := dbtest.NewTestUser()
+		require.NoError(err)
+		require.NoError(tx.Create(testCtx, before))
+
+		conn.SetReadOnly(true)
+		after, err := dbtest.NewTestUser()
+		require.NoError(err)
+		assert.ErrorIs(tx.Create(testCtx, after), dbw.ErrReadOnly)
+		// the writes made before the toggle are drained by the commit
+		require.NoError(tx.Commit(testCtx))
+		require.NoError(rw.LookupBy(testCtx, before))
+
+		_, err = rw.DoTx(testCtx, func(error) bool { return false }, 0, dbw.ConstBackoff{}, func(_ dbw.Reader, w dbw.Writer) error {
+			return w.Create(testCtx, after)
+		})
+		assert.ErrorIs(err, dbw.ErrReadOnly)
+		conn.SetReadOnly(false)
+	})
+	t.Run("concurrent-toggle", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		// each connection of an in-memory sqlite database has its own
+		// database, so the concurrent writes must share one connection
+		sqlDB, err := conn.SqlDB(testCtx)
+		require.NoError(err)
+		sqlDB.SetMaxOpenConns(1)
+		rw := dbw.New(conn)
+		const writes = 20
+		errs := make(chan error, writes)
+		var wg sync.WaitGroup
+		for i := 0; i < writes; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if i%5 == 0 {
+					conn.SetReadOnly(i%10 == 0)
+				}
+				u, err := dbtest.NewTestUser()
+				if err != nil {
+					errs <- err
+					return
+				}
+				errs <- rw.Create(testCtx, u)
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				assert.ErrorIs(err, dbw.ErrReadOnly)
+			}
+		}
+	})
+	t.Run("zero-db", func(t *testing.T) {
+		assert := assert.New(t)
+		db := &dbw.DB{}
+		assert.False(db.ReadOnly())
+		db.SetReadOnly(true)
+		assert.True(db.ReadOnly())
+	})
+}
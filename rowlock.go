@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// RowLockStrength specifies the strength of the locks on the rows of a read
+// (see: WithRowLock).
+type RowLockStrength int
+
+const (
+	// NoRowLock doesn't lock the rows of a read.
+	NoRowLock RowLockStrength = iota
+
+	// ForUpdate locks the rows as if they're being updated or deleted (FOR
+	// UPDATE), which blocks all other locks on the rows.
+	ForUpdate
+
+	// ForNoKeyUpdate locks the rows as if their non key columns are being
+	// updated (FOR NO KEY UPDATE), which doesn't block the ForKeyShare locks
+	// taken by the foreign key checks of other transactions.
+	ForNoKeyUpdate
+
+	// ForShare locks the rows with a shared lock (FOR SHARE), which blocks
+	// updates of the rows.
+	ForShare
+
+	// ForKeyShare locks the rows with a shared lock which only blocks deletes
+	// and updates of their key columns (FOR KEY SHARE).
+	ForKeyShare
+)
+
+// String returns the lock strength's sql (ex: "NO KEY UPDATE").
+func (s RowLockStrength) String() string {
+	switch s {
+	case NoRowLock:
+		return ""
+	case ForUpdate:
+		return clause.LockingStrengthUpdate
+	case ForNoKeyUpdate:
+		return "NO KEY UPDATE"
+	case ForShare:
+		return clause.LockingStrengthShare
+	case ForKeyShare:
+		return "KEY SHARE"
+	default:
+		return fmt.Sprintf("unknown row lock strength %d", int(s))
+	}
+}
+
+// rowLockClause returns the locking clause for the WithRowLock option and true,
+// or false when the option isn't set.  Postgres and CockroachDB support every
+// strength, sqlite doesn't support row locks and other dialects only support
+// ForUpdate and ForShare.  When the WithRowLockOf option is set, only the rows
+// of its tables are locked (FOR UPDATE OF ...), and each table must be
+// referenced by the resource's query.
+func (rw *RW) rowLockClause(resource interface{}, opts Options) (clause.Locking, bool, error) {
+	const op = "dbw.rowLockClause"
+	switch opts.WithRowLock {
+	case NoRowLock:
+		if len(opts.WithRowLockOf) > 0 {
+			return clause.Locking{}, false, fmt.Errorf("%s: row lock tables require a row lock strength: %w", op, ErrInvalidParameter)
+		}
+		return clause.Locking{}, false, nil
+	case ForUpdate, ForNoKeyUpdate, ForShare, ForKeyShare:
+	default:
+		return clause.Locking{}, false, fmt.Errorf("%s: %s: %w", op, opts.WithRowLock, ErrInvalidParameter)
+	}
+	typ, _, err := rw.underlying.DbType()
+	if err != nil {
+		return clause.Locking{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	switch {
+	case typ == Sqlite:
+		return clause.Locking{}, false, fmt.Errorf("%s: row locks are not supported by %s: %w", op, typ, ErrInvalidParameter)
+	case typ == UnknownDB && (opts.WithRowLock == ForNoKeyUpdate || opts.WithRowLock == ForKeyShare):
+		return clause.Locking{}, false, fmt.Errorf("%s: FOR %s row locks are not supported by %s: %w", op, opts.WithRowLock, typ, ErrInvalidParameter)
+	}
+	locking := clause.Locking{Strength: opts.WithRowLock.String()}
+	if len(opts.WithRowLockOf) == 0 {
+		return locking, true, nil
+	}
+	referenced, err := rw.queryTables(resource, opts)
+	if err != nil {
+		return clause.Locking{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	quoted := make([]string, 0, len(opts.WithRowLockOf))
+	for _, t := range opts.WithRowLockOf {
+		if !referenced[t] {
+			return clause.Locking{}, false, fmt.Errorf("%s: unknown row lock table %q: %w", op, t, ErrInvalidParameter)
+		}
+		quoted = append(quoted, rw.underlying.wrapped.Statement.Quote(t))
+	}
+	locking.Table = clause.Table{Name: strings.Join(quoted, ", "), Raw: true}
+	return locking, true, nil
+}
+
+// queryTables returns the names by which a query of the resource references
+// its tables: the tables and joins of a clause.From given by the
+// WithGormClauses option, or else the resource's table.  A table is
+// referenced by its alias when it has one, and by its name without a schema
+// otherwise.
+func (rw *RW) queryTables(resource interface{}, opts Options) (map[string]bool, error) {
+	const op = "dbw.queryTables"
+	refName := func(t clause.Table) string {
+		if t.Alias != "" {
+			return t.Alias
+		}
+		return t.Name[strings.LastIndex(t.Name, ".")+1:]
+	}
+	tables := map[string]bool{}
+	var from bool
+	for _, c := range opts.WithGormClauses {
+		f, ok := c.(clause.From)
+		if !ok {
+			continue
+		}
+		for _, t := range f.Tables {
+			from = true
+			tables[refName(t)] = true
+		}
+		for _, j := range f.Joins {
+			tables[refName(j.Table)] = true
+		}
+	}
+	if !from {
+		_, tableName, err := rw.parseSchema(resource, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tables[refName(clause.Table{Name: tableName})] = true
+	}
+	return tables, nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm/clause"
+)
+
+type testLockedAccount struct {
+	PublicId string `gorm:"primaryKey"`
+	Balance  int
+}
+
+func (*testLockedAccount) TableName() string { return "test_accounts" }
+
+func TestRW_WithRowLock(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := openDialector(postgres.New(postgres.Config{Conn: sqlDB}), Postgres)
+	require.NoError(t, err)
+	rw := New(db)
+
+	t.Run("search-where", func(t *testing.T) {
+		tests := []struct {
+			strength RowLockStrength
+			wantSql  string
+		}{
+			{ForUpdate, `SELECT * FROM "test_accounts" WHERE balance > $1 FOR UPDATE`},
+			{ForNoKeyUpdate, `SELECT * FROM "test_accounts" WHERE balance > $1 FOR NO KEY UPDATE`},
+			{ForShare, `SELECT * FROM "test_accounts" WHERE balance > $1 FOR SHARE`},
+			{ForKeyShare, `SELECT * FROM "test_accounts" WHERE balance > $1 FOR KEY SHARE`},
+			{NoRowLock, `SELECT * FROM "test_accounts" WHERE balance > $1`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.strength.String(), func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				mock.ExpectQuery(tt.wantSql).
+					WithArgs(0).
+					WillReturnRows(sqlmock.NewRows([]string{"public_id", "balance"}).AddRow("a_1", 10))
+				var accounts []*testLockedAccount
+				err := rw.SearchWhere(testCtx, &accounts, "balance > ?", []interface{}{0}, WithRowLock(tt.strength), WithLimit(-1))
+				require.NoError(err)
+				assert.Equal([]*testLockedAccount{{PublicId: "a_1", Balance: 10}}, accounts)
+				assert.NoError(mock.ExpectationsWereMet())
+			})
+		}
+	})
+	t.Run("lookup-where", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mock.ExpectQuery(`SELECT * FROM "test_accounts" WHERE public_id = $1 ORDER BY "test_accounts"."public_id" LIMIT $2 FOR NO KEY UPDATE`).
+			WithArgs("a_1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"public_id", "balance"}).AddRow("a_1", 10))
+		var account testLockedAccount
+		err := rw.LookupWhere(testCtx, &account, "public_id = ?", []interface{}{"a_1"}, WithRowLock(ForNoKeyUpdate))
+		require.NoError(err)
+		assert.Equal(10, account.Balance)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("lookup-by", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mock.ExpectQuery(`SELECT * FROM "test_accounts" WHERE public_id = $1 AND "test_accounts"."public_id" = $2 ORDER BY "test_accounts"."public_id" LIMIT $3 FOR KEY SHARE`).
+			WithArgs("a_1", "a_1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"public_id", "balance"}).AddRow("a_1", 10))
+		account := testLockedAccount{PublicId: "a_1"}
+		err := rw.LookupBy(testCtx, &account, WithRowLock(ForKeyShare))
+		require.NoError(err)
+		assert.Equal(10, account.Balance)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("of-tables", func(t *testing.T) {
+		joinOwners := WithGormClauses(clause.From{
+			Tables: []clause.Table{{Name: "test_accounts"}},
+			Joins: []clause.Join{{
+				Type:  clause.InnerJoin,
+				Table: clause.Table{Name: "test_owners", Alias: "o"},
+				ON:    clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "o.public_id = test_accounts.owner_id"}}},
+			}},
+		})
+		const joinSql = `SELECT "test_accounts"."public_id","test_accounts"."balance" FROM "test_accounts" INNER JOIN "test_owners" "o" ON o.public_id = test_accounts.owner_id WHERE o.name = $1 `
+		tests := []struct {
+			name    string
+			opt     []Option
+			wantSql string
+		}{
+			{
+				name:    "one-side-of-join",
+				opt:     []Option{joinOwners, WithRowLock(ForUpdate), WithRowLockOf("test_accounts")},
+				wantSql: joinSql + `FOR UPDATE OF "test_accounts"`,
+			},
+			{
+				name:    "alias",
+				opt:     []Option{joinOwners, WithRowLock(ForShare), WithRowLockOf("o")},
+				wantSql: joinSql + `FOR SHARE OF "o"`,
+			},
+			{
+				name:    "both-sides-of-join",
+				opt:     []Option{joinOwners, WithRowLock(ForNoKeyUpdate), WithRowLockOf("test_accounts", "o")},
+				wantSql: joinSql + `FOR NO KEY UPDATE OF "test_accounts", "o"`,
+			},
+			{
+				name:    "resource-table",
+				opt:     []Option{WithRowLock(ForUpdate), WithRowLockOf("test_accounts")},
+				wantSql: `SELECT * FROM "test_accounts" WHERE o.name = $1 FOR UPDATE OF "test_accounts"`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				mock.ExpectQuery(tt.wantSql).
+					WithArgs("alice").
+					WillReturnRows(sqlmock.NewRows([]string{"public_id", "balance"}).AddRow("a_1", 10))
+				var accounts []*testLockedAccount
+				err := rw.SearchWhere(testCtx, &accounts, "o.name = ?", []interface{}{"alice"}, append(tt.opt, WithLimit(-1))...)
+				require.NoError(err)
+				assert.Equal([]*testLockedAccount{{PublicId: "a_1", Balance: 10}}, accounts)
+				assert.NoError(mock.ExpectationsWereMet())
+			})
+		}
+		t.Run("unknown-table", func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var accounts []*testLockedAccount
+			err := rw.SearchWhere(testCtx, &accounts, "", nil, joinOwners, WithRowLock(ForUpdate), WithRowLockOf("test_owners"), WithLimit(-1))
+			require.Error(err)
+			assert.ErrorIs(err, ErrInvalidParameter)
+			assert.Contains(err.Error(), `unknown row lock table "test_owners"`)
+		})
+		t.Run("missing-strength", func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var account testLockedAccount
+			err := rw.LookupWhere(testCtx, &account, "public_id = ?", []interface{}{"a_1"}, WithRowLockOf("test_accounts"))
+			require.Error(err)
+			assert.ErrorIs(err, ErrInvalidParameter)
+			assert.Contains(err.Error(), "row lock tables require a row lock strength")
+		})
+	})
+	t.Run("invalid-strength", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var accounts []*testLockedAccount
+		err := rw.SearchWhere(testCtx, &accounts, "", nil, WithRowLock(RowLockStrength(100)), WithLimit(-1))
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "unknown row lock strength 100")
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		conn, _ := TestSetup(t)
+		rw := New(conn)
+		t.Run("of-tables", func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			var accounts []*testLockedAccount
+			err := rw.SearchWhere(testCtx, &accounts, "", nil, WithRowLock(ForUpdate), WithRowLockOf("test_accounts"), WithLimit(-1))
+			require.Error(err)
+			assert.ErrorIs(err, ErrInvalidParameter)
+			assert.Contains(err.Error(), "row locks are not supported by sqlite")
+		})
+		for _, strength := range []RowLockStrength{ForUpdate, ForNoKeyUpdate, ForShare, ForKeyShare} {
+			t.Run(strength.String(), func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var accounts []*testLockedAccount
+				err := rw.SearchWhere(testCtx, &accounts, "", nil, WithRowLock(strength), WithLimit(-1))
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), "row locks are not supported by sqlite")
+			})
+		}
+	})
+}
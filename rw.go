@@ -5,12 +5,19 @@ package dbw
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -44,17 +51,24 @@ func (rw *RW) DB() *DB {
 	return rw.underlying
 }
 
-// Exec will execute the sql with the values as parameters. The int returned
-// is the number of rows affected by the sql. The WithDebug option is supported.
+// Exec will execute the sql with the values as parameters. The int returned is
+// the number of rows affected by the sql. The statement is executed with the
+// ctx, so it's canceled when the ctx is done. The WithDebug option is
+// supported.
 func (rw *RW) Exec(ctx context.Context, sql string, values []interface{}, opt ...Option) (int, error) {
 	const op = "dbw.Exec"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
 	if rw.underlying == nil {
 		return 0, fmt.Errorf("%s: missing underlying db: %w", op, ErrInternal)
 	}
+	if rw.underlying.ReadOnly() {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
 	if sql == "" {
 		return noRowsAffected, fmt.Errorf("%s: missing sql: %w", op, ErrInvalidParameter)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
 	db := rw.underlying.wrapped.WithContext(ctx)
 	if opts.WithDebug {
 		db = db.Debug()
@@ -66,6 +80,139 @@ func (rw *RW) Exec(ctx context.Context, sql string, values []interface{}, opt ..
 	return int(db.RowsAffected), nil
 }
 
+// batchExecRetries is the number of times a batch will be retried by
+// BatchExec when it fails with a retryable error.
+const batchExecRetries = 3
+
+// BatchExec will execute the sql once for each set of values in argsBatch. The
+// sql is prepared once and every execution occurs within a single transaction,
+// which is started if the writer isn't already in one.  The int returned is the
+// total number of rows affected by the executions.
+//
+// When BatchExec starts the transaction, the batch is retried if it fails with
+// a retryable error (see: RW.IsRetryableError).  Supports WithDebug and
+// WithRetryableErrorFunc, which overrides the func that decides whether the
+// batch is retried.
+func (rw *RW) BatchExec(ctx context.Context, sql string, argsBatch [][]interface{}, opt ...Option) (int, error) {
+	const op = "dbw.BatchExec"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
+	switch {
+	case rw.underlying == nil:
+		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInternal)
+	case rw.underlying.ReadOnly():
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case sql == "":
+		return noRowsAffected, fmt.Errorf("%s: missing sql: %w", op, ErrInvalidParameter)
+	case len(argsBatch) == 0:
+		return noRowsAffected, fmt.Errorf("%s: missing args batch: %w", op, ErrInvalidParameter)
+	}
+	opts := rw.getOpts(opt...)
+	if rw.IsTx() {
+		// the caller owns the transaction, so it's also responsible for any
+		// retries
+		rowsAffected, err := rw.batchExec(ctx, sql, argsBatch, opts)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		return rowsAffected, nil
+	}
+
+	isRetryable := rw.IsRetryableError
+	if opts.WithRetryableErrorFunc != nil {
+		isRetryable = opts.WithRetryableErrorFunc
+	}
+	for attempts := uint(1); ; attempts++ {
+		rowsAffected, err := rw.batchExecInTx(ctx, sql, argsBatch, opts)
+		if err == nil {
+			return rowsAffected, nil
+		}
+		if !isRetryable(err) || attempts > batchExecRetries {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		select {
+		case <-ctx.Done():
+			return noRowsAffected, fmt.Errorf("%s: cancelled: %w", op, err)
+		case <-time.After(ExpBackoff{}.Duration(attempts)):
+		}
+	}
+}
+
+// batchExecInTx will execute the batch within a new transaction.
+func (rw *RW) batchExecInTx(ctx context.Context, query string, argsBatch [][]interface{}, opts Options) (int, error) {
+	const op = "dbw.batchExecInTx"
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := tx.batchExec(ctx, query, argsBatch, opts)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+		}
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return rowsAffected, nil
+}
+
+// batchExec will execute the query for each set of args using the writer's
+// transaction.  The query is rendered for the dialect by gorm (bind vars,
+// named args, expanded slices, etc) and prepared once for each distinct
+// rendering, which only differs when args expand to a different number of
+// parameters.  Each execution runs through gorm's callbacks, so it's logged
+// and limited like any other statement (see: WithMaxConcurrentOps).
+func (rw *RW) batchExec(ctx context.Context, query string, argsBatch [][]interface{}, opts Options) (int, error) {
+	const op = "dbw.batchExec"
+	db := rw.underlying.wrapped.WithContext(ctx)
+	pool := &preparedConnPool{ConnPool: db.Statement.ConnPool, stmts: map[string]*sql.Stmt{}}
+	defer pool.close()
+	db.Statement.ConnPool = pool
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	var totalRowsAffected int
+	for i, args := range argsBatch {
+		result := db.Exec(query, args...)
+		if result.Error != nil {
+			return noRowsAffected, fmt.Errorf("%s: args %d: %w", op, i, result.Error)
+		}
+		totalRowsAffected += int(result.RowsAffected)
+	}
+	return totalRowsAffected, nil
+}
+
+// preparedConnPool is a gorm.ConnPool which prepares each distinct query it
+// executes once and executes the prepared statement (see: batchExec).
+type preparedConnPool struct {
+	gorm.ConnPool
+	stmts map[string]*sql.Stmt
+}
+
+// ExecContext will execute the query's prepared statement, which is prepared
+// the first time the query is executed.
+func (p *preparedConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	const op = "dbw.(preparedConnPool).ExecContext"
+	stmt, ok := p.stmts[query]
+	if !ok {
+		var err error
+		if stmt, err = p.ConnPool.PrepareContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("%s: unable to prepare statement: %w", op, err)
+		}
+		p.stmts[query] = stmt
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// close will close the prepared statements.
+func (p *preparedConnPool) close() {
+	for _, stmt := range p.stmts {
+		_ = stmt.Close()
+	}
+}
+
 func (rw *RW) primaryFieldsAreZero(ctx context.Context, i interface{}) ([]string, bool, error) {
 	const op = "dbw.primaryFieldsAreZero"
 	var fieldNames []string
@@ -83,6 +230,73 @@ func (rw *RW) primaryFieldsAreZero(ctx context.Context, i interface{}) ([]string
 	return fieldNames, len(fieldNames) > 0, nil
 }
 
+// dryRunSql returns the sql of a statement which was executed in a dry run
+// session with its vars interpolated (see: WithDryRun)
+func dryRunSql(db *gorm.DB) string {
+	return db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...)
+}
+
+// renderedVars returns the vars of a statement which was rendered for the
+// dialect in a dry run session, so the statement's sql can be executed using
+// Raw(...).  Raw(...) expands a slice which follows a parenthesis into a var
+// for each of its elements, so slices which aren't a driver.Valuer (ex: a
+// []byte) are wrapped in one.
+func renderedVars(vars []interface{}) []interface{} {
+	rendered := make([]interface{}, 0, len(vars))
+	for _, v := range vars {
+		if _, ok := v.(driver.Valuer); !ok {
+			if k := reflect.ValueOf(v).Kind(); k == reflect.Slice || k == reflect.Array {
+				v = sliceVar{v: v}
+			}
+		}
+		rendered = append(rendered, v)
+	}
+	return rendered
+}
+
+// sliceVar is a driver.Valuer for a slice var (see: renderedVars).
+type sliceVar struct {
+	v interface{}
+}
+
+// Value returns the slice.
+func (s sliceVar) Value() (driver.Value, error) {
+	return s.v, nil
+}
+
+// readContext returns the context for a read operation, which has the DB's
+// default read timeout applied (see: WithDefaultReadTimeout).  The returned
+// cancel func must be called when the operation completes.
+func (rw *RW) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rw.underlying == nil {
+		return ctx, func() {}
+	}
+	return contextWithDefaultTimeout(ctx, rw.underlying.readTimeout)
+}
+
+// writeContext returns the context for a write operation, which has the DB's
+// default write timeout applied (see: WithDefaultWriteTimeout).  The returned
+// cancel func must be called when the operation completes.
+func (rw *RW) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rw.underlying == nil {
+		return ctx, func() {}
+	}
+	return contextWithDefaultTimeout(ctx, rw.underlying.writeTimeout)
+}
+
+// contextWithDefaultTimeout returns a context with the timeout, unless the
+// timeout is <= 0 or the ctx already has a deadline, which is never
+// overridden.
+func contextWithDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 func isNil(i interface{}) bool {
 	if i == nil {
 		return true
@@ -166,6 +380,15 @@ func (rw *RW) IsTx() bool {
 	}
 }
 
+// InTransaction returns true if the RW is scoped to a transaction (created by
+// Begin or passed to a DoTx handler), rather than a connection from the pool.
+// It's typically used by code which must run within a transaction to enforce
+// that precondition, rather than failing at the database.  It's the same as
+// IsTx.
+func (rw *RW) InTransaction() bool {
+	return rw.IsTx()
+}
+
 func (rw *RW) whereClausesFromOpts(_ context.Context, i interface{}, opts Options) (string, []interface{}, error) {
 	const op = "dbw.whereClausesFromOpts"
 	var where []string
@@ -284,10 +507,22 @@ func (rw *RW) primaryKeysWhere(ctx context.Context, i interface{}) (string, []in
 }
 
 // LookupWhere will lookup the first resource using a where clause with
-// parameters (it only returns the first one). Supports WithDebug, and
-// WithTable options.
+// parameters (it only returns the first one). Supports WithDebug, WithTable,
+// WithGormClauses, WithDeleted, WithOrder, WithOrderBy, WithLimit, WithRowLock
+// and WithResultTransformer options.  The resource is ordered by WithOrder (or
+// WithOrderBy) and then by its primary key, unless its primary key is a field
+// of a struct embedded with a column prefix (see: SearchWhere), which is a
+// column of a joined table.
+//
+// When the resource is a pointer to a slice of resources, the first WithLimit
+// resources are found (the first resource when WithLimit < 1).  A resource
+// which isn't a slice requires a WithLimit <= 1.  ErrRecordNotFound is returned
+// when no resources are found.  A default WithLimit (see:
+// DB.SetDefaultOptions) is ignored.
 func (rw *RW) LookupWhere(ctx context.Context, resource interface{}, where string, args []interface{}, opt ...Option) error {
 	const op = "dbw.LookupWhere"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
 	if rw.underlying == nil {
 		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
 	}
@@ -297,7 +532,24 @@ func (rw *RW) LookupWhere(ctx context.Context, resource interface{}, where strin
 	if err := raiseErrorOnHooks(resource); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
+	if err := validateGormClauses(opts.WithGormClauses); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// a default WithLimit (see: DB.SetDefaultOptions) is meant for searches,
+	// so only the WithLimit provided to the lookup is used
+	opts.WithLimit = GetOpts(opt...).WithLimit
+	isSlice := reflect.ValueOf(resource).Elem().Kind() == reflect.Slice
+	if !isSlice && opts.WithLimit > 1 {
+		return fmt.Errorf("%s: with limit greater than one requires a pointer to a slice of resources: %w", op, ErrInvalidParameter)
+	}
+	opts, err := rw.resolveTable(ctx, resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithOrder, err = rw.orderFromOpts(resource, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	db := rw.underlying.wrapped.WithContext(ctx)
 	if opts.WithTable != "" {
 		db = db.Table(opts.WithTable)
@@ -305,25 +557,86 @@ func (rw *RW) LookupWhere(ctx context.Context, resource interface{}, where strin
 	if opts.WithDebug {
 		db = db.Debug()
 	}
-	if err := db.Where(where, args...).First(resource).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if len(opts.WithGormClauses) > 0 {
+		db = db.Clauses(opts.WithGormClauses...)
+	}
+	locking, lockRows, err := rw.rowLockClause(resource, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if lockRows {
+		db = db.Clauses(locking)
+	}
+	if opts.WithOrder != "" {
+		db = db.Order(opts.WithOrder)
+	}
+	db = db.Where(where, args...)
+	if db, err = rw.softDeleteScope(db, resource, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// First orders by the primary key of the resource's table, which an
+	// embedded struct's key isn't (it's a column of a joined table)
+	s, _, err := rw.parseSchema(resource, opts)
+	orderByPrimaryKey := err != nil || !embeddedPrimaryKey(s)
+	switch {
+	case isSlice:
+		limit := opts.WithLimit
+		if limit < 1 {
+			limit = 1
+		}
+		query := db.Limit(limit)
+		if orderByPrimaryKey {
+			query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}})
+		}
+		tx := query.Find(resource)
+		if tx.Error != nil {
+			return fmt.Errorf("%s: %w", op, tx.Error)
+		}
+		if tx.RowsAffected == 0 {
 			return fmt.Errorf("%s: %w", op, ErrRecordNotFound)
 		}
+	default:
+		find := db.Take
+		if orderByPrimaryKey {
+			find = db.First
+		}
+		if err := find(resource).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("%s: %w", op, ErrRecordNotFound)
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := transformResults(resource, opts.WithResultTransformer); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
 // SearchWhere will search for all the resources it can find using a where
-// clause with parameters. An error will be returned if args are provided without a
-// where clause.
+// clause with parameters. An error will be returned if args are provided
+// without a where clause.
+//
+// Supports WithTable and WithLimit options.  If WithLimit < 0, then unlimited
+// results are returned. If WithLimit == 0, then default limits are used for
+// results. Supports the WithOrder, WithOrderBy, WithTable,
+// WithResultTransformer, WithExcludeColumns, WithWindowCount,
+// WithAppendResults, WithGormClauses, WithDistinctOn, WithDeleted, WithRowLock
+// and WithDebug options.  If the database was opened using WithRejectFullScans,
+// then an ErrUnsafeQuery is returned for unlimited results without a where
+// clause, unless WithAllowFullScan is used.  WithAppendResults appends the
+// resources found to the existing contents of the resources slice, rather than
+// replacing them.
 //
-// Supports WithTable and WithLimit options.  If WithLimit < 0, then unlimited results are returned.
-// If WithLimit == 0, then default limits are used for results.
-// Supports the WithOrder, WithTable, and WithDebug options.
+// The resources may embed a struct with a column prefix (ex:
+// `gorm:"embedded;embeddedPrefix:user__"`), which is populated from the
+// selected columns aliased with the prefix (ex: "u.name as user__name") of a
+// join specified using WithGormClauses.
 func (rw *RW) SearchWhere(ctx context.Context, resources interface{}, where string, args []interface{}, opt ...Option) error {
 	const op = "dbw.SearchWhere"
-	opts := GetOpts(opt...)
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	opts := rw.getOpts(opt...)
 	if rw.underlying == nil {
 		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
 	}
@@ -336,7 +649,28 @@ func (rw *RW) SearchWhere(ctx context.Context, resources interface{}, where stri
 	if err := validateResourcesInterface(resources); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	var err error
+	if rw.underlying.rejectFullScans && where == "" && opts.WithLimit < 0 && !opts.WithAllowFullScan {
+		return fmt.Errorf("%s: unlimited results without a where clause: %w", op, ErrUnsafeQuery)
+	}
+	if err := validateGormClauses(opts.WithGormClauses); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// when appending, the resources are found using a new slice which is
+	// appended to the existing resources after a successful read
+	found := resources
+	if opts.WithAppendResults {
+		if reflect.ValueOf(resources).Elem().Kind() != reflect.Slice {
+			return fmt.Errorf("%s: append results requires a slice of resources: %w", op, ErrInvalidParameter)
+		}
+		found = reflect.New(reflect.TypeOf(resources).Elem()).Interface()
+	}
+	opts, err := rw.resolveTable(ctx, resources, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithOrder, err = rw.orderFromOpts(resources, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	db := rw.underlying.wrapped.WithContext(ctx)
 	if opts.WithOrder != "" {
 		db = db.Order(opts.WithOrder)
@@ -347,6 +681,41 @@ func (rw *RW) SearchWhere(ctx context.Context, resources interface{}, where stri
 	if opts.WithTable != "" {
 		db = db.Table(opts.WithTable)
 	}
+	var columns []string
+	if len(opts.WithExcludeColumns) > 0 {
+		if columns, err = rw.selectColumns(resources, opts); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if opts.WithWindowCount != nil {
+		if columns == nil {
+			columns = []string{"*"}
+		}
+		columns = append(columns, windowCountSelect)
+	}
+	if len(opts.WithDistinctOn) > 0 {
+		distinctOn, err := rw.distinctOn(resources, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if columns == nil {
+			columns = []string{"*"}
+		}
+		columns[0] = distinctOn + " " + columns[0]
+	}
+	if columns != nil {
+		db = db.Select(columns)
+	}
+	if len(opts.WithGormClauses) > 0 {
+		db = db.Clauses(opts.WithGormClauses...)
+	}
+	locking, lockRows, err := rw.rowLockClause(resources, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if lockRows {
+		db = db.Clauses(locking)
+	}
 	// Perform limiting
 	switch {
 	case opts.WithLimit < 0: // any negative number signals unlimited results
@@ -359,13 +728,465 @@ func (rw *RW) SearchWhere(ctx context.Context, resources interface{}, where stri
 	if where != "" {
 		db = db.Where(where, args...)
 	}
+	if db, err = rw.softDeleteScope(db, resources, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	// Perform the query
-	err = db.Find(resources).Error
+	switch {
+	case opts.WithWindowCount != nil:
+		err = rw.findWithWindowCount(ctx, db, found, opts.WithWindowCount)
+	default:
+		err = db.Find(found).Error
+	}
 	if err != nil {
 		// searching with a slice parameter does not return a gorm.ErrRecordNotFound
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if err := transformResults(found, opts.WithResultTransformer); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithAppendResults {
+		existing := reflect.ValueOf(resources).Elem()
+		existing.Set(reflect.AppendSlice(existing, reflect.ValueOf(found).Elem()))
+	}
+	return nil
+}
+
+const (
+	// windowCountColumn is the column of the total count selected for the
+	// WithWindowCount option.
+	windowCountColumn = "total_count"
+
+	windowCountSelect = "count(*) over() as " + windowCountColumn
+)
+
+// findWithWindowCount will find the resources using the db's query, which
+// must select the windowCountColumn, and set the count to the total count
+// from the rows returned.  The rows are scanned by gorm, so the resources are
+// hydrated just like db.Find(...), while the count is captured by
+// windowCountRows.
+func (rw *RW) findWithWindowCount(ctx context.Context, db *gorm.DB, resources interface{}, count *int64) error {
+	const op = "dbw.findWithWindowCount"
+	*count = 0
+	rows, err := db.Model(resources).Rows()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	countIdx := -1
+	for idx, c := range columns {
+		if c == windowCountColumn {
+			countIdx = idx
+		}
+	}
+	if countIdx < 0 {
+		return fmt.Errorf("%s: missing %s column: %w", op, windowCountColumn, ErrInternal)
+	}
+
+	tx := rw.underlying.wrapped.Session(&gorm.Session{NewDB: true, Context: ctx})
+	if err := tx.Statement.Parse(resources); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tx.Statement.Dest = resources
+	tx.Statement.ReflectValue = reflect.ValueOf(resources).Elem()
+	gorm.Scan(&windowCountRows{Rows: rows, countIdx: countIdx, count: count}, tx, 0)
+	if tx.Error != nil {
+		return fmt.Errorf("%s: %w", op, tx.Error)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// windowCountRows captures the total count from the windowCountColumn of the
+// rows as they're scanned.  The column doesn't match a field of the resource,
+// so gorm scans it into an *interface{}.
+type windowCountRows struct {
+	*sql.Rows
+	countIdx int
+	count    *int64
+}
+
+func (r *windowCountRows) Scan(dest ...interface{}) error {
+	const op = "dbw.(windowCountRows).Scan"
+	if err := r.Rows.Scan(dest...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	v, ok := dest[r.countIdx].(*interface{})
+	if !ok {
+		return fmt.Errorf("%s: unexpected %s column destination %T: %w", op, windowCountColumn, dest[r.countIdx], ErrInternal)
+	}
+	switch c := (*v).(type) {
+	case int64:
+		*r.count = c
+	case int32:
+		*r.count = int64(c)
+	case []byte:
+		n, err := strconv.ParseInt(string(c), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid %s column value: %w", op, windowCountColumn, err)
+		}
+		*r.count = n
+	default:
+		return fmt.Errorf("%s: unexpected %s column type %T: %w", op, windowCountColumn, c, ErrInternal)
+	}
+	return nil
+}
+
+// selectColumns returns the columns of the resource(s) schema, without the
+// columns excluded by the WithExcludeColumns option.  An error is returned if
+// an excluded column doesn't exist or every column is excluded.
+func (rw *RW) selectColumns(resources interface{}, opts Options) ([]string, error) {
+	const op = "dbw.selectColumns"
+	s, _, err := rw.parseSchema(resources, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	excluded := make(map[string]struct{}, len(opts.WithExcludeColumns))
+	for _, c := range opts.WithExcludeColumns {
+		if _, ok := s.FieldsByDBName[c]; !ok {
+			return nil, fmt.Errorf("%s: excluded column %s does not exist: %w", op, c, ErrInvalidParameter)
+		}
+		excluded[c] = struct{}{}
+	}
+	columns := make([]string, 0, len(s.DBNames))
+	for _, c := range s.DBNames {
+		if _, ok := excluded[c]; !ok {
+			columns = append(columns, c)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s: all columns are excluded: %w", op, ErrInvalidParameter)
+	}
+	return columns, nil
+}
+
+// distinctOn returns the "distinct on" select expression for the
+// WithDistinctOn option.  The columns must exist in the resources' schema and
+// they must match the leading columns of the WithOrder option, which is
+// required by postgres.  Only Postgres and CockroachDB support distinct on.
+func (rw *RW) distinctOn(resources interface{}, opts Options) (string, error) {
+	const op = "dbw.distinctOn"
+	if opts.WithWindowCount != nil {
+		return "", fmt.Errorf("%s: distinct on cannot be used with a window count: %w", op, ErrInvalidParameter)
+	}
+	s, _, err := rw.parseSchema(resources, opts)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	for _, c := range opts.WithDistinctOn {
+		if _, ok := s.FieldsByDBName[c]; !ok {
+			return "", fmt.Errorf("%s: distinct on column %s does not exist: %w", op, c, ErrInvalidParameter)
+		}
+	}
+	orderColumns := orderByColumns(opts.WithOrder)
+	if len(orderColumns) < len(opts.WithDistinctOn) {
+		return "", fmt.Errorf("%s: distinct on columns %s must match the leading columns of the order: %w", op, opts.WithDistinctOn, ErrInvalidParameter)
+	}
+	for idx, c := range opts.WithDistinctOn {
+		if !strings.EqualFold(orderColumns[idx], c) {
+			return "", fmt.Errorf("%s: distinct on columns %s must match the leading columns of the order: %w", op, opts.WithDistinctOn, ErrInvalidParameter)
+		}
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	switch dbType {
+	case Postgres, CockroachDB:
+	default:
+		return "", fmt.Errorf("%s: distinct on is not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+	return "distinct on (" + strings.Join(opts.WithDistinctOn, ", ") + ")", nil
+}
+
+// orderByColumns returns the columns of an order by clause (ex: "name,
+// create_time desc" returns name and create_time), without any table
+// qualifiers or quotes.
+func orderByColumns(orderBy string) []string {
+	var columns []string
+	for _, part := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		col := fields[0]
+		if idx := strings.LastIndexByte(col, '.'); idx >= 0 {
+			col = col[idx+1:]
+		}
+		columns = append(columns, strings.Trim(col, `"`))
+	}
+	return columns
+}
+
+// transformResults will call the transformer func for the resource(s) read
+// (see: WithResultTransformer).  If the resources are a pointer to a slice,
+// then the func is called for each of its elements.
+func transformResults(resources interface{}, transformer func(interface{}) error) error {
+	const op = "dbw.transformResults"
+	if transformer == nil {
+		return nil
+	}
+	e := reflect.ValueOf(resources).Elem()
+	if e.Kind() != reflect.Slice {
+		if err := transformer(resources); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+	for i := 0; i < e.Len(); i++ {
+		if err := transformer(e.Index(i).Interface()); err != nil {
+			return fmt.Errorf("%s: result %d: %w", op, i, err)
+		}
+	}
+	return nil
+}
+
+// Exists returns whether any row of the resource's table matches the where
+// clause with parameters.  It issues a single "select 1 ... limit 1" query and
+// returns the result without hydrating a row, which makes it a cheap existence
+// check (ex: before deciding whether to insert).  It returns false when no rows
+// match and, unlike Count, the database stops at the first matching row.  The
+// resource is only used to determine the table and it's not modified.  An
+// error will be returned if args are provided without a where clause.
+// Supports the WithTable, WithDeleted, WithIndexPredicate and WithDebug
+// options.  WithIndexPredicate aligns the check with a partial unique index, so
+// it agrees with the index's uniqueness rule.
+func (rw *RW) Exists(ctx context.Context, resource interface{}, where string, args []interface{}, opt ...Option) (bool, error) {
+	const op = "dbw.Exists"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	if rw.underlying == nil {
+		return false, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	if where == "" && len(args) > 0 {
+		return false, fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	}
+	if err := validateResourcesInterface(resource); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, resource, opts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	_, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Table(tableName).Select("1")
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	if opts.WithIndexPredicate != "" {
+		query = query.Where("(" + opts.WithIndexPredicate + ")")
+	}
+	if query, err = rw.softDeleteScope(query, resource, opts); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	var one int
+	query = query.Limit(1).Scan(&one)
+	if query.Error != nil {
+		return false, fmt.Errorf("%s: %w", op, query.Error)
+	}
+	return query.RowsAffected > 0, nil
+}
+
+// Count returns the number of rows of the resource's table matching the where
+// clause with parameters.  It issues a single "select count(*)" query, so the
+// rows aren't read into memory, and it returns 0 when no rows match.  The
+// resource is only used to determine the table and it's not modified.  An
+// error will be returned if args are provided without a where clause.
+// Supports the WithTable, WithDeleted and WithDebug options.
+func (rw *RW) Count(ctx context.Context, resource interface{}, where string, args []interface{}, opt ...Option) (int64, error) {
+	const op = "dbw.Count"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	if rw.underlying == nil {
+		return 0, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	if where == "" && len(args) > 0 {
+		return 0, fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(resource); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := validateResourcesInterface(resource); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, resource, opts)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	_, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Table(tableName)
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	if query, err = rw.softDeleteScope(query, resource, opts); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+// validateGormClauses returns an error if any of the clauses are nil (see:
+// WithGormClauses)
+func validateGormClauses(clauses []clause.Expression) error {
+	const op = "dbw.validateGormClauses"
+	for idx, c := range clauses {
+		if isNil(c) {
+			return fmt.Errorf("%s: gorm clause %d is nil: %w", op, idx, ErrInvalidParameter)
+		}
+	}
+	return nil
+}
+
+// identifierRegexp matches a simple, unquoted identifier, which is used to
+// validate names (CTE names, key columns, etc) that are written into sql.
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// SearchWithCTE will search for all the resources it can find using a where
+// clause with parameters, where the query is prefixed with the common table
+// expressions (CTEs) provided.  The ctes map names to their subqueries (see:
+// Expr(...)) and the where clause may reference them (ex: "id in (select id
+// from active_users)").  CTE names must be simple identifiers.
+//
+// The CTEs are emitted as a "with recursive" clause ordered by name, which
+// allows recursive CTEs and lets a CTE reference the other CTEs.  Supports the
+// WithTable, WithOrder, WithOrderBy, WithLimit, WithDeleted, WithAllowFullScan,
+// WithResultTransformer and WithDebug options.  The other SearchWhere options
+// (WithAppendResults, WithWindowCount, WithExcludeColumns, WithGormClauses,
+// WithDistinctOn, WithRowLock and WithRowLockOf) and WithIndexPredicate aren't
+// supported and ErrInvalidParameter is returned when they're provided.
+func (rw *RW) SearchWithCTE(ctx context.Context, resources interface{}, ctes map[string]ExprValue, where string, args []interface{}, opt ...Option) error {
+	const op = "dbw.SearchWithCTE"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	opts := rw.getOpts(opt...)
+	if rw.underlying == nil {
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	if len(ctes) == 0 {
+		return fmt.Errorf("%s: missing common table expressions: %w", op, ErrInvalidParameter)
+	}
+	if where == "" && len(args) > 0 {
+		return fmt.Errorf("%s: args provided with empty where: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(resources); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := validateResourcesInterface(resources); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rw.underlying.rejectFullScans && where == "" && opts.WithLimit < 0 && !opts.WithAllowFullScan {
+		return fmt.Errorf("%s: unlimited results without a where clause: %w", op, ErrUnsafeQuery)
+	}
+	// the DB's default options (see: DB.SetDefaultOptions) which aren't
+	// supported are ignored
+	switch callOpts := GetOpts(opt...); {
+	case callOpts.WithAppendResults:
+		return fmt.Errorf("%s: with append results is not a supported option: %w", op, ErrInvalidParameter)
+	case callOpts.WithWindowCount != nil:
+		return fmt.Errorf("%s: with window count is not a supported option: %w", op, ErrInvalidParameter)
+	case len(callOpts.WithExcludeColumns) > 0:
+		return fmt.Errorf("%s: with exclude columns is not a supported option: %w", op, ErrInvalidParameter)
+	case len(callOpts.WithGormClauses) > 0:
+		return fmt.Errorf("%s: with gorm clauses is not a supported option: %w", op, ErrInvalidParameter)
+	case len(callOpts.WithDistinctOn) > 0:
+		return fmt.Errorf("%s: with distinct on is not a supported option: %w", op, ErrInvalidParameter)
+	case callOpts.WithRowLock != NoRowLock || len(callOpts.WithRowLockOf) > 0:
+		return fmt.Errorf("%s: with row lock is not a supported option: %w", op, ErrInvalidParameter)
+	case callOpts.WithIndexPredicate != "":
+		return fmt.Errorf("%s: with index predicate is not a supported option: %w", op, ErrInvalidParameter)
+	}
+	names := make([]string, 0, len(ctes))
+	for name, cte := range ctes {
+		if !identifierRegexp.MatchString(name) {
+			return fmt.Errorf("%s: invalid common table expression name %q: %w", op, name, ErrInvalidParameter)
+		}
+		if strings.TrimSpace(cte.Sql) == "" {
+			return fmt.Errorf("%s: missing sql for common table expression %s: %w", op, name, ErrInvalidParameter)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	opts, err := rw.resolveTable(ctx, resources, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithOrder, err = rw.orderFromOpts(resources, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, tableName, err := rw.parseSchema(resources, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Table(tableName)
+	if opts.WithOrder != "" {
+		query = query.Order(opts.WithOrder)
+	}
+	// Perform limiting
+	switch {
+	case opts.WithLimit < 0: // any negative number signals unlimited results
+	case opts.WithLimit == 0: // zero signals the default value and default limits
+		query = query.Limit(DefaultLimit)
+	default:
+		query = query.Limit(opts.WithLimit)
+	}
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	if query, err = rw.softDeleteScope(query, resources, opts); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var sql strings.Builder
+	vars := make([]interface{}, 0, len(names)+1)
+	sql.WriteString("with recursive ")
+	for idx, name := range names {
+		if idx > 0 {
+			sql.WriteString(", ")
+		}
+		sql.WriteString(name + " as (?)")
+		vars = append(vars, gorm.Expr(ctes[name].Sql, ctes[name].Vars...))
+	}
+	sql.WriteString(" ?")
+	vars = append(vars, query)
+
+	// Perform the query
+	if err := db.Raw(sql.String(), vars...).Scan(resources).Error; err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := transformResults(resources, opts.WithResultTransformer); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	return nil
 }
 
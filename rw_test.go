@@ -6,14 +6,18 @@ package dbw_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/hashicorp/go-dbw"
 	"github.com/hashicorp/go-dbw/internal/dbtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/clause"
 )
 
 func TestDb_Exec(t *testing.T) {
@@ -65,6 +69,139 @@ func TestDb_Exec(t *testing.T) {
 		require.Error(err)
 		assert.Zero(got)
 	})
+	t.Run("canceled-context", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		id, err := dbw.NewId("i")
+		require.NoError(err)
+		ctx, cancel := context.WithCancel(testCtx)
+		cancel()
+		got, err := rw.Exec(ctx,
+			"insert into db_test_user(public_id, name) values(@public_id, @name)",
+			[]interface{}{
+				sql.Named("public_id", id),
+				sql.Named("name", "alice"),
+			})
+		require.Error(err)
+		assert.ErrorIs(err, context.Canceled)
+		assert.Zero(got)
+
+		err = rw.LookupBy(testCtx, &dbtest.TestUser{StoreTestUser: &dbtest.StoreTestUser{PublicId: id}})
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+}
+
+func TestDb_BatchExec(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	t.Run("update", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		argsBatch := make([][]interface{}, 0, 100)
+		ids := make([]string, 0, 100)
+		for i := 0; i < 100; i++ {
+			u := testUser(t, testRw, "", "", "")
+			ids = append(ids, u.PublicId)
+			argsBatch = append(argsBatch, []interface{}{"batch-" + strconv.Itoa(i), u.PublicId})
+		}
+		rowsAffected, err := testRw.BatchExec(testCtx, "update db_test_user set name = ? where public_id = ?", argsBatch)
+		require.NoError(err)
+		assert.Equal(100, rowsAffected)
+
+		var users []*dbtest.TestUser
+		require.NoError(testRw.SearchWhere(testCtx, &users, "public_id in (?)", []interface{}{ids}))
+		require.Len(users, 100)
+		for _, u := range users {
+			assert.True(strings.HasPrefix(u.Name, "batch-"))
+		}
+	})
+	t.Run("named-args", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		u := testUser(t, testRw, "", "", "")
+		rowsAffected, err := testRw.BatchExec(testCtx,
+			"update db_test_user set email = @email where public_id = @public_id",
+			[][]interface{}{
+				{sql.Named("email", "alice@example.com"), sql.Named("public_id", u.PublicId)},
+				{sql.Named("email", "bob@example.com"), sql.Named("public_id", u.PublicId)},
+			},
+			dbw.WithDebug(true),
+		)
+		require.NoError(err)
+		assert.Equal(2, rowsAffected)
+	})
+	t.Run("failure-is-rolled-back", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		id, err := dbw.NewId("u")
+		require.NoError(err)
+		rowsAffected, err := testRw.BatchExec(testCtx,
+			"insert into db_test_user(public_id, name) values(?, ?)",
+			[][]interface{}{
+				{id, "rolled-back-" + id},
+				{id, "duplicate-" + id},
+			},
+		)
+		require.Error(err)
+		assert.Zero(rowsAffected)
+		found, err := testRw.Exists(testCtx, &dbtest.TestUser{}, "public_id = ?", []interface{}{id})
+		require.NoError(err)
+		assert.False(found)
+	})
+	t.Run("with-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		u := testUser(t, testRw, "", "", "")
+		tx, err := testRw.Begin(testCtx)
+		require.NoError(err)
+		rowsAffected, err := tx.BatchExec(testCtx,
+			"update db_test_user set phone_number = ? where public_id = ?",
+			[][]interface{}{{"555-1234", u.PublicId}},
+		)
+		require.NoError(err)
+		assert.Equal(1, rowsAffected)
+		require.NoError(tx.Rollback(testCtx))
+
+		found, err := testRw.Exists(testCtx, &dbtest.TestUser{}, "public_id = ? and phone_number = ?", []interface{}{u.PublicId, "555-1234"})
+		require.NoError(err)
+		assert.False(found)
+	})
+	t.Run("with-retryable-error-func", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var calls int
+		rowsAffected, err := testRw.BatchExec(testCtx,
+			"update db_test_user set bad_column_name = ?",
+			[][]interface{}{{1}},
+			dbw.WithRetryableErrorFunc(func(error) bool {
+				calls++
+				return true
+			}),
+		)
+		require.Error(err)
+		assert.Zero(rowsAffected)
+		// the initial attempt plus 3 retries
+		assert.Equal(4, calls)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			sql             string
+			argsBatch       [][]interface{}
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, "select 1", [][]interface{}{{}}, "missing underlying db"},
+			{"missing-sql", testRw, "", [][]interface{}{{}}, "missing sql"},
+			{"missing-args-batch", testRw, "select 1", nil, "missing args batch"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				rowsAffected, err := tt.rw.BatchExec(testCtx, tt.sql, tt.argsBatch)
+				require.Error(err)
+				assert.Zero(rowsAffected)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
 }
 
 func TestDb_LookupWhere(t *testing.T) {
@@ -103,6 +240,22 @@ func TestDb_LookupWhere(t *testing.T) {
 		err = w.LookupWhere(context.Background(), &foundUser, "public_id = ?", []interface{}{user.PublicId}, dbw.WithTable("invalid-table-name"))
 		require.Error(err)
 	})
+	t.Run("gorm-clauses", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		w := dbw.New(conn)
+		user := testUser(t, w, "gorm-clauses-lookup", "", "")
+
+		var foundUser dbtest.TestUser
+		err := w.LookupWhere(context.Background(), &foundUser, "1 = ?", []interface{}{1},
+			dbw.WithGormClauses(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "name", Value: user.Name}}}),
+		)
+		require.NoError(err)
+		assert.Equal(user.PublicId, foundUser.PublicId)
+
+		err = w.LookupWhere(context.Background(), &foundUser, "public_id = ?", []interface{}{user.PublicId}, dbw.WithGormClauses(nil))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+	})
 	t.Run("tx-nil,", func(t *testing.T) {
 		assert, require := assert.New(t), require.New(t)
 		w := dbw.RW{}
@@ -160,11 +313,79 @@ func TestDb_LookupWhere(t *testing.T) {
 			})
 		}
 	})
+	t.Run("first-n", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		w := dbw.New(conn)
+		id, err := dbw.NewId("u")
+		require.NoError(err)
+		prefix := "first-n-" + id
+		for _, suffix := range []string{"c", "a", "d", "b"} {
+			testUser(t, w, prefix+"-"+suffix, "", "")
+		}
+		where, args := "name like ?", []interface{}{prefix + "-%"}
+
+		// a single resource with an order
+		var first dbtest.TestUser
+		require.NoError(w.LookupWhere(context.Background(), &first, where, args, dbw.WithOrder("name desc")))
+		assert.Equal(prefix+"-d", first.Name)
+
+		// a slice of the first n resources
+		var found []*dbtest.TestUser
+		require.NoError(w.LookupWhere(context.Background(), &found, where, args, dbw.WithLimit(3), dbw.WithOrder("name")))
+		require.Len(found, 3)
+		assert.Equal(prefix+"-a", found[0].Name)
+		assert.Equal(prefix+"-b", found[1].Name)
+		assert.Equal(prefix+"-c", found[2].Name)
+
+		// a slice without a limit finds the first resource
+		found = nil
+		require.NoError(w.LookupWhere(context.Background(), &found, where, args, dbw.WithOrderBy(dbw.NewOrderBy().Desc("name"))))
+		require.Len(found, 1)
+		assert.Equal(prefix+"-d", found[0].Name)
+
+		found = nil
+		err = w.LookupWhere(context.Background(), &found, "name = ?", []interface{}{prefix}, dbw.WithLimit(3))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		// a limit greater than one requires a slice
+		err = w.LookupWhere(context.Background(), &first, where, args, dbw.WithLimit(3))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "with limit greater than one requires a pointer to a slice of resources")
+	})
+	t.Run("default-limit", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		w := dbw.New(conn)
+		prefix := "default-limit"
+		for _, suffix := range []string{"a", "b", "c"} {
+			testUser(t, w, prefix+"-"+suffix, "", "")
+		}
+		where, args := "name like ?", []interface{}{prefix + "-%"}
+		conn.SetDefaultOptions(dbw.WithLimit(50))
+
+		// the default limit doesn't apply to a single resource
+		var first dbtest.TestUser
+		require.NoError(w.LookupWhere(context.Background(), &first, where, args, dbw.WithOrder("name")))
+		assert.Equal(prefix+"-a", first.Name)
+
+		// or to a slice of resources
+		var found []*dbtest.TestUser
+		require.NoError(w.LookupWhere(context.Background(), &found, where, args, dbw.WithOrder("name")))
+		require.Len(found, 1)
+		assert.Equal(prefix+"-a", found[0].Name)
+
+		// but a limit provided to the lookup does
+		found = nil
+		require.NoError(w.LookupWhere(context.Background(), &found, where, args, dbw.WithLimit(2), dbw.WithOrder("name")))
+		assert.Len(found, 2)
+	})
 }
 
 func TestDb_SearchWhere(t *testing.T) {
 	t.Parallel()
-	conn, _ := dbw.TestSetup(t)
+	conn, url := dbw.TestSetup(t)
 	testRw := dbw.New(conn)
 	knownUser := testUser(t, testRw, "zedUser", "", "")
 
@@ -353,6 +574,706 @@ func TestDb_SearchWhere(t *testing.T) {
 			})
 		}
 	})
+	t.Run("reject-full-scans", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		testCtx := context.Background()
+		dbType, _, err := conn.DbType()
+		require.NoError(err)
+		rejectConn, err := dbw.Open(dbType, url, dbw.WithRejectFullScans(true))
+		require.NoError(err)
+		dbw.TestCreateTables(t, rejectConn)
+		rw := dbw.New(rejectConn)
+		testUser(t, rw, "reject-full-scans", "", "")
+
+		var foundUsers []*dbtest.TestUser
+		err = rw.SearchWhere(testCtx, &foundUsers, "", nil, dbw.WithLimit(-1))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrUnsafeQuery)
+		assert.Empty(foundUsers)
+
+		err = rw.SearchWhere(testCtx, &foundUsers, "", nil, dbw.WithLimit(-1), dbw.WithAllowFullScan(true))
+		require.NoError(err)
+		assert.NotEmpty(foundUsers)
+
+		foundUsers = nil
+		err = rw.SearchWhere(testCtx, &foundUsers, "1=1", nil, dbw.WithLimit(-1))
+		require.NoError(err)
+		assert.NotEmpty(foundUsers)
+
+		foundUsers = nil
+		err = rw.SearchWhere(testCtx, &foundUsers, "", nil)
+		require.NoError(err)
+		assert.NotEmpty(foundUsers)
+
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		defer func() { _ = tx.Rollback(testCtx) }()
+		err = tx.SearchWhere(testCtx, &foundUsers, "", nil, dbw.WithLimit(-1))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrUnsafeQuery)
+	})
+	t.Run("full-scans-allowed-by-default", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(context.Background(), &foundUsers, "", nil, dbw.WithLimit(-1))
+		require.NoError(err)
+		assert.NotEmpty(foundUsers)
+	})
+	t.Run("window-count", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		testCtx := context.Background()
+		for i := 0; i < 5; i++ {
+			testUser(t, testRw, "window-count-"+strconv.Itoa(i), "window-count@example.com", "")
+		}
+		const where = "email = ?"
+		args := []interface{}{"window-count@example.com"}
+
+		var count int64
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, where, args, dbw.WithLimit(2), dbw.WithOrder("name asc"), dbw.WithWindowCount(&count))
+		require.NoError(err)
+		assert.Equal(int64(5), count)
+		require.Len(foundUsers, 2)
+		assert.Equal("window-count-0", foundUsers[0].Name)
+		assert.Equal("window-count-1", foundUsers[1].Name)
+		assert.NotEmpty(foundUsers[0].PublicId)
+		assert.Equal("window-count@example.com", foundUsers[0].Email)
+
+		foundUsers = nil
+		err = testRw.SearchWhere(testCtx, &foundUsers, where, args, dbw.WithLimit(3), dbw.WithWindowCount(&count), dbw.WithExcludeColumns([]string{"email"}))
+		require.NoError(err)
+		assert.Equal(int64(5), count)
+		require.Len(foundUsers, 3)
+		assert.Empty(foundUsers[0].Email)
+		assert.NotEmpty(foundUsers[0].Name)
+
+		count = 42
+		foundUsers = nil
+		err = testRw.SearchWhere(testCtx, &foundUsers, where, []interface{}{"not-found@example.com"}, dbw.WithWindowCount(&count))
+		require.NoError(err)
+		assert.Equal(int64(0), count)
+		assert.Empty(foundUsers)
+	})
+	t.Run("distinct-on", func(t *testing.T) {
+		testCtx := context.Background()
+		testUser(t, testRw, "distinct-on-1", "distinct-on@example.com", "")
+		u2 := testUser(t, testRw, "distinct-on-2", "distinct-on@example.com", "")
+		where, args := "email = ?", []interface{}{"distinct-on@example.com"}
+
+		dbType, _, err := conn.DbType()
+		require.NoError(t, err)
+		switch dbType {
+		case dbw.Postgres, dbw.CockroachDB:
+			assert, require := assert.New(t), require.New(t)
+			var foundUsers []*dbtest.TestUser
+			err := testRw.SearchWhere(testCtx, &foundUsers, where, args, dbw.WithDistinctOn([]string{"email"}), dbw.WithOrder("email, name desc"))
+			require.NoError(err)
+			require.Len(foundUsers, 1)
+			assert.Equal(u2.PublicId, foundUsers[0].PublicId)
+		default:
+			assert, require := assert.New(t), require.New(t)
+			var foundUsers []*dbtest.TestUser
+			err := testRw.SearchWhere(testCtx, &foundUsers, where, args, dbw.WithDistinctOn([]string{"email"}), dbw.WithOrder("email, name desc"))
+			require.Error(err)
+			assert.ErrorIs(err, dbw.ErrInvalidParameter)
+			assert.Contains(err.Error(), "distinct on is not supported by")
+			assert.Empty(foundUsers)
+		}
+
+		tests := []struct {
+			name            string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"unknown-column", []dbw.Option{dbw.WithDistinctOn([]string{"not_a_column"}), dbw.WithOrder("not_a_column")}, "distinct on column not_a_column does not exist"},
+			{"missing-order", []dbw.Option{dbw.WithDistinctOn([]string{"email"})}, "must match the leading columns of the order"},
+			{"mismatched-order", []dbw.Option{dbw.WithDistinctOn([]string{"email"}), dbw.WithOrder("name, email")}, "must match the leading columns of the order"},
+			{"with-window-count", []dbw.Option{dbw.WithDistinctOn([]string{"email"}), dbw.WithOrder("email"), dbw.WithWindowCount(new(int64))}, "cannot be used with a window count"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var foundUsers []*dbtest.TestUser
+				err := testRw.SearchWhere(testCtx, &foundUsers, where, args, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+	t.Run("exclude-columns", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		testCtx := context.Background()
+		u := testUser(t, testRw, "exclude-columns", "exclude-columns@example.com", "555-555-5555")
+
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, "public_id = ?", []interface{}{u.PublicId}, dbw.WithExcludeColumns([]string{"email", "phone_number"}))
+		require.NoError(err)
+		require.Len(foundUsers, 1)
+		assert.Equal(u.PublicId, foundUsers[0].PublicId)
+		assert.Equal(u.Name, foundUsers[0].Name)
+		assert.Empty(foundUsers[0].Email)
+		assert.Empty(foundUsers[0].PhoneNumber)
+
+		foundUsers = nil
+		err = testRw.SearchWhere(testCtx, &foundUsers, "public_id = ?", []interface{}{u.PublicId}, dbw.WithExcludeColumns([]string{"not_a_column"}))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "excluded column not_a_column does not exist")
+		assert.Empty(foundUsers)
+	})
+	t.Run("append-results", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		testCtx := context.Background()
+		alice := testUser(t, testRw, "append-results-alice", "", "")
+		bob := testUser(t, testRw, "append-results-bob", "", "")
+
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, "public_id = ?", []interface{}{alice.PublicId}, dbw.WithAppendResults(true))
+		require.NoError(err)
+		require.Len(foundUsers, 1)
+		err = testRw.SearchWhere(testCtx, &foundUsers, "public_id = ?", []interface{}{bob.PublicId}, dbw.WithAppendResults(true))
+		require.NoError(err)
+		require.Len(foundUsers, 2)
+		assert.Equal(alice.PublicId, foundUsers[0].PublicId)
+		assert.Equal(bob.PublicId, foundUsers[1].PublicId)
+
+		// without append, the results are replaced
+		err = testRw.SearchWhere(testCtx, &foundUsers, "public_id = ?", []interface{}{bob.PublicId})
+		require.NoError(err)
+		require.Len(foundUsers, 1)
+		assert.Equal(bob.PublicId, foundUsers[0].PublicId)
+
+		// the existing results are left unchanged on error
+		err = testRw.SearchWhere(testCtx, &foundUsers, "bad_column_name = ?", []interface{}{1}, dbw.WithAppendResults(true))
+		require.Error(err)
+		require.Len(foundUsers, 1)
+
+		var user dbtest.TestUser
+		err = testRw.SearchWhere(testCtx, &user, "public_id = ?", []interface{}{bob.PublicId}, dbw.WithAppendResults(true))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+	})
+	t.Run("gorm-clauses", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		testCtx := context.Background()
+		for i := 0; i < 3; i++ {
+			testUser(t, testRw, "gorm-clauses-"+strconv.Itoa(i), "", "")
+		}
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, "name like ?", []interface{}{"gorm-clauses-%"},
+			dbw.WithGormClauses(clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "name"}, Desc: true}}}),
+		)
+		require.NoError(err)
+		require.Len(foundUsers, 3)
+		assert.Equal("gorm-clauses-2", foundUsers[0].Name)
+		assert.Equal("gorm-clauses-0", foundUsers[2].Name)
+
+		foundUsers = nil
+		err = testRw.SearchWhere(testCtx, &foundUsers, "name like ?", []interface{}{"gorm-clauses-%"}, dbw.WithGormClauses(nil))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Empty(foundUsers)
+	})
+}
+
+func TestDb_WithResultTransformer(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	for i := 0; i < 3; i++ {
+		testUser(t, testRw, "transformer-user-"+strconv.Itoa(i), "", "")
+	}
+	upperName := func(i interface{}) error {
+		u, ok := i.(*dbtest.TestUser)
+		if !ok {
+			return fmt.Errorf("unexpected type %T", i)
+		}
+		u.Name = strings.ToUpper(u.Name)
+		return nil
+	}
+	transformErr := errors.New("transform error")
+	failTransform := func(interface{}) error { return transformErr }
+
+	t.Run("search-where", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, "name like ?", []interface{}{"transformer-user-%"}, dbw.WithOrder("name asc"), dbw.WithResultTransformer(upperName))
+		require.NoError(err)
+		require.Len(foundUsers, 3)
+		for i, u := range foundUsers {
+			assert.Equal("TRANSFORMER-USER-"+strconv.Itoa(i), u.Name)
+		}
+	})
+	t.Run("search-where-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, "name like ?", []interface{}{"transformer-user-%"}, dbw.WithResultTransformer(failTransform))
+		require.Error(err)
+		assert.ErrorIs(err, transformErr)
+	})
+	t.Run("lookup-where", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var foundUser dbtest.TestUser
+		err := testRw.LookupWhere(testCtx, &foundUser, "name = ?", []interface{}{"transformer-user-0"}, dbw.WithResultTransformer(upperName))
+		require.NoError(err)
+		assert.Equal("TRANSFORMER-USER-0", foundUser.Name)
+	})
+	t.Run("lookup-where-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var foundUser dbtest.TestUser
+		err := testRw.LookupWhere(testCtx, &foundUser, "name = ?", []interface{}{"transformer-user-0"}, dbw.WithResultTransformer(failTransform))
+		require.Error(err)
+		assert.ErrorIs(err, transformErr)
+	})
+	t.Run("not-found", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		called := false
+		var foundUsers []*dbtest.TestUser
+		err := testRw.SearchWhere(testCtx, &foundUsers, "name = ?", []interface{}{"not-found"}, dbw.WithResultTransformer(func(interface{}) error { called = true; return nil }))
+		require.NoError(err)
+		assert.Empty(foundUsers)
+		assert.False(called)
+	})
+}
+
+func TestDb_Exists(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	knownUser := testUser(t, testRw, "exists-user", "exists@example.com", "")
+
+	tests := []struct {
+		name            string
+		rw              *dbw.RW
+		resource        interface{}
+		where           string
+		args            []interface{}
+		opt             []dbw.Option
+		want            bool
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{
+			name:     "exists",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "public_id = ? and email = ?",
+			args:     []interface{}{knownUser.PublicId, knownUser.Email},
+			want:     true,
+		},
+		{
+			name:     "not-exists",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "public_id = ? and email = ?",
+			args:     []interface{}{knownUser.PublicId, "not-found@example.com"},
+			want:     false,
+		},
+		{
+			name:     "no-where",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			want:     true,
+		},
+		{
+			name:     "with-table",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "public_id = ?",
+			args:     []interface{}{knownUser.PublicId},
+			opt:      []dbw.Option{dbw.WithTable(knownUser.TableName())},
+			want:     true,
+		},
+		{
+			name:     "slice-resource",
+			rw:       testRw,
+			resource: &[]*dbtest.TestUser{},
+			where:    "public_id = ?",
+			args:     []interface{}{knownUser.PublicId},
+			want:     true,
+		},
+		{
+			name:            "nil-underlying",
+			rw:              &dbw.RW{},
+			resource:        &dbtest.TestUser{},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing underlying db",
+		},
+		{
+			name:            "no-where-with-args",
+			rw:              testRw,
+			resource:        &dbtest.TestUser{},
+			args:            []interface{}{knownUser.PublicId},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "args provided with empty where",
+		},
+		{
+			name:            "not-a-ptr",
+			rw:              testRw,
+			resource:        dbtest.TestUser{},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "interface parameter must to be a pointer",
+		},
+		{
+			name:     "bad-where",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "bad_column_name = ?",
+			args:     []interface{}{knownUser.PublicId},
+			wantErr:  true,
+		},
+		{
+			name:     "bad-table",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			opt:      []dbw.Option{dbw.WithTable("invalid_table_name")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := tt.rw.Exists(testCtx, tt.resource, tt.where, tt.args, tt.opt...)
+			if tt.wantErr {
+				require.Error(err)
+				assert.False(got)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(err, tt.wantErrIs)
+				}
+				if tt.wantErrContains != "" {
+					assert.Contains(err.Error(), tt.wantErrContains)
+				}
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+	t.Run("select-1-limit-1", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mDb, mock := dbw.TestSetupWithMock(t)
+		mock.ExpectQuery(`SELECT 1 FROM "db_test_user" WHERE public_id = \$1 LIMIT \$2`).
+			WithArgs(knownUser.PublicId, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+		mock.ExpectQuery(`SELECT 1 FROM "db_test_user" WHERE public_id = \$1 LIMIT \$2`).
+			WithArgs("not-found", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+		mRw := dbw.New(mDb)
+		found, err := mRw.Exists(testCtx, &dbtest.TestUser{}, "public_id = ?", []interface{}{knownUser.PublicId})
+		require.NoError(err)
+		assert.True(found)
+		found, err = mRw.Exists(testCtx, &dbtest.TestUser{}, "public_id = ?", []interface{}{"not-found"})
+		require.NoError(err)
+		assert.False(found)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+}
+
+func TestDb_Count(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	for i := 1; i <= 3; i++ {
+		testUser(t, testRw, "count-user-"+strconv.Itoa(i), "count-user@example.com", "")
+	}
+
+	tests := []struct {
+		name            string
+		rw              *dbw.RW
+		resource        interface{}
+		where           string
+		args            []interface{}
+		opt             []dbw.Option
+		want            int64
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{
+			name:     "matches",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "email = ?",
+			args:     []interface{}{"count-user@example.com"},
+			want:     3,
+		},
+		{
+			name:     "no-matches",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "email = ?",
+			args:     []interface{}{"not-found@example.com"},
+			want:     0,
+		},
+		{
+			name:     "no-where",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			want:     3,
+		},
+		{
+			name:     "with-table",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "name = ?",
+			args:     []interface{}{"count-user-1"},
+			opt:      []dbw.Option{dbw.WithTable((&dbtest.TestUser{}).TableName())},
+			want:     1,
+		},
+		{
+			name:     "slice-resource",
+			rw:       testRw,
+			resource: &[]*dbtest.TestUser{},
+			where:    "name like ?",
+			args:     []interface{}{"count-user-%"},
+			want:     3,
+		},
+		{
+			name:            "nil-underlying",
+			rw:              &dbw.RW{},
+			resource:        &dbtest.TestUser{},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing underlying db",
+		},
+		{
+			name:            "no-where-with-args",
+			rw:              testRw,
+			resource:        &dbtest.TestUser{},
+			args:            []interface{}{"count-user-1"},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "args provided with empty where",
+		},
+		{
+			name:            "not-a-ptr",
+			rw:              testRw,
+			resource:        dbtest.TestUser{},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "interface parameter must to be a pointer",
+		},
+		{
+			name:            "hooks",
+			rw:              testRw,
+			resource:        &dbtest.TestWithAfterFind{},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "gorm callback/hooks are not supported",
+		},
+		{
+			name:     "bad-where",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			where:    "bad_column_name = ?",
+			args:     []interface{}{"count-user-1"},
+			wantErr:  true,
+		},
+		{
+			name:     "bad-table",
+			rw:       testRw,
+			resource: &dbtest.TestUser{},
+			opt:      []dbw.Option{dbw.WithTable("invalid_table_name")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			got, err := tt.rw.Count(testCtx, tt.resource, tt.where, tt.args, tt.opt...)
+			if tt.wantErr {
+				require.Error(err)
+				assert.Zero(got)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(err, tt.wantErrIs)
+				}
+				if tt.wantErrContains != "" {
+					assert.Contains(err.Error(), tt.wantErrContains)
+				}
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestDb_SearchWithCTE(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	for i := 1; i <= 5; i++ {
+		testUser(t, testRw, "cte-user-"+strconv.Itoa(i), "cte-user-"+strconv.Itoa(i)+"@example.com", "")
+	}
+	// nums is a recursive cte which generates the numbers 1 through 3
+	nums := dbw.Expr("select 1 as n union all select n + 1 from nums where n < ?", 3)
+
+	tests := []struct {
+		name            string
+		rw              *dbw.RW
+		resources       interface{}
+		ctes            map[string]dbw.ExprValue
+		where           string
+		args            []interface{}
+		opt             []dbw.Option
+		wantNames       []string
+		wantErr         bool
+		wantErrIs       error
+		wantErrContains string
+	}{
+		{
+			name:      "recursive",
+			rw:        testRw,
+			resources: &[]*dbtest.TestUser{},
+			ctes:      map[string]dbw.ExprValue{"nums": nums},
+			where:     "name in (select 'cte-user-' || n from nums)",
+			opt:       []dbw.Option{dbw.WithOrder("name asc")},
+			wantNames: []string{"cte-user-1", "cte-user-2", "cte-user-3"},
+		},
+		{
+			name:      "multiple-ctes",
+			rw:        testRw,
+			resources: &[]*dbtest.TestUser{},
+			ctes: map[string]dbw.ExprValue{
+				"nums":      nums,
+				"cte_users": dbw.Expr("select public_id from db_test_user where email like ?", "cte-user-%"),
+			},
+			where:     "public_id in (select public_id from cte_users) and name not in (select 'cte-user-' || n from nums)",
+			opt:       []dbw.Option{dbw.WithOrder("name desc"), dbw.WithLimit(1)},
+			wantNames: []string{"cte-user-5"},
+		},
+		{
+			name:      "with-args",
+			rw:        testRw,
+			resources: &[]*dbtest.TestUser{},
+			ctes:      map[string]dbw.ExprValue{"nums": nums},
+			where:     "name in (select 'cte-user-' || n from nums) and name <> ?",
+			args:      []interface{}{"cte-user-2"},
+			opt:       []dbw.Option{dbw.WithOrder("name asc")},
+			wantNames: []string{"cte-user-1", "cte-user-3"},
+		},
+		{
+			name:            "nil-underlying",
+			rw:              &dbw.RW{},
+			resources:       &[]*dbtest.TestUser{},
+			ctes:            map[string]dbw.ExprValue{"nums": nums},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing underlying db",
+		},
+		{
+			name:            "missing-ctes",
+			rw:              testRw,
+			resources:       &[]*dbtest.TestUser{},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing common table expressions",
+		},
+		{
+			name:            "invalid-name",
+			rw:              testRw,
+			resources:       &[]*dbtest.TestUser{},
+			ctes:            map[string]dbw.ExprValue{"nums; drop table db_test_user": nums},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "invalid common table expression name",
+		},
+		{
+			name:            "missing-sql",
+			rw:              testRw,
+			resources:       &[]*dbtest.TestUser{},
+			ctes:            map[string]dbw.ExprValue{"nums": dbw.Expr(" ")},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "missing sql for common table expression",
+		},
+		{
+			name:            "no-where-with-args",
+			rw:              testRw,
+			resources:       &[]*dbtest.TestUser{},
+			ctes:            map[string]dbw.ExprValue{"nums": nums},
+			args:            []interface{}{"cte-user-1"},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "args provided with empty where",
+		},
+		{
+			name:            "not-a-ptr",
+			rw:              testRw,
+			resources:       []*dbtest.TestUser{},
+			ctes:            map[string]dbw.ExprValue{"nums": nums},
+			wantErr:         true,
+			wantErrIs:       dbw.ErrInvalidParameter,
+			wantErrContains: "interface parameter must to be a pointer",
+		},
+		{
+			name:      "bad-cte",
+			rw:        testRw,
+			resources: &[]*dbtest.TestUser{},
+			ctes:      map[string]dbw.ExprValue{"nums": dbw.Expr("select bad_column_name from db_test_user")},
+			where:     "name in (select 'cte-user-' || n from nums)",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			err := tt.rw.SearchWithCTE(testCtx, tt.resources, tt.ctes, tt.where, tt.args, tt.opt...)
+			if tt.wantErr {
+				require.Error(err)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(err, tt.wantErrIs)
+				}
+				if tt.wantErrContains != "" {
+					assert.Contains(err.Error(), tt.wantErrContains)
+				}
+				return
+			}
+			require.NoError(err)
+			found := *tt.resources.(*[]*dbtest.TestUser)
+			gotNames := make([]string, 0, len(found))
+			for _, u := range found {
+				gotNames = append(gotNames, u.Name)
+			}
+			assert.Equal(tt.wantNames, gotNames)
+		})
+	}
+	t.Run("unsupported-options", func(t *testing.T) {
+		var count int64
+		for name, opt := range map[string]dbw.Option{
+			"with append results":  dbw.WithAppendResults(true),
+			"with window count":    dbw.WithWindowCount(&count),
+			"with exclude columns": dbw.WithExcludeColumns([]string{"email"}),
+			"with gorm clauses":    dbw.WithGormClauses(clause.Where{}),
+			"with distinct on":     dbw.WithDistinctOn([]string{"name"}),
+			"with row lock":        dbw.WithRowLock(dbw.ForUpdate),
+			"with index predicate": dbw.WithIndexPredicate("email is not null"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var found []*dbtest.TestUser
+				err := testRw.SearchWithCTE(testCtx, &found, map[string]dbw.ExprValue{"nums": nums}, "", nil, opt)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), name+" is not a supported option")
+			})
+		}
+
+		// unsupported default options are ignored
+		conn, _ := dbw.TestSetup(t)
+		conn.SetDefaultOptions(dbw.WithExcludeColumns([]string{"email"}))
+		var found []*dbtest.TestUser
+		require.NoError(t, dbw.New(conn).SearchWithCTE(testCtx, &found, map[string]dbw.ExprValue{"nums": nums}, "", nil))
+	})
 }
 
 func TestRW_IsTx(t *testing.T) {
@@ -363,11 +1284,22 @@ func TestRW_IsTx(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
 
 	assert.False(testRw.IsTx())
+	assert.False(testRw.InTransaction())
+	assert.False((&dbw.RW{}).InTransaction())
 
 	tx, err := testRw.Begin(testCtx)
 	require.NoError(err)
 	assert.NotNil(tx)
 	assert.True(tx.IsTx())
+	assert.True(tx.InTransaction())
+	require.NoError(tx.Rollback(testCtx))
+
+	_, err = testRw.DoTx(testCtx, func(error) bool { return false }, 0, dbw.ExpBackoff{}, func(r dbw.Reader, w dbw.Writer) error {
+		assert.True(r.(*dbw.RW).InTransaction())
+		assert.True(w.(*dbw.RW).InTransaction())
+		return nil
+	})
+	require.NoError(err)
 }
 
 func TestDialect(t *testing.T) {
@@ -437,3 +1369,339 @@ func testRental(t *testing.T, rw *dbw.RW, userId, carId string) *dbtest.TestRent
 	}
 	return r
 }
+
+type testTenantKey struct{}
+
+// testTenantModel is routed to a table per tenant by a table resolver.
+type testTenantModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Name     string `gorm:"default:null"`
+}
+
+func (*testTenantModel) TableName() string { return "db_test_tenant" }
+
+func TestDb_TableResolver(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	resolver := func(ctx context.Context, defaultName string) string {
+		tenant, _ := ctx.Value(testTenantKey{}).(string)
+		if tenant == "" {
+			return ""
+		}
+		return defaultName + "_" + tenant
+	}
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithTableResolver(resolver))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	testRw := dbw.New(conn)
+	for _, table := range []string{"db_test_tenant", "db_test_tenant_a", "db_test_tenant_b"} {
+		_, err := testRw.Exec(testCtx, fmt.Sprintf("create table %s (public_id text primary key, name text)", table), nil)
+		require.NoError(t, err)
+	}
+	tenantCtx := func(tenant string) context.Context {
+		return context.WithValue(testCtx, testTenantKey{}, tenant)
+	}
+	countRows := func(t *testing.T, table string) int {
+		t.Helper()
+		rows, err := testRw.Query(testCtx, fmt.Sprintf("select count(*) from %s", table), nil)
+		require.NoError(t, err)
+		defer rows.Close()
+		var count int
+		require.True(t, rows.Next())
+		require.NoError(t, rows.Scan(&count))
+		return count
+	}
+	newModel := func(t *testing.T, name string) *testTenantModel {
+		t.Helper()
+		id, err := dbw.NewId("t")
+		require.NoError(t, err)
+		return &testTenantModel{PublicId: id, Name: name}
+	}
+
+	t.Run("tenant-tables", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		ctxA, ctxB := tenantCtx("a"), tenantCtx("b")
+		a, b := newModel(t, "alice"), newModel(t, "bob")
+		require.NoError(testRw.Create(ctxA, a))
+		require.NoError(testRw.CreateItems(ctxB, []*testTenantModel{b}))
+		assert.Equal(1, countRows(t, "db_test_tenant_a"))
+		assert.Equal(1, countRows(t, "db_test_tenant_b"))
+		assert.Equal(0, countRows(t, "db_test_tenant"))
+
+		found := &testTenantModel{PublicId: a.PublicId}
+		require.NoError(testRw.LookupBy(ctxA, found))
+		assert.Equal("alice", found.Name)
+		err := testRw.LookupBy(ctxB, &testTenantModel{PublicId: a.PublicId})
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		var foundModels []*testTenantModel
+		require.NoError(testRw.SearchWhere(ctxB, &foundModels, "", nil))
+		require.Len(foundModels, 1)
+		assert.Equal(b.PublicId, foundModels[0].PublicId)
+
+		exists, err := testRw.Exists(ctxA, &testTenantModel{}, "name = ?", []interface{}{"bob"})
+		require.NoError(err)
+		assert.False(exists)
+
+		a.Name = "alice-updated"
+		rowsUpdated, err := testRw.Update(ctxA, a, []string{"Name"}, nil)
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+
+		rowsDeleted, err := testRw.Delete(ctxB, b)
+		require.NoError(err)
+		assert.Equal(1, rowsDeleted)
+		assert.Equal(0, countRows(t, "db_test_tenant_b"))
+	})
+	t.Run("default-table", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		m := newModel(t, "default")
+		require.NoError(testRw.Create(testCtx, m))
+		assert.Equal(1, countRows(t, "db_test_tenant"))
+		require.NoError(testRw.LookupBy(testCtx, &testTenantModel{PublicId: m.PublicId}))
+	})
+	t.Run("with-table-precedence", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		before := countRows(t, "db_test_tenant_b")
+		m := newModel(t, "explicit")
+		require.NoError(testRw.Create(tenantCtx("a"), m, dbw.WithTable("db_test_tenant_b")))
+		assert.Equal(before+1, countRows(t, "db_test_tenant_b"))
+	})
+}
+
+// testNullableModel has database/sql Null* fields for its nullable columns.
+type testNullableModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Email    sql.NullString
+	Age      sql.NullInt64
+}
+
+func (*testNullableModel) TableName() string { return "db_test_nullable" }
+
+func TestDb_SearchWhere_NullTypes(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	testRw := dbw.New(conn)
+	_, err = testRw.Exec(testCtx, "create table db_test_nullable (public_id text primary key, email text, age integer)", nil)
+	require.NoError(t, err)
+	require.NoError(t, testRw.CreateItems(testCtx, []*testNullableModel{
+		{PublicId: "1", Email: sql.NullString{String: "alice@example.com", Valid: true}, Age: sql.NullInt64{Int64: 42, Valid: true}},
+		{PublicId: "2"},
+		{PublicId: "3", Email: sql.NullString{String: "", Valid: true}},
+	}))
+
+	t.Run("search", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*testNullableModel
+		require.NoError(testRw.SearchWhere(testCtx, &found, "1=1", nil, dbw.WithOrder("public_id")))
+		assert.Equal([]*testNullableModel{
+			{PublicId: "1", Email: sql.NullString{String: "alice@example.com", Valid: true}, Age: sql.NullInt64{Int64: 42, Valid: true}},
+			{PublicId: "2"},
+			// an empty string is not NULL
+			{PublicId: "3", Email: sql.NullString{String: "", Valid: true}},
+		}, found)
+	})
+	t.Run("search-null", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*testNullableModel
+		require.NoError(testRw.SearchWhere(testCtx, &found, "email is null", nil))
+		require.Len(found, 1)
+		assert.Equal("2", found[0].PublicId)
+		assert.False(found[0].Email.Valid)
+		assert.False(found[0].Age.Valid)
+	})
+	t.Run("lookup", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		found := &testNullableModel{PublicId: "2"}
+		require.NoError(testRw.LookupBy(testCtx, found))
+		assert.False(found.Email.Valid)
+		assert.False(found.Age.Valid)
+
+		found = &testNullableModel{PublicId: "1"}
+		require.NoError(testRw.LookupBy(testCtx, found))
+		assert.Equal(sql.NullString{String: "alice@example.com", Valid: true}, found.Email)
+		assert.Equal(sql.NullInt64{Int64: 42, Valid: true}, found.Age)
+	})
+}
+
+// testRentalWithUser is a composite search result of a rental joined to its
+// user, whose columns are aliased with the "user__" prefix of the embedded
+// User.
+type testRentalWithUser struct {
+	UserId string
+	CarId  string
+	Name   string
+	User   *dbtest.StoreTestUser `gorm:"embedded;embeddedPrefix:user__"`
+}
+
+func (*testRentalWithUser) TableName() string { return "db_test_rental" }
+
+func TestDb_SearchWhere_EmbeddedStruct(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	user := testUser(t, testRw, "embedded-alice", "alice@example.com", "")
+	rental, err := dbtest.NewTestRental(user.PublicId, testCar(t, testRw).PublicId)
+	require.NoError(t, err)
+	rental.Name = "embedded-rental"
+	require.NoError(t, testRw.Create(testCtx, rental))
+	_ = testRental(t, testRw, testUser(t, testRw, "embedded-bob", "", "").PublicId, testCar(t, testRw).PublicId)
+
+	joinUsers := dbw.WithGormClauses(
+		clause.Select{Expression: clause.Expr{SQL: "db_test_rental.user_id, db_test_rental.car_id, db_test_rental.name, " +
+			"u.public_id as user__public_id, u.name as user__name, u.email as user__email"}},
+		clause.From{
+			Tables: []clause.Table{{Name: "db_test_rental"}},
+			Joins: []clause.Join{{
+				Type:  clause.InnerJoin,
+				Table: clause.Table{Name: "db_test_user", Alias: "u"},
+				ON:    clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "u.public_id = db_test_rental.user_id"}}},
+			}},
+		},
+	)
+
+	t.Run("search", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*testRentalWithUser
+		require.NoError(testRw.SearchWhere(testCtx, &found, "u.name = ?", []interface{}{"embedded-alice"}, joinUsers))
+		require.Len(found, 1)
+		assert.Equal(rental.UserId, found[0].UserId)
+		assert.Equal(rental.CarId, found[0].CarId)
+		assert.Equal("embedded-rental", found[0].Name)
+		require.NotNil(found[0].User)
+		assert.Equal(user.PublicId, found[0].User.PublicId)
+		assert.Equal("embedded-alice", found[0].User.Name)
+		assert.Equal("alice@example.com", found[0].User.Email)
+	})
+	t.Run("lookup", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found testRentalWithUser
+		require.NoError(testRw.LookupWhere(testCtx, &found, "db_test_rental.name = ?", []interface{}{"embedded-rental"}, joinUsers))
+		require.NotNil(found.User)
+		assert.Equal("embedded-alice", found.User.Name)
+	})
+}
+
+// testReportingEvent is stored in a table of the "reporting" schema.
+type testReportingEvent struct {
+	PublicId string `gorm:"primaryKey"`
+	UserId   string
+}
+
+func (*testReportingEvent) TableName() string { return "events" }
+
+func TestDb_SearchWhere_SchemaQualifiedTable(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	// attached sqlite databases are per connection, so a single connection is
+	// used for the "reporting" schema
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithMaxOpenConnections(1))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	testRw := dbw.New(conn)
+	for _, sql := range []string{
+		"attach database ':memory:' as reporting",
+		"create table reporting.events (public_id text primary key, user_id text not null)",
+		"create table users (public_id text primary key, name text not null)",
+	} {
+		_, err := testRw.Exec(testCtx, sql, nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, testRw.CreateItems(testCtx, []*testReportingEvent{
+		{PublicId: "e1", UserId: "u1"},
+		{PublicId: "e2", UserId: "u2"},
+	}, dbw.WithTable("reporting.events")))
+	_, err = testRw.Exec(testCtx, "insert into users (public_id, name) values ('u1', 'alice'), ('u2', 'bob')", nil)
+	require.NoError(t, err)
+
+	t.Run("cross-schema", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*testReportingEvent
+		err := testRw.SearchWhere(testCtx, &found,
+			"user_id in (select public_id from main.users where name = ?)", []interface{}{"alice"},
+			dbw.WithTable("reporting.events"),
+		)
+		require.NoError(err)
+		require.Len(found, 1)
+		assert.Equal("e1", found[0].PublicId)
+
+		exists, err := testRw.Exists(testCtx, &testReportingEvent{}, "user_id = ?", []interface{}{"u2"}, dbw.WithTable("reporting.events"))
+		require.NoError(err)
+		assert.True(exists)
+	})
+	t.Run("invalid-table-names", func(t *testing.T) {
+		for _, name := range []string{
+			"foo; drop table bar",
+			"events where 1=1 --",
+			"reporting.events.extra",
+			`"events"`,
+			"(select * from users)",
+			"reporting .events",
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var found []*testReportingEvent
+				err := testRw.SearchWhere(testCtx, &found, "", nil, dbw.WithTable(name))
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), "invalid table name")
+			})
+		}
+	})
+}
+
+func TestDb_HyphenatedTable(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithMaxOpenConnections(1))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	testRw := dbw.New(conn)
+	_, err = testRw.Exec(testCtx, "create table `test-events` (public_id text primary key, user_id text not null unique)", nil)
+	require.NoError(t, err)
+	table := dbw.WithTable("test-events")
+	search := func(t *testing.T) []*testReportingEvent {
+		t.Helper()
+		var found []*testReportingEvent
+		require.NoError(t, testRw.SearchWhere(testCtx, &found, "", nil, table, dbw.WithOrder("public_id")))
+		return found
+	}
+
+	assert, require := assert.New(t), require.New(t)
+	require.NoError(testRw.Create(testCtx, &testReportingEvent{PublicId: "e1", UserId: "u1"}, table))
+	require.NoError(testRw.CreateItems(testCtx, []*testReportingEvent{
+		{PublicId: "e2", UserId: "u2"},
+		{PublicId: "e3", UserId: "u3"},
+		{PublicId: "e4", UserId: "u4"},
+	}, table))
+	assert.Len(search(t), 4)
+
+	// the existing row with the same user_id is deleted
+	require.NoError(testRw.Create(testCtx, &testReportingEvent{PublicId: "e5", UserId: "u1"}, table,
+		dbw.WithOnConflict(&dbw.OnConflict{Target: dbw.Columns{"user_id"}, Action: dbw.DeleteExisting(true)}),
+	))
+
+	results, err := testRw.UpsertItems(testCtx, []interface{}{
+		&testReportingEvent{PublicId: "e2", UserId: "u2"},
+		&testReportingEvent{PublicId: "e6", UserId: "u6"},
+	}, dbw.OnConflict{Target: dbw.Columns{"public_id"}, Action: dbw.UpdateAll(true)}, table)
+	require.NoError(err)
+	assert.Len(results, 2)
+
+	deleted, err := testRw.DeleteByPublicIds(testCtx, &testReportingEvent{}, []string{"e2", "e3"}, table)
+	require.NoError(err)
+	assert.Equal(2, deleted)
+
+	deleted, err = testRw.PurgeWhere(testCtx, &testReportingEvent{}, "user_id = ?", []interface{}{"u4"}, 10, nil, table)
+	require.NoError(err)
+	assert.Equal(1, deleted)
+
+	found := search(t)
+	require.Len(found, 2)
+	assert.Equal("e5", found[0].PublicId)
+	assert.Equal("e6", found[1].PublicId)
+}
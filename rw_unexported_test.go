@@ -5,10 +5,15 @@ package dbw
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
 )
 
 func TestRW_whereClausesFromOpts(t *testing.T) {
@@ -117,3 +122,189 @@ func Test_validateResourcesInterface(t *testing.T) {
 		})
 	}
 }
+
+type timeoutTestUser struct {
+	PublicId string `gorm:"primaryKey"`
+}
+
+func (*timeoutTestUser) TableName() string { return "db_test_user" }
+
+func TestRW_defaultTimeouts(t *testing.T) {
+	t.Parallel()
+	conn, _ := TestSetup(t)
+	withTimeouts := func(read, write time.Duration) *RW {
+		db := conn.clone(conn.wrapped)
+		db.readTimeout, db.writeTimeout = read, write
+		return New(db)
+	}
+	const expired = time.Nanosecond
+	t.Run("read-timeout", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := withTimeouts(expired, 0)
+		_, err := rw.Exists(context.Background(), &timeoutTestUser{}, "", nil)
+		require.Error(err)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+
+		var users []*timeoutTestUser
+		err = rw.SearchWhere(context.Background(), &users, "", nil)
+		require.Error(err)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+
+		// writes aren't affected by the read timeout
+		_, err = rw.Exec(context.Background(), "select 1", nil)
+		require.NoError(err)
+	})
+	t.Run("write-timeout", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := withTimeouts(0, expired)
+		_, err := rw.Exec(context.Background(), "select 1", nil)
+		require.Error(err)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+
+		// reads aren't affected by the write timeout
+		_, err = rw.Exists(context.Background(), &timeoutTestUser{}, "", nil)
+		require.NoError(err)
+	})
+	t.Run("ctx-deadline-not-overridden", func(t *testing.T) {
+		assert := assert.New(t)
+		rw := withTimeouts(expired, expired)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, err := rw.Exists(ctx, &timeoutTestUser{}, "", nil)
+		assert.NoError(err)
+		_, err = rw.Exec(ctx, "select 1", nil)
+		assert.NoError(err)
+	})
+}
+
+func Test_contextWithDefaultTimeout(t *testing.T) {
+	t.Parallel()
+	t.Run("no-timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx, cancel := contextWithDefaultTimeout(context.Background(), 0)
+		defer cancel()
+		_, ok := ctx.Deadline()
+		assert.False(ok)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx, cancel := contextWithDefaultTimeout(context.Background(), time.Minute)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		assert.True(ok)
+		assert.WithinDuration(time.Now().Add(time.Minute), deadline, 5*time.Second)
+	})
+	t.Run("existing-deadline", func(t *testing.T) {
+		assert := assert.New(t)
+		parent, parentCancel := context.WithTimeout(context.Background(), time.Hour)
+		defer parentCancel()
+		want, _ := parent.Deadline()
+		ctx, cancel := contextWithDefaultTimeout(parent, time.Minute)
+		defer cancel()
+		got, ok := ctx.Deadline()
+		assert.True(ok)
+		assert.Equal(want, got)
+	})
+}
+
+func Test_publicIdBatches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		ids       []string
+		batchSize int
+		want      [][]string
+	}{
+		{name: "empty", want: [][]string{}},
+		{name: "exact", ids: []string{"a", "b", "c", "d"}, batchSize: 2, want: [][]string{{"a", "b"}, {"c", "d"}}},
+		{name: "remainder", ids: []string{"a", "b", "c"}, batchSize: 2, want: [][]string{{"a", "b"}, {"c"}}},
+		{name: "duplicates", ids: []string{"a", "b", "a", "c", "b"}, batchSize: 2, want: [][]string{{"a", "b"}, {"c"}}},
+		{name: "default-batch-size", ids: []string{"a", "b"}, want: [][]string{{"a", "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, publicIdBatches(tt.ids, tt.batchSize))
+		})
+	}
+}
+
+func Test_renderedVars(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	valuer := sql.NullString{String: "alice", Valid: true}
+	got := renderedVars([]interface{}{[]byte("abc"), "bob", valuer, [2]int{1, 2}})
+	require.Len(got, 4)
+	assert.Equal(sliceVar{v: []byte("abc")}, got[0])
+	assert.Equal("bob", got[1])
+	assert.Equal(valuer, got[2])
+	assert.Equal(sliceVar{v: [2]int{1, 2}}, got[3])
+	v, err := got[0].(driver.Valuer).Value()
+	require.NoError(err)
+	assert.Equal([]byte("abc"), v)
+}
+
+func Test_orderByColumns(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		orderBy string
+		want    []string
+	}{
+		{name: "empty"},
+		{name: "single", orderBy: "name", want: []string{"name"}},
+		{name: "directions", orderBy: "name asc, create_time desc", want: []string{"name", "create_time"}},
+		{name: "qualified-quoted", orderBy: `db_test_user."name" desc nulls last`, want: []string{"name"}},
+		{name: "empty-items", orderBy: "name,,email", want: []string{"name", "email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, orderByColumns(tt.orderBy))
+		})
+	}
+}
+
+func TestRW_SearchWhere_distinctOn(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	type testEvent struct {
+		Id     int `gorm:"primaryKey"`
+		UserId string
+		Name   string
+	}
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db, err := openDialector(postgres.New(postgres.Config{Conn: sqlDB}), Postgres)
+	require.NoError(t, err)
+	rw := New(db)
+
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mock.ExpectQuery(`SELECT distinct on (user_id) * FROM "test_events" WHERE name like $1 ORDER BY user_id, id desc`).
+			WithArgs("login%").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name"}).AddRow(2, "u1", "login-2"))
+		var events []*testEvent
+		err := rw.SearchWhere(testCtx, &events, "name like ?", []interface{}{"login%"},
+			WithDistinctOn([]string{"user_id"}),
+			WithOrder("user_id, id desc"),
+			WithLimit(-1),
+		)
+		require.NoError(err)
+		assert.Equal([]*testEvent{{Id: 2, UserId: "u1", Name: "login-2"}}, events)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("with-exclude-columns", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		mock.ExpectQuery(`SELECT distinct on (user_id) id,"user_id" FROM "test_events" ORDER BY user_id`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, "u1"))
+		var events []*testEvent
+		err := rw.SearchWhere(testCtx, &events, "", nil,
+			WithDistinctOn([]string{"user_id"}),
+			WithOrder("user_id"),
+			WithExcludeColumns([]string{"name"}),
+			WithLimit(-1),
+		)
+		require.NoError(err)
+		assert.Equal([]*testEvent{{Id: 1, UserId: "u1"}}, events)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+}
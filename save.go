@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm/schema"
+)
+
+// Save will insert or update the resource, based on whether its primary key is
+// set, within a transaction (a transaction is started if the writer isn't
+// already in one).  Save returns the number of rows inserted or updated.
+//
+// When the primary key is zero, the resource is created (see: Create).  When
+// the primary key is set and it's an auto increment key, the resource already
+// exists and all of its updatable fields are written by an Update, which
+// returns ErrRecordNotFound when it doesn't exist.  When the primary key is
+// set and it's assigned by the caller or it's a composite key (ex: a public
+// id), the key doesn't indicate whether the resource exists, so Save issues an
+// upsert using a Create with an OnConflict on the primary key columns which
+// updates all the inserted columns (zero values of fields with a default are
+// not inserted, so they're not updated).
+//
+// The options are passed to Create or Update, except WithOnConflict which is
+// not a supported option.
+func (rw *RW) Save(ctx context.Context, i interface{}, opt ...Option) (int, error) {
+	const op = "dbw.Save"
+	switch {
+	case rw.underlying == nil:
+		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case isNil(i):
+		return noRowsAffected, fmt.Errorf("%s: missing interface: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(i); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	if opts.WithOnConflict != nil {
+		return noRowsAffected, fmt.Errorf("%s: with on conflict is not a supported option: %w", op, ErrInvalidParameter)
+	}
+	if rw.IsTx() {
+		return rw.save(ctx, i, opt...)
+	}
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	rowsAffected, err := tx.save(ctx, i, opt...)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+		}
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return rowsAffected, nil
+}
+
+// save dispatches to Create, Update or an upsert (see: Save).  The caller is
+// responsible for the transaction.
+func (rw *RW) save(ctx context.Context, i interface{}, opt ...Option) (int, error) {
+	const op = "dbw.save"
+	_, isZero, err := rw.primaryFieldsAreZero(ctx, i)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if isZero {
+		if err := rw.Create(ctx, i, opt...); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		return 1, nil
+	}
+	s, _, err := rw.parseSchema(i, rw.getOpts(opt...))
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if primaryKeyIsGenerated(s) {
+		rowsUpdated, err := rw.Update(ctx, i, updatableFieldNames(s), nil, opt...)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		return rowsUpdated, nil
+	}
+	var rowsAffected int64
+	opt = append(opt,
+		WithOnConflict(&OnConflict{Target: Columns(s.PrimaryFieldDBNames), Action: UpdateAll(true)}),
+		WithReturnRowsAffected(&rowsAffected),
+	)
+	if err := rw.Create(ctx, i, opt...); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return int(rowsAffected), nil
+}
+
+// primaryKeyIsGenerated returns true when the schema has a single, auto
+// increment primary key.
+func primaryKeyIsGenerated(s *schema.Schema) bool {
+	return len(s.PrimaryFields) == 1 && s.PrimaryFields[0].AutoIncrement
+}
+
+// updatableFieldNames returns the names of the schema's updatable fields,
+// excluding its primary keys.
+func updatableFieldNames(s *schema.Schema) []string {
+	var names []string
+	for _, f := range s.Fields {
+		if f.DBName == "" || f.PrimaryKey || !f.Updatable {
+			continue
+		}
+		names = append(names, f.Name)
+	}
+	return names
+}
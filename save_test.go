@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSaveModel has a primary key which is generated by the database.
+type testSaveModel struct {
+	Id   int    `gorm:"primaryKey"`
+	Name string `gorm:"default:null"`
+}
+
+func (*testSaveModel) TableName() string { return "db_test_save" }
+
+func TestDb_Save(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+
+	t.Run("generated-key", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		testRw := dbw.New(conn)
+		_, err = testRw.Exec(testCtx, "create table db_test_save (id integer primary key autoincrement, name text)", nil)
+		require.NoError(err)
+
+		// a zero primary key inserts
+		m := &testSaveModel{Name: "alice"}
+		rowsAffected, err := testRw.Save(testCtx, m)
+		require.NoError(err)
+		assert.Equal(1, rowsAffected)
+		assert.NotZero(m.Id)
+
+		// a set primary key updates
+		m.Name = "bob"
+		rowsAffected, err = testRw.Save(testCtx, m)
+		require.NoError(err)
+		assert.Equal(1, rowsAffected)
+		found := &testSaveModel{Id: m.Id}
+		require.NoError(testRw.LookupBy(testCtx, found))
+		assert.Equal("bob", found.Name)
+
+		// the updated row must exist
+		rowsAffected, err = testRw.Save(testCtx, &testSaveModel{Id: m.Id + 100, Name: "carol"})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+		assert.Zero(rowsAffected)
+	})
+	t.Run("assigned-key", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		testRw := dbw.New(conn)
+
+		// the row doesn't exist, so the upsert inserts
+		u := testUser(t, nil, "save-alice", "alice@example.com", "")
+		rowsAffected, err := testRw.Save(testCtx, u)
+		require.NoError(err)
+		assert.Equal(1, rowsAffected)
+		found := dbtest.AllocTestUser()
+		found.PublicId = u.PublicId
+		require.NoError(testRw.LookupByPublicId(testCtx, &found))
+		assert.Equal("save-alice", found.Name)
+
+		// the row exists, so the upsert updates
+		u.Name = "save-bob"
+		u.Email = "bob@example.com"
+		rowsAffected, err = testRw.Save(testCtx, u)
+		require.NoError(err)
+		assert.Equal(1, rowsAffected)
+		found = dbtest.AllocTestUser()
+		found.PublicId = u.PublicId
+		require.NoError(testRw.LookupByPublicId(testCtx, &found))
+		assert.Equal("save-bob", found.Name)
+		assert.Equal("bob@example.com", found.Email)
+	})
+	t.Run("in-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := dbw.TestSetup(t)
+		testRw := dbw.New(conn)
+		tx, err := testRw.Begin(testCtx)
+		require.NoError(err)
+		u := testUser(t, nil, "save-in-tx", "", "")
+		rowsAffected, err := tx.Save(testCtx, u)
+		require.NoError(err)
+		assert.Equal(1, rowsAffected)
+		require.NoError(tx.Rollback(testCtx))
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = u.PublicId
+		err = testRw.LookupByPublicId(testCtx, &found)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		conn, _ := dbw.TestSetup(t)
+		testRw := dbw.New(conn)
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			i               interface{}
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, testUser(t, nil, "", "", ""), nil, "missing underlying db"},
+			{"missing-interface", testRw, nil, nil, "missing interface"},
+			{"with-on-conflict", testRw, testUser(t, nil, "", "", ""), []dbw.Option{dbw.WithOnConflict(&dbw.OnConflict{})}, "with on conflict is not a supported option"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				rowsAffected, err := tt.rw.Save(testCtx, tt.i, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Zero(rowsAffected)
+			})
+		}
+	})
+}
@@ -0,0 +1,555 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// pgUniqueKeysQuery returns the names and columns of the unique
+	// (non-partial) indexes for a table, which includes primary keys and
+	// unique constraints.  The primary key is returned first.
+	pgUniqueKeysQuery = `
+select ic.relname as name, array_to_string(array_agg(a.attname order by k.ord), ',') as columns
+from pg_index ix
+join pg_class ic on ic.oid = ix.indexrelid
+cross join lateral unnest(ix.indkey) with ordinality as k(attnum, ord)
+join pg_attribute a on a.attrelid = ix.indrelid and a.attnum = k.attnum
+where ix.indrelid = to_regclass(?) and ix.indisunique and ix.indpred is null
+group by ix.indexrelid, ic.relname, ix.indisprimary
+order by ix.indisprimary desc, ic.relname`
+
+	// sqliteUniqueKeysQuery returns the names and columns of the unique
+	// indexes for a table, which includes primary keys (except a rowid alias)
+	// and unique constraints.  The primary key is returned first.
+	sqliteUniqueKeysQuery = `
+select idx as name, group_concat(col, ',') as columns from (
+  select il.name as idx, il.origin as origin, ii.name as col
+  from pragma_index_list(?) il
+  join pragma_index_info(il.name) ii
+  where il."unique" = 1 and il.partial = 0
+  order by il.name, ii.seqno
+)
+group by idx
+order by origin = 'pk' desc, idx`
+
+	// sqlitePrimaryKeyQuery returns the primary key columns for a table,
+	// which is required since a rowid alias (INTEGER PRIMARY KEY) doesn't have
+	// an index.
+	sqlitePrimaryKeyQuery = `select name from pragma_table_info(?) where pk > 0 order by pk`
+
+	// sqliteTableKindQuery returns whether a table is a WITHOUT ROWID table
+	// and its rowid alias (INTEGER PRIMARY KEY) column, if any.  No rows are
+	// returned when the table doesn't exist.
+	sqliteTableKindQuery = `
+select tl.wr as without_rowid, coalesce((
+  select ti.name from pragma_table_info(tl.name) ti
+  where ti.pk = 1 and lower(ti.type) = 'integer'
+  and (select count(*) from pragma_table_info(tl.name) where pk > 0) = 1
+), '') as rowid_alias
+from pragma_table_list(?) tl
+where tl.type = 'table'`
+
+	// sqliteColumnExistsQuery returns the number of columns of a table with a
+	// name.
+	sqliteColumnExistsQuery = `select count(*) from pragma_table_info(?) where lower(name) = lower(?)`
+)
+
+// parseSchema returns the parsed schema and table name of the resource. The
+// table name is overridden by the WithTable option.
+func (rw *RW) parseSchema(i interface{}, opts Options) (*schema.Schema, string, error) {
+	const op = "dbw.parseSchema"
+	mDb := rw.underlying.wrapped.Model(i)
+	if err := mDb.Statement.Parse(i); err != nil || mDb.Statement.Schema == nil {
+		return nil, "", fmt.Errorf("%s: (internal error) unable to parse stmt: %w", op, ErrUnknown)
+	}
+	tableName := mDb.Statement.Schema.Table
+	if opts.WithTable != "" {
+		tableName = opts.WithTable
+	}
+	return mDb.Statement.Schema, tableName, nil
+}
+
+// resolveTable returns the opts with the resource's table name resolved by the
+// DB's table resolver (see: WithTableResolver).  The WithTable option takes
+// precedence over the resolver, and the default table name is retained when
+// the resolver returns an empty name.  An ErrInvalidParameter is returned when
+// the resolved table name isn't a valid table reference (see: WithTable).
+func (rw *RW) resolveTable(ctx context.Context, i interface{}, opts Options) (Options, error) {
+	const op = "dbw.resolveTable"
+	if rw.underlying != nil && rw.underlying.tableResolver != nil && opts.WithTable == "" {
+		_, defaultName, err := rw.parseSchema(i, opts)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", op, err)
+		}
+		if name := rw.underlying.tableResolver(ctx, defaultName); name != "" {
+			opts.WithTable = name
+		}
+	}
+	if opts.WithTable != "" && !tableNameRegexp.MatchString(opts.WithTable) {
+		return opts, fmt.Errorf("%s: invalid table name %q: %w", op, opts.WithTable, ErrInvalidParameter)
+	}
+	return opts, nil
+}
+
+// tableNameRegexp matches a table name which may be qualified by its schema
+// (ex: reporting.events).  Hyphens are allowed, since the names are quoted
+// when they're written into sql, but whitespace, quotes and any other
+// punctuation which could inject sql are not.
+var tableNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_$-]*(\.[a-zA-Z_][a-zA-Z0-9_$-]*)?$`)
+
+// detectConflictTarget returns the opts with the on conflict target set to the
+// resource's single unique key, when the target is auto detected (see:
+// WithUpsert), unless the WithConflictOnMultipleTargets option is used.
+func (rw *RW) detectConflictTarget(i interface{}, opts Options) (Options, error) {
+	const op = "dbw.detectConflictTarget"
+	if !opts.WithConflictTargetAutoDetect || opts.WithOnConflict == nil || opts.WithOnConflict.Target != nil || opts.WithConflictTargets != nil {
+		return opts, nil
+	}
+	s, _, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return opts, fmt.Errorf("%s: %w", op, err)
+	}
+	keys := schemaUniqueKeys(s)
+	switch len(keys) {
+	case 0:
+		return opts, fmt.Errorf("%s: unable to detect the conflict target of %s, since it has no unique keys: %w", op, s.Table, ErrInvalidParameter)
+	case 1:
+	default:
+		return opts, fmt.Errorf("%s: unable to detect the conflict target of %s, since it has %d unique keys, use WithOnConflict with an explicit target: %w", op, s.Table, len(keys), ErrInvalidParameter)
+	}
+	opts.WithOnConflict = &OnConflict{Target: Columns(keys[0]), Action: opts.WithOnConflict.Action}
+	return opts, nil
+}
+
+// schemaUniqueKeys returns the sets of columns which are unique based on the
+// schema's primary keys, unique fields and unique indexes.
+func schemaUniqueKeys(s *schema.Schema) [][]string {
+	var keys [][]string
+	if len(s.PrimaryFieldDBNames) > 0 {
+		keys = append(keys, s.PrimaryFieldDBNames)
+	}
+	for _, f := range s.Fields {
+		if f.Unique && f.DBName != "" && !containsKey(keys, []string{f.DBName}) {
+			keys = append(keys, []string{f.DBName})
+		}
+	}
+	indexes := s.ParseIndexes()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx := indexes[name]
+		if !strings.EqualFold(idx.Class, "UNIQUE") || idx.Where != "" {
+			continue
+		}
+		columns := make([]string, 0, len(idx.Fields))
+		for _, f := range idx.Fields {
+			if f.Field == nil {
+				// expression indexes can't be matched to columns
+				columns = nil
+				break
+			}
+			columns = append(columns, f.DBName)
+		}
+		if len(columns) > 0 && !containsKey(keys, columns) {
+			keys = append(keys, columns)
+		}
+	}
+	return keys
+}
+
+// sqliteRowidPrimaryKey is the name of a sqlite rowid alias (INTEGER PRIMARY
+// KEY) primary key, which doesn't have an index.
+const sqliteRowidPrimaryKey = "PRIMARY KEY"
+
+// uniqueKey is a named set of columns which are unique for a table.
+type uniqueKey struct {
+	Name    string
+	Columns []string
+}
+
+// catalogUniqueKeys returns the sets of columns which are unique for the table
+// using the database's catalog. The bool returned is false when the database's
+// dialect doesn't support catalog lookups.
+func (rw *RW) catalogUniqueKeys(ctx context.Context, tableName string) ([][]string, bool, error) {
+	const op = "dbw.catalogUniqueKeys"
+	named, supported, err := rw.catalogNamedUniqueKeys(ctx, tableName)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	keys := make([][]string, 0, len(named))
+	for _, k := range named {
+		if !containsKey(keys, k.Columns) {
+			keys = append(keys, k.Columns)
+		}
+	}
+	return keys, supported, nil
+}
+
+// catalogNamedUniqueKeys returns the unique keys for the table using the
+// database's catalog, with its primary key first. The bool returned is false
+// when the database's dialect doesn't support catalog lookups.
+func (rw *RW) catalogNamedUniqueKeys(ctx context.Context, tableName string) ([]uniqueKey, bool, error) {
+	const op = "dbw.catalogNamedUniqueKeys"
+	typ, _, err := rw.underlying.DbType()
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	switch typ {
+	case Postgres, CockroachDB, Sqlite:
+	default:
+		return nil, false, nil
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	var results []struct {
+		Name    string
+		Columns string
+	}
+	if err := db.Raw(uniqueKeysQuery(typ), tableName).Scan(&results).Error; err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	keys := make([]uniqueKey, 0, len(results))
+	for _, r := range results {
+		if r.Columns == "" {
+			continue
+		}
+		keys = append(keys, uniqueKey{Name: r.Name, Columns: strings.Split(r.Columns, ",")})
+	}
+	if typ == Sqlite {
+		// the primary key query returns one column per row
+		var pk []string
+		if err := db.Raw(sqlitePrimaryKeyQuery, tableName).Scan(&pk).Error; err != nil {
+			return nil, false, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(pk) > 0 && !containsUniqueKey(keys, pk) {
+			keys = append([]uniqueKey{{Name: sqliteRowidPrimaryKey, Columns: pk}}, keys...)
+		}
+	}
+	return keys, true, nil
+}
+
+// uniqueKeysQuery returns the unique keys query for the dialect.
+func uniqueKeysQuery(typ DbType) string {
+	if typ == Sqlite {
+		return sqliteUniqueKeysQuery
+	}
+	return pgUniqueKeysQuery
+}
+
+// containsUniqueKey returns true if keys contains a key with the same set of
+// columns as k.
+func containsUniqueKey(keys []uniqueKey, k []string) bool {
+	for _, key := range keys {
+		if sameColumns(key.Columns, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConflictTarget returns an ErrInvalidParameter when the on conflict
+// target columns don't match a unique key for the resource's table.
+func (rw *RW) validateConflictTarget(ctx context.Context, i interface{}, target Columns, opts Options) error {
+	const op = "dbw.validateConflictTarget"
+	s, tableName, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if containsKey(schemaUniqueKeys(s), target) {
+		return nil
+	}
+	if opts.WithIndexPredicate != "" {
+		// the catalog lookups only return unique keys without a predicate
+		// and a predicate can't be reliably compared with the index's, so
+		// we'll let the database decide.
+		return nil
+	}
+	cacheKey := newConflictTargetKey(tableName, target)
+	cache := rw.underlying.catalogCache
+	if cache != nil {
+		if _, ok := cache.Load(cacheKey); ok {
+			return nil
+		}
+	}
+	keys, supported, err := rw.catalogUniqueKeys(ctx, tableName)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	case !supported:
+		// the dialect doesn't support catalog lookups, so we'll let the
+		// database decide.
+		return nil
+	case containsKey(keys, target):
+		// only matching targets are cached, so a unique index which is
+		// created after a target is rejected will still be found.
+		if cache != nil {
+			cache.Store(cacheKey, true)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: no unique index on (%s) for ON CONFLICT: %w", op, strings.Join(target, ", "), ErrInvalidParameter)
+}
+
+// conflictTargetKey is the catalog cache key of an on conflict target which
+// matches a unique key of the table (see: validateConflictTarget).
+type conflictTargetKey struct {
+	tableName string
+	columns   string
+}
+
+// newConflictTargetKey returns the key of the target, whose columns are
+// compared without regard to their order or case.
+func newConflictTargetKey(tableName string, target Columns) conflictTargetKey {
+	columns := make([]string, 0, len(target))
+	for _, c := range target {
+		columns = append(columns, strings.ToLower(c))
+	}
+	sort.Strings(columns)
+	return conflictTargetKey{tableName: tableName, columns: strings.Join(columns, ",")}
+}
+
+// isSqliteRowid returns true if the column is one of the names which sqlite
+// accepts for the rowid of a table.
+func isSqliteRowid(column string) bool {
+	switch strings.ToLower(column) {
+	case "rowid", "_rowid_", "oid":
+		return true
+	}
+	return false
+}
+
+// sqliteConflictTarget returns the on conflict target to render for a sqlite
+// table and true when the target is the table's rowid, which is unique but
+// isn't returned by the catalog lookups.  A rowid target is rendered as the
+// table's rowid alias (INTEGER PRIMARY KEY) column, when it has one, and an
+// ErrInvalidParameter is returned for a WITHOUT ROWID table, which doesn't have
+// a rowid.  Other targets are returned unchanged.
+func (rw *RW) sqliteConflictTarget(ctx context.Context, tableName string, target Columns) (Columns, bool, error) {
+	const op = "dbw.sqliteConflictTarget"
+	if len(target) != 1 || !isSqliteRowid(target[0]) {
+		return target, false, nil
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	var columns int
+	if err := db.Raw(sqliteColumnExistsQuery, tableName, target[0]).Scan(&columns).Error; err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if columns > 0 {
+		// a column named rowid hides the table's rowid
+		return target, false, nil
+	}
+	var kind []struct {
+		WithoutRowid bool
+		RowidAlias   string
+	}
+	if err := db.Raw(sqliteTableKindQuery, tableName).Scan(&kind).Error; err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	switch {
+	case len(kind) == 0:
+		// the table wasn't found, so we'll let the database decide.
+		return target, false, nil
+	case kind[0].WithoutRowid:
+		return nil, false, fmt.Errorf("%s: %s is a WITHOUT ROWID table, so %s is not a valid conflict target: %w", op, tableName, target[0], ErrInvalidParameter)
+	case kind[0].RowidAlias != "":
+		return Columns{kind[0].RowidAlias}, true, nil
+	default:
+		return target, true, nil
+	}
+}
+
+// containsKey returns true if keys contains a key with the same set of
+// columns (case-insensitive and regardless of order) as k.
+func containsKey(keys [][]string, k []string) bool {
+	for _, key := range keys {
+		if sameColumns(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, c := range a {
+		if !contains(b, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// SchemaDiff describes the differences between the columns of a model, as
+// mapped by its parsed schema, and the columns of its live table (see:
+// RW.SchemaDiff)
+type SchemaDiff struct {
+	// Table is the name of the table which was compared.
+	Table string
+
+	// MissingColumns are the model's columns which aren't in the table.
+	MissingColumns []string
+
+	// ExtraColumns are the table's columns which aren't mapped by the model.
+	ExtraColumns []string
+
+	// TypeMismatches are the columns whose types differ between the model and
+	// the table.
+	TypeMismatches []ColumnTypeMismatch
+}
+
+// ColumnTypeMismatch describes a column whose type in the model differs from
+// its type in the table.
+type ColumnTypeMismatch struct {
+	// Column is the name of the column.
+	Column string
+
+	// ModelType is the column's data type in the model's schema.
+	ModelType string
+
+	// TableType is the column's database type in the table.
+	TableType string
+}
+
+// HasDrift returns true if there are any differences between the model and
+// the table.
+func (d SchemaDiff) HasDrift() bool {
+	return len(d.MissingColumns) > 0 || len(d.ExtraColumns) > 0 || len(d.TypeMismatches) > 0
+}
+
+// SchemaDiff will compare the columns of the model's parsed schema to the
+// columns of its live table, which are introspected using the dialect's
+// catalog (information_schema for postgres and pragmas for sqlite).  It
+// reports the model's columns missing from the table, the table's columns
+// which the model doesn't map and the columns whose types differ.  It's a read
+// only diagnostic and types are compared by their general kind (string, int,
+// float, bool, time and bytes), so types which can't be classified (ex: a
+// domain or enum) are never reported as mismatches.  Supports the WithTable
+// option.
+func (rw *RW) SchemaDiff(ctx context.Context, model interface{}, opt ...Option) (SchemaDiff, error) {
+	const op = "dbw.SchemaDiff"
+	ctx, cancel := rw.readContext(ctx)
+	defer cancel()
+	switch {
+	case rw.underlying == nil:
+		return SchemaDiff{}, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case isNil(model):
+		return SchemaDiff{}, fmt.Errorf("%s: missing model: %w", op, ErrInvalidParameter)
+	}
+	opts, err := rw.resolveTable(ctx, model, rw.getOpts(opt...))
+	if err != nil {
+		return SchemaDiff{}, fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(model, opts)
+	if err != nil {
+		return SchemaDiff{}, fmt.Errorf("%s: %w", op, err)
+	}
+	migrator := rw.underlying.wrapped.WithContext(ctx).Migrator()
+	if !migrator.HasTable(tableName) {
+		return SchemaDiff{}, fmt.Errorf("%s: table %s does not exist: %w", op, tableName, ErrInvalidParameter)
+	}
+	columnTypes, err := migrator.ColumnTypes(tableName)
+	if err != nil {
+		return SchemaDiff{}, fmt.Errorf("%s: unable to get columns of table %s: %w", op, tableName, err)
+	}
+
+	diff := SchemaDiff{Table: tableName}
+	tableTypes := make(map[string]string, len(columnTypes))
+	for _, ct := range columnTypes {
+		tableTypes[ct.Name()] = ct.DatabaseTypeName()
+		if _, ok := s.FieldsByDBName[ct.Name()]; !ok {
+			diff.ExtraColumns = append(diff.ExtraColumns, ct.Name())
+		}
+	}
+	for _, col := range s.DBNames {
+		tableType, ok := tableTypes[col]
+		if !ok {
+			diff.MissingColumns = append(diff.MissingColumns, col)
+			continue
+		}
+		modelType := string(s.FieldsByDBName[col].DataType)
+		modelKind, tableKind := columnTypeKind(modelType), columnTypeKind(tableType)
+		if modelKind != "" && tableKind != "" && modelKind != tableKind {
+			diff.TypeMismatches = append(diff.TypeMismatches, ColumnTypeMismatch{
+				Column:    col,
+				ModelType: modelType,
+				TableType: tableType,
+			})
+		}
+	}
+	return diff, nil
+}
+
+// columnTypeKinds maps gorm data types and database column types to their
+// general kind (see: columnTypeKind)
+var columnTypeKinds = map[string]string{
+	string(schema.Bool): "bool", "boolean": "bool",
+
+	string(schema.Int): "int", string(schema.Uint): "int", "integer": "int", "int2": "int",
+	"int4": "int", "int8": "int", "smallint": "int", "bigint": "int", "tinyint": "int",
+	"mediumint": "int", "smallserial": "int", "serial": "int", "bigserial": "int",
+
+	string(schema.Float): "float", "float4": "float", "float8": "float", "real": "float",
+	"double": "float", "double precision": "float", "numeric": "float", "decimal": "float",
+
+	string(schema.String): "string", "text": "string", "varchar": "string",
+	"character varying": "string", "char": "string", "character": "string",
+	"bpchar": "string", "citext": "string", "uuid": "string", "clob": "string",
+
+	string(schema.Time): "time", "timestamp": "time", "timestamptz": "time",
+	"timestamp with time zone": "time", "timestamp without time zone": "time",
+	"datetime": "time", "date": "time",
+
+	string(schema.Bytes): "bytes", "bytea": "bytes", "blob": "bytes",
+}
+
+// columnTypeKind returns the general kind of a gorm data type or a database
+// column type, which is one of: string, int, float, bool, time and bytes. An
+// empty kind is returned for a type which can't be classified.
+func columnTypeKind(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if idx := strings.IndexByte(typ, '('); idx >= 0 {
+		// remove any type modifiers (ex: varchar(255))
+		typ = strings.TrimSpace(typ[:idx])
+	}
+	return columnTypeKinds[typ]
+}
+
+// embeddedPrimaryKey returns true when the prioritized primary key of the
+// schema is a field of a struct embedded with a column prefix (ex:
+// `gorm:"embedded;embeddedPrefix:user__"`), which is a column of a joined
+// table rather than a column of the schema's table.
+func embeddedPrimaryKey(s *schema.Schema) bool {
+	f := s.PrioritizedPrimaryField
+	if f == nil || len(f.BindNames) < 2 {
+		return false
+	}
+	t := s.ModelType
+	for _, name := range f.BindNames[:len(f.BindNames)-1] {
+		sf, ok := t.FieldByName(name)
+		if !ok {
+			return false
+		}
+		if _, ok := schema.ParseTagSetting(sf.Tag.Get("gorm"), ";")["EMBEDDEDPREFIX"]; ok {
+			return true
+		}
+		t = sf.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDriftModel expects an email column which its table is missing.
+type testDriftModel struct {
+	PublicId string `gorm:"primaryKey"`
+	Name     string
+	Email    string
+	Mpg      int32
+}
+
+func (*testDriftModel) TableName() string { return "db_test_drift" }
+
+func TestRW_SchemaDiff(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	_, err := testRw.Exec(testCtx, `create table db_test_drift (
+		public_id text primary key,
+		name text,
+		mpg text,
+		legacy_id int
+	)`, nil)
+	require.NoError(t, err)
+
+	t.Run("drift", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		diff, err := testRw.SchemaDiff(testCtx, &testDriftModel{})
+		require.NoError(err)
+		assert.True(diff.HasDrift())
+		assert.Equal("db_test_drift", diff.Table)
+		assert.Equal([]string{"email"}, diff.MissingColumns)
+		assert.Equal([]string{"legacy_id"}, diff.ExtraColumns)
+		require.Len(diff.TypeMismatches, 1)
+		assert.Equal("mpg", diff.TypeMismatches[0].Column)
+		assert.Equal("int", diff.TypeMismatches[0].ModelType)
+		assert.Equal("text", diff.TypeMismatches[0].TableType)
+	})
+	t.Run("no-drift", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		diff, err := testRw.SchemaDiff(testCtx, &dbtest.TestUser{})
+		require.NoError(err)
+		assert.False(diff.HasDrift(), "unexpected drift: %+v", diff)
+		assert.Equal("db_test_user", diff.Table)
+	})
+	t.Run("with-table", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		diff, err := testRw.SchemaDiff(testCtx, &testDriftModel{}, dbw.WithTable("db_test_car"))
+		require.NoError(err)
+		assert.Equal("db_test_car", diff.Table)
+		assert.Equal([]string{"email"}, diff.MissingColumns)
+		assert.Empty(diff.TypeMismatches)
+	})
+	t.Run("missing-table", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := testRw.SchemaDiff(testCtx, &testDriftModel{}, dbw.WithTable("db_test_not_found"))
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Contains(err.Error(), "table db_test_not_found does not exist")
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		_, err := (&dbw.RW{}).SchemaDiff(testCtx, &testDriftModel{})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+
+		_, err = testRw.SchemaDiff(testCtx, nil)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUniqueUser struct {
+	PublicId string `gorm:"primaryKey"`
+	Name     string `gorm:"unique"`
+	Email    string `gorm:"uniqueIndex:idx_email_phone"`
+	Phone    string `gorm:"uniqueIndex:idx_email_phone"`
+	Nickname string `gorm:"index"`
+}
+
+func (*testUniqueUser) TableName() string { return "db_test_user" }
+
+func Test_schemaUniqueKeys(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	db, _ := TestSetup(t)
+	s, tableName, err := New(db).parseSchema(&testUniqueUser{}, GetOpts())
+	require.NoError(err)
+	assert.Equal("db_test_user", tableName)
+	assert.Equal([][]string{{"public_id"}, {"name"}, {"email", "phone"}}, schemaUniqueKeys(s))
+}
+
+func TestRW_validateConflictTarget(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	db, _ := TestSetup(t)
+	rw := New(db)
+
+	type testUser struct {
+		PublicId    string `gorm:"primaryKey"`
+		Name        string
+		Email       string
+		PhoneNumber string
+	}
+	type testRental struct {
+		UserId string
+		CarId  string
+	}
+
+	tests := []struct {
+		name            string
+		resource        interface{}
+		target          Columns
+		opts            Options
+		wantErrContains string
+	}{
+		{"schema-primary-key", &testUser{}, Columns{"public_id"}, Options{WithTable: "db_test_user"}, ""},
+		{"catalog-unique-column", &testUser{}, Columns{"NAME"}, Options{WithTable: "db_test_user"}, ""},
+		{"catalog-composite-primary-key", &testRental{}, Columns{"car_id", "user_id"}, Options{WithTable: "db_test_rental"}, ""},
+		{"no-unique-index", &testUser{}, Columns{"email"}, Options{WithTable: "db_test_user"}, "no unique index on (email) for ON CONFLICT"},
+		{"partial-composite-key", &testRental{}, Columns{"user_id"}, Options{WithTable: "db_test_rental"}, "no unique index on (user_id) for ON CONFLICT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			err := rw.validateConflictTarget(testCtx, tt.resource, tt.target, tt.opts)
+			if tt.wantErrContains != "" {
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				return
+			}
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestRW_validateConflictTarget_cache(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+	testCtx := context.Background()
+	db, mock := TestSetupWithMock(t)
+	rw := New(db)
+
+	type testUser struct {
+		PublicId string `gorm:"primaryKey"`
+		Name     string
+		Email    string
+	}
+	opts := Options{WithTable: "db_test_user"}
+
+	// a rejected target isn't cached, so the catalog is queried every time
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`.*`).WithArgs("db_test_user").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "columns"}).AddRow("db_test_user_pkey", "public_id"))
+		err := rw.validateConflictTarget(testCtx, &testUser{}, Columns{"email"}, opts)
+		require.ErrorIs(err, ErrInvalidParameter)
+	}
+	require.NoError(mock.ExpectationsWereMet())
+
+	// a matching target is only looked up in the catalog once, regardless of
+	// the order or case of its columns.
+	mock.ExpectQuery(`.*`).WithArgs("db_test_user").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "columns"}).AddRow("db_test_user_name_email_key", "name,email"))
+	require.NoError(rw.validateConflictTarget(testCtx, &testUser{}, Columns{"name", "email"}, opts))
+	require.NoError(rw.validateConflictTarget(testCtx, &testUser{}, Columns{"EMAIL", "name"}, opts))
+	assert.NoError(mock.ExpectationsWereMet())
+}
+
+func Test_columnTypeKind(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{"string", "string"},
+		{"VARCHAR(255)", "string"},
+		{"character varying", "string"},
+		{"int", "int"},
+		{"uint", "int"},
+		{"INTEGER", "int"},
+		{"int8", "int"},
+		{"numeric(10, 2)", "float"},
+		{"double precision", "float"},
+		{"bool", "bool"},
+		{"timestamp", "time"},
+		{"timestamptz", "time"},
+		{"bytea", "bytes"},
+		{"interval", ""},
+		{"point", ""},
+		{"wt_timestamp", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			assert.Equal(t, tt.want, columnTypeKind(tt.typ))
+		})
+	}
+}
+
+func Test_embeddedPrimaryKey(t *testing.T) {
+	t.Parallel()
+	db, _ := TestSetup(t)
+	rw := New(db)
+
+	type testKey struct {
+		PublicId string `gorm:"primaryKey"`
+		Name     string
+	}
+	type testEmbedded struct {
+		*testKey
+	}
+	type testPrefixed struct {
+		Name string
+		Key  testKey `gorm:"embedded;embeddedPrefix:key__"`
+	}
+	type testPrefixedPtr struct {
+		Name string
+		Key  *testKey `gorm:"embedded;embeddedPrefix:key__"`
+	}
+
+	tests := []struct {
+		name     string
+		resource interface{}
+		want     bool
+	}{
+		{"primary-key", &testKey{}, false},
+		{"embedded", &testEmbedded{}, false},
+		{"embedded-prefix", &testPrefixed{}, true},
+		{"embedded-prefix-ptr", &testPrefixedPtr{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, err := rw.parseSchema(tt.resource, Options{WithTable: "db_test_user"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, embeddedPrimaryKey(s))
+		})
+	}
+}
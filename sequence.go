@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ResetSequence will realign the sequence which generates the values of the
+// table's column with the max value of the column, so the next generated value
+// is one greater than it (or 1 when the table is empty).  It's typically used
+// after a bulk load which set explicit values for the column, since the
+// sequence isn't advanced by them and the next insert which relies on the
+// sequence would fail with a duplicate key error.  ResetSequence is idempotent
+// and the table may be qualified by its schema (ex: public.users).
+//
+// For Postgres and CockroachDB, the column must be backed by a sequence (ex:
+// a serial or identity column).  For Sqlite, the table's row in sqlite_sequence
+// is updated when the table uses AUTOINCREMENT, otherwise ResetSequence is a
+// no-op since sqlite derives the next rowid from the max rowid of the table.
+func (rw *RW) ResetSequence(ctx context.Context, table, column string) error {
+	const op = "dbw.ResetSequence"
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case table == "":
+		return fmt.Errorf("%s: missing table: %w", op, ErrInvalidParameter)
+	case column == "":
+		return fmt.Errorf("%s: missing column: %w", op, ErrInvalidParameter)
+	case !identifierRegexp.MatchString(column):
+		return fmt.Errorf("%s: invalid column %q: %w", op, column, ErrInvalidParameter)
+	}
+	tableParts := strings.Split(table, ".")
+	if len(tableParts) > 2 {
+		return fmt.Errorf("%s: invalid table %q: %w", op, table, ErrInvalidParameter)
+	}
+	for _, name := range tableParts {
+		if !identifierRegexp.MatchString(name) {
+			return fmt.Errorf("%s: invalid table %q: %w", op, table, ErrInvalidParameter)
+		}
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
+	db := rw.underlying.wrapped.WithContext(ctx)
+
+	switch dbType {
+	case Postgres, CockroachDB:
+		// setval returns null when the column isn't backed by a sequence,
+		// since pg_get_serial_sequence returns null for it.
+		var next sql.NullInt64
+		query := fmt.Sprintf("select setval(pg_get_serial_sequence(?, ?), coalesce(max(%s), 0) + 1, false) from %s", column, table)
+		if err := db.Raw(query, table, column).Scan(&next).Error; err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !next.Valid {
+			return fmt.Errorf("%s: column %q of table %q is not backed by a sequence: %w", op, column, table, ErrInvalidParameter)
+		}
+		return nil
+	case Sqlite:
+		sequenceTable, name := "sqlite_sequence", tableParts[0]
+		masterTable := "sqlite_master"
+		if len(tableParts) == 2 {
+			sequenceTable = tableParts[0] + ".sqlite_sequence"
+			masterTable = tableParts[0] + ".sqlite_master"
+			name = tableParts[1]
+		}
+		// sqlite_sequence only exists once a table using AUTOINCREMENT has
+		// been created.
+		var exists int
+		if err := db.Raw(fmt.Sprintf("select count(*) from %s where type = 'table' and name = 'sqlite_sequence'", masterTable)).Scan(&exists).Error; err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if exists == 0 {
+			return nil
+		}
+		update := fmt.Sprintf("update %s set seq = (select coalesce(max(%s), 0) from %s) where name = ?", sequenceTable, column, table)
+		if err := db.Exec(update, name).Error; err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: resetting a sequence is not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+}
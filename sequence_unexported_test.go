@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRW_ResetSequence(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const setvalSql = "select setval(pg_get_serial_sequence($1, $2), coalesce(max(id), 0) + 1, false) from public.db_test_serial"
+	t.Run("postgres", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectQuery(regexp.QuoteMeta(setvalSql)).
+			WithArgs("public.db_test_serial", "id").
+			WillReturnRows(sqlmock.NewRows([]string{"setval"}).AddRow(11))
+		require.NoError(rw.ResetSequence(testCtx, "public.db_test_serial", "id"))
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("postgres-no-sequence", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectQuery(regexp.QuoteMeta(setvalSql)).
+			WithArgs("public.db_test_serial", "id").
+			WillReturnRows(sqlmock.NewRows([]string{"setval"}).AddRow(nil))
+		err := rw.ResetSequence(testCtx, "public.db_test_serial", "id")
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "is not backed by a sequence")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("postgres-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectQuery(regexp.QuoteMeta(setvalSql)).
+			WithArgs("public.db_test_serial", "id").
+			WillReturnError(errors.New(`relation "public.db_test_serial" does not exist`))
+		err := rw.ResetSequence(testCtx, "public.db_test_serial", "id")
+		require.Error(err)
+		assert.Contains(err.Error(), "does not exist")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, err := Open(Sqlite, "file::memory:")
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		rw := New(conn)
+
+		// without any AUTOINCREMENT table, there's no sqlite_sequence
+		_, err = rw.Exec(testCtx, "create table db_test_rowid (id integer primary key, name text)", nil)
+		require.NoError(err)
+		require.NoError(rw.ResetSequence(testCtx, "db_test_rowid", "id"))
+
+		_, err = rw.Exec(testCtx, "create table db_test_serial (id integer primary key autoincrement, name text)", nil)
+		require.NoError(err)
+		_, err = rw.Exec(testCtx, "insert into db_test_serial (id, name) values (10, 'alice'), (20, 'bob')", nil)
+		require.NoError(err)
+		_, err = rw.Exec(testCtx, "delete from db_test_serial where id = 20", nil)
+		require.NoError(err)
+
+		// it's idempotent
+		require.NoError(rw.ResetSequence(testCtx, "db_test_serial", "id"))
+		require.NoError(rw.ResetSequence(testCtx, "main.db_test_serial", "id"))
+		_, err = rw.Exec(testCtx, "insert into db_test_serial (name) values ('carol')", nil)
+		require.NoError(err)
+		var id int
+		require.NoError(conn.wrapped.Raw("select id from db_test_serial where name = 'carol'").Scan(&id).Error)
+		assert.Equal(11, id)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		conn, _ := TestSetupWithMock(t)
+		rw := New(conn)
+		tests := []struct {
+			name            string
+			rw              *RW
+			table           string
+			column          string
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &RW{}, "db_test_serial", "id", "missing underlying db"},
+			{"missing-table", rw, "", "id", "missing table"},
+			{"missing-column", rw, "db_test_serial", "", "missing column"},
+			{"invalid-table", rw, "db_test_serial; drop table db_test_serial", "id", "invalid table"},
+			{"invalid-qualified-table", rw, "a.b.db_test_serial", "id", "invalid table"},
+			{"invalid-column", rw, "db_test_serial", "id)", "invalid column"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := tt.rw.ResetSequence(testCtx, tt.table, tt.column)
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// snapshotIdRegexp matches a snapshot id returned by pg_export_snapshot()
+// (ex: 00000003-0000001B-1)
+var snapshotIdRegexp = regexp.MustCompile(`^[0-9A-Fa-f]+-[0-9A-Fa-f]+(-[0-9]+)?$`)
+
+// ExportSnapshot will export the snapshot of the transaction and return its
+// id, which can be used to begin other transactions that see the same data
+// (see: WithSnapshot).  This allows multiple transactions, possibly on
+// different connections, to run a consistent set of reads in parallel (ex: for
+// a report).  The snapshot can only be imported while the exporting
+// transaction is open, so the RW must be a transaction (see: Begin) and the
+// caller must not commit or rollback it until the other transactions have
+// begun.  It's only supported by Postgres.
+func (rw *RW) ExportSnapshot(ctx context.Context) (string, error) {
+	const op = "dbw.ExportSnapshot"
+	if rw.underlying == nil {
+		return "", fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	if err := rw.snapshotSupported(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if _, ok := rw.TxID(); !ok {
+		return "", fmt.Errorf("%s: exporting a snapshot requires a transaction: %w", op, ErrInvalidParameter)
+	}
+	var id string
+	if err := rw.underlying.wrapped.WithContext(ctx).Raw("select pg_export_snapshot()").Scan(&id).Error; err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// setSnapshot will set the snapshot of the transaction to the exported
+// snapshot (see: ExportSnapshot).  It must be called before any other
+// statement of the transaction, which is run with the repeatable read
+// isolation level required to import a snapshot.
+func (rw *RW) setSnapshot(ctx context.Context, id string) error {
+	const op = "dbw.setSnapshot"
+	if err := rw.snapshotSupported(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !snapshotIdRegexp.MatchString(id) {
+		return fmt.Errorf("%s: invalid snapshot id %q: %w", op, id, ErrInvalidParameter)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if err := db.Exec("set transaction isolation level repeatable read").Error; err != nil {
+		return fmt.Errorf("%s: unable to set isolation level: %w", op, err)
+	}
+	// the snapshot id can't be a parameter, which is why it's validated
+	if err := db.Exec(fmt.Sprintf("set transaction snapshot '%s'", id)).Error; err != nil {
+		return fmt.Errorf("%s: unable to set snapshot: %w", op, err)
+	}
+	return nil
+}
+
+// snapshotSupported returns an error when the db doesn't support exporting
+// and importing snapshots.
+func (rw *RW) snapshotSupported() error {
+	const op = "dbw.snapshotSupported"
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if dbType != Postgres {
+		return fmt.Errorf("%s: snapshots are not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRW_Snapshot(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const (
+		snapshotId   = "00000003-0000001B-1"
+		exportSql    = "select pg_export_snapshot()"
+		isolationSql = "set transaction isolation level repeatable read"
+		snapshotSql  = "set transaction snapshot '" + snapshotId + "'"
+		readSql      = "select name from db_test_user where public_id = $1"
+	)
+	readName := func(t *testing.T, rw *RW) string {
+		t.Helper()
+		rows, err := rw.Query(testCtx, "select name from db_test_user where public_id = ?", []interface{}{"u_1234567890"})
+		require.NoError(t, err)
+		defer rows.Close()
+		require.True(t, rows.Next())
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		return name
+	}
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(exportSql)).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_export_snapshot"}).AddRow(snapshotId))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(isolationSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(snapshotSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(readSql)).
+			WithArgs("u_1234567890").
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+		mock.ExpectCommit()
+		mock.ExpectCommit()
+
+		exporter, err := rw.Begin(testCtx)
+		require.NoError(err)
+		id, err := exporter.ExportSnapshot(testCtx)
+		require.NoError(err)
+		assert.Equal(snapshotId, id)
+
+		// the second transaction sees the data of the exported snapshot
+		reader, err := rw.BeginTx(testCtx, WithSnapshot(id))
+		require.NoError(err)
+		assert.Equal("alice", readName(t, reader))
+		require.NoError(reader.Commit(testCtx))
+		require.NoError(exporter.Commit(testCtx))
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("set-snapshot-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(isolationSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(snapshotSql)).WillReturnError(errors.New(`invalid snapshot identifier: "` + snapshotId + `"`))
+		mock.ExpectRollback()
+		reader, err := rw.BeginTx(testCtx, WithSnapshot(snapshotId))
+		require.Error(err)
+		assert.Nil(reader)
+		assert.Contains(err.Error(), "unable to set snapshot")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("export-requires-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		_, err := rw.ExportSnapshot(testCtx)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "requires a transaction")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("invalid-snapshot-id", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		_, err := rw.BeginTx(testCtx, WithSnapshot("1'; drop table db_test_user; --"))
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "invalid snapshot id")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := TestSetup(t, WithTestDialect(Sqlite.String()))
+		rw := New(conn)
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		t.Cleanup(func() { _ = tx.Rollback(testCtx) })
+		_, err = tx.ExportSnapshot(testCtx)
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "not supported by sqlite")
+
+		_, err = rw.BeginTx(testCtx, WithSnapshot("00000003-0000001B-1"))
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "not supported by sqlite")
+	})
+}
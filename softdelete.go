@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SoftDeleter defines an interface for resources whose deleted rows are
+// retained and marked as deleted by setting a column (ex: deleted_at).  The
+// rows of a resource which implements SoftDeleter are excluded by
+// SearchWhere, SearchWithCTE, LookupWhere, LookupBy, LookupByPublicId,
+// LookupByPublicIds, Exists, Count, GroupCount and DistinctValues when
+// the column isn't NULL, unless WithDeleted(true) is used.  The reads of resources which don't implement it are unchanged.
+type SoftDeleter interface {
+	// SoftDeleteColumn returns the column which is set when a row is deleted.
+	SoftDeleteColumn() string
+}
+
+// SoftDeleteKind defines how a soft delete column marks a row as deleted.
+type SoftDeleteKind int
+
+const (
+	// TimestampNull is a timestamp column which is NULL until the row is
+	// deleted, when it's set to the current timestamp.
+	TimestampNull SoftDeleteKind = iota
+
+	// BooleanFlag is a boolean column which is false (or NULL) until the row
+	// is deleted, when it's set to true.
+	BooleanFlag
+
+	// UnixTimestamp is an integer column which is 0 (or NULL) until the row is
+	// deleted, when it's set to the current unix time in seconds.
+	UnixTimestamp
+)
+
+// String returns the name of the soft delete kind.
+func (k SoftDeleteKind) String() string {
+	switch k {
+	case TimestampNull:
+		return "timestamp null"
+	case BooleanFlag:
+		return "boolean flag"
+	case UnixTimestamp:
+		return "unix timestamp"
+	default:
+		return fmt.Sprintf("unknown soft delete kind %d", int(k))
+	}
+}
+
+// SoftDeleteColumn defines a soft delete column of a DB (see:
+// WithSoftDeleteColumn)
+type SoftDeleteColumn struct {
+	// Name is the name of the column.
+	Name string
+
+	// Kind defines how the column marks a row as deleted.
+	Kind SoftDeleteKind
+}
+
+// validate returns an ErrInvalidParameter when the column's name isn't an
+// identifier or its kind is unknown.
+func (c SoftDeleteColumn) validate() error {
+	const op = "dbw.(SoftDeleteColumn).validate"
+	switch {
+	case !identifierRegexp.MatchString(c.Name):
+		return fmt.Errorf("%s: invalid soft delete column %q: %w", op, c.Name, ErrInvalidParameter)
+	case c.Kind < TimestampNull || c.Kind > UnixTimestamp:
+		return fmt.Errorf("%s: soft delete column %s: %s: %w", op, c.Name, c.Kind, ErrInvalidParameter)
+	}
+	return nil
+}
+
+// notDeleted returns the condition which matches the rows of the table which
+// the column doesn't mark as deleted.  The table name must be quoted.
+func (c SoftDeleteColumn) notDeleted(tableName string) (string, []interface{}) {
+	column := tableName + "." + c.Name
+	switch c.Kind {
+	case BooleanFlag:
+		return fmt.Sprintf("(%s is null or %s = ?)", column, column), []interface{}{false}
+	case UnixTimestamp:
+		return fmt.Sprintf("(%s is null or %s = ?)", column, column), []interface{}{0}
+	default:
+		return fmt.Sprintf("%s is null", column), nil
+	}
+}
+
+// deletedValue returns the value which marks a row as deleted.
+func (c SoftDeleteColumn) deletedValue() interface{} {
+	switch c.Kind {
+	case BooleanFlag:
+		return true
+	case UnixTimestamp:
+		return time.Now().Unix()
+	default:
+		return gorm.Expr("current_timestamp")
+	}
+}
+
+// softDeleteScope returns the db with a where clause which excludes the deleted
+// rows of the resources (see: softDeleteColumns), unless WithDeleted(true) is
+// used.  The resources may be a resource or a slice of resources.
+func (rw *RW) softDeleteScope(db *gorm.DB, resources interface{}, opts Options) (*gorm.DB, error) {
+	const op = "dbw.softDeleteScope"
+	if opts.WithDeleted {
+		return db, nil
+	}
+	typ := reflect.TypeOf(resources)
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return db, nil
+	}
+	columns, tableName, err := rw.softDeleteColumns(reflect.New(typ).Interface(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return notDeletedScope(db, tableName, columns), nil
+}
+
+// softDeleteColumns returns the soft delete columns of the resource and its
+// table name.  The columns are the resource's SoftDeleter column and the DB's
+// soft delete columns (see: WithSoftDeleteColumn) which are columns of the
+// resource.  A SoftDeleter column which isn't one of the DB's soft delete
+// columns is a TimestampNull.
+func (rw *RW) softDeleteColumns(resource interface{}, opts Options) ([]SoftDeleteColumn, string, error) {
+	const op = "dbw.softDeleteColumns"
+	var columns []SoftDeleteColumn
+	if sd, ok := resource.(SoftDeleter); ok {
+		c := SoftDeleteColumn{Name: sd.SoftDeleteColumn(), Kind: TimestampNull}
+		if !identifierRegexp.MatchString(c.Name) {
+			return nil, "", fmt.Errorf("%s: invalid soft delete column %q: %w", op, c.Name, ErrInvalidParameter)
+		}
+		for _, configured := range rw.underlying.softDeleteColumns {
+			if configured.Name == c.Name {
+				c.Kind = configured.Kind
+			}
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 && len(rw.underlying.softDeleteColumns) == 0 {
+		return nil, "", nil
+	}
+	s, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	for _, configured := range rw.underlying.softDeleteColumns {
+		if _, ok := s.FieldsByDBName[configured.Name]; !ok {
+			continue
+		}
+		if len(columns) > 0 && columns[0].Name == configured.Name {
+			continue
+		}
+		columns = append(columns, configured)
+	}
+	return columns, tableName, nil
+}
+
+// notDeletedScope returns the db with a where clause which excludes the rows
+// of the table which any of the columns mark as deleted.
+func notDeletedScope(db *gorm.DB, tableName string, columns []SoftDeleteColumn) *gorm.DB {
+	for _, c := range columns {
+		where, args := c.notDeleted(db.Statement.Quote(tableName))
+		db = db.Where(where, args...)
+	}
+	return db
+}
+
+// softDeleteAssignments returns the soft delete columns of the resource (see:
+// softDeleteColumns), its table name and the assignments which mark its rows as
+// deleted, when the DB has soft delete columns (see: WithSoftDeleteColumn).
+// Otherwise, no assignments are returned and the rows should be deleted.
+func (rw *RW) softDeleteAssignments(resource interface{}, opts Options) ([]SoftDeleteColumn, string, map[string]interface{}, error) {
+	const op = "dbw.softDeleteAssignments"
+	if len(rw.underlying.softDeleteColumns) == 0 {
+		return nil, "", nil, nil
+	}
+	columns, tableName, err := rw.softDeleteColumns(resource, opts)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(columns) == 0 {
+		return nil, "", nil, nil
+	}
+	assignments := make(map[string]interface{}, len(columns))
+	for _, c := range columns {
+		assignments[c.Name] = c.deletedValue()
+	}
+	return columns, tableName, assignments, nil
+}
@@ -0,0 +1,313 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSoftDeleteModel opts in to soft deletes.
+type testSoftDeleteModel struct {
+	PublicId  string `gorm:"primaryKey"`
+	Name      string
+	DeletedAt *time.Time
+}
+
+func (*testSoftDeleteModel) TableName() string { return "db_test_soft_delete" }
+
+func (*testSoftDeleteModel) SoftDeleteColumn() string { return "deleted_at" }
+
+// testNoSoftDeleteModel has a deleted_at column, but doesn't opt in to soft
+// deletes.
+type testNoSoftDeleteModel struct {
+	PublicId  string `gorm:"primaryKey"`
+	Name      string
+	DeletedAt *time.Time
+}
+
+func (*testNoSoftDeleteModel) TableName() string { return "db_test_no_soft_delete" }
+
+func TestDb_SoftDelete(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	testRw := dbw.New(conn)
+	deletedAt := time.Now()
+	for _, table := range []string{"db_test_soft_delete", "db_test_no_soft_delete"} {
+		_, err := testRw.Exec(testCtx, "create table "+table+" (public_id text primary key, name text not null, deleted_at timestamp)", nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, testRw.CreateItems(testCtx, []*testSoftDeleteModel{
+		{PublicId: "1", Name: "alice"},
+		{PublicId: "2", Name: "bob", DeletedAt: &deletedAt},
+	}))
+	require.NoError(t, testRw.CreateItems(testCtx, []*testNoSoftDeleteModel{
+		{PublicId: "1", Name: "alice"},
+		{PublicId: "2", Name: "bob", DeletedAt: &deletedAt},
+	}))
+
+	t.Run("search", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*testSoftDeleteModel
+		require.NoError(testRw.SearchWhere(testCtx, &found, "", nil))
+		require.Len(found, 1)
+		assert.Equal("alice", found[0].Name)
+
+		found = nil
+		require.NoError(testRw.SearchWhere(testCtx, &found, "name = ? or name = ?", []interface{}{"alice", "bob"}))
+		assert.Len(found, 1)
+
+		found = nil
+		require.NoError(testRw.SearchWhere(testCtx, &found, "", nil, dbw.WithDeleted(true)))
+		assert.Len(found, 2)
+
+		var notOptedIn []*testNoSoftDeleteModel
+		require.NoError(testRw.SearchWhere(testCtx, &notOptedIn, "", nil))
+		assert.Len(notOptedIn, 2)
+	})
+	t.Run("search-with-cte", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		ctes := map[string]dbw.ExprValue{"names": dbw.Expr("select 'bob' as name")}
+		var found []*testSoftDeleteModel
+		require.NoError(testRw.SearchWithCTE(testCtx, &found, ctes, "name in (select name from names)", nil))
+		assert.Empty(found)
+
+		require.NoError(testRw.SearchWithCTE(testCtx, &found, ctes, "name in (select name from names)", nil, dbw.WithDeleted(true)))
+		assert.Len(found, 1)
+	})
+	t.Run("lookup", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found testSoftDeleteModel
+		err := testRw.LookupWhere(testCtx, &found, "name = ?", []interface{}{"bob"})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		require.NoError(testRw.LookupWhere(testCtx, &found, "name = ?", []interface{}{"bob"}, dbw.WithDeleted(true)))
+		assert.Equal("2", found.PublicId)
+
+		var notOptedIn testNoSoftDeleteModel
+		require.NoError(testRw.LookupWhere(testCtx, &notOptedIn, "name = ?", []interface{}{"bob"}))
+	})
+	t.Run("lookup-by", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		found := testSoftDeleteModel{PublicId: "2"}
+		err := testRw.LookupBy(testCtx, &found)
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrRecordNotFound)
+
+		require.NoError(testRw.LookupBy(testCtx, &found, dbw.WithDeleted(true)))
+		assert.Equal("bob", found.Name)
+
+		notOptedIn := testNoSoftDeleteModel{PublicId: "2"}
+		require.NoError(testRw.LookupBy(testCtx, &notOptedIn))
+		assert.Equal("bob", notOptedIn.Name)
+	})
+	t.Run("update-deleted", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		// the resource is looked up after the write, even though it's deleted
+		require.NoError(testRw.Create(testCtx, &testSoftDeleteModel{PublicId: "3", Name: "carol", DeletedAt: &deletedAt}))
+		updated := &testSoftDeleteModel{PublicId: "3", Name: "carol-updated"}
+		rowsUpdated, err := testRw.Update(testCtx, updated, []string{"Name"}, nil)
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.NotNil(updated.DeletedAt)
+		_, err = testRw.Exec(testCtx, "delete from db_test_soft_delete where public_id = ?", []interface{}{"3"})
+		require.NoError(err)
+	})
+	t.Run("lookup-by-public-ids", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*testSoftDeleteModel
+		require.NoError(testRw.LookupByPublicIds(testCtx, &found, []string{"1", "2"}))
+		require.Len(found, 1)
+		assert.Equal("alice", found[0].Name)
+
+		found = nil
+		require.NoError(testRw.LookupByPublicIds(testCtx, &found, []string{"1", "2"}, dbw.WithDeleted(true)))
+		assert.Len(found, 2)
+
+		var notOptedIn []*testNoSoftDeleteModel
+		require.NoError(testRw.LookupByPublicIds(testCtx, &notOptedIn, []string{"1", "2"}))
+		assert.Len(notOptedIn, 2)
+	})
+	t.Run("exists", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		exists, err := testRw.Exists(testCtx, &testSoftDeleteModel{}, "name = ?", []interface{}{"bob"})
+		require.NoError(err)
+		assert.False(exists)
+
+		exists, err = testRw.Exists(testCtx, &testSoftDeleteModel{}, "name = ?", []interface{}{"bob"}, dbw.WithDeleted(true))
+		require.NoError(err)
+		assert.True(exists)
+
+		exists, err = testRw.Exists(testCtx, &testNoSoftDeleteModel{}, "name = ?", []interface{}{"bob"})
+		require.NoError(err)
+		assert.True(exists)
+	})
+	t.Run("count", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counts, err := dbw.GroupCount[string](testCtx, testRw, &testSoftDeleteModel{}, "name", "", nil)
+		require.NoError(err)
+		assert.Equal(map[string]int64{"alice": 1}, counts)
+
+		counts, err = dbw.GroupCount[string](testCtx, testRw, &testSoftDeleteModel{}, "name", "", nil, dbw.WithDeleted(true))
+		require.NoError(err)
+		assert.Equal(map[string]int64{"alice": 1, "bob": 1}, counts)
+
+		counts, err = dbw.GroupCount[string](testCtx, testRw, &testNoSoftDeleteModel{}, "name", "", nil)
+		require.NoError(err)
+		assert.Equal(map[string]int64{"alice": 1, "bob": 1}, counts)
+	})
+	t.Run("distinct-values", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		names := []string{}
+		require.NoError(testRw.DistinctValues(testCtx, &testSoftDeleteModel{}, "name", "", nil, &names))
+		assert.Equal([]string{"alice"}, names)
+	})
+}
+
+// testLegacySoftDeleteModel has soft delete columns of each kind, but doesn't
+// implement SoftDeleter.
+type testLegacySoftDeleteModel struct {
+	PublicId    string `gorm:"primaryKey"`
+	Name        string
+	IsDeleted   bool
+	DeletedUnix int64
+	DeletedTime *time.Time
+}
+
+func (*testLegacySoftDeleteModel) TableName() string { return "db_test_legacy_soft_delete" }
+
+func TestDb_WithSoftDeleteColumn(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	tests := []struct {
+		name        string
+		opts        []dbw.Option
+		wantDeleted func(*testing.T, *testLegacySoftDeleteModel)
+	}{
+		{
+			name: "timestamp-null",
+			opts: []dbw.Option{dbw.WithSoftDeleteColumn("deleted_time", dbw.TimestampNull)},
+			wantDeleted: func(t *testing.T, m *testLegacySoftDeleteModel) {
+				assert.NotNil(t, m.DeletedTime)
+				assert.False(t, m.IsDeleted)
+			},
+		},
+		{
+			name: "boolean-flag",
+			opts: []dbw.Option{dbw.WithSoftDeleteColumn("is_deleted", dbw.BooleanFlag)},
+			wantDeleted: func(t *testing.T, m *testLegacySoftDeleteModel) {
+				assert.True(t, m.IsDeleted)
+				assert.Nil(t, m.DeletedTime)
+			},
+		},
+		{
+			name: "unix-timestamp",
+			opts: []dbw.Option{dbw.WithSoftDeleteColumn("deleted_unix", dbw.UnixTimestamp)},
+			wantDeleted: func(t *testing.T, m *testLegacySoftDeleteModel) {
+				assert.InDelta(t, time.Now().Unix(), m.DeletedUnix, 60)
+				assert.False(t, m.IsDeleted)
+			},
+		},
+		{
+			name: "flag-and-timestamp",
+			opts: []dbw.Option{
+				dbw.WithSoftDeleteColumn("is_deleted", dbw.BooleanFlag),
+				dbw.WithSoftDeleteColumn("deleted_time", dbw.TimestampNull),
+			},
+			wantDeleted: func(t *testing.T, m *testLegacySoftDeleteModel) {
+				assert.True(t, m.IsDeleted)
+				assert.NotNil(t, m.DeletedTime)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert, require := assert.New(t), require.New(t)
+			conn, err := dbw.Open(dbw.Sqlite, "file::memory:", tt.opts...)
+			require.NoError(err)
+			t.Cleanup(func() { _ = conn.Close(testCtx) })
+			rw := dbw.New(conn)
+			_, err = rw.Exec(testCtx, "create table db_test_legacy_soft_delete (public_id text primary key, name text not null, is_deleted boolean not null default false, deleted_unix integer not null default 0, deleted_time timestamp)", nil)
+			require.NoError(err)
+			_, err = rw.Exec(testCtx, "create table db_test_no_soft_delete (public_id text primary key, name text not null, deleted_at timestamp)", nil)
+			require.NoError(err)
+			require.NoError(rw.CreateItems(testCtx, []*testLegacySoftDeleteModel{
+				{PublicId: "1", Name: "alice"},
+				{PublicId: "2", Name: "bob"},
+				{PublicId: "3", Name: "carol"},
+				{PublicId: "4", Name: "dave"},
+			}))
+
+			deleted, err := rw.Delete(testCtx, &testLegacySoftDeleteModel{PublicId: "2"})
+			require.NoError(err)
+			assert.Equal(1, deleted)
+			// it's already marked as deleted
+			deleted, err = rw.Delete(testCtx, &testLegacySoftDeleteModel{PublicId: "2"})
+			require.NoError(err)
+			assert.Equal(0, deleted)
+
+			deleted, err = rw.DeleteItems(testCtx, []*testLegacySoftDeleteModel{{PublicId: "3"}})
+			require.NoError(err)
+			assert.Equal(1, deleted)
+
+			deleted, err = rw.DeleteByPublicIds(testCtx, &testLegacySoftDeleteModel{}, []string{"4", "2"})
+			require.NoError(err)
+			assert.Equal(1, deleted)
+
+			var found []*testLegacySoftDeleteModel
+			require.NoError(rw.SearchWhere(testCtx, &found, "", nil, dbw.WithOrder("public_id")))
+			require.Len(found, 1)
+			assert.Equal("alice", found[0].Name)
+			exists, err := rw.Exists(testCtx, &testLegacySoftDeleteModel{}, "name = ?", []interface{}{"bob"})
+			require.NoError(err)
+			assert.False(exists)
+
+			// the deleted rows are retained and marked as deleted
+			var all []*testLegacySoftDeleteModel
+			require.NoError(rw.SearchWhere(testCtx, &all, "", nil, dbw.WithOrder("public_id"), dbw.WithDeleted(true)))
+			require.Len(all, 4)
+			for _, m := range all[1:] {
+				tt.wantDeleted(t, m)
+			}
+
+			// a resource without the column is deleted
+			require.NoError(rw.Create(testCtx, &testNoSoftDeleteModel{PublicId: "1", Name: "alice"}))
+			deleted, err = rw.Delete(testCtx, &testNoSoftDeleteModel{PublicId: "1"})
+			require.NoError(err)
+			assert.Equal(1, deleted)
+			exists, err = rw.Exists(testCtx, &testNoSoftDeleteModel{}, "public_id = ?", []interface{}{"1"}, dbw.WithDeleted(true))
+			require.NoError(err)
+			assert.False(exists)
+		})
+	}
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			column          string
+			kind            dbw.SoftDeleteKind
+			wantErrContains string
+		}{
+			{"invalid-column", "is_deleted; drop table users", dbw.BooleanFlag, "invalid soft delete column"},
+			{"unknown-kind", "is_deleted", dbw.SoftDeleteKind(10), "unknown soft delete kind 10"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				_, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithSoftDeleteColumn(tt.column, tt.kind))
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
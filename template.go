@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templatePlaceholderRegexp matches a {{name}} placeholder of a template.
+var templatePlaceholderRegexp = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*\}\}`)
+
+// SearchTemplate will search for all the resources using a where clause which
+// is built from templates, so identifiers can be chosen dynamically without
+// concatenating them into the sql.  Each {{name}} placeholder of tmpl, where
+// and WithOrder is replaced by the column named by idents[name], which must be
+// a column of the resources and is quoted for the dialect of the db.  Values
+// must still be passed as args using placeholders.  tmpl and where are
+// combined with "and" and either may be empty, and the args are bound to the
+// placeholders of tmpl before the placeholders of where.
+//
+// For example, with idents of {"col": "name"}, the tmpl of "{{col}} = ?"
+// becomes `"name" = ?` for postgres.  SearchTemplate supports the same options
+// as SearchWhere.
+func (rw *RW) SearchTemplate(ctx context.Context, resources interface{}, tmpl string, idents map[string]string, where string, args []interface{}, opt ...Option) error {
+	const op = "dbw.SearchTemplate"
+	if rw.underlying == nil {
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	if err := validateResourcesInterface(resources); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	s, _, err := rw.parseSchema(resources, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	quoted := make(map[string]string, len(idents))
+	for name, column := range idents {
+		if _, ok := s.FieldsByDBName[column]; !ok {
+			return fmt.Errorf("%s: identifier %q is not a column of %s: %w", op, name, s.Table, ErrInvalidParameter)
+		}
+		quoted[name] = rw.underlying.Quote(column)
+	}
+	execute := func(t string) (string, error) {
+		var missing []string
+		ret := templatePlaceholderRegexp.ReplaceAllStringFunc(t, func(placeholder string) string {
+			name := templatePlaceholderRegexp.FindStringSubmatch(placeholder)[1]
+			q, ok := quoted[name]
+			if !ok {
+				missing = append(missing, name)
+			}
+			return q
+		})
+		switch {
+		case len(missing) > 0:
+			return "", fmt.Errorf("missing identifiers %q: %w", missing, ErrInvalidParameter)
+		case strings.Contains(ret, "{{") || strings.Contains(ret, "}}"):
+			return "", fmt.Errorf("invalid placeholder in %q: %w", t, ErrInvalidParameter)
+		}
+		return ret, nil
+	}
+
+	var clauses []string
+	for _, t := range []string{tmpl, where} {
+		if t == "" {
+			continue
+		}
+		c, err := execute(t)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		clauses = append(clauses, c)
+	}
+	if len(clauses) > 1 {
+		for i, c := range clauses {
+			clauses[i] = "(" + c + ")"
+		}
+	}
+	if opts.WithOrder != "" {
+		order, err := execute(opts.WithOrder)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		opt = append(opt, WithOrder(order))
+	}
+	if err := rw.SearchWhere(ctx, resources, strings.Join(clauses, " and "), args, opt...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDb_SearchTemplate(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	id, err := dbw.NewId("u")
+	require.NoError(t, err)
+	prefix := "template-" + id
+	for _, suffix := range []string{"b", "a", "c"} {
+		testUser(t, testRw, prefix+"-"+suffix, suffix+"@example.com", "")
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*dbtest.TestUser
+		err := testRw.SearchTemplate(testCtx, &found,
+			"{{filter}} like ?",
+			map[string]string{"filter": "name", "sort": "email"},
+			"{{ sort }} <> ?",
+			[]interface{}{prefix + "-%", "b@example.com"},
+			dbw.WithOrder("{{sort}} desc"),
+		)
+		require.NoError(err)
+		require.Len(found, 2)
+		assert.Equal(prefix+"-c", found[0].Name)
+		assert.Equal(prefix+"-a", found[1].Name)
+	})
+	t.Run("only-where", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var found []*dbtest.TestUser
+		err := testRw.SearchTemplate(testCtx, &found, "", nil, "name = ?", []interface{}{prefix + "-a"})
+		require.NoError(err)
+		require.Len(found, 1)
+		assert.Equal(prefix+"-a", found[0].Name)
+	})
+	t.Run("quoted-sql", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		counter := &testStatementCounter{}
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:", dbw.WithLogger(counter))
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		conn.LogLevel(dbw.Info)
+
+		// the table doesn't exist, but the failed statement is still logged
+		var found []*dbtest.TestUser
+		err = dbw.New(conn).SearchTemplate(testCtx, &found, "{{col}} = ?", map[string]string{"col": "name"}, "", []interface{}{"alice"})
+		require.Error(err)
+		stmts := counter.reset()
+		require.Len(stmts, 1)
+		assert.Contains(stmts[0], "WHERE `name` = \"alice\"")
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		tests := []struct {
+			name            string
+			tmpl            string
+			idents          map[string]string
+			where           string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"unknown-column", "{{col}} = ?", map[string]string{"col": "name; drop table db_test_user"}, "", nil, `identifier "col" is not a column`},
+			{"missing-ident", "{{col}} = ?", map[string]string{"other": "name"}, "", nil, "missing identifiers"},
+			{"missing-ident-in-where", "", nil, "{{col}} = ?", nil, "missing identifiers"},
+			{"missing-ident-in-order", "name = ?", nil, "", []dbw.Option{dbw.WithOrder("{{col}}")}, "missing identifiers"},
+			{"invalid-placeholder", "{{col-1}} = ?", map[string]string{"col": "name"}, "", nil, "invalid placeholder"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				var found []*dbtest.TestUser
+				err := testRw.SearchTemplate(testCtx, &found, tt.tmpl, tt.idents, tt.where, []interface{}{"alice"}, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
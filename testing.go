@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -34,27 +35,9 @@ func TestSetup(t *testing.T, opt ...TestOption) (*DB, string) {
 
 	ctx := context.Background()
 
-	opts := getTestOpts(opt...)
-
-	switch strings.ToLower(os.Getenv("DB_DIALECT")) {
-	case "postgres":
-		opts.withDialect = Postgres.String()
-	case "sqlite":
-		opts.withDialect = Sqlite.String()
-	default:
-		if opts.withDialect == "" {
-			opts.withDialect = Sqlite.String()
-		}
-	}
-
-	if url := os.Getenv("DB_DSN"); url != "" {
-		opts.withTestDatabaseUrl = url
-	}
+	opts := getTestOptsFromEnv(t, opt...)
 
 	switch {
-	case opts.withDialect == Postgres.String() && opts.withTestDatabaseUrl == "":
-		t.Fatal("missing postgres test db url")
-
 	case opts.withDialect == Sqlite.String() && opts.withTestDatabaseUrl == "":
 		url = "file::memory:" // just using a temp in-memory sqlite database
 	default:
@@ -105,12 +88,117 @@ func TestSetup(t *testing.T, opt ...TestOption) (*DB, string) {
 		db.Debug(true)
 	}
 
-	// we're only going to run one set of migrations.  Either one of the
-	// migration functions passed in as an option or the default
-	// TestCreateTables(...)
+	testMigrate(ctx, t, db, url, opts)
+	return db, url
+}
+
+// TestSetupIsolated is a variant of TestSetup for parallel tests, which
+// provisions an isolated database for each call, so tests (and subtests)
+// running in parallel don't see each other's data.  For postgres, the tables
+// are created in a uniquely named schema of the test database, which is used
+// as the search path of the returned db.  For sqlite, the tables are created in
+// a uniquely named in-memory database, which is shared by the db's
+// connections.  The ctx is used to setup the database.  The returned cleanup
+// closes the db and drops the schema, and it's registered with t.Cleanup, so
+// it only needs to be called to cleanup early (it's safe to call more than
+// once).  Supported test options: WithDebug, WithTestDialect,
+// WithTestDatabaseUrl, WithTestMigration and WithTestMigrationUsingDB.
+func TestSetupIsolated(ctx context.Context, t *testing.T, opt ...TestOption) (*DB, func()) {
+	t.Helper()
+	require := require.New(t)
+
+	InitNonUpdatableFields([]string{"CreateTime", "UpdateTime", "PublicId"})
+	InitNonCreatableFields([]string{"CreateTime", "UpdateTime"})
+
+	opts := getTestOptsFromEnv(t, opt...)
+	dbType, err := StringToDbType(opts.withDialect)
+	require.NoError(err)
+	name, err := NewId("go_db_tmp")
+	require.NoError(err)
+	name = strings.ToLower(name)
+
+	var url string
+	var dropSchema func() error
+	switch dbType {
+	case Postgres:
+		u, err := dburl.Parse(opts.withTestDatabaseUrl)
+		require.NoError(err)
+		admin, err := Open(Postgres, u.DSN)
+		require.NoError(err)
+		_, err = New(admin).Exec(ctx, fmt.Sprintf(`create schema "%s"`, name), nil)
+		require.NoError(err)
+		dropSchema = func() error {
+			defer admin.Close(context.Background())
+			_, err := New(admin).Exec(context.Background(), fmt.Sprintf(`drop schema "%s" cascade`, name), nil)
+			return err
+		}
+		url = fmt.Sprintf("%s search_path=%s", u.DSN, name)
+	default:
+		// a named in-memory database is shared by the connections which use
+		// its name, and it's dropped when its last connection is closed.
+		url = fmt.Sprintf("file:%s?mode=memory&cache=shared&_foreign_keys=1", name)
+	}
+
+	db, err := Open(dbType, url)
+	require.NoError(err)
+	db.wrapped.Logger.LogMode(logger.Error)
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() {
+			assert.NoError(t, db.Close(context.Background()), "Got error closing db.")
+			if dropSchema != nil {
+				assert.NoError(t, dropSchema(), "Got error dropping schema.")
+			}
+		})
+	}
+	t.Cleanup(cleanup)
+
+	if opts.withTestDebug || strings.ToLower(os.Getenv("DEBUG")) == "true" {
+		db.Debug(true)
+	}
+
+	testMigrate(ctx, t, db, url, opts)
+	return db, cleanup
+}
+
+// getTestOptsFromEnv returns the test options, whose dialect and database url
+// are overridden by the DB_DIALECT and DB_DSN environment variables.  The
+// dialect defaults to sqlite and the test fails when the dialect is postgres
+// without a database url.
+func getTestOptsFromEnv(t *testing.T, opt ...TestOption) testOptions {
+	t.Helper()
+	opts := getTestOpts(opt...)
+
+	switch strings.ToLower(os.Getenv("DB_DIALECT")) {
+	case "postgres":
+		opts.withDialect = Postgres.String()
+	case "sqlite":
+		opts.withDialect = Sqlite.String()
+	default:
+		if opts.withDialect == "" {
+			opts.withDialect = Sqlite.String()
+		}
+	}
+
+	if url := os.Getenv("DB_DSN"); url != "" {
+		opts.withTestDatabaseUrl = url
+	}
+
+	if opts.withDialect == Postgres.String() && opts.withTestDatabaseUrl == "" {
+		t.Fatal("missing postgres test db url")
+	}
+	return opts
+}
+
+// testMigrate runs the test migrations for the db.  We're only going to run
+// one set of migrations.  Either one of the migration functions passed in as
+// an option or the default TestCreateTables(...)
+func testMigrate(ctx context.Context, t *testing.T, db *DB, url string, opts testOptions) {
+	t.Helper()
+	require := require.New(t)
 	switch {
 	case opts.withTestMigration != nil:
-		err = opts.withTestMigration(ctx, opts.withDialect, url)
+		err := opts.withTestMigration(ctx, opts.withDialect, url)
 		require.NoError(err)
 
 	case opts.withTestMigrationUsingDb != nil:
@@ -121,8 +209,8 @@ func TestSetup(t *testing.T, opt ...TestOption) (*DB, string) {
 			// the migration on an in-memory database that isn't shared
 			// "file::memory:" or ":memory:" so we have to be sure that we don't
 			// open a new connection, which would create a new in-memory db vs
-			// using the existing one already opened in this function a few
-			// lines above with: db, err := Open(dbType, url)
+			// using the existing one already opened by the caller with:
+			// db, err := Open(dbType, url)
 			// see: https://www.sqlite.org/inmemorydb.html
 			//
 			// luckily, the gorm sqlite ConnPool is the existing opened *sql.DB,
@@ -137,12 +225,11 @@ func TestSetup(t *testing.T, opt ...TestOption) (*DB, string) {
 				require.NoError(err)
 			}
 		}
-		err = opts.withTestMigrationUsingDb(ctx, rawDB)
+		err := opts.withTestMigrationUsingDb(ctx, rawDB)
 		require.NoError(err)
 	default:
 		TestCreateTables(t, db)
 	}
-	return db, url
 }
 
 // TestSetupWithMock will return a test DB and an associated Sqlmock which can
@@ -7,6 +7,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -101,6 +102,51 @@ func Test_TestSetup(t *testing.T) {
 	}
 }
 
+func Test_TestSetupIsolated(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert, require := assert.New(t), require.New(t)
+			db, cleanup := TestSetupIsolated(testCtx, t)
+			require.NotNil(cleanup)
+			rw := New(db)
+			// the same unique name is created by every subtest, which only
+			// succeeds when their databases are isolated
+			for i := 0; i < 10; i++ {
+				publicId, err := NewId("u")
+				require.NoError(err)
+				user := &testUser{PublicId: publicId, Name: fmt.Sprintf("user-%d", i)}
+				require.NoError(rw.Create(testCtx, user))
+			}
+			var users []*testUser
+			require.NoError(rw.SearchWhere(testCtx, &users, "", nil, WithLimit(-1)))
+			assert.Len(users, 10)
+
+			// the database is shared by the db's connections
+			sqlDB, err := db.SqlDB(testCtx)
+			require.NoError(err)
+			conns := make([]*sql.Conn, 0, 2)
+			for i := 0; i < 2; i++ {
+				conn, err := sqlDB.Conn(testCtx)
+				require.NoError(err)
+				conns = append(conns, conn)
+				var count int
+				require.NoError(conn.QueryRowContext(testCtx, "select count(*) from db_test_user").Scan(&count))
+				assert.Equal(10, count)
+			}
+			for _, conn := range conns {
+				require.NoError(conn.Close())
+			}
+
+			cleanup()
+			cleanup()
+		})
+	}
+}
+
 func Test_TestSetupWithMock(t *testing.T) {
 	assert := assert.New(t)
 	testCtx := context.Background()
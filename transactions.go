@@ -6,19 +6,83 @@ package dbw
 import (
 	"context"
 	"fmt"
+
+	"gorm.io/gorm"
 )
 
 // Begin will start a transaction
 func (rw *RW) Begin(ctx context.Context) (*RW, error) {
 	const op = "dbw.Begin"
-	newTx := rw.underlying.wrapped.WithContext(ctx)
-	newTx = newTx.Begin()
-	if newTx.Error != nil {
-		return nil, fmt.Errorf("%s: %w", op, newTx.Error)
-	}
-	return New(
-		&DB{wrapped: newTx},
-	), nil
+	tx, err := rw.begin(ctx, rw.getOpts())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tx, nil
+}
+
+// BeginTx will start a transaction like Begin, using the options provided.
+// The WithSnapshot option is supported.
+func (rw *RW) BeginTx(ctx context.Context, opt ...Option) (*RW, error) {
+	const op = "dbw.BeginTx"
+	tx, err := rw.begin(ctx, rw.getOpts(opt...))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tx, nil
+}
+
+// begin will start a transaction using the opts.
+func (rw *RW) begin(ctx context.Context, opts Options) (*RW, error) {
+	const op = "dbw.begin"
+	if rw.underlying == nil {
+		return nil, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	}
+	tx, err := rw.underlying.beginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if tx.wrapped.Error != nil {
+		return nil, fmt.Errorf("%s: %w", op, tx.wrapped.Error)
+	}
+	txRw := New(tx)
+	if opts.WithSnapshot != "" {
+		if err := txRw.setSnapshot(ctx, opts.WithSnapshot); err != nil {
+			if rollbackErr := txRw.Rollback(ctx); rollbackErr != nil {
+				return nil, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return txRw, nil
+}
+
+// TxID returns the id assigned to the transaction when it began and true.  If
+// the RW isn't a transaction started via Begin(...) or DoTx(...), then false
+// is returned.  The id is also included in the statements logged for
+// operations within the transaction, which allows them to be correlated.
+func (rw *RW) TxID() (string, bool) {
+	if rw.underlying == nil || rw.underlying.txId == "" {
+		return "", false
+	}
+	return rw.underlying.txId, true
+}
+
+// beginTx will start a transaction which is assigned a new transaction id and
+// return a DB for it.  Errors from starting the transaction are not returned,
+// but are available via the returned DB's wrapped.Error
+func (db *DB) beginTx(ctx context.Context) (*DB, error) {
+	const op = "dbw.(DB).beginTx"
+	txId, err := NewId("tx")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	newTx := db.wrapped.Session(&gorm.Session{
+		Context: ctx,
+		Logger:  newTxLogger(db.wrapped.Logger, txId),
+	})
+	tx := db.clone(newTx.Begin())
+	tx.txId = txId
+	return tx, nil
 }
 
 // Rollback will rollback the current transaction
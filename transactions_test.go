@@ -5,12 +5,17 @@ package dbw_test
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/go-dbw"
 	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
 )
 
 func TestRW_Transactions(t *testing.T) {
@@ -63,3 +68,82 @@ func TestRW_Transactions(t *testing.T) {
 		assert.Error(w.Commit(testCtx))
 	})
 }
+
+func TestRW_TxID(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	buf := new(strings.Builder)
+	testLogger := hclog.New(&hclog.LoggerOptions{
+		Mutex:  &sync.Mutex{},
+		Name:   "test",
+		Output: buf,
+		Level:  hclog.Debug,
+	})
+	conn, err := dbw.OpenWith(sqlite.Open("file::memory:"), dbw.WithLogger(gormDebugLogger{Logger: testLogger}), dbw.WithDebug(true))
+	require.NoError(t, err)
+	rw := dbw.New(conn)
+
+	t.Run("not-a-tx", func(t *testing.T) {
+		assert := assert.New(t)
+		id, ok := rw.TxID()
+		assert.False(ok)
+		assert.Empty(id)
+
+		id, ok = (&dbw.RW{}).TxID()
+		assert.False(ok)
+		assert.Empty(id)
+	})
+	t.Run("begin", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		defer func() { _ = tx.Rollback(testCtx) }()
+		id, ok := tx.TxID()
+		require.True(ok)
+		assert.True(strings.HasPrefix(id, "tx_"))
+
+		buf.Reset()
+		_, err = tx.Exec(testCtx, "select 'begin'", nil)
+		require.NoError(err)
+		assert.Contains(buf.String(), "select 'begin' /* tx_id: "+id+" */")
+
+		buf.Reset()
+		_, err = rw.Exec(testCtx, "select 'not-a-tx'", nil)
+		require.NoError(err)
+		assert.Contains(buf.String(), "select 'not-a-tx'")
+		assert.NotContains(buf.String(), "tx_id")
+	})
+	t.Run("do-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var txIds []string
+		for i := 0; i < 2; i++ {
+			buf.Reset()
+			_, err := rw.DoTx(testCtx, func(error) bool { return false }, 0, dbw.ExpBackoff{},
+				func(r dbw.Reader, w dbw.Writer) error {
+					id, ok := w.(*dbw.RW).TxID()
+					if !ok {
+						return errors.New("missing tx id")
+					}
+					readerId, ok := r.(*dbw.RW).TxID()
+					if !ok || readerId != id {
+						return errors.New("reader tx id doesn't match writer tx id")
+					}
+					txIds = append(txIds, id)
+					if _, err := w.Exec(testCtx, "select 'first'", nil); err != nil {
+						return err
+					}
+					rows, err := r.Query(testCtx, "select 'second'", nil)
+					if err != nil {
+						return err
+					}
+					return rows.Close()
+				})
+			require.NoError(err)
+			require.Len(txIds, i+1)
+			// both operations within the same transaction log the same id
+			assert.Contains(buf.String(), "select 'first' /* tx_id: "+txIds[i]+" */")
+			assert.Contains(buf.String(), "select 'second' /* tx_id: "+txIds[i]+" */")
+		}
+		assert.NotEqual(txIds[0], txIds[1])
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WithTriggersDisabled will disable the user triggers of the table, run fn and
+// then enable the triggers again, which is typically used to speed up a large
+// idempotent bulk load.  The triggers are enabled again even if fn returns an
+// error or panics.  If the RW is a transaction, then the triggers are disabled
+// and enabled within it, so a rollback also enables them.  The table may be
+// qualified by its schema (ex: public.users).
+//
+// It's only supported by Postgres and it requires the caller to be the owner
+// of the table or a superuser.  Disabling the triggers takes an ACCESS
+// EXCLUSIVE lock on the table and, while they're disabled, the triggers are
+// skipped for writes to the table from every session.
+func (rw *RW) WithTriggersDisabled(ctx context.Context, table string, fn func() error) (retErr error) {
+	const op = "dbw.WithTriggersDisabled"
+	switch {
+	case rw.underlying == nil:
+		return fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case table == "":
+		return fmt.Errorf("%s: missing table: %w", op, ErrInvalidParameter)
+	case fn == nil:
+		return fmt.Errorf("%s: missing func: %w", op, ErrInvalidParameter)
+	}
+	for _, name := range strings.Split(table, ".") {
+		if !identifierRegexp.MatchString(name) {
+			return fmt.Errorf("%s: invalid table %q: %w", op, table, ErrInvalidParameter)
+		}
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if dbType != Postgres {
+		return fmt.Errorf("%s: disabling triggers is not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+
+	if _, err := rw.Exec(ctx, fmt.Sprintf("alter table %s disable trigger user", table), nil); err != nil {
+		return fmt.Errorf("%s: unable to disable triggers: %w", op, err)
+	}
+	defer func() {
+		// the triggers must be enabled even when the ctx was cancelled by fn
+		enableCtx := ctx
+		if ctx.Err() != nil {
+			enableCtx = context.Background()
+		}
+		if _, err := rw.Exec(enableCtx, fmt.Sprintf("alter table %s enable trigger user", table), nil); err != nil {
+			if retErr != nil {
+				retErr = fmt.Errorf("%w (unable to enable triggers: %s)", retErr, err)
+				return
+			}
+			retErr = fmt.Errorf("%s: unable to enable triggers: %w", op, err)
+		}
+	}()
+	if err := fn(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRW_WithTriggersDisabled(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const (
+		disableSql = "alter table public.db_test_user disable trigger user"
+		enableSql  = "alter table public.db_test_user enable trigger user"
+	)
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectExec(regexp.QuoteMeta(disableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(enableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		called := false
+		err := rw.WithTriggersDisabled(testCtx, "public.db_test_user", func() error {
+			called = true
+			return nil
+		})
+		require.NoError(err)
+		assert.True(called)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("fn-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectExec(regexp.QuoteMeta(disableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(enableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		fnErr := errors.New("load failed")
+		err := rw.WithTriggersDisabled(testCtx, "public.db_test_user", func() error { return fnErr })
+		require.Error(err)
+		assert.ErrorIs(err, fnErr)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("fn-panic", func(t *testing.T) {
+		assert := assert.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectExec(regexp.QuoteMeta(disableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(enableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		assert.Panics(func() {
+			_ = rw.WithTriggersDisabled(testCtx, "public.db_test_user", func() error { panic("load panicked") })
+		})
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("enable-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectExec(regexp.QuoteMeta(disableSql)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(enableSql)).WillReturnError(errors.New("must be owner of table db_test_user"))
+		err := rw.WithTriggersDisabled(testCtx, "public.db_test_user", func() error { return nil })
+		require.Error(err)
+		assert.Contains(err.Error(), "unable to enable triggers")
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("disable-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, mock := TestSetupWithMock(t)
+		rw := New(conn)
+		mock.ExpectExec(regexp.QuoteMeta(disableSql)).WillReturnError(errors.New("must be owner of table db_test_user"))
+		called := false
+		err := rw.WithTriggersDisabled(testCtx, "public.db_test_user", func() error {
+			called = true
+			return nil
+		})
+		require.Error(err)
+		assert.Contains(err.Error(), "unable to disable triggers")
+		assert.False(called)
+		assert.NoError(mock.ExpectationsWereMet())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		conn, _ := TestSetup(t, WithTestDialect(Sqlite.String()))
+		err := New(conn).WithTriggersDisabled(testCtx, "db_test_user", func() error { return nil })
+		require.Error(err)
+		assert.ErrorIs(err, ErrInvalidParameter)
+		assert.Contains(err.Error(), "not supported by sqlite")
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		conn, _ := TestSetupWithMock(t)
+		rw := New(conn)
+		fn := func() error { return nil }
+		tests := []struct {
+			name            string
+			rw              *RW
+			table           string
+			fn              func() error
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &RW{}, "db_test_user", fn, "missing underlying db"},
+			{"missing-table", rw, "", fn, "missing table"},
+			{"missing-func", rw, "db_test_user", nil, "missing func"},
+			{"invalid-table", rw, "db_test_user; drop table db_test_user", fn, "invalid table"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				err := tt.rw.WithTriggersDisabled(testCtx, tt.table, tt.fn)
+				require.Error(err)
+				assert.ErrorIs(err, ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+			})
+		}
+	})
+}
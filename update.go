@@ -7,13 +7,27 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 var nonUpdateFields atomic.Value
 
+const (
+	// DefaultCreateTimeColumn is the default create time column (see:
+	// WithCreateTimeColumn)
+	DefaultCreateTimeColumn = "create_time"
+
+	// DefaultUpdateTimeColumn is the default update time column (see:
+	// WithUpdateTimeColumn)
+	DefaultUpdateTimeColumn = "update_time"
+)
+
 // InitNonUpdatableFields sets the fields which are not updatable using
 // via RW.Update(...)
 func InitNonUpdatableFields(fields []string) {
@@ -50,18 +64,34 @@ func NonUpdatableFields() []string {
 // always should be to rollback.  Update returns the number of rows updated.
 //
 // Supported options: WithBeforeWrite, WithAfterWrite, WithWhere, WithDebug,
-// WithTable and WithVersion. If WithVersion is used, then the update will
-// include the version number in the update where clause, which basically makes
-// the update use optimistic locking and the update will only succeed if the
-// existing rows version matches the WithVersion option. Zero is not a valid
-// value for the WithVersion option and will return an error. WithWhere allows
-// specifying an additional constraint on the operation in addition to the PKs.
-// WithDebug will turn on debugging for the update call.
+// WithTable, WithDryRun, WithNoDatabaseSideEffects, WithReturnOldValues,
+// WithCreateTimeColumn, WithUpdateTimeColumn and WithVersion. If WithVersion
+// is used, then the
+// update will include the version number in the update where clause, which
+// basically makes the update use optimistic locking and the update will only
+// succeed if the existing rows version matches the WithVersion option. Zero is
+// not a valid value for the WithVersion option and will return an error.
+// WithWhere allows specifying an additional constraint on the operation in
+// addition to the PKs. WithDebug will turn on debugging for the update call.
+// WithDryRun will generate the update statement without executing it.
+// WithNoDatabaseSideEffects skips the lookup which refreshes the resource
+// after the update. WithReturnOldValues will read the row's column values
+// before it's updated, within the same transaction as the update (a
+// transaction is started if the writer isn't already in one).
+// WithCreateTimeColumn and WithUpdateTimeColumn override the time columns,
+// which are managed by the database, so they're filtered out of the
+// fieldMaskPaths and setToNullPaths (see: DefaultCreateTimeColumn and
+// DefaultUpdateTimeColumn).
 func (rw *RW) Update(ctx context.Context, i interface{}, fieldMaskPaths []string, setToNullPaths []string, opt ...Option) (int, error) {
 	const op = "dbw.Update"
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
 	if rw.underlying == nil {
 		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
 	}
+	if rw.underlying.ReadOnly() {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
 	if isNil(i) {
 		return noRowsAffected, fmt.Errorf("%s: missing interface: %w", op, ErrInvalidParameter)
 	}
@@ -71,11 +101,27 @@ func (rw *RW) Update(ctx context.Context, i interface{}, fieldMaskPaths []string
 	if len(fieldMaskPaths) == 0 && len(setToNullPaths) == 0 {
 		return noRowsAffected, fmt.Errorf("%s: both fieldMaskPaths and setToNullPaths are missing: %w", op, ErrInvalidParameter)
 	}
-	opts := GetOpts(opt...)
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, i, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	if opts.WithReturnOldValues != nil && opts.WithDryRun == nil && !rw.IsTx() {
+		// the old values must be read in the same transaction as the update
+		return rw.updateInTx(ctx, i, fieldMaskPaths, setToNullPaths, opt...)
+	}
 
 	// we need to filter out some non-updatable fields (like: CreateTime, etc)
 	fieldMaskPaths = filterPaths(fieldMaskPaths)
 	setToNullPaths = filterPaths(setToNullPaths)
+
+	// the time columns are managed by the database
+	timeFields, err := rw.timeFields(i, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	fieldMaskPaths = filterFieldPaths(fieldMaskPaths, timeFields)
+	setToNullPaths = filterFieldPaths(setToNullPaths, timeFields)
 	if len(fieldMaskPaths) == 0 && len(setToNullPaths) == 0 {
 		return noRowsAffected, fmt.Errorf("%s: after filtering non-updated fields, there are no fields left in fieldMaskPaths or setToNullPaths: %w", op, ErrInvalidParameter)
 	}
@@ -118,11 +164,27 @@ func (rw *RW) Update(ctx context.Context, i interface{}, fieldMaskPaths []string
 			}
 		}
 	}
+	if opts.WithReturnOldValues != nil && opts.WithDryRun == nil {
+		oldValues, err := rw.oldValues(ctx, i, opts)
+		if err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		*opts.WithReturnOldValues = oldValues
+	}
 	if opts.WithBeforeWrite != nil {
 		if err := opts.WithBeforeWrite(i); err != nil {
 			return noRowsAffected, fmt.Errorf("%s: error before write: %w", op, err)
 		}
 	}
+	if len(rw.underlying.writeCallbacks) > 0 {
+		if err := rw.runWriteCallbacks(ctx, i); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		// the callbacks may have set fields of the field mask
+		if updateFields, err = UpdateFields(i, fieldMaskPaths, setToNullPaths); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: getting update fields failed: %w", op, err)
+		}
+	}
 	underlying := rw.underlying.wrapped.Model(i)
 	if opts.WithDebug {
 		underlying = underlying.Debug()
@@ -130,6 +192,9 @@ func (rw *RW) Update(ctx context.Context, i interface{}, fieldMaskPaths []string
 	if opts.WithTable != "" {
 		underlying = underlying.Table(opts.WithTable)
 	}
+	if opts.WithDryRun != nil {
+		underlying = underlying.Session(&gorm.Session{DryRun: true})
+	}
 	switch {
 	case opts.WithVersion != nil || opts.WithWhereClause != "":
 		where, args, err := rw.whereClausesFromOpts(ctx, i, opts)
@@ -146,6 +211,10 @@ func (rw *RW) Update(ctx context.Context, i interface{}, fieldMaskPaths []string
 		}
 		return noRowsAffected, fmt.Errorf("%s: %w", op, underlying.Error)
 	}
+	if opts.WithDryRun != nil {
+		*opts.WithDryRun = dryRunSql(underlying)
+		return noRowsAffected, nil
+	}
 	rowsUpdated := int(underlying.RowsAffected)
 	if rowsUpdated > 0 && (opts.WithAfterWrite != nil) {
 		if err := opts.WithAfterWrite(i, rowsUpdated); err != nil {
@@ -161,6 +230,116 @@ func (rw *RW) Update(ctx context.Context, i interface{}, fieldMaskPaths []string
 	return rowsUpdated, nil
 }
 
+// updateInTx will run the update within a new transaction.
+func (rw *RW) updateInTx(ctx context.Context, i interface{}, fieldMaskPaths []string, setToNullPaths []string, opt ...Option) (int, error) {
+	const op = "dbw.updateInTx"
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	rowsUpdated, err := tx.Update(ctx, i, fieldMaskPaths, setToNullPaths, opt...)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+		}
+		return noRowsAffected, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	return rowsUpdated, nil
+}
+
+// oldValues reads the column values of the resource's row using its primary
+// keys.  The row is locked for update when the dialect supports it.  An empty
+// map is returned if the row doesn't exist.
+func (rw *RW) oldValues(ctx context.Context, i interface{}, opts Options) (map[string]interface{}, error) {
+	const op = "dbw.oldValues"
+	where, keys, err := rw.primaryKeysWhere(ctx, i)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	_, tableName, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Table(tableName).Where(where, keys...)
+	if dbType, _, err := rw.underlying.DbType(); err == nil && (dbType == Postgres || dbType == CockroachDB) {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	oldValues := map[string]interface{}{}
+	if err := query.Take(&oldValues).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return oldValues, nil
+}
+
+// timeFields returns the resource's fields for its create and update time
+// columns, which are resolved using the WithCreateTimeColumn and
+// WithUpdateTimeColumn options, then the DB's time columns, and then their
+// defaults.  A time column which isn't in the resource's schema is ignored.
+func (rw *RW) timeFields(i interface{}, opts Options) ([]*schema.Field, error) {
+	const op = "dbw.timeFields"
+	s, _, err := rw.parseSchema(i, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	createTimeColumn, updateTimeColumn := rw.timeColumns(opts)
+	var fields []*schema.Field
+	for _, col := range []string{createTimeColumn, updateTimeColumn} {
+		if f, ok := s.FieldsByDBName[col]; ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// timeColumns returns the create and update time columns, which are resolved
+// using the WithCreateTimeColumn and WithUpdateTimeColumn options, then the
+// DB's time columns, and then their defaults.
+func (rw *RW) timeColumns(opts Options) (createTimeColumn, updateTimeColumn string) {
+	createTimeColumn, updateTimeColumn = rw.underlying.createTimeColumn, rw.underlying.updateTimeColumn
+	if opts.WithCreateTimeColumn != "" {
+		createTimeColumn = opts.WithCreateTimeColumn
+	}
+	if opts.WithUpdateTimeColumn != "" {
+		updateTimeColumn = opts.WithUpdateTimeColumn
+	}
+	if createTimeColumn == "" {
+		createTimeColumn = DefaultCreateTimeColumn
+	}
+	if updateTimeColumn == "" {
+		updateTimeColumn = DefaultUpdateTimeColumn
+	}
+	return createTimeColumn, updateTimeColumn
+}
+
+// filterFieldPaths will filter out the paths for the fields, where a path
+// matches a field's name or column case insensitively.
+func filterFieldPaths(paths []string, fields []*schema.Field) []string {
+	if len(paths) == 0 || len(fields) == 0 {
+		return paths
+	}
+	var filtered []string
+	for _, p := range paths {
+		matched := false
+		for _, f := range fields {
+			if strings.EqualFold(p, f.Name) || strings.EqualFold(p, f.DBName) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
 // filterPaths will filter out non-updatable fields
 func filterPaths(paths []string) []string {
 	if len(paths) == 0 {
@@ -181,3 +360,129 @@ func filterPaths(paths []string) []string {
 	}
 	return filtered
 }
+
+// UpdateWhere will update all the resources matching the where clause with
+// parameters, setting the columns to the values of columnValues, and it
+// returns the number of rows updated.  The resource is used to determine the
+// table and columns of the rows being updated, and a value may be an
+// Expr(...) (ex: Expr("version + 1")).  The columns must exist in the
+// resource's schema and they can't be primary keys.  Unlike Update, no
+// fields of the resource are read and it's not refreshed after the update.
+// The WithDebug, WithTable and WithReturningResults options are supported.
+func (rw *RW) UpdateWhere(ctx context.Context, resource interface{}, columnValues map[string]interface{}, where string, args []interface{}, opt ...Option) (int, error) {
+	const op = "dbw.UpdateWhere"
+	switch {
+	case rw.underlying == nil:
+		return noRowsAffected, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return noRowsAffected, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case isNil(resource):
+		return noRowsAffected, fmt.Errorf("%s: missing resource: %w", op, ErrInvalidParameter)
+	case len(columnValues) == 0:
+		return noRowsAffected, fmt.Errorf("%s: missing column values: %w", op, ErrInvalidParameter)
+	case where == "":
+		return noRowsAffected, fmt.Errorf("%s: missing where clause: %w", op, ErrInvalidParameter)
+	}
+	if err := raiseErrorOnHooks(resource); err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts, err := rw.resolveTable(ctx, resource, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(resource, opts)
+	if err != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+	}
+	updates := make(map[string]interface{}, len(columnValues))
+	for column, value := range columnValues {
+		f, ok := s.FieldsByDBName[column]
+		switch {
+		case !ok:
+			return noRowsAffected, fmt.Errorf("%s: unknown column %s: %w", op, column, ErrInvalidParameter)
+		case f.PrimaryKey:
+			return noRowsAffected, fmt.Errorf("%s: primary key column %s cannot be updated: %w", op, column, ErrInvalidParameter)
+		}
+		switch v := value.(type) {
+		case ExprValue:
+			updates[column] = gorm.Expr(v.Sql, v.Vars...)
+		case *ExprValue:
+			updates[column] = gorm.Expr(v.Sql, v.Vars...)
+		default:
+			updates[column] = value
+		}
+	}
+
+	ctx, cancel := rw.writeContext(ctx)
+	defer cancel()
+	db := rw.underlying.wrapped.WithContext(ctx)
+	if opts.WithDebug {
+		db = db.Debug()
+	}
+	if opts.WithReturningResults != nil {
+		if err := rw.validateReturningResults(ctx, resource, opts.WithReturningResults); err != nil {
+			return noRowsAffected, fmt.Errorf("%s: %w", op, err)
+		}
+		// the returned rows replace any existing elements of the results
+		results := reflect.ValueOf(opts.WithReturningResults).Elem()
+		results.Set(reflect.MakeSlice(results.Type(), 0, 0))
+		db = db.Model(opts.WithReturningResults).Clauses(clause.Returning{})
+	}
+	db = db.Table(tableName).Where(where, args...).Updates(updates)
+	if db.Error != nil {
+		return noRowsAffected, fmt.Errorf("%s: %w", op, db.Error)
+	}
+	return int(db.RowsAffected), nil
+}
+
+// minSqliteReturningVersion is the first version of sqlite which supports a
+// returning clause.
+var minSqliteReturningVersion = []int{3, 35, 0}
+
+// validateReturningResults returns an error when the results of
+// WithReturningResults aren't a pointer to a slice of pointers to the
+// resource's type, or when the database doesn't support a returning clause.
+func (rw *RW) validateReturningResults(ctx context.Context, resource interface{}, results interface{}) error {
+	const op = "dbw.validateReturningResults"
+	rt := reflect.TypeOf(results)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%s: returning results must be a pointer to a slice: %w", op, ErrInvalidParameter)
+	}
+	resourceType := reflect.TypeOf(resource)
+	if resourceType.Kind() != reflect.Ptr {
+		resourceType = reflect.PtrTo(resourceType)
+	}
+	if rt.Elem().Elem() != resourceType {
+		return fmt.Errorf("%s: returning results must be a pointer to a slice of %s: %w", op, resourceType, ErrInvalidParameter)
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	switch dbType {
+	case Postgres, CockroachDB:
+		return nil
+	case Sqlite:
+		var version string
+		if err := rw.underlying.wrapped.WithContext(ctx).Raw("select sqlite_version()").Scan(&version).Error; err != nil {
+			return fmt.Errorf("%s: unable to read sqlite version: %w", op, err)
+		}
+		parts := strings.Split(version, ".")
+		for i, min := range minSqliteReturningVersion {
+			var v int
+			if i < len(parts) {
+				v, _ = strconv.Atoi(parts[i])
+			}
+			if v != min {
+				if v > min {
+					return nil
+				}
+				return fmt.Errorf("%s: returning results are not supported by sqlite %s: %w", op, version, ErrInvalidParameter)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: returning results are not supported by %s: %w", op, dbType, ErrInvalidParameter)
+	}
+}
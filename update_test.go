@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-dbw"
 	"github.com/hashicorp/go-dbw/internal/dbtest"
@@ -493,6 +494,38 @@ func TestDb_Update(t *testing.T) {
 			assert.Equal(u.Version+1, foundUser.Version)
 		})
 	}
+	t.Run("dry-run", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		w := dbw.New(conn)
+		user := testUser(t, w, "dry-run-user", "dry-run@example.com", "")
+		updatedUser := user.Clone().(*dbtest.TestUser)
+		updatedUser.Name = "dry-run-updated"
+		updatedUser.Email = "ignored@example.com"
+		var sql string
+		rowsUpdated, err := w.Update(context.Background(), updatedUser, []string{"Name"}, nil, dbw.WithDryRun(&sql))
+		require.NoError(err)
+		assert.Equal(0, rowsUpdated)
+		lowerSql := strings.ToLower(sql)
+		assert.Contains(lowerSql, "update")
+		assert.Contains(sql, "db_test_user")
+		assert.Contains(sql, "dry-run-updated")
+		assert.Contains(sql, user.PublicId)
+		// only the field mask paths are updated
+		assert.NotContains(sql, "ignored@example.com")
+		assert.NotContains(lowerSql, "email")
+
+		// the user was not updated
+		foundUser := dbtest.AllocTestUser()
+		foundUser.PublicId = user.PublicId
+		require.NoError(w.LookupByPublicId(context.Background(), &foundUser))
+		assert.Equal(user.Name, foundUser.Name)
+
+		// validation still runs
+		sql = ""
+		_, err = w.Update(context.Background(), updatedUser, []string{"PublicId"}, nil, dbw.WithDryRun(&sql))
+		require.Error(err)
+		assert.Empty(sql)
+	})
 	t.Run("no-version-field", func(t *testing.T) {
 		assert := assert.New(t)
 		testCarFn := func(t *testing.T, rw *dbw.RW, name, model string, mpg int32) *dbtest.TestCar {
@@ -615,3 +648,255 @@ func TestDb_Update(t *testing.T) {
 		}
 	})
 }
+
+func TestDb_Update_ReturnOldValues(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	t.Run("without-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		user := testUser(t, rw, "old-name", "", "")
+		user.Name = "new-name"
+		var oldValues map[string]interface{}
+		rowsUpdated, err := rw.Update(testCtx, user, []string{"Name"}, nil, dbw.WithReturnOldValues(&oldValues))
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal("old-name", oldValues["name"])
+		assert.Equal(user.PublicId, oldValues["public_id"])
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = user.PublicId
+		require.NoError(rw.LookupByPublicId(testCtx, &found))
+		assert.Equal("new-name", found.Name)
+	})
+	t.Run("with-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		user := testUser(t, rw, "old-tx-name", "", "")
+		tx, err := rw.Begin(testCtx)
+		require.NoError(err)
+		user.Name = "new-tx-name"
+		var oldValues map[string]interface{}
+		rowsUpdated, err := tx.Update(testCtx, user, []string{"Name"}, nil, dbw.WithReturnOldValues(&oldValues))
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal("old-tx-name", oldValues["name"])
+		require.NoError(tx.Rollback(testCtx))
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = user.PublicId
+		require.NoError(rw.LookupByPublicId(testCtx, &found))
+		assert.Equal("old-tx-name", found.Name)
+	})
+	t.Run("failed-update-is-rolled-back", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		user := testUser(t, rw, "rollback-name", "", "")
+		other := testUser(t, rw, "other-name", "", "")
+		user.Name = other.Name
+		var oldValues map[string]interface{}
+		rowsUpdated, err := rw.Update(testCtx, user, []string{"Name"}, nil, dbw.WithReturnOldValues(&oldValues))
+		require.Error(err)
+		assert.Equal(0, rowsUpdated)
+		assert.Equal("rollback-name", oldValues["name"])
+	})
+	t.Run("not-found", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw := dbw.New(conn)
+		user := testUser(t, nil, "missing-name", "", "")
+		var oldValues map[string]interface{}
+		rowsUpdated, err := rw.Update(testCtx, user, []string{"Name"}, nil, dbw.WithReturnOldValues(&oldValues))
+		require.Error(err)
+		assert.Equal(0, rowsUpdated)
+		assert.Empty(oldValues)
+	})
+}
+
+// testTimeColumnsModel uses created_at and updated_at time columns, rather
+// than the default create_time and update_time.
+type testTimeColumnsModel struct {
+	PublicId string    `gorm:"primaryKey"`
+	Name     string    `gorm:"default:null"`
+	Created  time.Time `gorm:"column:created_at"`
+	Updated  time.Time `gorm:"column:updated_at"`
+}
+
+func (*testTimeColumnsModel) TableName() string { return "db_test_time_columns" }
+
+func TestDb_Update_TimeColumns(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	const createTable = `create table db_test_time_columns (
+		public_id text primary key,
+		name text,
+		created_at timestamp not null,
+		updated_at timestamp not null
+	)`
+	originalTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	newTime := time.Date(2022, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	setup := func(t *testing.T, opt ...dbw.Option) (*dbw.RW, *testTimeColumnsModel) {
+		t.Helper()
+		require := require.New(t)
+		conn, err := dbw.Open(dbw.Sqlite, "file::memory:", opt...)
+		require.NoError(err)
+		t.Cleanup(func() { _ = conn.Close(testCtx) })
+		rw := dbw.New(conn)
+		_, err = rw.Exec(testCtx, createTable, nil)
+		require.NoError(err)
+		id, err := dbw.NewId("t")
+		require.NoError(err)
+		m := &testTimeColumnsModel{PublicId: id, Name: "alice", Created: originalTime, Updated: originalTime}
+		require.NoError(rw.Create(testCtx, m))
+		return rw, m
+	}
+	updateAll := func(m *testTimeColumnsModel) *testTimeColumnsModel {
+		return &testTimeColumnsModel{PublicId: m.PublicId, Name: "bob", Created: newTime, Updated: newTime}
+	}
+
+	t.Run("default-columns", func(t *testing.T) {
+		// the default time columns aren't in the schema, so nothing is filtered
+		assert, require := assert.New(t), require.New(t)
+		rw, m := setup(t)
+		updated := updateAll(m)
+		rowsUpdated, err := rw.Update(testCtx, updated, []string{"Name", "Created", "Updated"}, nil)
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal("bob", updated.Name)
+		assert.True(newTime.Equal(updated.Created))
+		assert.True(newTime.Equal(updated.Updated))
+	})
+	t.Run("per-operation", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, m := setup(t)
+		updated := updateAll(m)
+		rowsUpdated, err := rw.Update(testCtx, updated, []string{"Name", "Created", "updated_at"}, nil,
+			dbw.WithCreateTimeColumn("created_at"),
+			dbw.WithUpdateTimeColumn("updated_at"),
+		)
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal("bob", updated.Name)
+		assert.True(originalTime.Equal(updated.Created))
+		assert.True(originalTime.Equal(updated.Updated))
+	})
+	t.Run("open", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rw, m := setup(t, dbw.WithCreateTimeColumn("created_at"), dbw.WithUpdateTimeColumn("updated_at"))
+		updated := updateAll(m)
+		rowsUpdated, err := rw.Update(testCtx, updated, []string{"Name", "Created", "Updated"}, nil)
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal("bob", updated.Name)
+		assert.True(originalTime.Equal(updated.Created))
+		assert.True(originalTime.Equal(updated.Updated))
+
+		// only time columns leaves nothing to update
+		rowsUpdated, err = rw.Update(testCtx, updateAll(m), []string{"Created"}, []string{"Updated"})
+		require.Error(err)
+		assert.ErrorIs(err, dbw.ErrInvalidParameter)
+		assert.Zero(rowsUpdated)
+	})
+}
+
+func TestDb_UpdateWhere(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	id, err := dbw.NewId("u")
+	require.NoError(t, err)
+	prefix := "update-where-" + id
+	var users []*dbtest.TestUser
+	for _, suffix := range []string{"a", "b", "c"} {
+		users = append(users, testUser(t, testRw, prefix+"-"+suffix, "", ""))
+	}
+	lookup := func(t *testing.T, publicId string) *dbtest.TestUser {
+		t.Helper()
+		found := dbtest.AllocTestUser()
+		found.PublicId = publicId
+		require.NoError(t, testRw.LookupByPublicId(testCtx, &found))
+		return &found
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		rowsUpdated, err := testRw.UpdateWhere(testCtx, &dbtest.TestUser{},
+			map[string]interface{}{"email": "updated@example.com", "phone_number": dbw.Expr("name")},
+			"name in (?, ?)", []interface{}{prefix + "-a", prefix + "-b"},
+		)
+		require.NoError(err)
+		assert.Equal(2, rowsUpdated)
+		for _, u := range users[:2] {
+			found := lookup(t, u.PublicId)
+			assert.Equal("updated@example.com", found.Email)
+			assert.Equal(u.Name, found.PhoneNumber)
+		}
+		assert.Empty(lookup(t, users[2].PublicId).Email)
+	})
+	t.Run("returning-results", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		// existing results are replaced
+		results := []*dbtest.TestUser{{}}
+		rowsUpdated, err := testRw.UpdateWhere(testCtx, &dbtest.TestUser{},
+			map[string]interface{}{"email": "returned@example.com"},
+			"name like ?", []interface{}{prefix + "-%"},
+			dbw.WithReturningResults(&results),
+		)
+		require.NoError(err)
+		assert.Equal(3, rowsUpdated)
+		require.Len(results, 3)
+		names := make([]string, 0, len(results))
+		for _, r := range results {
+			assert.NotEmpty(r.PublicId)
+			assert.Equal("returned@example.com", r.Email)
+			names = append(names, r.Name)
+		}
+		assert.ElementsMatch([]string{prefix + "-a", prefix + "-b", prefix + "-c"}, names)
+	})
+	t.Run("no-rows", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		var results []*dbtest.TestUser
+		rowsUpdated, err := testRw.UpdateWhere(testCtx, &dbtest.TestUser{},
+			map[string]interface{}{"email": "none@example.com"},
+			"name = ?", []interface{}{prefix},
+			dbw.WithReturningResults(&results),
+		)
+		require.NoError(err)
+		assert.Equal(0, rowsUpdated)
+		assert.Empty(results)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		var results []*dbtest.TestUser
+		var cars []*dbtest.TestCar
+		tests := []struct {
+			name            string
+			rw              *dbw.RW
+			resource        interface{}
+			columnValues    map[string]interface{}
+			where           string
+			opt             []dbw.Option
+			wantErrContains string
+		}{
+			{"missing-underlying-db", &dbw.RW{}, &dbtest.TestUser{}, map[string]interface{}{"email": "x"}, "name = ?", nil, "missing underlying db"},
+			{"missing-resource", testRw, nil, map[string]interface{}{"email": "x"}, "name = ?", nil, "missing resource"},
+			{"missing-column-values", testRw, &dbtest.TestUser{}, nil, "name = ?", nil, "missing column values"},
+			{"missing-where", testRw, &dbtest.TestUser{}, map[string]interface{}{"email": "x"}, "", nil, "missing where clause"},
+			{"unknown-column", testRw, &dbtest.TestUser{}, map[string]interface{}{"unknown": "x"}, "name = ?", nil, "unknown column unknown"},
+			{"primary-key", testRw, &dbtest.TestUser{}, map[string]interface{}{"public_id": "x"}, "name = ?", nil, "primary key column public_id"},
+			{"results-not-slice", testRw, &dbtest.TestUser{}, map[string]interface{}{"email": "x"}, "name = ?", []dbw.Option{dbw.WithReturningResults(results)}, "must be a pointer to a slice"},
+			{"results-wrong-type", testRw, &dbtest.TestUser{}, map[string]interface{}{"email": "x"}, "name = ?", []dbw.Option{dbw.WithReturningResults(&cars)}, "must be a pointer to a slice of *dbtest.TestUser"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				rowsUpdated, err := tt.rw.UpdateWhere(testCtx, tt.resource, tt.columnValues, tt.where, []interface{}{prefix}, tt.opt...)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Equal(0, rowsUpdated)
+			})
+		}
+	})
+}
@@ -0,0 +1,261 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// UpsertResult is the result of a row which was inserted or updated by
+// UpsertItems.
+type UpsertResult struct {
+	// PrimaryKey is the row's primary key values by column name
+	PrimaryKey map[string]interface{}
+
+	// Inserted is true when the row was inserted and false when an existing
+	// row was updated.
+	Inserted bool
+}
+
+// UpsertItems will insert the items, which must be pointers to the same type,
+// using a single insert with the on conflict, and return a result for each row
+// which was inserted or updated, which reports whether it was inserted.  It's
+// typically used by sync jobs which need to know which rows are new.  Rows
+// which were neither inserted nor updated (ex: a DoNothing action) have no
+// result.  The results are in the order the rows are returned by the database,
+// so their PrimaryKey should be used to correlate them with the items.
+//
+// For Postgres, the results are exact, since they're determined by the
+// returned rows' xmax.  For Sqlite and CockroachDB, the results are an
+// approximation: the primary keys of the rows which match the on conflict's
+// Columns target are read before the insert, within the same transaction, and
+// a returned row whose primary key was read is reported as updated.  A row
+// inserted by another connection between the read and the insert is reported
+// as updated.  A transaction is started if the writer isn't already in one.
+//
+// Supported options: WithTable, WithVersion and WithWhere.
+func (rw *RW) UpsertItems(ctx context.Context, items []interface{}, conflict OnConflict, opt ...Option) ([]UpsertResult, error) {
+	const op = "dbw.UpsertItems"
+	switch {
+	case rw.underlying == nil:
+		return nil, fmt.Errorf("%s: missing underlying db: %w", op, ErrInvalidParameter)
+	case rw.underlying.ReadOnly():
+		return nil, fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case len(items) == 0:
+		return nil, fmt.Errorf("%s: missing items: %w", op, ErrInvalidParameter)
+	}
+	itemType := reflect.TypeOf(items[0])
+	for idx, item := range items {
+		switch {
+		case isNil(item):
+			return nil, fmt.Errorf("%s: item %d is nil: %w", op, idx, ErrInvalidParameter)
+		case reflect.TypeOf(item) != itemType:
+			return nil, fmt.Errorf("%s: items contains disparate types. item %d is not a %s: %w", op, idx, itemType, ErrInvalidParameter)
+		case itemType.Kind() != reflect.Ptr:
+			return nil, fmt.Errorf("%s: item %d is not a pointer: %w", op, idx, ErrInvalidParameter)
+		}
+	}
+	if err := raiseErrorOnHooks(items[0]); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	opts := rw.getOpts(opt...)
+	opts.WithOnConflict = &conflict
+	opts, err := rw.resolveTable(ctx, items[0], opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s, tableName, err := rw.parseSchema(items[0], opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(s.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("%s: no primary key(s) for %s: %w", op, s.Table, ErrInvalidParameter)
+	}
+	dbType, _, err := rw.underlying.DbType()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	exact := dbType == Postgres
+	var target Columns
+	if !exact {
+		var ok bool
+		if target, ok = conflict.Target.(Columns); !ok {
+			return nil, fmt.Errorf("%s: on conflict target must be columns for %s: %w", op, dbType, ErrInvalidParameter)
+		}
+	}
+
+	valItems := reflect.MakeSlice(reflect.SliceOf(itemType), 0, len(items))
+	for _, item := range items {
+		setFieldsToNil(item, NonCreatableFields())
+		valItems = reflect.Append(valItems, reflect.ValueOf(item))
+	}
+
+	upsert := func(w *RW) ([]UpsertResult, error) {
+		var existing map[string]bool
+		if !exact {
+			var err error
+			if existing, err = w.existingPrimaryKeys(ctx, s, tableName, target, items); err != nil {
+				return nil, err
+			}
+		}
+		db := w.underlying.wrapped.WithContext(ctx)
+		c, err := w.onConflictClause(ctx, db, items[0], opts)
+		if err != nil {
+			return nil, err
+		}
+		returning := make([]clause.Column, 0, len(s.PrimaryFields)+1)
+		for _, f := range s.PrimaryFields {
+			returning = append(returning, clause.Column{Name: f.DBName})
+		}
+		if exact {
+			// a row which was inserted by the statement has no xmax
+			returning = append(returning, clause.Column{Name: "(xmax = 0)", Raw: true})
+		}
+		stmtDb := db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true, Logger: logger.Discard}).Clauses(c, clause.Returning{Columns: returning})
+		if opts.WithTable != "" {
+			stmtDb = stmtDb.Table(opts.WithTable)
+		}
+		stmtDb = stmtDb.Create(valItems.Interface())
+		if stmtDb.Error != nil {
+			return nil, fmt.Errorf("create failed: %w", stmtDb.Error)
+		}
+		// the statement is executed using Raw, so it runs through the
+		// callbacks (logging, WithMaxConcurrentOps, etc)
+		rows, err := db.Raw(stmtDb.Statement.SQL.String(), renderedVars(stmtDb.Statement.Vars)...).Rows()
+		if err != nil {
+			return nil, fmt.Errorf("create failed: %w", err)
+		}
+		defer rows.Close()
+		var results []UpsertResult
+		for rows.Next() {
+			values := make([]interface{}, len(returning))
+			dest := make([]interface{}, len(returning))
+			for idx := range values {
+				dest[idx] = &values[idx]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return nil, fmt.Errorf("unable to scan returned row: %w", err)
+			}
+			result := UpsertResult{PrimaryKey: make(map[string]interface{}, len(s.PrimaryFields))}
+			for idx, f := range s.PrimaryFields {
+				result.PrimaryKey[f.DBName] = normalizeScannedValue(values[idx])
+			}
+			if exact {
+				result.Inserted, _ = values[len(values)-1].(bool)
+			} else {
+				result.Inserted = !existing[primaryKeyString(s, result.PrimaryKey)]
+			}
+			results = append(results, result)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("unable to read returned rows: %w", err)
+		}
+		return results, nil
+	}
+
+	if rw.IsTx() || exact {
+		results, err := upsert(rw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return results, nil
+	}
+	tx, err := rw.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	results, err := upsert(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			return nil, fmt.Errorf("%s: %w (rollback failed: %s)", op, err, rollbackErr)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return results, nil
+}
+
+// existingPrimaryKeys returns the primary keys (see: primaryKeyString) of the
+// rows which match the items on the target columns.
+func (rw *RW) existingPrimaryKeys(ctx context.Context, s *schema.Schema, tableName string, target Columns, items []interface{}) (map[string]bool, error) {
+	const op = "dbw.existingPrimaryKeys"
+	fields := make([]*schema.Field, 0, len(target))
+	for _, col := range target {
+		f := s.LookUpField(col)
+		if f == nil || f.DBName == "" {
+			return nil, fmt.Errorf("%s: conflict target column %s not found in resource: %w", op, col, ErrInvalidParameter)
+		}
+		fields = append(fields, f)
+	}
+	conditions := make([]string, 0, len(items))
+	args := make([]interface{}, 0, len(items)*len(fields))
+	for _, item := range items {
+		columns := make([]string, 0, len(fields))
+		for _, f := range fields {
+			v, _ := f.ValueOf(ctx, reflect.ValueOf(item))
+			columns = append(columns, f.DBName+" = ?")
+			args = append(args, v)
+		}
+		conditions = append(conditions, "("+strings.Join(columns, " and ")+")")
+	}
+	pkColumns := make([]string, 0, len(s.PrimaryFields))
+	for _, f := range s.PrimaryFields {
+		pkColumns = append(pkColumns, f.DBName)
+	}
+	query := fmt.Sprintf("select %s from %s where %s", strings.Join(pkColumns, ", "), rw.underlying.wrapped.Statement.Quote(tableName), strings.Join(conditions, " or "))
+	rows, err := rw.underlying.wrapped.WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to read existing rows: %w", op, err)
+	}
+	defer rows.Close()
+	existing := map[string]bool{}
+	for rows.Next() {
+		values := make([]interface{}, len(pkColumns))
+		dest := make([]interface{}, len(pkColumns))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("%s: unable to scan existing row: %w", op, err)
+		}
+		pk := make(map[string]interface{}, len(pkColumns))
+		for idx, col := range pkColumns {
+			pk[col] = normalizeScannedValue(values[idx])
+		}
+		existing[primaryKeyString(s, pk)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: unable to read existing rows: %w", op, err)
+	}
+	return existing, nil
+}
+
+// primaryKeyString returns a string which identifies the primary key values,
+// which is used to compare primary keys.
+func primaryKeyString(s *schema.Schema, pk map[string]interface{}) string {
+	parts := make([]string, 0, len(s.PrimaryFields))
+	for _, f := range s.PrimaryFields {
+		parts = append(parts, fmt.Sprintf("%v", pk[f.DBName]))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// normalizeScannedValue returns a value scanned into an interface{}, with
+// []byte values (which some drivers return for text) converted to a string.
+func normalizeScannedValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
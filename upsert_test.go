@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/hashicorp/go-dbw/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDb_UpsertItems(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	conn, _ := dbw.TestSetup(t)
+	testRw := dbw.New(conn)
+	onConflict := dbw.OnConflict{
+		Target: dbw.Columns{"public_id"},
+		Action: dbw.SetColumns([]string{"name", "email"}),
+	}
+
+	t.Run("inserted-and-updated", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, testRw, "upsert-items-existing", "", "")
+		updated := testUser(t, nil, "upsert-items-updated", "updated@example.com", "")
+		updated.PublicId = existing.PublicId
+		inserted := testUser(t, nil, "upsert-items-inserted", "inserted@example.com", "")
+
+		results, err := testRw.UpsertItems(testCtx, []interface{}{updated, inserted}, onConflict)
+		require.NoError(err)
+		require.Len(results, 2)
+		gotInserted := map[interface{}]bool{}
+		for _, r := range results {
+			gotInserted[r.PrimaryKey["public_id"]] = r.Inserted
+		}
+		assert.Equal(map[interface{}]bool{existing.PublicId: false, inserted.PublicId: true}, gotInserted)
+
+		found := dbtest.AllocTestUser()
+		found.PublicId = existing.PublicId
+		require.NoError(testRw.LookupByPublicId(testCtx, &found))
+		assert.Equal("upsert-items-updated", found.Name)
+		assert.Equal("updated@example.com", found.Email)
+	})
+	t.Run("do-nothing", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, testRw, "upsert-items-do-nothing", "", "")
+		conflict := testUser(t, nil, "upsert-items-do-nothing-conflict", "", "")
+		conflict.PublicId = existing.PublicId
+		inserted := testUser(t, nil, "upsert-items-do-nothing-inserted", "", "")
+		results, err := testRw.UpsertItems(testCtx, []interface{}{conflict, inserted}, dbw.OnConflict{
+			Target: dbw.Columns{"public_id"},
+			Action: dbw.DoNothing(true),
+		})
+		require.NoError(err)
+		require.Len(results, 1)
+		assert.Equal(inserted.PublicId, results[0].PrimaryKey["public_id"])
+		assert.True(results[0].Inserted)
+	})
+	t.Run("in-tx", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		existing := testUser(t, testRw, "upsert-items-tx", "", "")
+		updated := testUser(t, nil, "upsert-items-tx-updated", "", "")
+		updated.PublicId = existing.PublicId
+		var results []dbw.UpsertResult
+		_, err := testRw.DoTx(testCtx, func(error) bool { return false }, 0, dbw.ExpBackoff{}, func(_ dbw.Reader, w dbw.Writer) error {
+			var err error
+			results, err = w.(*dbw.RW).UpsertItems(testCtx, []interface{}{updated}, onConflict)
+			return err
+		})
+		require.NoError(err)
+		require.Len(results, 1)
+		assert.False(results[0].Inserted)
+	})
+	t.Run("invalid-parameters", func(t *testing.T) {
+		user := testUser(t, nil, "upsert-items-invalid", "", "")
+		car := testCar(t, nil)
+		tests := []struct {
+			name            string
+			items           []interface{}
+			conflict        dbw.OnConflict
+			wantErrContains string
+		}{
+			{"missing-items", nil, onConflict, "missing items"},
+			{"nil-item", []interface{}{user, (*dbtest.TestUser)(nil)}, onConflict, "item 1 is nil"},
+			{"disparate-types", []interface{}{user, car}, onConflict, "item 1 is not a *dbtest.TestUser"},
+			{"not-a-pointer", []interface{}{*user}, onConflict, "item 0 is not a pointer"},
+			{"constraint-target", []interface{}{user}, dbw.OnConflict{Target: dbw.Constraint("db_test_user_pkey"), Action: dbw.DoNothing(true)}, "on conflict target must be columns"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				results, err := testRw.UpsertItems(testCtx, tt.items, tt.conflict)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Nil(results)
+			})
+		}
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+)
+
+func TestRW_UpsertItems_postgres(t *testing.T) {
+	t.Parallel()
+	type testUser struct {
+		PublicId string `gorm:"primaryKey"`
+		Name     string
+	}
+	assert, require := assert.New(t), require.New(t)
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(err)
+	db, err := openDialector(postgres.New(postgres.Config{Conn: sqlDB}), Postgres)
+	require.NoError(err)
+	rw := New(db)
+
+	// the insert returns whether each row was inserted, using its xmax
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "test_users" ("public_id","name") VALUES ($1,$2),($3,$4) ON CONFLICT ("public_id") DO UPDATE SET "name"="excluded"."name" RETURNING "public_id",(xmax = 0)`)).
+		WithArgs("u_1", "alice", "u_2", "bob").
+		WillReturnRows(sqlmock.NewRows([]string{"public_id", "?column?"}).AddRow("u_1", false).AddRow("u_2", true))
+	results, err := rw.UpsertItems(context.Background(),
+		[]interface{}{&testUser{PublicId: "u_1", Name: "alice"}, &testUser{PublicId: "u_2", Name: "bob"}},
+		OnConflict{Target: Columns{"public_id"}, Action: SetColumns([]string{"name"})},
+	)
+	require.NoError(err)
+	assert.Equal([]UpsertResult{
+		{PrimaryKey: map[string]interface{}{"public_id": "u_1"}, Inserted: false},
+		{PrimaryKey: map[string]interface{}{"public_id": "u_2"}, Inserted: true},
+	}, results)
+	assert.NoError(mock.ExpectationsWereMet())
+}
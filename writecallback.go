@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// WriteCallback defines a callback of a DB which is invoked before every
+// resource of a type is created or updated (see: WithWriteCallback).
+type WriteCallback struct {
+	// Type is the type of the resources, which may be a struct or a pointer
+	// to a struct.
+	Type reflect.Type
+
+	// Fn is invoked with the resource (a pointer to a struct) and the write
+	// fails when it returns an error.
+	Fn func(ctx context.Context, resource interface{}) error
+}
+
+// validate returns an ErrInvalidParameter when the callback's type isn't a
+// struct (or a pointer to a struct) or it's missing its func.
+func (c WriteCallback) validate() error {
+	const op = "dbw.(WriteCallback).validate"
+	switch {
+	case c.Type == nil:
+		return fmt.Errorf("%s: write callback: missing type: %w", op, ErrInvalidParameter)
+	case c.Fn == nil:
+		return fmt.Errorf("%s: write callback for %s: missing func: %w", op, c.Type, ErrInvalidParameter)
+	case structType(c.Type) == nil:
+		return fmt.Errorf("%s: write callback for %s: type must be a struct: %w", op, c.Type, ErrInvalidParameter)
+	}
+	return nil
+}
+
+// structType returns the struct type of the type, after dereferencing any
+// pointers, or nil when it isn't a struct.
+func structType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+// runWriteCallbacks invokes the DB's write callbacks for the resource's type
+// (see: WithWriteCallback), in the order they were registered.
+func (rw *RW) runWriteCallbacks(ctx context.Context, resource interface{}) error {
+	const op = "dbw.runWriteCallbacks"
+	if len(rw.underlying.writeCallbacks) == 0 || isNil(resource) {
+		return nil
+	}
+	typ := structType(reflect.TypeOf(resource))
+	for _, c := range rw.underlying.writeCallbacks {
+		if structType(c.Type) != typ {
+			continue
+		}
+		if err := c.Fn(ctx, resource); err != nil {
+			return fmt.Errorf("%s: write callback for %s: %w", op, typ, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dbw_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-dbw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testContact struct {
+	PublicId  string `gorm:"primaryKey"`
+	FirstName string
+	LastName  string
+	SortName  string
+}
+
+func (*testContact) TableName() string { return "db_test_contact" }
+
+type testNote struct {
+	PublicId string `gorm:"primaryKey"`
+	Body     string
+}
+
+func (*testNote) TableName() string { return "db_test_note" }
+
+func TestDb_WithWriteCallback(t *testing.T) {
+	t.Parallel()
+	testCtx := context.Background()
+	errInvalidName := errors.New("invalid name")
+	sortName := func(_ context.Context, i interface{}) error {
+		c := i.(*testContact)
+		if c.LastName == "" {
+			return errInvalidName
+		}
+		c.SortName = strings.ToLower(c.LastName + ", " + c.FirstName)
+		return nil
+	}
+	var calls []string
+	record := func(_ context.Context, i interface{}) error {
+		calls = append(calls, i.(*testContact).PublicId)
+		return nil
+	}
+	conn, err := dbw.Open(dbw.Sqlite, "file::memory:",
+		dbw.WithWriteCallback(reflect.TypeOf(&testContact{}), sortName),
+		dbw.WithWriteCallback(reflect.TypeOf(testContact{}), record),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(testCtx) })
+	rw := dbw.New(conn)
+	_, err = rw.Exec(testCtx, `create table db_test_contact (
+  public_id text primary key,
+  first_name text,
+  last_name text,
+  sort_name text
+)`, nil)
+	require.NoError(t, err)
+
+	lookup := func(t *testing.T, publicId string) *testContact {
+		t.Helper()
+		found := &testContact{PublicId: publicId}
+		require.NoError(t, rw.LookupBy(testCtx, found))
+		return found
+	}
+
+	t.Run("create", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		calls = nil
+		c := &testContact{PublicId: "c_1", FirstName: "Alice", LastName: "Smith"}
+		require.NoError(rw.Create(testCtx, c))
+		assert.Equal("smith, alice", c.SortName)
+		assert.Equal("smith, alice", lookup(t, "c_1").SortName)
+		assert.Equal([]string{"c_1"}, calls)
+	})
+	t.Run("create-items", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		calls = nil
+		items := []*testContact{
+			{PublicId: "c_2", FirstName: "Bob", LastName: "Jones"},
+			{PublicId: "c_3", FirstName: "Eve", LastName: "Brown"},
+		}
+		require.NoError(rw.CreateItems(testCtx, items))
+		assert.Equal("jones, bob", lookup(t, "c_2").SortName)
+		assert.Equal("brown, eve", lookup(t, "c_3").SortName)
+		assert.Equal([]string{"c_2", "c_3"}, calls)
+	})
+	t.Run("update", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		c := lookup(t, "c_1")
+		c.LastName = "Jones"
+		rowsUpdated, err := rw.Update(testCtx, c, []string{"LastName", "SortName"}, nil)
+		require.NoError(err)
+		assert.Equal(1, rowsUpdated)
+		assert.Equal("jones, alice", lookup(t, "c_1").SortName)
+	})
+	t.Run("callback-error", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		err := rw.Create(testCtx, &testContact{PublicId: "c_4", FirstName: "Mallory"})
+		require.Error(err)
+		assert.ErrorIs(err, errInvalidName)
+		assert.Contains(err.Error(), "write callback for dbw_test.testContact")
+
+		err = rw.CreateItems(testCtx, []*testContact{{PublicId: "c_5", LastName: "Doe"}, {PublicId: "c_6"}})
+		require.Error(err)
+		assert.ErrorIs(err, errInvalidName)
+		assert.Contains(err.Error(), "item 1")
+
+		c := lookup(t, "c_2")
+		c.LastName = ""
+		_, err = rw.Update(testCtx, c, []string{"LastName"}, nil)
+		require.Error(err)
+		assert.ErrorIs(err, errInvalidName)
+
+		for _, publicId := range []string{"c_4", "c_5", "c_6"} {
+			err := rw.LookupBy(testCtx, &testContact{PublicId: publicId})
+			assert.ErrorIs(err, dbw.ErrRecordNotFound)
+		}
+		assert.Equal("Jones", lookup(t, "c_2").LastName)
+	})
+	t.Run("other-types", func(t *testing.T) {
+		assert, require := assert.New(t), require.New(t)
+		calls = nil
+		_, err := rw.Exec(testCtx, "create table db_test_note (public_id text primary key, body text)", nil)
+		require.NoError(err)
+		require.NoError(rw.Create(testCtx, &testNote{PublicId: "n_1", Body: "no callbacks"}))
+		assert.Empty(calls)
+	})
+	t.Run("invalid-callbacks", func(t *testing.T) {
+		fn := func(context.Context, interface{}) error { return nil }
+		tests := []struct {
+			name            string
+			opt             dbw.Option
+			wantErrContains string
+		}{
+			{"missing-type", dbw.WithWriteCallback(nil, fn), "missing type"},
+			{"missing-func", dbw.WithWriteCallback(reflect.TypeOf(&testContact{}), nil), "missing func"},
+			{"not-a-struct", dbw.WithWriteCallback(reflect.TypeOf(""), fn), "type must be a struct"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert, require := assert.New(t), require.New(t)
+				conn, err := dbw.Open(dbw.Sqlite, "file::memory:", tt.opt)
+				require.Error(err)
+				assert.ErrorIs(err, dbw.ErrInvalidParameter)
+				assert.Contains(err.Error(), tt.wantErrContains)
+				assert.Nil(conn)
+			})
+		}
+	})
+}
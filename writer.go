@@ -93,7 +93,29 @@ type Writer interface {
 type RetryInfo struct {
 	Retries int
 	Backoff time.Duration
+
+	// Errors is the number of retries by the class of the error which caused
+	// them.  The class is the error's SQLSTATE code when the database provides
+	// one (ex: 40001 for a serialization failure), otherwise it's one of
+	// RetryErrorDatabaseLocked, RetryErrorRestartTransaction or
+	// RetryErrorOther.  It's nil when there are no retries.
+	Errors map[string]int
 }
 
+const (
+	// RetryErrorDatabaseLocked is the RetryInfo error class of a retry caused
+	// by a locked database (ex: sqlite's SQLITE_BUSY and SQLITE_LOCKED)
+	RetryErrorDatabaseLocked = "database_locked"
+
+	// RetryErrorRestartTransaction is the RetryInfo error class of a retry
+	// caused by a CockroachDB "restart transaction" error without a SQLSTATE
+	// code.
+	RetryErrorRestartTransaction = "restart_transaction"
+
+	// RetryErrorOther is the RetryInfo error class of a retry caused by an
+	// error which isn't otherwise classified.
+	RetryErrorOther = "other"
+)
+
 // TxHandler defines a handler for a func that writes a transaction for use with DoTx
 type TxHandler func(Reader, Writer) error